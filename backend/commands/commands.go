@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// IsCommand reports whether the process was started to run an admin command
+// instead of the web server
+func IsCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	_, ok := registry[args[0]]
+	return ok
+}
+
+type command struct {
+	usage string
+	run   func(args []string, out io.Writer) error
+}
+
+var registry = map[string]command{
+	"jwt-keys": {
+		usage: "jwt-keys list|rotate|retire|prune   manage the JWT signing key ring",
+		run:   runJWTKeys,
+	},
+	"users": {
+		usage: "users set-role|list-staff|suspend|unsuspend   manage user roles and suspensions",
+		run:   runUsers,
+	},
+}
+
+// Run executes the admin command named by args[0] and returns the exit code
+func Run(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return 2
+	}
+	cmd, ok := registry[args[0]]
+	if !ok {
+		printUsage(os.Stderr)
+		return 2
+	}
+	if err := cmd.run(args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+var errUsage = errors.New("invalid usage")
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: go run main.go <command> [arguments]")
+	fmt.Fprintln(w, "Commands:")
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", registry[name].usage)
+	}
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"forum/backend/utils"
+)
+
+// runJWTKeys manages the key ring read by the server at startup and reloaded
+// every minute while it runs
+func runJWTKeys(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: expected list, rotate, retire or prune", errUsage)
+	}
+
+	fs := flag.NewFlagSet("jwt-keys "+args[0], flag.ContinueOnError)
+	path := fs.String("file", utils.SigningKeysPath(), "path to the key ring file")
+
+	switch args[0] {
+	case "list":
+		if err := fs.Parse(args[1:]); err != nil {
+			return errUsage
+		}
+		if err := utils.LoadSigningKeys(*path); err != nil {
+			return err
+		}
+		kr, err := utils.ReadKeyRing(*path)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, key := range kr.Keys {
+			status := "accepted"
+			switch {
+			case key.ID == kr.Active:
+				status = "active"
+			case key.IsRetired(now):
+				status = "retired"
+			}
+			retire := "-"
+			if key.RetireAt != nil {
+				retire = key.RetireAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%-20s %-9s created=%s retire_at=%s\n",
+				key.ID, status, key.CreatedAt.Format(time.RFC3339), retire)
+		}
+		return nil
+
+	case "rotate":
+		retireAfter := fs.Duration("retire-after", 24*time.Hour,
+			"how long tokens signed by the previous keys stay valid")
+		if err := fs.Parse(args[1:]); err != nil {
+			return errUsage
+		}
+		if err := utils.LoadSigningKeys(*path); err != nil {
+			return err
+		}
+		key, err := utils.RotateSigningKey(*path, *retireAfter)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "New active key %s, previous keys retire at %s\n",
+			key.ID, time.Now().Add(*retireAfter).Format(time.RFC3339))
+		return nil
+
+	case "retire":
+		kid := fs.String("kid", "", "key to retire")
+		after := fs.Duration("after", 0, "delay before the key stops being accepted")
+		if err := fs.Parse(args[1:]); err != nil || *kid == "" {
+			return fmt.Errorf("%w: retire requires -kid", errUsage)
+		}
+		if err := utils.RetireSigningKey(*path, *kid, time.Now().Add(*after)); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Key %s retires at %s\n", *kid, time.Now().Add(*after).Format(time.RFC3339))
+		return nil
+
+	case "prune":
+		olderThan := fs.Duration("older-than", 30*24*time.Hour,
+			"remove keys retired for longer than this")
+		if err := fs.Parse(args[1:]); err != nil {
+			return errUsage
+		}
+		removed, err := utils.PruneSigningKeys(*path, *olderThan)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Removed %d retired keys\n", removed)
+		return nil
+	}
+
+	return fmt.Errorf("%w: unknown subcommand %s", errUsage, args[0])
+}
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/database"
+	"forum/backend/models"
+)
+
+// runUsers manages user roles and suspensions, so the first admin can be
+// appointed before anyone is able to use the admin API
+func runUsers(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: expected set-role, list-staff, suspend or unsuspend", errUsage)
+	}
+
+	fs := flag.NewFlagSet("users "+args[0], flag.ContinueOnError)
+
+	switch args[0] {
+	case "set-role":
+		user := fs.String("user", "", "user ID, nickname or email")
+		role := fs.String("role", "", "member, moderator or admin")
+		if err := fs.Parse(args[1:]); err != nil || *user == "" || *role == "" {
+			return fmt.Errorf("%w: set-role requires -user and -role", errUsage)
+		}
+
+		db, err := database.InitializeDatabase()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		userID, err := findUser(db, *user)
+		if err != nil {
+			return err
+		}
+		if err := controllers.SetUserRole(db, userID, *role); err != nil {
+			return err
+		}
+		controllers.RecordSecurityEvent(db, nil, models.SecurityEvent{
+			UserID:    userID,
+			EventType: controllers.EventRoleChanged,
+			Details:   "set to " + *role + " from the command line",
+		})
+		fmt.Fprintf(out, "User %d is now %s\n", userID, *role)
+		return nil
+
+	case "list-staff":
+		if err := fs.Parse(args[1:]); err != nil {
+			return errUsage
+		}
+
+		db, err := database.InitializeDatabase()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		users, err := controllers.GetStaffUsers(db)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			fmt.Fprintf(out, "%-6d %-10s %-20s %s\n", user.ID, user.Role, user.Nickname, user.Email)
+		}
+		return nil
+
+	case "suspend":
+		user := fs.String("user", "", "user ID, nickname or email")
+		reason := fs.String("reason", "", "why the user is suspended")
+		duration := fs.Duration("for", 0, "how long the suspension lasts; 0 bans permanently")
+		hide := fs.Bool("hide-content", false, "hide the user's posts and comments while suspended")
+		if err := fs.Parse(args[1:]); err != nil || *user == "" || *reason == "" {
+			return fmt.Errorf("%w: suspend requires -user and -reason", errUsage)
+		}
+		policy := controllers.ContentPolicyKeep
+		if *hide {
+			policy = controllers.ContentPolicyHide
+		}
+
+		db, err := database.InitializeDatabase()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		userID, err := findUser(db, *user)
+		if err != nil {
+			return err
+		}
+		suspension, err := controllers.SuspendUser(db, 0, userID, *reason, *duration, policy)
+		if err != nil {
+			return err
+		}
+		controllers.RecordSecurityEvent(db, nil, models.SecurityEvent{
+			UserID:    userID,
+			EventType: controllers.EventUserSuspended,
+			Details:   "from the command line: " + suspension.Reason,
+		})
+		if suspension.Permanent() {
+			fmt.Fprintf(out, "User %d is banned\n", userID)
+		} else {
+			fmt.Fprintf(out, "User %d is suspended until %s\n", userID, suspension.ExpiresAt.Format(time.RFC3339))
+		}
+		return nil
+
+	case "unsuspend":
+		user := fs.String("user", "", "user ID, nickname or email")
+		if err := fs.Parse(args[1:]); err != nil || *user == "" {
+			return fmt.Errorf("%w: unsuspend requires -user", errUsage)
+		}
+
+		db, err := database.InitializeDatabase()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		userID, err := findUser(db, *user)
+		if err != nil {
+			return err
+		}
+		if err := controllers.LiftSuspension(db, 0, userID); err != nil {
+			return err
+		}
+		controllers.RecordSecurityEvent(db, nil, models.SecurityEvent{
+			UserID:    userID,
+			EventType: controllers.EventSuspensionLifted,
+			Details:   "from the command line",
+		})
+		fmt.Fprintf(out, "Lifted the suspension of user %d\n", userID)
+		return nil
+	}
+	return fmt.Errorf("%w: unknown subcommand %q", errUsage, args[0])
+}
+
+// findUser resolves -user to an ID with a readable error
+func findUser(db *sql.DB, identifier string) (int, error) {
+	userID, err := controllers.FindUserID(db, identifier)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("user %q not found", identifier)
+	}
+	return userID, err
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"forum/backend/logger"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+const (
+	passwordResetTTL = 1 * time.Hour
+	emailVerifyTTL   = 48 * time.Hour
+	magicLinkTTL     = 15 * time.Minute
+	// magicLinkInterval is how long to wait before emailing another sign-in link
+	magicLinkInterval = 1 * time.Minute
+)
+
+var ErrEmailAlreadyVerified = errors.New("email address is already verified")
+
+// RequestPasswordReset emails a reset link if an account uses the address.
+// It reports success either way so the endpoint does not reveal which
+// addresses are registered.
+func (ac *AuthController) RequestPasswordReset(email string) error {
+	var userID int
+	err := ac.DB.QueryRow("SELECT id FROM users WHERE email = ?", email).Scan(&userID)
+	if err == sql.ErrNoRows {
+		logger.Info("Password reset requested for unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := CreateUserToken(ac.DB, userID, TokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	link := utils.AppBaseURL() + "/resetPasswordPage?token=" + url.QueryEscape(token)
+	return QueueMail(ac.DB, utils.MailMessage{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your account.\n\n"+
+			"Open this link within %d minutes to choose a new password:\n%s\n\n"+
+			"If this wasn't you, you can ignore this email.", int(passwordResetTTL.Minutes()), link),
+	})
+}
+
+// ResetPassword sets a new password using a reset token and signs the user
+// out everywhere. Following the emailed link also proves the address is theirs.
+func (ac *AuthController) ResetPassword(token, newPassword string) (int, error) {
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		logger.Error("Failed to hash password: %v", err)
+		return 0, errors.New("internal server error")
+	}
+
+	userID, err := ConsumeUserToken(ac.DB, token, TokenPurposePasswordReset)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = ac.DB.Exec("UPDATE users SET password = ?, email_verified = TRUE WHERE id = ?", hashedPassword, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update password: %w", err)
+	}
+	if err := DeleteUserSessions(ac.DB, userID); err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return userID, nil
+}
+
+// SendVerificationEmail emails the user a link confirming their address
+func (ac *AuthController) SendVerificationEmail(userID int) error {
+	var email string
+	var verified bool
+	err := ac.DB.QueryRow("SELECT email, email_verified FROM users WHERE id = ?", userID).Scan(&email, &verified)
+	if err != nil {
+		return err
+	}
+	if verified {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := CreateUserToken(ac.DB, userID, TokenPurposeEmailVerify, emailVerifyTTL)
+	if err != nil {
+		return err
+	}
+	link := utils.AppBaseURL() + "/verify-email?token=" + url.QueryEscape(token)
+	return QueueMail(ac.DB, utils.MailMessage{
+		To:      email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Welcome to the forum!\n\n"+
+			"Open this link to confirm your email address:\n%s\n\n"+
+			"The link expires in %d hours.", link, int(emailVerifyTTL.Hours())),
+	})
+}
+
+// VerifyEmail marks the address behind a verification token as confirmed
+func (ac *AuthController) VerifyEmail(token string) (int, error) {
+	userID, err := ConsumeUserToken(ac.DB, token, TokenPurposeEmailVerify)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := ac.DB.Exec("UPDATE users SET email_verified = TRUE WHERE id = ?", userID); err != nil {
+		return 0, fmt.Errorf("failed to verify email: %w", err)
+	}
+	return userID, nil
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address
+func IsEmailVerified(db *sql.DB, userID int) (bool, error) {
+	var verified bool
+	err := db.QueryRow("SELECT email_verified FROM users WHERE id = ?", userID).Scan(&verified)
+	return verified, err
+}
+
+// RequestMagicLink emails a single-use sign-in link if an account uses the
+// address. Like RequestPasswordReset it reports success either way, and it
+// sends at most one link a minute per account.
+func (ac *AuthController) RequestMagicLink(email string) error {
+	var userID int
+	err := ac.DB.QueryRow("SELECT id FROM users WHERE email = ?", email).Scan(&userID)
+	if err == sql.ErrNoRows {
+		logger.Info("Sign-in link requested for unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var recent int
+	err = ac.DB.QueryRow(`
+		SELECT COUNT(*) FROM user_tokens
+		WHERE user_id = ? AND purpose = ? AND created_at > ?`,
+		userID, TokenPurposeMagicLogin, time.Now().Add(-magicLinkInterval)).Scan(&recent)
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		logger.Info("Sign-in link for user %d requested again too soon", userID)
+		return nil
+	}
+
+	token, err := CreateUserToken(ac.DB, userID, TokenPurposeMagicLogin, magicLinkTTL)
+	if err != nil {
+		return err
+	}
+	link := utils.AppBaseURL() + "/magicLoginPage?token=" + url.QueryEscape(token)
+	return QueueMail(ac.DB, utils.MailMessage{
+		To:      email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Someone asked to sign in to your account with this email address.\n\n"+
+			"Open this link within %d minutes to sign in:\n%s\n\n"+
+			"The link works once. If this wasn't you, you can ignore this email.", int(magicLinkTTL.Minutes()), link),
+	})
+}
+
+// ConsumeMagicLink signs in with a sign-in link and returns its user.
+// Following the emailed link also proves the address is theirs.
+func (ac *AuthController) ConsumeMagicLink(token string) (*models.User, error) {
+	userID, err := ConsumeUserToken(ac.DB, token, TokenPurposeMagicLogin)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ac.DB.Exec("UPDATE users SET email_verified = TRUE WHERE id = ?", userID); err != nil {
+		return nil, fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	suspension, err := GetActiveSuspension(ac.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	if suspension != nil {
+		return nil, &AccountSuspendedError{Suspension: *suspension}
+	}
+	return ac.GetUserByID(userID)
+}
@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"forum/backend/logger"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+const (
+	// AccountDeletionGracePeriod is how long a deleted account can still be
+	// restored by signing in
+	AccountDeletionGracePeriod = 14 * 24 * time.Hour
+	// DeletedUserName is shown instead of the nickname on a deleted user's content
+	DeletedUserName = "deleted user"
+)
+
+var ErrAccountDeleted = errors.New("account has been deleted")
+
+// nicknameColumn selects the nickname of the users row aliased as alias, or
+// DeletedUserName for a deleted account
+func nicknameColumn(alias string) string {
+	return fmt.Sprintf("CASE WHEN %[1]s.deleted_at IS NULL THEN %[1]s.nickname ELSE '%[2]s' END", alias, DeletedUserName)
+}
+
+// ScheduleAccountDeletion signs a user out everywhere and marks the account
+// for deletion once the grace period is over. Signing in before then keeps it.
+func ScheduleAccountDeletion(db *sql.DB, userID int) (time.Time, error) {
+	deleteAfter := time.Now().Add(AccountDeletionGracePeriod)
+	var email string
+	err := utils.RetryOnLocked(db, func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var role string
+		var deletedAt sql.NullTime
+		if err := tx.QueryRow("SELECT COALESCE(email, ''), role, deleted_at FROM users WHERE id = ?", userID).Scan(&email, &role, &deletedAt); err != nil {
+			return err
+		}
+		if deletedAt.Valid {
+			return ErrAccountDeleted
+		}
+		// Like demoting, deleting must leave someone who can manage roles
+		if role == models.RoleAdmin {
+			var admins int
+			if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE role = ? AND delete_after IS NULL", models.RoleAdmin).Scan(&admins); err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return ErrLastAdmin
+			}
+		}
+		if _, err := tx.Exec("UPDATE users SET delete_after = ? WHERE id = ?", deleteAfter, userID); err != nil {
+			return err
+		}
+
+		if err := signOutUser(tx, userID); err != nil {
+			return fmt.Errorf("failed to sign out deleted user: %w", err)
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return deleteAfter, err
+	}
+
+	if email != "" {
+		if err := QueueMail(db, utils.MailMessage{
+			To:      email,
+			Subject: "Your account will be deleted",
+			Body: fmt.Sprintf("Your account is scheduled for deletion on %s.\n\n"+
+				"Until then you can keep it by signing in again:\n%s\n\n"+
+				"After that your profile and uploads are removed and your posts, comments and messages are shown as from a %s.",
+				deleteAfter.Format("2 January 2006"), utils.AppBaseURL()+"/loginPage", DeletedUserName),
+		}); err != nil {
+			logger.Error("Failed to queue deletion notice for user %d: %v", userID, err)
+		}
+	}
+	return deleteAfter, nil
+}
+
+// CancelAccountDeletion keeps an account that was scheduled for deletion. It
+// reports whether there was anything to cancel.
+func CancelAccountDeletion(db *sql.DB, userID int) (bool, error) {
+	result, err := db.Exec("UPDATE users SET delete_after = NULL WHERE id = ? AND delete_after IS NOT NULL AND deleted_at IS NULL", userID)
+	if err != nil {
+		return false, err
+	}
+	cancelled, err := result.RowsAffected()
+	return cancelled > 0, err
+}
+
+// PurgeDeletedAccounts anonymises the accounts whose grace period is over
+// and returns how many were purged
+func PurgeDeletedAccounts(db *sql.DB) (int, error) {
+	rows, err := db.Query("SELECT id FROM users WHERE delete_after <= ? AND deleted_at IS NULL", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	var due []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, id)
+	}
+	rows.Close()
+
+	purged := 0
+	for _, userID := range due {
+		if err := AnonymizeUser(db, userID); err != nil {
+			return purged, fmt.Errorf("failed to purge user %d: %w", userID, err)
+		}
+		RecordSecurityEvent(db, nil, models.SecurityEvent{UserID: userID, EventType: EventAccountDeleted})
+		purged++
+	}
+	return purged, nil
+}
+
+// AnonymizeUser removes a user's personal data and uploaded files. The user
+// row is kept, emptied, so that their posts, comments and messages stay in
+// other people's threads and conversations, shown as from DeletedUserName.
+func AnonymizeUser(db *sql.DB, userID int) error {
+	var files []string
+	addFile := func(url sql.NullString) {
+		if path, ok := uploadedFilePath(url.String); url.Valid && ok {
+			files = append(files, path)
+		}
+	}
+
+	var avatar, coverImage sql.NullString
+	if err := db.QueryRow("SELECT avatar, cover_image FROM users WHERE id = ?", userID).Scan(&avatar, &coverImage); err != nil {
+		return err
+	}
+	addFile(avatar)
+	addFile(coverImage)
+
+	for _, query := range []string{
+		"SELECT i.image_url FROM post_images i JOIN posts p ON p.id = i.post_id WHERE p.user_id = ?",
+		"SELECT video_url FROM posts WHERE user_id = ? AND video_url IS NOT NULL",
+	} {
+		rows, err := db.Query(query, userID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var url sql.NullString
+			if err := rows.Scan(&url); err != nil {
+				rows.Close()
+				return err
+			}
+			addFile(url)
+		}
+		rows.Close()
+	}
+
+	var exports []string
+	rows, err := db.Query("SELECT file_path FROM data_exports WHERE user_id = ? AND file_path IS NOT NULL", userID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return err
+		}
+		exports = append(exports, path)
+	}
+	rows.Close()
+
+	err = utils.RetryOnLocked(db, func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// Content other people replied to or talked about stays, without the files
+		if _, err := tx.Exec("UPDATE posts SET author = ? WHERE user_id = ?", DeletedUserName, userID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE comments SET author = ? WHERE user_id = ?", DeletedUserName, userID); err != nil {
+			return err
+		}
+		if err := signOutUser(tx, userID); err != nil {
+			return err
+		}
+		// Mail still queued to the address, and the audit log entries that
+		// name the account, go before the address and nickname are cleared;
+		// other users' entries only lose the name
+		_, err = tx.Exec("DELETE FROM mail_outbox WHERE recipient = (SELECT email FROM users WHERE id = ?)", userID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			UPDATE security_events SET identifier = NULL
+			WHERE user_id != ? AND identifier IN (SELECT email FROM users WHERE id = ? UNION SELECT nickname FROM users WHERE id = ?)`,
+			userID, userID, userID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM security_events WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		// Drafts and scheduled posts were never shown to anyone, so they go
+		// entirely; their files are removed with the rest below
+		unpublished := "SELECT id FROM posts WHERE user_id = ? AND status != ?"
+		for _, query := range []string{
+			"DELETE FROM post_images WHERE post_id IN (" + unpublished + ")",
+			"DELETE FROM post_tags WHERE post_id IN (" + unpublished + ")",
+			"DELETE FROM post_revisions WHERE post_id IN (" + unpublished + ")",
+			"DELETE FROM posts WHERE id IN (" + unpublished + ")",
+		} {
+			if _, err := tx.Exec(query, userID, models.PostPublished); err != nil {
+				return err
+			}
+		}
+		for _, query := range []string{
+			"UPDATE posts SET video_url = NULL WHERE user_id = ? AND video_url LIKE '/uploads/%'",
+			"DELETE FROM post_images WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)",
+			"DELETE FROM user_about WHERE user_id = ?",
+			"DELETE FROM user_experience WHERE user_id = ?",
+			"DELETE FROM followers WHERE follower_id = ? OR following_id = ?",
+			"DELETE FROM notifications WHERE recipient_id = ? OR actor_id = ?",
+			"DELETE FROM user_status WHERE user_id = ?",
+			"DELETE FROM user_tokens WHERE user_id = ?",
+			"DELETE FROM user_recovery_codes WHERE user_id = ?",
+			"DELETE FROM user_identities WHERE user_id = ?",
+			"DELETE FROM data_exports WHERE user_id = ?",
+		} {
+			args := make([]interface{}, strings.Count(query, "?"))
+			for i := range args {
+				args[i] = userID
+			}
+			if _, err := tx.Exec(query, args...); err != nil {
+				return err
+			}
+		}
+
+		// Nicknames are unique, so each deleted account keeps its own placeholder
+		_, err = tx.Exec(`
+			UPDATE users SET
+				nickname = ?, email = NULL, password = '', first_name = '', last_name = '', age = 0,
+				gender = '', profession = NULL, avatar = NULL, cover_image = NULL,
+				totp_secret = NULL, totp_enabled = FALSE, email_verified = FALSE, role = ?,
+				delete_after = NULL, deleted_at = ?
+			WHERE id = ?`,
+			fmt.Sprintf("deleted_user_%d", userID), models.RoleMember, time.Now(), userID)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range append(files, exports...) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.Warning("Failed to remove file %s of deleted user %d: %v", path, userID, err)
+		}
+	}
+	logger.Info("Deleted account of user %d and %d files", userID, len(files))
+	return nil
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// APITokenPrefix marks personal access tokens so they can be told apart from
+// JWTs in the Authorization header
+const APITokenPrefix = "fpat_"
+
+const (
+	DefaultAPITokenLifetime = 90 * 24 * time.Hour
+	MaxAPITokenLifetime     = 365 * 24 * time.Hour
+	maxAPITokensPerUser     = 20
+)
+
+// APITokenScopes lists the scopes a personal access token can be given.
+// Read scopes cover GET requests, write scopes everything else.
+var APITokenScopes = []string{
+	"posts:read", "posts:write",
+	"comments:read", "comments:write",
+	"messages:read", "messages:write",
+	"notifications:read", "notifications:write",
+}
+
+var (
+	ErrInvalidAPIToken    = errors.New("invalid API token")
+	ErrInvalidTokenScope  = errors.New("unknown token scope")
+	ErrInvalidTokenName   = errors.New("token name must be 1 to 64 characters")
+	ErrTooManyAPITokens   = errors.New("too many API tokens")
+	ErrInvalidTokenExpiry = errors.New("token lifetime must be between 1 and 365 days")
+)
+
+// normalizeScopes validates the requested scopes and returns them sorted and
+// without duplicates
+func normalizeScopes(scopes []string) ([]string, error) {
+	known := make(map[string]bool, len(APITokenScopes))
+	for _, scope := range APITokenScopes {
+		known[scope] = true
+	}
+
+	seen := make(map[string]bool)
+	var result []string
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !known[scope] {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTokenScope, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidTokenScope)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// CreateAPIToken mints a personal access token for a user. The returned
+// token is the only copy; only its hash is stored.
+func CreateAPIToken(db *sql.DB, userID int, name string, scopes []string, lifetime time.Duration) (string, models.APIToken, error) {
+	var info models.APIToken
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 64 {
+		return "", info, ErrInvalidTokenName
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", info, err
+	}
+	if lifetime == 0 {
+		lifetime = DefaultAPITokenLifetime
+	}
+	if lifetime < 24*time.Hour || lifetime > MaxAPITokenLifetime {
+		return "", info, ErrInvalidTokenExpiry
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM api_tokens WHERE user_id = ? AND expires_at > ?", userID, time.Now()).Scan(&count); err != nil {
+		return "", info, err
+	}
+	if count >= maxAPITokensPerUser {
+		return "", info, ErrTooManyAPITokens
+	}
+
+	secret, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", info, err
+	}
+	token := APITokenPrefix + secret
+
+	now := time.Now()
+	info = models.APIToken{
+		Name:      name,
+		Prefix:    token[:len(APITokenPrefix)+6],
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: now.Add(lifetime),
+	}
+	result, err := db.Exec(`
+		INSERT INTO api_tokens (user_id, name, token_hash, token_prefix, scopes, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, info.Name, utils.HashToken(token), info.Prefix, strings.Join(scopes, " "), info.CreatedAt, info.ExpiresAt)
+	if err != nil {
+		return "", info, fmt.Errorf("failed to store API token: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", info, err
+	}
+	info.ID = int(id)
+	return token, info, nil
+}
+
+// GetAPITokens lists a user's unexpired personal access tokens, newest first
+func GetAPITokens(db *sql.DB, userID int) ([]models.APIToken, error) {
+	rows, err := db.Query(`
+		SELECT id, name, token_prefix, scopes, created_at, expires_at, last_used_at
+		FROM api_tokens
+		WHERE user_id = ? AND expires_at > ?
+		ORDER BY created_at DESC`, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		var token models.APIToken
+		var scopes string
+		var lastUsedAt sql.NullTime
+		if err := rows.Scan(&token.ID, &token.Name, &token.Prefix, &scopes, &token.CreatedAt, &token.ExpiresAt, &lastUsedAt); err != nil {
+			return nil, err
+		}
+		token.Scopes = strings.Fields(scopes)
+		if lastUsedAt.Valid {
+			token.LastUsedAt = &lastUsedAt.Time
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeAPIToken deletes one of a user's personal access tokens
+func RevokeAPIToken(db *sql.DB, userID, tokenID int) error {
+	result, err := db.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", tokenID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AuthenticateAPIToken returns the owner and scopes of a valid personal
+// access token and records that it was used
+func AuthenticateAPIToken(db *sql.DB, token string) (int, []string, error) {
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		return 0, nil, ErrInvalidAPIToken
+	}
+
+	var id, userID int
+	var scopes string
+	var expiresAt time.Time
+	err := db.QueryRow("SELECT id, user_id, scopes, expires_at FROM api_tokens WHERE token_hash = ?", utils.HashToken(token)).
+		Scan(&id, &userID, &scopes, &expiresAt)
+	if err == sql.ErrNoRows {
+		return 0, nil, ErrInvalidAPIToken
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if time.Now().After(expiresAt) {
+		return 0, nil, ErrInvalidAPIToken
+	}
+
+	// Like sessions, last use is recorded at most once a minute
+	now := time.Now()
+	if _, err := db.Exec(`
+		UPDATE api_tokens SET last_used_at = ?
+		WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)`,
+		now, id, now.Add(-1*time.Minute)); err != nil {
+		return 0, nil, err
+	}
+	return userID, strings.Fields(scopes), nil
+}
+
+// HasScope reports whether scope is among a token's scopes
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
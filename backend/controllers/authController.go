@@ -10,8 +10,7 @@ import (
 
 	"forum/backend/logger"
 	"forum/backend/models"
-
-	"golang.org/x/crypto/bcrypt"
+	"forum/backend/utils"
 )
 
 type AuthController struct {
@@ -31,7 +30,7 @@ func (ac *AuthController) RegisterUser(user models.User) (int64, error) {
 		logger.Warning("Registration failed - invalid email format: %s", user.Email)
 		return 0, errors.New("invalid email format")
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := utils.HashPassword(user.Password)
 	if err != nil {
 		logger.Error("Failed to hash password: %v", err)
 		return 0, errors.New("internal server error")
@@ -61,20 +60,70 @@ func (ac *AuthController) AuthenticateUser(credentials models.LoginRequest) (*mo
 	var hashedPassword string
 	user := &models.User{}
 	err := ac.DB.QueryRow(`
-	SELECT id, nickname, email, first_name, last_name, age, gender, password
+	SELECT id, nickname, email, first_name, last_name, age, gender, password, email_verified, role
 	FROM users 
 	WHERE email = ? OR nickname = ?
-`, credentials.Identifier, credentials.Identifier).Scan(&user.ID, &user.Nickname, &user.Email, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &hashedPassword)
+`, credentials.Identifier, credentials.Identifier).Scan(&user.ID, &user.Nickname, &user.Email, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &hashedPassword, &user.EmailVerified, &user.Role)
 	if err != nil {
 		logger.Warning("Authentication failed - invalid credentials: %s error: %v", credentials.Identifier, err)
 		return nil, errors.New("invalid credentials")
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(credentials.Password)); err != nil {
+	needsRehash, err := utils.CheckPassword(hashedPassword, credentials.Password)
+	if err != nil {
+		if err != utils.ErrPasswordMismatch {
+			logger.Error("Failed to check password for user %d: %v", user.ID, err)
+		}
 		logger.Warning("Authentication failed - invalid password for user: %s", credentials.Identifier)
 		return nil, errors.New("invalid password")
 	}
 
+	// Upgrade hashes made with an older algorithm or weaker settings while
+	// the plain password is at hand
+	if needsRehash {
+		if err := ac.rehashPassword(user.ID, hashedPassword, credentials.Password); err != nil {
+			logger.Error("Failed to upgrade password hash for user %d: %v", user.ID, err)
+		}
+	}
+
+	// Only tell who got the password right that the account is suspended
+	suspension, err := GetActiveSuspension(ac.DB, user.ID)
+	if err != nil {
+		logger.Error("Failed to check suspension of user %d: %v", user.ID, err)
+		return nil, errors.New("internal server error")
+	}
+	if suspension != nil {
+		logger.Warning("Authentication refused - user %d is suspended", user.ID)
+		return nil, &AccountSuspendedError{Suspension: *suspension}
+	}
+
+	return user, nil
+}
+
+// rehashPassword replaces a user's password hash with one from the current
+// hasher, unless the password was changed in the meantime
+func (ac *AuthController) rehashPassword(userID int, oldHash, password string) error {
+	newHash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return utils.RetryOnLocked(ac.DB, func() error {
+		_, err := ac.DB.Exec("UPDATE users SET password = ? WHERE id = ? AND password = ?", newHash, userID, oldHash)
+		return err
+	})
+}
+
+// GetUserByID loads the account fields returned to the client at login
+func (ac *AuthController) GetUserByID(userID int) (*models.User, error) {
+	user := &models.User{}
+	err := ac.DB.QueryRow(`
+	SELECT id, nickname, email, first_name, last_name, age, gender, email_verified, role
+	FROM users
+	WHERE id = ?
+`, userID).Scan(&user.ID, &user.Nickname, &user.Email, &user.FirstName, &user.LastName, &user.Age, &user.Gender, &user.EmailVerified, &user.Role)
+	if err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
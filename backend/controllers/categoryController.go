@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+const (
+	maxCategoryName        = 50
+	maxCategoryDescription = 500
+)
+
+var (
+	ErrCategoryName     = errors.New("category name must be 1 to 50 characters")
+	ErrCategorySlug     = errors.New("category slug may only contain lowercase letters, digits and dashes")
+	ErrCategoryDesc     = errors.New("category description is too long")
+	ErrCategoryExists   = errors.New("a category with that name or slug already exists")
+	ErrCategoryInUse    = errors.New("category has posts, archive it instead")
+	ErrUnknownCategory  = errors.New("unknown category")
+	ErrCategoryArchived = errors.New("category is archived")
+)
+
+// categoryMatch is true when post alias is filed under the category slug
+// given as its placeholder; posts.category holds a comma separated list
+func categoryMatch(alias string) string {
+	return "(',' || " + alias + ".category || ',') LIKE '%,' || ? || ',%'"
+}
+
+// categoryColumns selects a category c and the number of published posts in it
+var categoryColumns = `
+	c.id, c.slug, c.name, c.description, c.position, c.archived, c.created_at,
+	(SELECT COUNT(*) FROM posts p JOIN users u ON u.id = p.user_id
+	 WHERE p.status = 'published' AND u.content_hidden = FALSE
+	   AND (',' || p.category || ',') LIKE '%,' || c.slug || ',%')`
+
+func scanCategory(row interface{ Scan(...interface{}) error }) (models.Category, error) {
+	var category models.Category
+	err := row.Scan(&category.ID, &category.Slug, &category.Name, &category.Description,
+		&category.Position, &category.Archived, &category.CreatedAt, &category.PostCount)
+	return category, err
+}
+
+// GetCategories lists the categories in display order, the archived ones only
+// when asked for
+func GetCategories(db *sql.DB, includeArchived bool) ([]models.Category, error) {
+	query := "SELECT " + categoryColumns + " FROM categories c"
+	if !includeArchived {
+		query += " WHERE c.archived = FALSE"
+	}
+	query += " ORDER BY c.position, c.name"
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// GetCategory returns the category with slug, or sql.ErrNoRows
+func GetCategory(db *sql.DB, slug string) (models.Category, error) {
+	return scanCategory(db.QueryRow("SELECT "+categoryColumns+" FROM categories c WHERE c.slug = ?", slug))
+}
+
+// CreateCategory adds a category. Without a slug, one is made from the name.
+func CreateCategory(db *sql.DB, category models.Category) (models.Category, error) {
+	category.Name = strings.TrimSpace(category.Name)
+	category.Description = strings.TrimSpace(category.Description)
+	if category.Slug == "" {
+		category.Slug = Slugify(category.Name)
+	}
+	if err := validateCategory(category); err != nil {
+		return category, err
+	}
+
+	err := utils.RetryOnLocked(db, func() error {
+		_, err := db.Exec(`
+			INSERT INTO categories (slug, name, description, position, archived)
+			VALUES (?, ?, ?, ?, ?)`,
+			category.Slug, category.Name, category.Description, category.Position, category.Archived)
+		return err
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return category, ErrCategoryExists
+		}
+		return category, fmt.Errorf("failed to create category: %w", err)
+	}
+	return GetCategory(db, category.Slug)
+}
+
+// UpdateCategory changes the name, description, position or archived flag of
+// the category with slug. The slug itself never changes.
+func UpdateCategory(db *sql.DB, slug string, update models.CategoryUpdate) (models.Category, error) {
+	category, err := GetCategory(db, slug)
+	if err != nil {
+		return category, err
+	}
+	if update.Name != nil {
+		category.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Description != nil {
+		category.Description = strings.TrimSpace(*update.Description)
+	}
+	if update.Position != nil {
+		category.Position = *update.Position
+	}
+	if update.Archived != nil {
+		category.Archived = *update.Archived
+	}
+	if err := validateCategory(category); err != nil {
+		return category, err
+	}
+
+	err = utils.RetryOnLocked(db, func() error {
+		_, err := db.Exec(`
+			UPDATE categories SET name = ?, description = ?, position = ?, archived = ?
+			WHERE slug = ?`,
+			category.Name, category.Description, category.Position, category.Archived, slug)
+		return err
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return category, ErrCategoryExists
+		}
+		return category, fmt.Errorf("failed to update category: %w", err)
+	}
+	return category, nil
+}
+
+// DeleteCategory removes a category no post uses, drafts included.
+// Categories with posts can only be archived.
+func DeleteCategory(db *sql.DB, slug string) error {
+	return utils.RetryOnLocked(db, func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var inUse bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM posts p WHERE "+categoryMatch("p")+")", slug).
+			Scan(&inUse); err != nil {
+			return fmt.Errorf("failed to check category posts: %w", err)
+		}
+		if inUse {
+			return ErrCategoryInUse
+		}
+
+		result, err := tx.Exec("DELETE FROM categories WHERE slug = ?", slug)
+		if err != nil {
+			return fmt.Errorf("failed to delete category: %w", err)
+		}
+		if deleted, _ := result.RowsAffected(); deleted == 0 {
+			return sql.ErrNoRows
+		}
+		return tx.Commit()
+	})
+}
+
+// ResolveCategories turns the comma separated categories picked for a post,
+// given by slug or name, into the list of slugs stored with it. Archived
+// categories are only accepted when the post already had them in current.
+func ResolveCategories(db *sql.DB, picked, current string) (string, error) {
+	kept := map[string]bool{}
+	for _, slug := range strings.Split(current, ",") {
+		kept[strings.TrimSpace(slug)] = true
+	}
+
+	var slugs []string
+	seen := map[string]bool{}
+	for _, value := range strings.Split(picked, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		var slug string
+		var archived bool
+		err := db.QueryRow("SELECT slug, archived FROM categories WHERE slug = ? OR name = ?", strings.ToLower(value), value).
+			Scan(&slug, &archived)
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("%w: %s", ErrUnknownCategory, value)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to look up category: %w", err)
+		}
+		if archived && !kept[slug] {
+			return "", fmt.Errorf("%w: %s", ErrCategoryArchived, value)
+		}
+		if !seen[slug] {
+			seen[slug] = true
+			slugs = append(slugs, slug)
+		}
+	}
+	return strings.Join(slugs, ","), nil
+}
+
+// Slugify makes a slug from a category name: lowercase letters and digits,
+// with a dash for every run of anything else
+func Slugify(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return slug.String()
+}
+
+func validateCategory(category models.Category) error {
+	if n := utf8.RuneCountInString(category.Name); n == 0 || n > maxCategoryName {
+		return ErrCategoryName
+	}
+	if category.Slug == "" || Slugify(category.Slug) != category.Slug {
+		return ErrCategorySlug
+	}
+	if utf8.RuneCountInString(category.Description) > maxCategoryDescription {
+		return ErrCategoryDesc
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"forum/backend/models"
+	"forum/backend/utils"
 )
 
 type CommentController struct {
@@ -16,8 +17,22 @@ func NewCommentController(db *sql.DB) *CommentController {
 	return &CommentController{DB: db}
 }
 
-// CreateComment creates a new comment for a post
+// CreateComment creates a new comment for a post. Locked posts take no new
+// comments, and drafts and scheduled posts none at all.
 func (c *CommentController) CreateComment(comment models.Comment) (int, error) {
+	var locked bool
+	status := models.PostPublished
+	err := c.DB.QueryRow("SELECT locked, status FROM posts WHERE id = ?", comment.PostID).Scan(&locked, &status)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	if locked {
+		return 0, ErrPostLocked
+	}
+	if status != models.PostPublished {
+		return 0, ErrPostNotPublished
+	}
+
 	query := `
 		INSERT INTO comments (post_id, user_id, parent_id, author, content, timestamp)
 		VALUES (?, ?, ?, ?, ?, ?)
@@ -45,12 +60,39 @@ func (c *CommentController) DeleteComment(commentID, userID int) error {
 	}
 
 	if ownerID != userID {
-		return errors.New("unauthorized to delete this comment")
+		allowed, err := UserHasPermission(c.DB, userID, PermDeleteAnyComment)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return errors.New("unauthorized to delete this comment")
+		}
 	}
 
-	// Delete the comment and its replies (cascade delete will handle replies)
-	_, err = c.DB.Exec("DELETE FROM comments WHERE id = ?", commentID)
-	return err
+	// Delete the comment, its replies at any depth and the reactions to all
+	// of them together
+	thread := `
+		WITH RECURSIVE thread(id) AS (
+			SELECT ?
+			UNION ALL
+			SELECT c.id FROM comments c JOIN thread t ON c.parent_id = t.id
+		)`
+	return utils.RetryOnLocked(c.DB, func() error {
+		tx, err := c.DB.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(thread+" DELETE FROM reactions WHERE entity_type = ? AND entity_id IN (SELECT id FROM thread)",
+			commentID, models.ReactionOnComment); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(thread+" DELETE FROM comments WHERE id IN (SELECT id FROM thread)", commentID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
 }
 
 // LikeComment adds a like to a comment
@@ -80,10 +122,11 @@ func (c *CommentController) UndislikeComment(commentID int) error {
 // GetCommentsByPostID retrieves all comments for a specific post
 func (c *CommentController) GetCommentsByPostID(postID int) ([]models.Comment, error) {
 	query := `
-		SELECT id, post_id, user_id, parent_id, author, content, likes, dislikes, timestamp
-		FROM comments
-		WHERE post_id = ?
-		ORDER BY timestamp DESC
+		SELECT c.id, c.post_id, c.user_id, c.parent_id, c.author, c.content, c.likes, c.dislikes, c.timestamp
+		FROM comments c
+		JOIN users u ON c.user_id = u.id
+		WHERE c.post_id = ? AND u.content_hidden = FALSE
+		ORDER BY c.timestamp DESC
 	`
 	rows, err := c.DB.Query(query, postID)
 	if err != nil {
@@ -125,7 +168,7 @@ func (c *CommentController) GetCommentWithDetails(commentID int) (*models.Commen
 	query := `
 		SELECT c.id, c.post_id, c.user_id, c.parent_id, c.author, c.content, 
 			   c.likes, c.dislikes, c.timestamp,
-			   u.id as user_id, u.nickname, u.email, u.avatar, u.cover_image, u.profession, u.age, u.created_at,
+			   u.id as user_id, ` + nicknameColumn("u") + `, COALESCE(u.email, ''), u.avatar, u.cover_image, u.profession, u.age, u.created_at,
 			   EXISTS(SELECT 1 FROM comments r WHERE r.parent_id = c.id) as has_replies
 		FROM comments c
 		LEFT JOIN users u ON c.user_id = u.id
@@ -199,11 +242,11 @@ func (c *CommentController) getReplies(parentID int) ([]models.Comment, error) {
 	query := `
 		SELECT c.id, c.post_id, c.user_id, c.parent_id, c.author, c.content, 
 			   c.likes, c.dislikes, c.timestamp,
-			   u.id as user_id, u.nickname, u.email, u.avatar, u.cover_image, u.profession, u.age, u.created_at,
+			   u.id as user_id, ` + nicknameColumn("u") + `, COALESCE(u.email, ''), u.avatar, u.cover_image, u.profession, u.age, u.created_at,
 			   EXISTS(SELECT 1 FROM comments r WHERE r.parent_id = c.id) as has_replies
 		FROM comments c
 		LEFT JOIN users u ON c.user_id = u.id
-		WHERE c.parent_id = ?
+		WHERE c.parent_id = ? AND (u.content_hidden IS NULL OR u.content_hidden = FALSE)
 		ORDER BY c.timestamp ASC
 	`
 	rows, err := c.DB.Query(query, parentID)
@@ -2,17 +2,87 @@ package controllers
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/base64"
 	"errors"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"forum/backend/logger"
 )
 
+// CSRF protection modes, chosen with CSRF_MODE. In session mode the token is
+// stored per session and the client echoes it in X-CSRF-Token. In
+// double_submit mode nothing is stored: the token is signed with the session
+// cookie and the client echoes the csrf_token cookie in the header.
+const (
+	CSRFModeSession      = "session"
+	CSRFModeDoubleSubmit = "double_submit"
+)
+
+// CSRFCookieName is the readable cookie carrying the current CSRF token
+const CSRFCookieName = "csrf_token"
+
+// CSRFMode returns the configured CSRF protection mode
+func CSRFMode() string {
+	if os.Getenv("CSRF_MODE") == CSRFModeDoubleSubmit {
+		return CSRFModeDoubleSubmit
+	}
+	return CSRFModeSession
+}
+
+// IssueCSRFToken returns the CSRF token for a session in the configured mode
+func IssueCSRFToken(db *sql.DB, sessionToken string) (string, error) {
+	if CSRFMode() == CSRFModeDoubleSubmit {
+		return signedCSRFToken(sessionToken)
+	}
+	return GenerateCSRFToken(db, sessionToken)
+}
+
+// RotateCSRFToken discards the session's current CSRF token and issues a new one
+func RotateCSRFToken(db *sql.DB, sessionToken string) (string, error) {
+	if err := DeleteCSRFToken(db, sessionToken); err != nil {
+		return "", err
+	}
+	return IssueCSRFToken(db, sessionToken)
+}
+
+// signedCSRFToken returns a random value and its HMAC keyed by the session
+// token. Only someone holding the HttpOnly session cookie can produce it.
+func signedCSRFToken(sessionToken string) (string, error) {
+	if sessionToken == "" {
+		return "", errors.New("session token is empty")
+	}
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+	return nonce + "." + csrfSignature(sessionToken, nonce), nil
+}
+
+func csrfSignature(sessionToken, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(sessionToken))
+	mac.Write([]byte(nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignedCSRFToken checks a double-submit token against the session
+func verifySignedCSRFToken(sessionToken, token string) bool {
+	nonce, signature, ok := strings.Cut(token, ".")
+	if !ok || nonce == "" {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(csrfSignature(sessionToken, nonce)))
+}
+
 func GenerateCSRFToken(db *sql.DB, sessionToken string) (string, error) {
 	// First, try to get an existing valid token
 	existingToken, expiresAt, err := GetCSRFToken(db, sessionToken)
@@ -64,20 +134,30 @@ func VerifyCSRFToken(db *sql.DB, r *http.Request) bool {
 		return false
 	}
 
+	// Double submit: the header must repeat the cookie, and the cookie must
+	// have been signed for this session
+	if CSRFMode() == CSRFModeDoubleSubmit {
+		csrfCookie, err := r.Cookie(CSRFCookieName)
+		if err != nil || subtle.ConstantTimeCompare([]byte(csrfCookie.Value), []byte(token)) != 1 {
+			return false
+		}
+		return verifySignedCSRFToken(cookie.Value, token)
+	}
+
 	// Retrieve the stored token from the database
 	storedToken, expiresAt, err := GetCSRFToken(db, cookie.Value)
 	if err != nil {
 		return false
 	}
 
-	// Check if the token matches and is not expired
-	if storedToken != token || time.Now().After(expiresAt) {
-		// Delete the expired or invalid token
+	// Delete the expired token; a wrong token leaves it in place so a forged
+	// request cannot log the user's tab out of CSRF protection
+	if time.Now().After(expiresAt) {
 		_ = DeleteCSRFToken(db, cookie.Value)
 		return false
 	}
 
-	return true
+	return subtle.ConstantTimeCompare([]byte(storedToken), []byte(token)) == 1
 }
 
 // AddCSRFToken stores a new CSRF token in the database
@@ -0,0 +1,590 @@
+package controllers
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"forum/backend/logger"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+const (
+	dataExportTTL            = 7 * 24 * time.Hour
+	dataExportInterval       = 1 * time.Hour
+	dataExportWorkerInterval = 5 * time.Second
+	// Accounts with more rows than this are exported in the background
+	inlineDataExportRows = 2000
+)
+
+var (
+	ErrDataExportInProgress = errors.New("a data export is already being prepared")
+	ErrDataExportTooSoon    = errors.New("a data export was requested recently, try again later")
+	ErrDataExportNotReady   = errors.New("data export is not ready")
+)
+
+// dataExportDir is where archives are written, EXPORT_DIR or data/exports
+func dataExportDir() string {
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join("data", "exports")
+}
+
+// RequestDataExport starts an export of everything stored about a user.
+// Small accounts are exported right away; larger ones are left pending for
+// ProcessDataExports, which emails the user once the archive is ready.
+func RequestDataExport(db *sql.DB, userID int) (models.DataExport, error) {
+	var export models.DataExport
+	err := utils.RetryOnLocked(db, func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var active, recent int
+		if err := tx.QueryRow(`
+			SELECT
+				COUNT(CASE WHEN status IN (?, ?) THEN 1 END),
+				COUNT(CASE WHEN status = ? AND created_at > ? THEN 1 END)
+			FROM data_exports WHERE user_id = ?`,
+			models.DataExportPending, models.DataExportProcessing,
+			models.DataExportReady, time.Now().Add(-dataExportInterval), userID).Scan(&active, &recent); err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrDataExportInProgress
+		}
+		if recent > 0 {
+			return ErrDataExportTooSoon
+		}
+
+		export = models.DataExport{UserID: userID, Status: models.DataExportPending, CreatedAt: time.Now()}
+		result, err := tx.Exec("INSERT INTO data_exports (user_id, status, created_at) VALUES (?, ?, ?)",
+			userID, export.Status, export.CreatedAt)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		export.ID = int(id)
+		return tx.Commit()
+	})
+	if err != nil {
+		return export, err
+	}
+
+	rowCount, err := countDataExportRows(db, userID)
+	if err != nil {
+		return export, err
+	}
+	if rowCount > inlineDataExportRows {
+		return export, nil
+	}
+	if err := BuildDataExport(db, export.ID); err != nil {
+		return export, err
+	}
+	return GetDataExport(db, userID, export.ID)
+}
+
+// countDataExportRows estimates how big a user's export will be
+func countDataExportRows(db *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM posts WHERE user_id = ?) +
+			(SELECT COUNT(*) FROM comments WHERE user_id = ?) +
+			(SELECT COUNT(*) FROM reactions WHERE user_id = ?) +
+			(SELECT COUNT(*) FROM followers WHERE follower_id = ? OR following_id = ?) +
+			(SELECT COUNT(*) FROM messages WHERE sender_id = ? OR recipient_id = ?) +
+			(SELECT COUNT(*) FROM notifications WHERE recipient_id = ?)`,
+		userID, userID, userID, userID, userID, userID, userID, userID).Scan(&count)
+	return count, err
+}
+
+// GetDataExports lists a user's exports that have not expired, newest first
+func GetDataExports(db *sql.DB, userID int) ([]models.DataExport, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, status, COALESCE(file_path, ''), size_bytes, created_at, completed_at, expires_at
+		FROM data_exports
+		WHERE user_id = ? AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY created_at DESC, id DESC`, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []models.DataExport{}
+	for rows.Next() {
+		export, err := scanDataExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+	return exports, rows.Err()
+}
+
+// GetDataExport returns one of a user's exports
+func GetDataExport(db *sql.DB, userID, exportID int) (models.DataExport, error) {
+	return scanDataExport(db.QueryRow(`
+		SELECT id, user_id, status, COALESCE(file_path, ''), size_bytes, created_at, completed_at, expires_at
+		FROM data_exports
+		WHERE id = ? AND user_id = ?`, exportID, userID))
+}
+
+// GetDownloadableDataExport returns an export whose archive can be downloaded
+func GetDownloadableDataExport(db *sql.DB, userID, exportID int) (models.DataExport, error) {
+	export, err := GetDataExport(db, userID, exportID)
+	if err != nil {
+		return export, err
+	}
+	if export.Status != models.DataExportReady || export.ExpiresAt == nil || time.Now().After(*export.ExpiresAt) {
+		return export, ErrDataExportNotReady
+	}
+	return export, nil
+}
+
+func scanDataExport(row interface{ Scan(...interface{}) error }) (models.DataExport, error) {
+	var export models.DataExport
+	var completedAt, expiresAt sql.NullTime
+	err := row.Scan(&export.ID, &export.UserID, &export.Status, &export.FilePath, &export.SizeBytes,
+		&export.CreatedAt, &completedAt, &expiresAt)
+	if completedAt.Valid {
+		export.CompletedAt = &completedAt.Time
+	}
+	if expiresAt.Valid {
+		export.ExpiresAt = &expiresAt.Time
+	}
+	return export, err
+}
+
+// BuildDataExport writes the archive of a pending export. It does nothing if
+// the export was already claimed by someone else.
+func BuildDataExport(db *sql.DB, exportID int) error {
+	result, err := db.Exec("UPDATE data_exports SET status = ? WHERE id = ? AND status = ?",
+		models.DataExportProcessing, exportID, models.DataExportPending)
+	if err != nil {
+		return err
+	}
+	if claimed, err := result.RowsAffected(); err != nil || claimed == 0 {
+		return err
+	}
+
+	var userID int
+	if err := db.QueryRow("SELECT user_id FROM data_exports WHERE id = ?", exportID).Scan(&userID); err != nil {
+		return err
+	}
+
+	path, size, err := writeDataExport(db, userID)
+	if err != nil {
+		logger.Error("Data export %d of user %d failed: %v", exportID, userID, err)
+		if _, dbErr := db.Exec("UPDATE data_exports SET status = ?, last_error = ?, completed_at = ? WHERE id = ?",
+			models.DataExportFailed, err.Error(), time.Now(), exportID); dbErr != nil {
+			return dbErr
+		}
+		return err
+	}
+
+	now := time.Now()
+	_, err = db.Exec(`
+		UPDATE data_exports SET status = ?, file_path = ?, size_bytes = ?, completed_at = ?, expires_at = ?
+		WHERE id = ?`,
+		models.DataExportReady, path, size, now, now.Add(dataExportTTL), exportID)
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// ProcessDataExports builds pending exports and removes expired ones until
+// ctx is cancelled
+func ProcessDataExports(ctx context.Context, db *sql.DB) {
+	// Exports that were being built when the server stopped are started over
+	if _, err := db.Exec("UPDATE data_exports SET status = ? WHERE status = ?",
+		models.DataExportPending, models.DataExportProcessing); err != nil {
+		logger.Error("Failed to requeue interrupted data exports: %v", err)
+	}
+
+	ticker := time.NewTicker(dataExportWorkerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopping data export task...")
+			return
+		case <-ticker.C:
+			if _, err := RunPendingDataExports(db); err != nil {
+				logger.Error("Failed to run data exports: %v", err)
+			}
+			if err := pruneDataExports(db); err != nil {
+				logger.Error("Failed to remove expired data exports: %v", err)
+			}
+		}
+	}
+}
+
+// RunPendingDataExports builds every pending export, emails their owners and
+// returns how many were built
+func RunPendingDataExports(db *sql.DB) (int, error) {
+	rows, err := db.Query("SELECT id FROM data_exports WHERE status = ? ORDER BY id", models.DataExportPending)
+	if err != nil {
+		return 0, err
+	}
+	var pending []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, id)
+	}
+	rows.Close()
+
+	built := 0
+	for _, id := range pending {
+		// A failed export is recorded on its row and does not hold up the others
+		if err := BuildDataExport(db, id); err != nil {
+			continue
+		}
+		built++
+
+		var email string
+		if err := db.QueryRow("SELECT u.email FROM data_exports e JOIN users u ON u.id = e.user_id WHERE e.id = ?", id).Scan(&email); err != nil {
+			return built, err
+		}
+		if err := QueueMail(db, utils.MailMessage{
+			To:      email,
+			Subject: "Your data export is ready",
+			Body: fmt.Sprintf("The copy of your data you asked for is ready.\n\n"+
+				"Sign in and download it from your account within %d days:\n%s\n\n"+
+				"If you didn't ask for this, change your password.", int(dataExportTTL.Hours()/24), utils.AppBaseURL()),
+		}); err != nil {
+			return built, err
+		}
+	}
+	return built, nil
+}
+
+// pruneDataExports deletes expired archives and old failed exports
+func pruneDataExports(db *sql.DB) error {
+	now := time.Now()
+	rows, err := db.Query(`
+		SELECT id, COALESCE(file_path, '') FROM data_exports
+		WHERE expires_at < ? OR (status = ? AND created_at < ?)`,
+		now, models.DataExportFailed, now.Add(-dataExportTTL))
+	if err != nil {
+		return err
+	}
+	expired := map[int]string{}
+	for rows.Next() {
+		var id int
+		var path string
+		if err := rows.Scan(&id, &path); err != nil {
+			rows.Close()
+			return err
+		}
+		expired[id] = path
+	}
+	rows.Close()
+
+	for id, path := range expired {
+		if path != "" {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if _, err := db.Exec("DELETE FROM data_exports WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDataExport writes a ZIP of a user's data as JSON files, plus the
+// uploaded files those records refer to, and returns its path and size
+func writeDataExport(db *sql.DB, userID int) (string, int64, error) {
+	dir := dataExportDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", 0, err
+	}
+	name, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("export-%d-%s.zip", userID, name))
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", 0, err
+	}
+	zw := zip.NewWriter(file)
+	err = writeDataExportEntries(db, zw, userID)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	return path, info.Size(), nil
+}
+
+func writeDataExportEntries(db *sql.DB, zw *zip.Writer, userID int) error {
+	users, err := queryExportRows(db, `
+		SELECT id, nickname, email, first_name, last_name, age, gender, profession, avatar, cover_image,
+			created_at, email_verified, totp_enabled, role
+		FROM users WHERE id = ?`, userID)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return sql.ErrNoRows
+	}
+	about, err := queryExportRows(db, "SELECT * FROM user_about WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+	experience, err := queryExportRows(db, "SELECT * FROM user_experience WHERE user_id = ? ORDER BY start_date", userID)
+	if err != nil {
+		return err
+	}
+	profile := map[string]interface{}{"user": users[0], "about": nil, "experience": experience}
+	if len(about) > 0 {
+		profile["about"] = about[0]
+	}
+
+	posts, err := queryExportRows(db, "SELECT * FROM posts WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return err
+	}
+	images, err := queryExportRows(db, `
+		SELECT i.* FROM post_images i JOIN posts p ON p.id = i.post_id
+		WHERE p.user_id = ? ORDER BY i.id`, userID)
+	if err != nil {
+		return err
+	}
+	imagesByPost := map[interface{}][]map[string]interface{}{}
+	for _, image := range images {
+		imagesByPost[image["post_id"]] = append(imagesByPost[image["post_id"]], image)
+	}
+	for _, post := range posts {
+		post["images"] = imagesByPost[post["id"]]
+		if post["images"] == nil {
+			post["images"] = []map[string]interface{}{}
+		}
+	}
+
+	comments, err := queryExportRows(db, "SELECT * FROM comments WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return err
+	}
+	reactions, err := queryExportRows(db, `
+		SELECT entity_type, entity_id, reaction, created_at
+		FROM reactions WHERE user_id = ? ORDER BY created_at, entity_type, entity_id`, userID)
+	if err != nil {
+		return err
+	}
+	followers, err := queryExportRows(db, `
+		SELECT f.follower_id AS user_id, u.nickname, f.followed_at
+		FROM followers f JOIN users u ON u.id = f.follower_id
+		WHERE f.following_id = ? ORDER BY f.followed_at`, userID)
+	if err != nil {
+		return err
+	}
+	following, err := queryExportRows(db, `
+		SELECT f.following_id AS user_id, u.nickname, f.followed_at
+		FROM followers f JOIN users u ON u.id = f.following_id
+		WHERE f.follower_id = ? ORDER BY f.followed_at`, userID)
+	if err != nil {
+		return err
+	}
+	conversations, err := queryExportRows(db, `
+		SELECT c.id, c.created_at, c.updated_at,
+			CASE WHEN c.user1_id = ? THEN c.user2_id ELSE c.user1_id END AS other_user_id,
+			u.nickname AS other_nickname
+		FROM conversations c
+		LEFT JOIN users u ON u.id = CASE WHEN c.user1_id = ? THEN c.user2_id ELSE c.user1_id END
+		WHERE c.user1_id = ? OR c.user2_id = ?
+		ORDER BY c.id`, userID, userID, userID, userID)
+	if err != nil {
+		return err
+	}
+	messages, err := queryExportRows(db, `
+		SELECT * FROM messages WHERE sender_id = ? OR recipient_id = ? ORDER BY sent_at, id`, userID, userID)
+	if err != nil {
+		return err
+	}
+	notifications, err := queryExportRows(db, "SELECT * FROM notifications WHERE recipient_id = ? ORDER BY id", userID)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	seen := map[string]bool{}
+	addFile := func(value interface{}) {
+		path, ok := value.(string)
+		if !ok {
+			return
+		}
+		clean, ok := uploadedFilePath(path)
+		if !ok || seen[clean] {
+			return
+		}
+		seen[clean] = true
+		files = append(files, clean)
+	}
+	addFile(users[0]["avatar"])
+	addFile(users[0]["cover_image"])
+	for _, post := range posts {
+		addFile(post["video_url"])
+	}
+	for _, image := range images {
+		addFile(image["image_url"])
+	}
+
+	entries := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", profile},
+		{"posts.json", posts},
+		{"comments.json", comments},
+		{"reactions.json", reactions},
+		{"followers.json", map[string]interface{}{"followers": followers, "following": following}},
+		{"messages.json", map[string]interface{}{"conversations": conversations, "messages": messages}},
+		{"notifications.json", notifications},
+	}
+	for _, entry := range entries {
+		w, err := createDataExportEntry(zw, entry.name, time.Now())
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entry.data); err != nil {
+			return err
+		}
+	}
+
+	var included, missing []string
+	for _, path := range files {
+		ok, err := addDataExportFile(zw, path)
+		if err != nil {
+			return err
+		}
+		if ok {
+			included = append(included, filepath.ToSlash(path))
+		} else {
+			missing = append(missing, filepath.ToSlash(path))
+		}
+	}
+
+	w, err := createDataExportEntry(zw, "manifest.json", time.Now())
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{
+		"user_id":       userID,
+		"exported_at":   time.Now(),
+		"files":         included,
+		"missing_files": missing,
+	})
+}
+
+// uploadedFilePath turns a stored /uploads/... URL into a file path. Paths
+// that point anywhere outside the uploads folder, or at other sites, are refused.
+func uploadedFilePath(url string) (string, bool) {
+	clean := filepath.Clean(strings.TrimPrefix(url, "/"))
+	return clean, strings.HasPrefix(clean, "uploads"+string(filepath.Separator))
+}
+
+// addDataExportFile copies an uploaded file into the archive. It reports
+// false if the file no longer exists.
+func addDataExportFile(zw *zip.Writer, path string) (bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		logger.Warning("Data export skipped missing file %s", path)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	w, err := createDataExportEntry(zw, filepath.ToSlash(path), info.ModTime())
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func createDataExportEntry(zw *zip.Writer, name string, modified time.Time) (io.Writer, error) {
+	return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+}
+
+// queryExportRows returns each row as a map of column name to value
+func queryExportRows(db *sql.DB, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
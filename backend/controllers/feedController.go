@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"forum/backend/models"
+)
+
+var (
+	ErrInvalidFeed   = errors.New("feed must be new, following, hot or top")
+	ErrInvalidWindow = errors.New("window must be day, week, month or all")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// defaultTopWindow is how far back the top feed looks unless told otherwise
+const defaultTopWindow = "week"
+
+// topWindows are the periods the top feed can cover; all has no limit
+var topWindows = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"all":   0,
+}
+
+// reactionScore is the net reactions on a post p: each reaction adds one
+// and each dislike takes one away. Reactions by hidden users don't count.
+const reactionScore = `
+	(SELECT COALESCE(SUM(CASE WHEN r.reaction = 'dislike' THEN -1 ELSE 1 END), 0)
+	 FROM reactions r JOIN users ru ON ru.id = r.user_id
+	 WHERE r.entity_type = 'post' AND r.entity_id = p.id AND ru.content_hidden = FALSE)`
+
+// hotScore ranks a post p by its net reactions plus its comments, divided by
+// the square of its age in hours (plus two) at the time given twice as its
+// placeholders, so a post needs ever more activity to stay near the top as
+// it gets older. Posts disliked below zero score zero.
+const hotScore = `
+	(MAX(` + reactionScore + ` + (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id), -1) + 1.0) /
+	(((julianday(?) - julianday(p.timestamp)) * 24 + 2) * ((julianday(?) - julianday(p.timestamp)) * 24 + 2))`
+
+// feedQuery is a feed turned into SQL: the posts meeting condition, ordered
+// by score, then newest first
+type feedQuery struct {
+	mode      string
+	viewerID  int
+	asOf      time.Time
+	condition string
+	args      []interface{}
+	score     string
+	scoreArgs []interface{}
+}
+
+// feedCursor is the last post of a page of a feed, where the next page
+// starts. AsOf is when the first page was read; hot scores and the top
+// window are computed at that time for every page, so posts don't shift
+// between pages as the clock moves.
+type feedCursor struct {
+	Mode  string    `json:"m"`
+	AsOf  time.Time `json:"a"`
+	Score float64   `json:"s"`
+	Time  string    `json:"t"`
+	ID    int       `json:"i"`
+}
+
+// encodeCursor turns a cursor into the opaque string handed to clients
+func encodeCursor(cursor feedCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (feedCursor, error) {
+	var cursor feedCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || json.Unmarshal(data, &cursor) != nil || cursor.ID == 0 {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// scanFunc lets scanPost read from a function
+type scanFunc func(dest ...interface{}) error
+
+func (f scanFunc) Scan(dest ...interface{}) error { return f(dest...) }
+
+// scanWith reads the columns scanPost knows about into its destinations and
+// the ones after them into extra
+func scanWith(row interface{ Scan(...interface{}) error }, extra ...interface{}) scanFunc {
+	return func(dest ...interface{}) error {
+		return row.Scan(append(dest, extra...)...)
+	}
+}
+
+// GetFeed lists a page of the published posts of a feed:
+//   - new: every post, newest first
+//   - following: posts by the users feed.ViewerID follows, newest first
+//   - hot: by hotScore
+//   - top: by net votes, over feed.Window
+//
+// Any feed can be narrowed to a category and a tag. The first page is read
+// with an empty cursor, and each page returns the cursor of the next one,
+// which is empty after the last page.
+func (pc *PostController) GetFeed(feed models.Feed, cursor string, limit int) ([]models.Post, string, error) {
+	query := feedQuery{mode: feed.Mode, viewerID: feed.ViewerID, asOf: time.Now(), score: "0"}
+	if query.mode == "" {
+		query.mode = models.FeedNew
+	}
+
+	var after *feedCursor
+	if cursor != "" {
+		decoded, err := decodeCursor(cursor)
+		if err != nil || decoded.Mode != query.mode {
+			return nil, "", ErrInvalidCursor
+		}
+		after = &decoded
+		// Back in local time, like the post timestamps it is compared with
+		query.asOf = decoded.AsOf.Local()
+	}
+
+	if feed.Category != "" {
+		query.condition += " AND " + categoryMatch("p")
+		query.args = append(query.args, feed.Category)
+	}
+	if feed.Tag != "" {
+		query.condition += " AND " + tagMatch("p")
+		query.args = append(query.args, feed.Tag)
+	}
+
+	switch query.mode {
+	case models.FeedNew:
+	case models.FeedFollowing:
+		query.condition += " AND p.user_id IN (SELECT following_id FROM followers WHERE follower_id = ?)"
+		query.args = append(query.args, feed.ViewerID)
+	case models.FeedHot:
+		query.score = hotScore
+		query.scoreArgs = []interface{}{query.asOf, query.asOf}
+	case models.FeedTop:
+		if feed.Window == "" {
+			feed.Window = defaultTopWindow
+		}
+		window, ok := topWindows[feed.Window]
+		if !ok {
+			return nil, "", ErrInvalidWindow
+		}
+		if window > 0 {
+			query.condition += " AND p.timestamp >= ?"
+			query.args = append(query.args, query.asOf.Add(-window))
+		}
+		query.score = reactionScore
+	default:
+		return nil, "", ErrInvalidFeed
+	}
+	return pc.listPosts(query, after, limit)
+}
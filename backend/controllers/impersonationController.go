@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"forum/backend/models"
+	"forum/backend/utils"
+
+	"github.com/google/uuid"
+)
+
+// ImpersonationTTL is how long an admin can act as another user. No refresh
+// token is issued, so the session cannot outlive its first access token.
+const ImpersonationTTL = utils.AccessTokenTTL
+
+const maxImpersonationReasonLength = 500
+
+var (
+	ErrImpersonationReason = errors.New("a reason of at most 500 characters is required")
+	ErrCannotImpersonate   = errors.New("you can only act as users with a lower role")
+	ErrNotImpersonating    = errors.New("session is not an impersonation")
+	ErrSessionEnded        = errors.New("session has ended")
+)
+
+// impersonationBlocked lists what an admin may not do on someone else's
+// behalf. An empty method blocks every method; paths ending in / are prefixes.
+var impersonationBlocked = []struct {
+	method string
+	path   string
+}{
+	{"", "/api/users/password"},
+	{"", "/api/users/delete"},
+	{"", "/messages/send"},
+	{"", "/messages/typing-status"},
+	{"", "/api/2fa"},
+	{"", "/api/2fa/"},
+	{"", "/api/account/export"},
+	{"", "/api/account/export/download"},
+	{"", "/api/oidc/link"},
+	{"", "/api/admin/"},
+	{http.MethodPost, "/api/tokens"},
+	{http.MethodPost, "/messages/reactions"},
+	{http.MethodDelete, "/api/oidc/identities"},
+}
+
+// ImpersonationAllows reports whether a request may be made while an admin is
+// acting as the user
+func ImpersonationAllows(method, path string) bool {
+	for _, blocked := range impersonationBlocked {
+		if blocked.method != "" && blocked.method != method {
+			continue
+		}
+		if path == blocked.path || (strings.HasSuffix(blocked.path, "/") && strings.HasPrefix(path, blocked.path)) {
+			return false
+		}
+	}
+	return true
+}
+
+// StartImpersonation opens a session for targetID on behalf of adminID and
+// returns its session token. The admin's own session, adminSessionToken, is
+// left alone so that it can be resumed when the impersonation ends.
+func StartImpersonation(db *sql.DB, adminID int, adminSessionToken string, targetID int, reason, userAgent, ipAddress string) (string, models.Impersonation, error) {
+	var impersonation models.Impersonation
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len(reason) > maxImpersonationReasonLength {
+		return "", impersonation, ErrImpersonationReason
+	}
+	outranks, err := OutranksUser(db, adminID, targetID)
+	if err != nil {
+		return "", impersonation, err
+	}
+	if !outranks {
+		return "", impersonation, ErrCannotImpersonate
+	}
+
+	var deletedAt sql.NullTime
+	if err := db.QueryRow("SELECT nickname, deleted_at FROM users WHERE id = ?", targetID).
+		Scan(&impersonation.UserNickname, &deletedAt); err != nil {
+		return "", impersonation, err
+	}
+	if deletedAt.Valid {
+		return "", impersonation, ErrAccountDeleted
+	}
+	if err := db.QueryRow("SELECT nickname FROM users WHERE id = ?", adminID).Scan(&impersonation.AdminNickname); err != nil {
+		return "", impersonation, err
+	}
+
+	now := time.Now()
+	sessionToken := uuid.New().String()
+	impersonation.AdminID = adminID
+	impersonation.UserID = targetID
+	impersonation.SessionID = uuid.New().String()
+	impersonation.AdminSessionToken = adminSessionToken
+	impersonation.Reason = reason
+	impersonation.StartedAt = now
+	impersonation.ExpiresAt = now.Add(ImpersonationTTL)
+
+	err = utils.RetryOnLocked(db, func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+			INSERT INTO sessions (session_token, user_id, expires_at, id, user_agent, ip_address, created_at, last_used_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			sessionToken, targetID, impersonation.ExpiresAt, impersonation.SessionID,
+			userAgent, ipAddress, now, now); err != nil {
+			return err
+		}
+		result, err := tx.Exec(`
+			INSERT INTO impersonations (admin_id, user_id, session_id, admin_session_token, reason, started_at, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			adminID, targetID, impersonation.SessionID, adminSessionToken, reason, now, impersonation.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		impersonation.ID = int(id)
+		return tx.Commit()
+	})
+	if err != nil {
+		return "", impersonation, fmt.Errorf("failed to start impersonation: %w", err)
+	}
+	return sessionToken, impersonation, nil
+}
+
+const impersonationColumns = `
+	i.id, i.admin_id, a.nickname, i.user_id, u.nickname, i.session_id,
+	i.admin_session_token, i.reason, i.started_at, i.expires_at, i.ended_at`
+
+func scanImpersonation(row interface{ Scan(...interface{}) error }) (models.Impersonation, error) {
+	var impersonation models.Impersonation
+	var adminSessionToken sql.NullString
+	var endedAt sql.NullTime
+	err := row.Scan(&impersonation.ID, &impersonation.AdminID, &impersonation.AdminNickname,
+		&impersonation.UserID, &impersonation.UserNickname, &impersonation.SessionID,
+		&adminSessionToken, &impersonation.Reason, &impersonation.StartedAt,
+		&impersonation.ExpiresAt, &endedAt)
+	impersonation.AdminSessionToken = adminSessionToken.String
+	if endedAt.Valid {
+		impersonation.EndedAt = &endedAt.Time
+	}
+	return impersonation, err
+}
+
+// activeImpersonation returns the running impersonation behind the session
+// matching condition, or nil when the session is the user's own
+func activeImpersonation(db *sql.DB, condition string, arg string) (*models.Impersonation, error) {
+	row := db.QueryRow(`
+		SELECT `+impersonationColumns+`
+		FROM impersonations i
+		JOIN sessions s ON s.id = i.session_id
+		JOIN users a ON a.id = i.admin_id
+		JOIN users u ON u.id = i.user_id
+		WHERE `+condition+` AND i.ended_at IS NULL AND i.expires_at > ?`, arg, time.Now())
+	impersonation, err := scanImpersonation(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &impersonation, nil
+}
+
+// GetSessionImpersonation returns the impersonation behind a session cookie,
+// or nil when the session is the user's own
+func GetSessionImpersonation(db *sql.DB, sessionToken string) (*models.Impersonation, error) {
+	return activeImpersonation(db, "s.session_token = ?", sessionToken)
+}
+
+// GetImpersonationBySessionID is GetSessionImpersonation for a session's
+// public ID, as named by an access token. Ending an impersonation deletes its
+// session, so a session that is gone, expired or belonged to an impersonation
+// that is over is ErrSessionEnded rather than the user's own.
+func GetImpersonationBySessionID(db *sql.DB, sessionID string) (*models.Impersonation, error) {
+	impersonation, err := activeImpersonation(db, "s.id = ?", sessionID)
+	if impersonation != nil || err != nil {
+		return impersonation, err
+	}
+
+	var ended bool
+	err = db.QueryRow(`
+		SELECT NOT EXISTS(SELECT 1 FROM sessions WHERE id = ? AND expires_at > ?)
+			OR EXISTS(SELECT 1 FROM impersonations WHERE session_id = ?)`,
+		sessionID, time.Now(), sessionID).Scan(&ended)
+	if err != nil {
+		return nil, err
+	}
+	if ended {
+		return nil, ErrSessionEnded
+	}
+	return nil, nil
+}
+
+// EndImpersonation signs the admin out of the impersonation session and
+// returns it, so the admin's own session can be resumed
+func EndImpersonation(db *sql.DB, sessionToken string) (models.Impersonation, error) {
+	impersonation, err := GetSessionImpersonation(db, sessionToken)
+	if err != nil {
+		return models.Impersonation{}, err
+	}
+	if impersonation == nil {
+		return models.Impersonation{}, ErrNotImpersonating
+	}
+
+	now := time.Now()
+	err = utils.RetryOnLocked(db, func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec("UPDATE impersonations SET ended_at = ? WHERE id = ?", now, impersonation.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM sessions WHERE session_token = ?", sessionToken); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return *impersonation, err
+	}
+	impersonation.EndedAt = &now
+	return *impersonation, nil
+}
+
+// GetImpersonations lists the most recent impersonations, newest first
+func GetImpersonations(db *sql.DB, limit int) ([]models.Impersonation, error) {
+	if limit <= 0 || limit > maxSecurityEventLimit {
+		limit = defaultSecurityEventLimit
+	}
+	rows, err := db.Query(`
+		SELECT `+impersonationColumns+`
+		FROM impersonations i
+		JOIN users a ON a.id = i.admin_id
+		JOIN users u ON u.id = i.user_id
+		ORDER BY i.started_at DESC, i.id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	impersonations := []models.Impersonation{}
+	for rows.Next() {
+		impersonation, err := scanImpersonation(rows)
+		if err != nil {
+			return nil, err
+		}
+		impersonations = append(impersonations, impersonation)
+	}
+	return impersonations, rows.Err()
+}
+
+// RecordImpersonatedRequest adds a request made during an impersonation to
+// the impersonated user's audit log
+func RecordImpersonatedRequest(db *sql.DB, r *http.Request, impersonation *models.Impersonation, status int) {
+	RecordSecurityEvent(db, r, models.SecurityEvent{
+		UserID:     impersonation.UserID,
+		EventType:  EventImpersonatedRequest,
+		Identifier: impersonation.AdminNickname,
+		Details:    fmt.Sprintf("%s %s -> %d (impersonation %d)", r.Method, r.URL.Path, status, impersonation.ID),
+	})
+}
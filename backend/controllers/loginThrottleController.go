@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum/backend/logger"
+	"forum/backend/utils"
+)
+
+// throttlePolicy describes how failed logins for one key slow down further attempts
+type throttlePolicy struct {
+	scope string
+	// freeAttempts failures are allowed before any delay
+	freeAttempts int
+	// each later failure doubles the wait, starting at baseDelay, up to maxDelay
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	// lockoutAfter failures lock the key for lockoutFor
+	lockoutAfter int
+	lockoutFor   time.Duration
+	// failures are forgotten after resetAfter without a new one
+	resetAfter time.Duration
+}
+
+var (
+	// Guessing one account's password
+	accountThrottle = throttlePolicy{
+		scope:        "account",
+		freeAttempts: 3,
+		baseDelay:    2 * time.Second,
+		maxDelay:     5 * time.Minute,
+		lockoutAfter: 10,
+		lockoutFor:   15 * time.Minute,
+		resetAfter:   time.Hour,
+	}
+	// One address trying many accounts
+	ipThrottle = throttlePolicy{
+		scope:        "ip",
+		freeAttempts: 10,
+		baseDelay:    time.Second,
+		maxDelay:     5 * time.Minute,
+		lockoutAfter: 50,
+		lockoutFor:   time.Hour,
+		resetAfter:   time.Hour,
+	}
+)
+
+// LoginThrottleResult describes the state after a failed login
+type LoginThrottleResult struct {
+	RetryAfter time.Duration
+	// LockedUserID is set when this failure locked a real account
+	LockedUserID int
+}
+
+// accountThrottleKey keys attempts by account, so switching between email and
+// nickname does not reset the count. Unknown identifiers are tracked as typed.
+func accountThrottleKey(db *sql.DB, identifier string) (string, int) {
+	identifier = strings.ToLower(strings.TrimSpace(identifier))
+	var userID int
+	err := db.QueryRow("SELECT id FROM users WHERE lower(email) = ? OR lower(nickname) = ?", identifier, identifier).Scan(&userID)
+	if err != nil {
+		return "identifier:" + identifier, 0
+	}
+	return userThrottleKey(userID), userID
+}
+
+func userThrottleKey(userID int) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// CheckLoginAllowed returns how long the client must wait before trying to log
+// in as identifier from ip. Zero means the attempt may go ahead.
+func CheckLoginAllowed(db *sql.DB, identifier, ip string) (time.Duration, error) {
+	accountKey, _ := accountThrottleKey(db, identifier)
+	return checkThrottles(db, accountKey, ip)
+}
+
+// CheckTwoFactorAllowed is CheckLoginAllowed for the second login step, where
+// the account is known from the challenge. Wrong codes and wrong passwords
+// count against the same account.
+func CheckTwoFactorAllowed(db *sql.DB, userID int, ip string) (time.Duration, error) {
+	return checkThrottles(db, userThrottleKey(userID), ip)
+}
+
+func checkThrottles(db *sql.DB, accountKey, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, check := range []struct {
+		policy throttlePolicy
+		key    string
+	}{{accountThrottle, accountKey}, {ipThrottle, ip}} {
+		var lockedUntil sql.NullTime
+		err := db.QueryRow("SELECT locked_until FROM login_throttles WHERE scope = ? AND throttle_key = ?",
+			check.policy.scope, check.key).Scan(&lockedUntil)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if lockedUntil.Valid {
+			if d := time.Until(lockedUntil.Time); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait, nil
+}
+
+// RecordLoginFailure counts a failed login against the account and the
+// address, and returns the wait now imposed on the next attempt
+func RecordLoginFailure(db *sql.DB, identifier, ip string) (LoginThrottleResult, error) {
+	accountKey, userID := accountThrottleKey(db, identifier)
+	return recordFailures(db, accountKey, userID, ip)
+}
+
+// RecordTwoFactorFailure counts a wrong second factor code against the
+// account and the address. userID is 0 when the challenge itself was invalid,
+// which only counts against the address.
+func RecordTwoFactorFailure(db *sql.DB, userID int, ip string) (LoginThrottleResult, error) {
+	accountKey := ""
+	if userID > 0 {
+		accountKey = userThrottleKey(userID)
+	}
+	return recordFailures(db, accountKey, userID, ip)
+}
+
+func recordFailures(db *sql.DB, accountKey string, userID int, ip string) (LoginThrottleResult, error) {
+	var result LoginThrottleResult
+
+	var accountWait time.Duration
+	var locked bool
+	if accountKey != "" {
+		var err error
+		accountWait, locked, err = recordThrottleFailure(db, accountThrottle, accountKey)
+		if err != nil {
+			return result, err
+		}
+	}
+	ipWait, _, err := recordThrottleFailure(db, ipThrottle, ip)
+	if err != nil {
+		return result, err
+	}
+
+	result.RetryAfter = accountWait
+	if ipWait > result.RetryAfter {
+		result.RetryAfter = ipWait
+	}
+	if locked && userID > 0 {
+		result.LockedUserID = userID
+	}
+	return result, nil
+}
+
+// RecordLoginSuccess clears the failed attempts against the account once a
+// login completes, after any second factor. The address keeps its count, so
+// one valid account cannot be used to reset it.
+func RecordLoginSuccess(db *sql.DB, userID int) error {
+	_, err := db.Exec("DELETE FROM login_throttles WHERE scope = ? AND throttle_key = ?", accountThrottle.scope, userThrottleKey(userID))
+	return err
+}
+
+// recordThrottleFailure bumps the failure count for a key and sets its next
+// allowed attempt. It reports whether this failure started a lockout.
+func recordThrottleFailure(db *sql.DB, policy throttlePolicy, key string) (time.Duration, bool, error) {
+	now := time.Now()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var failures int
+	var lastFailure time.Time
+	err = tx.QueryRow("SELECT failures, last_failure_at FROM login_throttles WHERE scope = ? AND throttle_key = ?",
+		policy.scope, key).Scan(&failures, &lastFailure)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, false, err
+	}
+	if err == nil && now.Sub(lastFailure) > policy.resetAfter {
+		failures = 0
+	}
+	failures++
+
+	var wait time.Duration
+	locked := false
+	switch {
+	case failures == policy.lockoutAfter:
+		wait = policy.lockoutFor
+		locked = true
+	case failures > policy.lockoutAfter:
+		wait = policy.lockoutFor
+	case failures > policy.freeAttempts:
+		wait = policy.baseDelay << (failures - policy.freeAttempts - 1)
+		if wait > policy.maxDelay {
+			wait = policy.maxDelay
+		}
+	}
+
+	var lockedUntil interface{}
+	if wait > 0 {
+		lockedUntil = now.Add(wait)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO login_throttles (scope, throttle_key, failures, last_failure_at, locked_until)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(scope, throttle_key) DO UPDATE SET
+			failures = excluded.failures,
+			last_failure_at = excluded.last_failure_at,
+			locked_until = excluded.locked_until`,
+		policy.scope, key, failures, now, lockedUntil)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return wait, locked, tx.Commit()
+}
+
+// NotifyAccountLocked tells the owner that sign-in to their account was locked
+func NotifyAccountLocked(db *sql.DB, userID int, ip string) {
+	var email string
+	if err := db.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email); err != nil {
+		logger.Error("Failed to look up locked account %d: %v", userID, err)
+		return
+	}
+
+	err := QueueMail(db, utils.MailMessage{
+		To:      email,
+		Subject: "Sign-in to your account was locked",
+		Body: fmt.Sprintf("We saw %d failed attempts to sign in to your account, the last from %s.\n\n"+
+			"Sign-in is locked for %d minutes. If this wasn't you, consider resetting your password:\n%s",
+			accountThrottle.lockoutAfter, ip, int(accountThrottle.lockoutFor.Minutes()),
+			utils.AppBaseURL()+"/loginPage"),
+	})
+	if err != nil {
+		logger.Error("Failed to notify user %d of lockout: %v", userID, err)
+	}
+}
+
+// cleanupLoginThrottles forgets keys with no recent failures
+func cleanupLoginThrottles(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		DELETE FROM login_throttles
+		WHERE last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)`,
+		time.Now().Add(-ipThrottle.resetAfter), time.Now())
+	return err
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/backend/logger"
+	"forum/backend/utils"
+)
+
+const (
+	mailOutboxInterval   = 5 * time.Second
+	mailOutboxBatchSize  = 20
+	maxMailDeliveryTries = 8
+	// mailOutboxRetention is how long sent and abandoned mail is kept, for
+	// looking into delivery problems
+	mailOutboxRetention = 7 * 24 * time.Hour
+)
+
+// QueueMail stores a message in the outbox. It is delivered by ProcessMailOutbox,
+// so requests never wait on, or fail because of, the mail server.
+func QueueMail(db *sql.DB, msg utils.MailMessage) error {
+	now := time.Now()
+	_, err := db.Exec(`
+		INSERT INTO mail_outbox (recipient, subject, body, created_at, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		msg.To, msg.Subject, msg.Body, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to queue mail: %w", err)
+	}
+	return nil
+}
+
+// ProcessMailOutbox delivers queued mail until ctx is cancelled
+func ProcessMailOutbox(ctx context.Context, db *sql.DB, mailer utils.Mailer) {
+	ticker := time.NewTicker(mailOutboxInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopping mail outbox task...")
+			return
+		case <-ticker.C:
+			if _, err := DeliverQueuedMail(db, mailer); err != nil {
+				logger.Error("Failed to deliver queued mail: %v", err)
+			}
+		}
+	}
+}
+
+// DeliverQueuedMail sends the messages that are due and returns how many were sent.
+// Failed messages are retried with exponential back-off and given up on after
+// maxMailDeliveryTries. The body, with any links in it, is blanked once a
+// message is sent or given up on.
+func DeliverQueuedMail(db *sql.DB, mailer utils.Mailer) (int, error) {
+	rows, err := db.Query(`
+		SELECT id, recipient, subject, body, attempts
+		FROM mail_outbox
+		WHERE sent_at IS NULL AND attempts < ? AND next_attempt_at <= ?
+		ORDER BY id
+		LIMIT ?`, maxMailDeliveryTries, time.Now(), mailOutboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type queued struct {
+		id       int
+		msg      utils.MailMessage
+		attempts int
+	}
+	var pending []queued
+	for rows.Next() {
+		var q queued
+		if err := rows.Scan(&q.id, &q.msg.To, &q.msg.Subject, &q.msg.Body, &q.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, q)
+	}
+	rows.Close()
+
+	sent := 0
+	for _, q := range pending {
+		if err := mailer.Send(q.msg); err != nil {
+			backoff := time.Duration(1<<q.attempts) * time.Minute
+			logger.Warning("Mail %d to %s failed (attempt %d): %v", q.id, q.msg.To, q.attempts+1, err)
+			_, dbErr := db.Exec(`
+				UPDATE mail_outbox SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?,
+					body = CASE WHEN attempts + 1 >= ? THEN '' ELSE body END
+				WHERE id = ?`, err.Error(), time.Now().Add(backoff), maxMailDeliveryTries, q.id)
+			if dbErr != nil {
+				return sent, dbErr
+			}
+			continue
+		}
+		if _, err := db.Exec("UPDATE mail_outbox SET sent_at = ?, attempts = attempts + 1, body = '' WHERE id = ?", time.Now(), q.id); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// PruneMailOutbox deletes mail that was sent or given up on more than
+// mailOutboxRetention ago and returns how many messages it deleted
+func PruneMailOutbox(db *sql.DB) (int64, error) {
+	result, err := db.Exec(`
+		DELETE FROM mail_outbox
+		WHERE (sent_at IS NOT NULL OR attempts >= ?) AND created_at < ?`,
+		maxMailDeliveryTries, time.Now().Add(-mailOutboxRetention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune mail outbox: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -118,17 +118,17 @@ func (mc *MessageController) GetMessagesInConversation(user1ID, user2ID, offset,
             m.sent_at, 
             m.is_read,
             CASE 
-                WHEN m.sender_id = ? THEN r.nickname
-                ELSE s.nickname
+                WHEN m.sender_id = ? THEN ` + nicknameColumn("r") + `
+                ELSE ` + nicknameColumn("s") + `
             END as other_user_nickname,
             CASE 
                 WHEN m.sender_id = ? THEN r.avatar
                 ELSE s.avatar
             END as other_user_avatar,
-            CASE 
+            COALESCE(CASE 
                 WHEN m.sender_id = ? THEN r_status.is_online
                 ELSE s_status.is_online
-            END as other_user_online
+            END, FALSE) as other_user_online
         FROM messages m
         JOIN conversations c ON m.conversation_id = c.id
         JOIN users s ON m.sender_id = s.id
@@ -278,17 +278,17 @@ func (mc *MessageController) GetAllMessages(userID, limit, offset int) ([]models
         SELECT 
             m.id, m.sender_id, m.recipient_id, m.conversation_id, m.content, m.sent_at, m.is_read,
             CASE 
-                WHEN m.sender_id = ? THEN r.nickname
-                ELSE s.nickname
+                WHEN m.sender_id = ? THEN ` + nicknameColumn("r") + `
+                ELSE ` + nicknameColumn("s") + `
             END as other_user_nickname,
             CASE 
                 WHEN m.sender_id = ? THEN r.avatar
                 ELSE s.avatar
             END as other_user_avatar,
-            CASE 
+            COALESCE(CASE 
                 WHEN m.sender_id = ? THEN r_status.is_online
                 ELSE s_status.is_online
-            END as other_user_online
+            END, FALSE) as other_user_online
         FROM messages m
         JOIN users s ON m.sender_id = s.id
         JOIN users r ON m.recipient_id = r.id
@@ -356,7 +356,7 @@ func (mc *MessageController) GetUserInfo(userID int) (models.MessageUser, error)
 	var avatarNull sql.NullString
 
 	err := mc.db.QueryRow(`
-		SELECT u.id, u.nickname, u.avatar, COALESCE(us.is_online, false) 
+		SELECT u.id, `+nicknameColumn("u")+`, u.avatar, COALESCE(us.is_online, false) 
 		FROM users u 
 		LEFT JOIN user_status us ON u.id = us.user_id 
 		WHERE u.id = ?`, userID).Scan(
@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"forum/backend/logger"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// OIDCStateTTL is how long the user has to finish signing in at the provider
+const OIDCStateTTL = 10 * time.Minute
+
+var (
+	ErrInvalidOIDCState        = errors.New("sign-in request is invalid or has expired")
+	ErrIdentityLinkedElsewhere = errors.New("this external account is linked to another user")
+	ErrOIDCEmailRequired       = errors.New("the identity provider did not share an email address")
+	ErrOIDCEmailTaken          = errors.New("an account already uses this email; log in and link the provider from your settings")
+)
+
+// BeginOIDCLogin stores the state, nonce and PKCE verifier for a new sign-in
+// and returns the provider URL to send the browser to, along with the state
+// the browser must keep to finish the sign-in. A non-zero linkUserID links the
+// external identity to that signed-in user instead of logging in.
+func BeginOIDCLogin(ctx context.Context, db *sql.DB, provider *utils.OIDCProvider, linkUserID int) (string, string, error) {
+	state, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, challenge, err := utils.NewPKCEVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	var link interface{}
+	if linkUserID > 0 {
+		link = linkUserID
+	}
+	_, err = db.Exec(`
+		INSERT INTO oidc_states (state_hash, code_verifier, nonce, link_user_id, expires_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		utils.HashToken(state), verifier, nonce, link, time.Now().Add(OIDCStateTTL))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to store OIDC state: %w", err)
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, challenge)
+	return authURL, state, err
+}
+
+// OIDCLoginResult is the local user behind a completed OIDC sign-in
+type OIDCLoginResult struct {
+	UserID int
+	// Linked is set when the sign-in only linked the identity to a user who
+	// was already logged in
+	Linked bool
+}
+
+// CompleteOIDCLogin handles the provider's redirect back: it checks the state,
+// exchanges the code, verifies the ID token and returns the local user the
+// identity belongs to, linking or creating one as needed. browserState is the
+// state the browser kept from BeginOIDCLogin; it must match the one the
+// provider sent back, so a sign-in started elsewhere cannot be finished here.
+func CompleteOIDCLogin(ctx context.Context, db *sql.DB, provider *utils.OIDCProvider, state, browserState, code string) (OIDCLoginResult, error) {
+	var result OIDCLoginResult
+	var verifier, nonce string
+	var linkUserID sql.NullInt64
+	var expiresAt time.Time
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return result, ErrInvalidOIDCState
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stateHash := utils.HashToken(state)
+	err = tx.QueryRow("SELECT code_verifier, nonce, link_user_id, expires_at FROM oidc_states WHERE state_hash = ?", stateHash).
+		Scan(&verifier, &nonce, &linkUserID, &expiresAt)
+	if err == sql.ErrNoRows {
+		return result, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return result, err
+	}
+	// Each state is used once, whatever the outcome
+	if _, err := tx.Exec("DELETE FROM oidc_states WHERE state_hash = ?", stateHash); err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+	if time.Now().After(expiresAt) {
+		return result, ErrInvalidOIDCState
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		return result, err
+	}
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return result, err
+	}
+
+	result.Linked = linkUserID.Valid
+	result.UserID, err = linkOrCreateOIDCUser(db, claims, int(linkUserID.Int64))
+	return result, err
+}
+
+// linkOrCreateOIDCUser finds the user for an external identity. Known
+// identities log straight in; otherwise the identity is linked to the user who
+// started a link, or to the account with the same email when both the provider
+// and the account have verified it, or a new account is created from the claims.
+func linkOrCreateOIDCUser(db *sql.DB, claims *utils.OIDCClaims, linkUserID int) (int, error) {
+	var userID int
+	err := db.QueryRow("SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?", claims.Issuer, claims.Subject).Scan(&userID)
+	if err == nil {
+		if linkUserID > 0 && linkUserID != userID {
+			return 0, ErrIdentityLinkedElsewhere
+		}
+		return userID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	switch {
+	case linkUserID > 0:
+		userID = linkUserID
+
+	case claims.Email == "":
+		return 0, ErrOIDCEmailRequired
+
+	default:
+		var localVerified bool
+		err := db.QueryRow("SELECT id, email_verified FROM users WHERE lower(email) = lower(?)", claims.Email).Scan(&userID, &localVerified)
+		switch {
+		case err == nil && !(bool(claims.EmailVerified) && localVerified):
+			// Only trust the address if the provider vouches for it and the
+			// account owner has confirmed it too
+			return 0, ErrOIDCEmailTaken
+		case err == sql.ErrNoRows:
+			userID, err = createOIDCUser(db, claims)
+			if err != nil {
+				return 0, err
+			}
+		case err != nil:
+			return 0, err
+		}
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO user_identities (user_id, issuer, subject, email, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		userID, claims.Issuer, claims.Subject, claims.Email, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to link identity: %w", err)
+	}
+	logger.Info("Linked %s identity %s to user %d", claims.Issuer, claims.Subject, userID)
+	return userID, nil
+}
+
+var nicknameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// createOIDCUser registers an account without a password for a new external identity
+func createOIDCUser(db *sql.DB, claims *utils.OIDCClaims) (int, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = nicknameInvalidChars.ReplaceAllString(base, "_")
+	base = strings.Trim(base, "_")
+	if base == "" || !regexp.MustCompile(`^[a-zA-Z]`).MatchString(base) {
+		base = "user_" + base
+	}
+	if len(base) > 16 {
+		base = base[:16]
+	}
+
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" && lastName == "" && claims.Name != "" {
+		parts := strings.SplitN(claims.Name, " ", 2)
+		firstName = parts[0]
+		if len(parts) > 1 {
+			lastName = parts[1]
+		}
+	}
+
+	// Find a free nickname: base, base1, base2, ...
+	nickname := base
+	for i := 1; ; i++ {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE lower(nickname) = lower(?))", nickname).Scan(&exists); err != nil {
+			return 0, err
+		}
+		if !exists {
+			break
+		}
+		if i > 999 {
+			return 0, errors.New("could not find a free nickname")
+		}
+		nickname = fmt.Sprintf("%s%d", base, i)
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO users (nickname, email, password, first_name, last_name, age, gender, email_verified)
+		VALUES (?, ?, '', ?, ?, 0, '', ?)`,
+		nickname, claims.Email, firstName, lastName, bool(claims.EmailVerified))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create user: %w", err)
+	}
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	logger.Info("Created user %d (%s) from %s sign-in", userID, nickname, claims.Issuer)
+	return int(userID), nil
+}
+
+// GetUserIdentities lists the external accounts linked to a user
+func GetUserIdentities(db *sql.DB, userID int) ([]models.UserIdentity, error) {
+	rows, err := db.Query("SELECT issuer, email, created_at FROM user_identities WHERE user_id = ? ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []models.UserIdentity{}
+	for rows.Next() {
+		var identity models.UserIdentity
+		var email sql.NullString
+		if err := rows.Scan(&identity.Issuer, &email, &identity.LinkedAt); err != nil {
+			return nil, err
+		}
+		identity.Email = email.String
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
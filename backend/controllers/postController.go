@@ -4,7 +4,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
+	"forum/backend/logger"
 	"forum/backend/models"
 	"forum/backend/utils"
 )
@@ -18,19 +22,33 @@ func NewPostController(db *sql.DB) *PostController {
 }
 
 func (pc *PostController) InsertPost(post models.Post) (int, error) {
+	tags, err := postTags(post)
+	if err != nil {
+		return 0, err
+	}
+
 	var postID int
-	err := utils.RetryOnLocked(pc.DB, func() error {
+	err = utils.RetryOnLocked(pc.DB, func() error {
 		tx, err := pc.DB.Begin()
 		if err != nil {
 			return fmt.Errorf("failed to begin transaction: %w", err)
 		}
 		defer tx.Rollback()
 
-		// Insert the post
+		// Insert the post, published unless it is a draft or scheduled
+		if post.Status == "" {
+			post.Status = models.PostPublished
+		}
+		// Stored like every other timestamp, so the scheduler can compare them
+		if post.PublishAt != nil {
+			publishAt := post.PublishAt.Local()
+			post.PublishAt = &publishAt
+		}
 		result, err := tx.Exec(`
-			INSERT INTO posts (title, user_id, author, category, likes, dislikes, content, timestamp, video_url)
+			INSERT INTO posts (title, user_id, author, category, content, timestamp, video_url, status, publish_at)
 			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
-		`, post.Title, post.UserID, post.Author, post.Category, post.Likes, post.Dislikes, post.Content, post.Timestamp, post.VideoUrl)
+		`, post.Title, post.UserID, post.Author, post.Category, post.Content, post.Timestamp, post.VideoUrl,
+			post.Status, post.PublishAt)
 		if err != nil {
 			return fmt.Errorf("failed to insert post: %w", err)
 		}
@@ -52,6 +70,10 @@ func (pc *PostController) InsertPost(post models.Post) (int, error) {
 			}
 		}
 
+		if err := setPostTags(tx, postID, tags); err != nil {
+			return err
+		}
+
 		return tx.Commit()
 	})
 	if err != nil {
@@ -60,67 +82,182 @@ func (pc *PostController) InsertPost(post models.Post) (int, error) {
 	return postID, nil
 }
 
-func (pc *PostController) GetAllPosts(offset, limit int) ([]models.Post, error) {
+// postColumns selects a post p and its author u, in the order scanPost reads them
+var postColumns = `
+	p.id, p.title, p.content, p.category, p.timestamp, p.video_url, p.locked,
+	p.edited_at, (SELECT COUNT(*) FROM post_revisions r WHERE r.post_id = p.id), p.status, p.publish_at,
+	` + postTagsColumn("p") + `,
+	u.id, ` + nicknameColumn("u") + `, u.profession, u.avatar`
+
+func scanPost(row interface{ Scan(...interface{}) error }) (models.Post, error) {
+	var post models.Post
+	var user models.User
+	var profession, avatar sql.NullString // Use sql.NullString for nullable fields
+	var editedAt, publishAt sql.NullTime
+	var tags sql.NullString
+
+	err := row.Scan(
+		&post.ID, &post.Title, &post.Content, &post.Category,
+		&post.Timestamp, &post.VideoUrl, &post.Locked,
+		&editedAt, &post.RevisionCount, &post.Status, &publishAt, &tags,
+		&user.ID, &user.Nickname, &profession, &avatar,
+	)
+	if err != nil {
+		return post, err
+	}
+
+	// Set the values only if they are valid
+	if avatar.Valid {
+		avatarStr := avatar.String
+		user.Avatar = &avatarStr
+	}
+	if profession.Valid {
+		user.Profession = profession.String
+	}
+	if editedAt.Valid {
+		post.EditedAt = &editedAt.Time
+	}
+	if publishAt.Valid {
+		post.PublishAt = &publishAt.Time
+	}
+	post.Tags = splitTags(tags)
+	post.User = user
+	post.UserID = user.ID
+	post.Author = user.Nickname
+	return post, nil
+}
+
+// GetAllPosts lists the published posts, newest first, a page at a time
+func (pc *PostController) GetAllPosts(cursor string, limit int) ([]models.Post, string, error) {
+	return pc.GetFeed(models.Feed{}, cursor, limit)
+}
+
+// GetCategoryPosts lists the published posts filed under the category slug
+func (pc *PostController) GetCategoryPosts(slug, cursor string, limit int) ([]models.Post, string, error) {
+	return pc.GetFeed(models.Feed{Category: slug}, cursor, limit)
+}
+
+// listPosts lists a page of the published posts feed selects, those after
+// the cursor after, and returns the cursor for the page that follows them,
+// or "" on the last page. Images and comment counts are loaded for the
+// whole page at once, with reactions as feed.viewerID sees them; comments
+// are left for the client to fetch.
+func (pc *PostController) listPosts(feed feedQuery, after *feedCursor, limit int) ([]models.Post, string, error) {
 	query := `
-		SELECT 
-			p.id, p.title, p.content, p.category, p.likes, p.dislikes, p.timestamp, p.video_url,
-			u.id, u.nickname, u.profession, u.avatar
+		SELECT ` + postColumns + `, ` + feed.score + ` AS feed_score, CAST(p.timestamp AS TEXT)
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
-		ORDER BY p.timestamp DESC
-		LIMIT ? OFFSET ?
-	`
+		WHERE u.content_hidden = FALSE AND p.status = 'published'` + feed.condition
+	args := append(append([]interface{}{}, feed.scoreArgs...), feed.args...)
+	if after != nil {
+		query += " AND (" + feed.score + ", p.timestamp, p.id) < (?, ?, ?)"
+		args = append(append(args, feed.scoreArgs...), after.Score, after.Time, after.ID)
+	}
+	query += " ORDER BY feed_score DESC, p.timestamp DESC, p.id DESC LIMIT ?"
+	args = append(args, limit+1)
 
-	rows, err := pc.DB.Query(query, limit, offset)
+	rows, err := pc.DB.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch posts: %w", err)
+		return nil, "", fmt.Errorf("failed to fetch posts: %w", err)
 	}
 	defer rows.Close()
 
-	var posts []models.Post
+	posts := []models.Post{}
+	cursors := []feedCursor{}
 	for rows.Next() {
-		var post models.Post
-		var user models.User
-		var profession, avatar sql.NullString // Use sql.NullString for nullable fields
-
-		err := rows.Scan(
-			&post.ID, &post.Title, &post.Content, &post.Category,
-			&post.Likes, &post.Dislikes, &post.Timestamp, &post.VideoUrl,
-			&user.ID, &user.Nickname, &profession, &avatar,
-		)
+		cursor := feedCursor{Mode: feed.mode, AsOf: feed.asOf}
+		post, err := scanPost(scanWith(rows, &cursor.Score, &cursor.Time))
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan post: %w", err)
+			return nil, "", fmt.Errorf("failed to scan post: %w", err)
 		}
+		cursor.ID = post.ID
+		posts = append(posts, post)
+		cursors = append(cursors, cursor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to fetch posts: %w", err)
+	}
+	rows.Close()
 
-		// Set the values only if they are valid
-		if avatar.Valid {
-			avatarStr := avatar.String
-			user.Avatar = &avatarStr
-		}
-		if profession.Valid {
-			user.Profession = profession.String
-		}
+	// One more post than fits means another page follows
+	var next string
+	if len(posts) > limit {
+		posts = posts[:limit]
+		next = encodeCursor(cursors[limit-1])
+	}
+	if err := pc.loadPostExtras(posts, feed.viewerID); err != nil {
+		return nil, "", err
+	}
+	return posts, next, nil
+}
 
-		post.User = user
+// loadPostExtras fills in the images, comment counts and reactions of posts
+// with one query each, however many posts there are. Reacted marks the
+// reactions of viewerID.
+func (pc *PostController) loadPostExtras(posts []models.Post, viewerID int) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	byID := make(map[int]*models.Post, len(posts))
+	ids := make([]interface{}, len(posts))
+	for i := range posts {
+		byID[posts[i].ID] = &posts[i]
+		ids[i] = posts[i].ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
 
-		images, err := pc.GetPostImages(post.ID)
-		if err != nil {
-			return posts, err
+	rows, err := pc.DB.Query(`
+		SELECT post_id, image_url FROM post_images
+		WHERE post_id IN (`+placeholders+`)
+		ORDER BY id`, ids...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch images: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID int
+		var imagePath string
+		if err := rows.Scan(&postID, &imagePath); err != nil {
+			return fmt.Errorf("failed to scan image path: %w", err)
 		}
+		byID[postID].Images = append(byID[postID].Images, imagePath)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to fetch images: %w", err)
+	}
 
-		post.Images = images
-
-		// Fetch comments for this post
-		comments, err := pc.GetPostComments(post.ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch comments: %w", err)
+	rows, err = pc.DB.Query(`
+		SELECT c.post_id, COUNT(*) FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE u.content_hidden = FALSE AND c.post_id IN (`+placeholders+`)
+		GROUP BY c.post_id`, ids...)
+	if err != nil {
+		return fmt.Errorf("failed to count comments: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID, count int
+		if err := rows.Scan(&postID, &count); err != nil {
+			return fmt.Errorf("failed to scan comment count: %w", err)
 		}
-		post.Comments = comments
-
-		posts = append(posts, post)
+		byID[postID].CommentCount = count
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to count comments: %w", err)
 	}
 
-	return posts, nil
+	postIDs := make([]int, len(posts))
+	for i := range posts {
+		postIDs[i] = posts[i].ID
+	}
+	reactions, err := GetReactionCounts(pc.DB, models.ReactionOnPost, postIDs, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Reactions = reactions[posts[i].ID]
+	}
+	return nil
 }
 
 func (pc *PostController) GetPostImages(postId int) ([]string, error) {
@@ -154,7 +291,8 @@ func (pc *PostController) GetPostComments(postID int) ([]models.Comment, error)
 		SELECT c.id, c.content, c.user_id, c.post_id, c.parent_id, c.timestamp,
 			   EXISTS(SELECT 1 FROM comments r WHERE r.parent_id = c.id) as has_replies
 		FROM comments c
-		WHERE c.post_id = ? AND (c.parent_id IS NULL OR c.parent_id = 0)
+		JOIN users u ON c.user_id = u.id
+		WHERE c.post_id = ? AND (c.parent_id IS NULL OR c.parent_id = 0) AND u.content_hidden = FALSE
 		ORDER BY c.timestamp DESC
 	`
 
@@ -203,63 +341,34 @@ func (pc *PostController) GetPostComments(postID int) ([]models.Comment, error)
 	return comments, nil
 }
 
+// GetPostByID returns a published post with its images and comments
 func (pc *PostController) GetPostByID(postID int) (models.Post, error) {
+	return pc.getPost("p.id = ? AND p.status = 'published' AND u.content_hidden = FALSE", postID)
+}
+
+// GetAuthorPost returns one of userID's own posts, whether published or not
+func (pc *PostController) GetAuthorPost(postID, userID int) (models.Post, error) {
+	return pc.getPost("p.id = ? AND p.user_id = ?", postID, userID)
+}
+
+func (pc *PostController) getPost(condition string, args ...interface{}) (models.Post, error) {
 	// Query to fetch the post details along with user details
 	query := `
-        SELECT 
-            p.id, p.title, p.content, p.category, p.likes, p.dislikes, p.timestamp, p.video_url,
-            u.id, u.nickname, u.profession, u.avatar
+        SELECT ` + postColumns + `
         FROM posts p
         JOIN users u ON p.user_id = u.id
-        WHERE p.id = ?;
-    `
+        WHERE ` + condition
 
-	row := pc.DB.QueryRow(query, postID)
-
-	var post models.Post
-	var user models.User
-	var profession, avatar sql.NullString // Use sql.NullString for nullable fields
-
-	err := row.Scan(
-		&post.ID, &post.Title, &post.Content, &post.Category,
-		&post.Likes, &post.Dislikes, &post.Timestamp, &post.VideoUrl,
-		&user.ID, &user.Nickname, &profession, &avatar,
-	)
+	post, err := scanPost(pc.DB.QueryRow(query, args...))
 	if err != nil {
 		return post, fmt.Errorf("failed to fetch post: %w", err)
 	}
 
-	// Set the values only if they are valid
-	if avatar.Valid {
-		avatarStr := avatar.String
-		user.Avatar = &avatarStr
-	}
-	if profession.Valid {
-		user.Profession = profession.String
-	}
-	post.User = user
-
-	// Fetch images for this post
-	imageQuery := `
-        SELECT image_url 
-        FROM post_images 
-        WHERE post_id = ?;
-    `
-	rows, err := pc.DB.Query(imageQuery, post.ID)
-	if err != nil {
-		return post, fmt.Errorf("failed to fetch images: %w", err)
-	}
-	defer rows.Close()
-
-	var images []string
-	for rows.Next() {
-		var imagePath string
-		if err := rows.Scan(&imagePath); err != nil {
-			return post, fmt.Errorf("failed to scan image path: %w", err)
-		}
-		images = append(images, imagePath)
+	posts := []models.Post{post}
+	if err := pc.loadPostExtras(posts, 0); err != nil {
+		return post, err
 	}
-	post.Images = images
+	post = posts[0]
 
 	comments, err := pc.GetPostComments(post.ID)
 	if err != nil {
@@ -270,45 +379,142 @@ func (pc *PostController) GetPostByID(postID int) (models.Post, error) {
 	return post, nil
 }
 
-func (pc *PostController) UpdatePost(post models.Post) error {
-	// Prepare the SQL statement for updating the post
-	query := `
-	UPDATE posts
-	SET title = ?, author = ?, user_id = ?, category = ?, likes = ?, dislikes = ?, content = ?, video_url = ?, timestamp = ?
-	WHERE id = ?;
-	`
+// UpdatePost saves the editable fields of a post. The author, votes and
+// creation time stay as they are, also when a moderator edits the post.
+// When the title, content or category of a published post change, the new
+// version is stored as a revision edited by editorID, and the first edit also
+// keeps the original. Drafts are edited without history. post.Images replaces
+// the post's images, and the hashtags in the content together with post.Tags
+// replace its tags.
+func (pc *PostController) UpdatePost(post models.Post, editorID int) error {
+	tags, err := postTags(post)
+	if err != nil {
+		return err
+	}
 
-	// Execute the SQL statement with the post data
-	result, err := pc.DB.Exec(query,
-		post.Title,
-		post.Author,
-		post.UserID,
-		post.Category,
-		post.Likes,
-		post.Dislikes,
-		post.Content,
-		post.VideoUrl,
-		post.Timestamp,
-		post.ID,
-	)
+	var removedImages []string
+	err = utils.RetryOnLocked(pc.DB, func() error {
+		removedImages = nil
+		tx, err := pc.DB.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		var current models.Post
+		err = tx.QueryRow(`
+			SELECT title, content, category, user_id, timestamp, status FROM posts WHERE id = ?`, post.ID).
+			Scan(&current.Title, &current.Content, &current.Category, &current.UserID, &current.Timestamp, &current.Status)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no post found with ID %d", post.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch post: %w", err)
+		}
+		edited := post.Title != current.Title || post.Content != current.Content || post.Category != current.Category
+		if current.Status != models.PostPublished {
+			edited = false
+		}
+
+		removedImages, err = replacePostImages(tx, post.ID, post.Images)
+		if err != nil {
+			return err
+		}
+		if err := setPostTags(tx, post.ID, tags); err != nil {
+			return err
+		}
+
+		// Execute the SQL statement with the post data
+		now := time.Now()
+		_, err = tx.Exec(`
+			UPDATE posts
+			SET title = ?, category = ?, content = ?, video_url = ?,
+				edited_at = CASE WHEN ? THEN ? ELSE edited_at END
+			WHERE id = ?`,
+			post.Title, post.Category, post.Content, post.VideoUrl, edited, now, post.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update post: %w", err)
+		}
+
+		if edited {
+			var revisions int
+			if err := tx.QueryRow("SELECT COUNT(*) FROM post_revisions WHERE post_id = ?", post.ID).Scan(&revisions); err != nil {
+				return fmt.Errorf("failed to count revisions: %w", err)
+			}
+			if revisions == 0 {
+				current.ID = post.ID
+				if err := insertPostRevision(tx, current, 1, current.UserID, current.Timestamp); err != nil {
+					return err
+				}
+				revisions = 1
+			}
+			if err := insertPostRevision(tx, post, revisions+1, editorID, now); err != nil {
+				return err
+			}
+		}
+
+		return tx.Commit()
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update post: %w", err)
+		return err
 	}
 
-	// Check if any rows were affected
-	rowsAffected, err := result.RowsAffected()
+	if err := utils.RemoveImages(removedImages); err != nil {
+		logger.Warning("Failed to remove replaced images of post %d: %v", post.ID, err)
+	}
+	return nil
+}
+
+// replacePostImages makes images the image list of a post and returns the
+// paths of the images it no longer uses
+func replacePostImages(tx *sql.Tx, postID int, images []string) ([]string, error) {
+	rows, err := tx.Query("SELECT image_url FROM post_images WHERE post_id = ? ORDER BY id", postID)
 	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
+		return nil, fmt.Errorf("failed to fetch images: %w", err)
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("no post found with ID %d", post.ID)
+	var current []string
+	for rows.Next() {
+		var imagePath string
+		if err := rows.Scan(&imagePath); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan image path: %w", err)
+		}
+		current = append(current, imagePath)
 	}
+	rows.Close()
 
-	return nil
+	if slices.Equal(current, images) {
+		return nil, nil
+	}
+	if _, err := tx.Exec("DELETE FROM post_images WHERE post_id = ?", postID); err != nil {
+		return nil, fmt.Errorf("failed to delete images: %w", err)
+	}
+	for _, imagePath := range images {
+		if _, err := tx.Exec("INSERT INTO post_images (post_id, image_url) VALUES (?, ?)", postID, imagePath); err != nil {
+			return nil, fmt.Errorf("failed to insert image: %w", err)
+		}
+	}
+
+	var removed []string
+	for _, imagePath := range current {
+		if !slices.Contains(images, imagePath) {
+			removed = append(removed, imagePath)
+		}
+	}
+	return removed, nil
 }
 
-// DeletePost deletes a post from the database by its ID, along with its comments and associated images
+// DeletePost deletes a post from the database by its ID, along with its comments and associated images.
+// userID must be the author or have PermDeleteAnyPost.
 func (pc *PostController) DeletePost(postID, userID int) error {
+	allowed, err := pc.CanModifyPost(postID, userID, PermDeleteAnyPost)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrNotAllowed
+	}
+
 	return utils.RetryOnLocked(pc.DB, func() error {
 		tx, err := pc.DB.Begin()
 		if err != nil {
@@ -316,7 +522,16 @@ func (pc *PostController) DeletePost(postID, userID int) error {
 		}
 		defer tx.Rollback()
 
-		// Step 1: Delete all comments associated with the post
+		// Step 1: Delete all comments associated with the post, and the
+		// reactions to them and to the post
+		_, err = tx.Exec(`
+			DELETE FROM reactions
+			WHERE (entity_type = 'post' AND entity_id = ?)
+			   OR (entity_type = 'comment' AND entity_id IN (SELECT id FROM comments WHERE post_id = ?))
+		`, postID, postID)
+		if err != nil {
+			return fmt.Errorf("failed to delete reactions: %w", err)
+		}
 		_, err = tx.Exec(`
 			DELETE FROM comments 
 			WHERE post_id = ?;
@@ -346,11 +561,17 @@ func (pc *PostController) DeletePost(postID, userID int) error {
 			}
 		}
 
-		// Step 3: Delete the post
+		// Step 3: Delete the post, its edit history and its tags
+		if _, err := tx.Exec("DELETE FROM post_revisions WHERE post_id = ?", postID); err != nil {
+			return fmt.Errorf("failed to delete revisions: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM post_tags WHERE post_id = ?", postID); err != nil {
+			return fmt.Errorf("failed to delete tags: %w", err)
+		}
 		result, err := tx.Exec(`
 			DELETE FROM posts 
-			WHERE id = ? AND user_id = ?;
-		`, postID, userID)
+			WHERE id = ?;
+		`, postID)
 		if err != nil {
 			return fmt.Errorf("failed to delete post: %w", err)
 		}
@@ -361,7 +582,7 @@ func (pc *PostController) DeletePost(postID, userID int) error {
 			return fmt.Errorf("failed to check rows affected: %w", err)
 		}
 		if rowsAffected == 0 {
-			return errors.New("no post found with the given ID or user ID")
+			return errors.New("no post found with the given ID")
 		}
 
 		// Step 4: Delete the image files from the upload folder
@@ -394,12 +615,38 @@ func (pc *PostController) IsPostAuthor(postID, userID int) (bool, error) {
 	return authorID == userID, nil
 }
 
+// CanModifyPost reports whether userID may act on a post: authors always
+// may, others only when their role grants perm
+func (pc *PostController) CanModifyPost(postID, userID int, perm Permission) (bool, error) {
+	isAuthor, err := pc.IsPostAuthor(postID, userID)
+	if err != nil || isAuthor {
+		return isAuthor, err
+	}
+	return UserHasPermission(pc.DB, userID, perm)
+}
+
+// SetPostLocked locks or unlocks a post. Locked posts take no new comments.
+func (pc *PostController) SetPostLocked(postID int, locked bool) error {
+	result, err := pc.DB.Exec("UPDATE posts SET locked = ? WHERE id = ?", locked, postID)
+	if err != nil {
+		return fmt.Errorf("failed to lock post: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 func (pc *PostController) GetUserPostCount(userID int) (int, error) {
 	var count int
 	err := pc.DB.QueryRow(`
 		SELECT COUNT(*) 
 		FROM posts 
-		WHERE user_id = ?
+		WHERE user_id = ? AND status = 'published'
 	`, userID).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get user post count: %w", err)
@@ -413,7 +660,8 @@ func (pc *PostController) GetCommentReplies(commentID int) ([]models.Comment, er
 		SELECT c.id, c.content, c.user_id, c.post_id, c.parent_id, c.timestamp,
 			   EXISTS(SELECT 1 FROM comments r WHERE r.parent_id = c.id) as has_replies
 		FROM comments c
-		WHERE c.parent_id = ?
+		JOIN users u ON c.user_id = u.id
+		WHERE c.parent_id = ? AND u.content_hidden = FALSE
 		ORDER BY c.timestamp ASC
 	`
 
@@ -460,144 +708,3 @@ func (pc *PostController) GetCommentReplies(commentID int) ([]models.Comment, er
 
 	return replies, nil
 }
-
-// HandleVote manages likes and dislikes for a post
-func (pc *PostController) HandleVote(postID, userID int, voteType string) (bool, error) {
-	var sameVote bool
-	err := utils.RetryOnLocked(pc.DB, func() error {
-		tx, err := pc.DB.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction: %w", err)
-		}
-		defer tx.Rollback()
-
-		// Check if user has already voted
-		var existingVote string
-		err = tx.QueryRow(`
-			SELECT user_vote 
-			FROM user_votes 
-			WHERE post_id = ? AND user_id = ?
-		`, postID, userID).Scan(&existingVote)
-
-		if err != nil && err != sql.ErrNoRows {
-			return fmt.Errorf("failed to check existing vote: %w", err)
-		}
-
-		// If user hasn't voted before
-		if err == sql.ErrNoRows {
-			// Insert new vote
-			_, err = tx.Exec(`
-				INSERT INTO user_votes (post_id, user_id, user_vote)
-				VALUES (?, ?, ?)
-			`, postID, userID, voteType)
-			if err != nil {
-				return fmt.Errorf("failed to insert vote: %w", err)
-			}
-
-			// Update post likes/dislikes count
-			var column string
-			if voteType == "like" {
-				column = "likes"
-			} else {
-				column = "dislikes"
-			}
-
-			_, err = tx.Exec(`
-				UPDATE posts 
-				SET `+column+` = `+column+` + 1 
-				WHERE id = ?
-			`, postID)
-			if err != nil {
-				return fmt.Errorf("failed to update post vote count: %w", err)
-			}
-		} else {
-			// If user is voting the same way, remove the vote
-			if existingVote == voteType {
-				// Delete the vote
-				_, err = tx.Exec(`
-					DELETE FROM user_votes 
-					WHERE post_id = ? AND user_id = ?
-				`, postID, userID)
-				if err != nil {
-					return fmt.Errorf("failed to delete vote: %w", err)
-				}
-
-				// Decrease the corresponding counter
-				var column string
-				if voteType == "like" {
-					column = "likes"
-				} else {
-					column = "dislikes"
-				}
-
-				_, err = tx.Exec(`
-					UPDATE posts 
-					SET `+column+` = `+column+` - 1 
-					WHERE id = ?
-				`, postID)
-				if err != nil {
-					return fmt.Errorf("failed to update post vote count: %w", err)
-				}
-				sameVote = true
-			} else {
-				// If user is changing their vote
-				// Update the vote type
-				_, err = tx.Exec(`
-					UPDATE user_votes 
-					SET user_vote = ? 
-					WHERE post_id = ? AND user_id = ?
-				`, voteType, postID, userID)
-				if err != nil {
-					return fmt.Errorf("failed to update vote: %w", err)
-				}
-
-				// Update post counts (decrease old vote type, increase new vote type)
-				_, err = tx.Exec(`
-					UPDATE posts 
-					SET likes = CASE 
-							WHEN ? = 'like' THEN likes + 1 
-							ELSE likes - 1 
-						END,
-						dislikes = CASE 
-							WHEN ? = 'dislike' THEN dislikes + 1 
-							ELSE dislikes - 1 
-						END
-					WHERE id = ?
-				`, voteType, voteType, postID)
-				if err != nil {
-					return fmt.Errorf("failed to update post vote counts: %w", err)
-				}
-			}
-		}
-
-		// Set sameVote value based on the existing logic
-		if err == sql.ErrNoRows {
-			sameVote = false
-		} else {
-			sameVote = (existingVote == voteType)
-		}
-
-		return tx.Commit()
-	})
-
-	return sameVote, err
-}
-
-// GetUserVote returns the user's current vote on a post
-func (pc *PostController) GetUserVote(postID, userID int) (string, error) {
-	var voteType string
-	err := pc.DB.QueryRow(`
-		SELECT user_vote 
-		FROM user_votes 
-		WHERE post_id = ? AND user_id = ?
-	`, postID, userID).Scan(&voteType)
-
-	if err == sql.ErrNoRows {
-		return "", nil
-	}
-	if err != nil {
-		return "", fmt.Errorf("failed to get user vote: %w", err)
-	}
-
-	return voteType, nil
-}
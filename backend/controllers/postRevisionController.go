@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// postRevisionColumns selects a revision r and the user e who saved it
+var postRevisionColumns = `
+	r.post_id, r.revision, r.title, r.content, r.category, r.created_at,
+	e.id, ` + nicknameColumn("e") + `, e.avatar`
+
+// insertPostRevision stores a version of a post as revision number revision
+func insertPostRevision(tx *sql.Tx, post models.Post, revision, editorID int, createdAt time.Time) error {
+	var editedBy sql.NullInt64
+	if editorID != 0 {
+		editedBy = sql.NullInt64{Int64: int64(editorID), Valid: true}
+	}
+	_, err := tx.Exec(`
+		INSERT INTO post_revisions (post_id, revision, title, content, category, edited_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		post.ID, revision, post.Title, post.Content, post.Category, editedBy, createdAt)
+	if err != nil {
+		return fmt.Errorf("failed to store revision %d of post %d: %w", revision, post.ID, err)
+	}
+	return nil
+}
+
+func scanPostRevision(row interface{ Scan(...interface{}) error }) (models.PostRevision, error) {
+	var revision models.PostRevision
+	var editorID sql.NullInt64
+	var editorNickname, editorAvatar sql.NullString
+	err := row.Scan(&revision.PostID, &revision.Revision, &revision.Title, &revision.Content,
+		&revision.Category, &revision.CreatedAt, &editorID, &editorNickname, &editorAvatar)
+	if editorID.Valid {
+		revision.EditedBy = &models.User{ID: int(editorID.Int64), Nickname: editorNickname.String}
+		if editorAvatar.Valid {
+			revision.EditedBy.Avatar = &editorAvatar.String
+		}
+	}
+	return revision, err
+}
+
+// postVisible returns sql.ErrNoRows unless the post exists and is shown to others
+func (pc *PostController) postVisible(postID int) error {
+	var id int
+	return pc.DB.QueryRow(`
+		SELECT p.id FROM posts p JOIN users u ON p.user_id = u.id
+		WHERE p.id = ? AND p.status = 'published' AND u.content_hidden = FALSE`, postID).Scan(&id)
+}
+
+// GetPostRevisions lists the saved versions of a post, oldest first. Posts
+// that were never edited have none.
+func (pc *PostController) GetPostRevisions(postID int) ([]models.PostRevision, error) {
+	if err := pc.postVisible(postID); err != nil {
+		return nil, err
+	}
+
+	rows, err := pc.DB.Query(`
+		SELECT `+postRevisionColumns+`
+		FROM post_revisions r
+		LEFT JOIN users e ON e.id = r.edited_by
+		WHERE r.post_id = ?
+		ORDER BY r.revision`, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []models.PostRevision{}
+	for rows.Next() {
+		revision, err := scanPostRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// GetPostRevision returns one saved version of a post
+func (pc *PostController) GetPostRevision(postID, revision int) (models.PostRevision, error) {
+	if err := pc.postVisible(postID); err != nil {
+		return models.PostRevision{}, err
+	}
+	row := pc.DB.QueryRow(`
+		SELECT `+postRevisionColumns+`
+		FROM post_revisions r
+		LEFT JOIN users e ON e.id = r.edited_by
+		WHERE r.post_id = ? AND r.revision = ?`, postID, revision)
+	return scanPostRevision(row)
+}
+
+// DiffPostRevisions compares revision from of a post with revision to
+func (pc *PostController) DiffPostRevisions(postID, from, to int) (models.PostRevisionDiff, error) {
+	diff := models.PostRevisionDiff{PostID: postID, From: from, To: to}
+	older, err := pc.GetPostRevision(postID, from)
+	if err != nil {
+		return diff, err
+	}
+	newer, err := pc.GetPostRevision(postID, to)
+	if err != nil {
+		return diff, err
+	}
+
+	diff.Title = utils.DiffLines(older.Title, newer.Title)
+	diff.Content = utils.DiffLines(older.Content, newer.Content)
+	diff.Category = utils.DiffLines(older.Category, newer.Category)
+	return diff, nil
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"forum/backend/logger"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// scheduledPostInterval is how often the scheduler looks for posts that are due
+const scheduledPostInterval = 30 * time.Second
+
+var (
+	ErrInvalidPostStatus = errors.New("status must be draft, scheduled or published")
+	ErrPublishAtRequired = errors.New("scheduled posts need a publish_at time in the future")
+	ErrPostPublished     = errors.New("a published post cannot be turned back into a draft")
+	ErrPostNotPublished  = errors.New("post is not published")
+)
+
+// ValidatePostStatus checks a status requested for a post. publishAt is only
+// allowed, and required, for scheduled posts.
+func ValidatePostStatus(status string, publishAt *time.Time) error {
+	switch status {
+	case models.PostDraft, models.PostPublished:
+		if publishAt != nil {
+			return ErrInvalidPostStatus
+		}
+		return nil
+	case models.PostScheduled:
+		if publishAt == nil || !publishAt.After(time.Now()) {
+			return ErrPublishAtRequired
+		}
+		return nil
+	}
+	return ErrInvalidPostStatus
+}
+
+// SetPostStatus moves one of the author's unpublished posts to status. It
+// reports whether the post was published by the change, in which case its
+// timestamp becomes now so it shows up as new.
+func (pc *PostController) SetPostStatus(postID int, status string, publishAt *time.Time) (bool, error) {
+	if err := ValidatePostStatus(status, publishAt); err != nil {
+		return false, err
+	}
+	// Stored like every other timestamp, so the scheduler can compare them
+	if publishAt != nil {
+		local := publishAt.Local()
+		publishAt = &local
+	}
+
+	published := false
+	err := utils.RetryOnLocked(pc.DB, func() error {
+		tx, err := pc.DB.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		var current string
+		if err := tx.QueryRow("SELECT status FROM posts WHERE id = ?", postID).Scan(&current); err != nil {
+			return err
+		}
+		if current == models.PostPublished {
+			if status != models.PostPublished {
+				return ErrPostPublished
+			}
+			return nil
+		}
+
+		if status == models.PostPublished {
+			_, err = tx.Exec("UPDATE posts SET status = ?, publish_at = NULL, timestamp = ? WHERE id = ?",
+				status, time.Now(), postID)
+		} else {
+			_, err = tx.Exec("UPDATE posts SET status = ?, publish_at = ? WHERE id = ?", status, publishAt, postID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update post status: %w", err)
+		}
+		published = status == models.PostPublished
+		return tx.Commit()
+	})
+	return published, err
+}
+
+// GetUserDrafts lists a user's drafts and scheduled posts, the ones due
+// first and the most recently started drafts after them
+func (pc *PostController) GetUserDrafts(userID int) ([]models.Post, error) {
+	rows, err := pc.DB.Query(`
+		SELECT `+postColumns+`
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.user_id = ? AND p.status IN (?, ?)
+		ORDER BY p.publish_at IS NULL, p.publish_at, p.timestamp DESC`,
+		userID, models.PostDraft, models.PostScheduled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch drafts: %w", err)
+	}
+	defer rows.Close()
+
+	posts := []models.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan draft: %w", err)
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range posts {
+		if posts[i].Images, err = pc.GetPostImages(posts[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return posts, nil
+}
+
+// PublishDuePosts publishes the scheduled posts whose time has come and
+// returns their IDs. Each post is published exactly once, even when several
+// schedulers run, and never once its author's account is deleted.
+func PublishDuePosts(db *sql.DB) ([]int, error) {
+	rows, err := db.Query("SELECT id, publish_at FROM posts WHERE status = ? AND publish_at <= ? ORDER BY publish_at",
+		models.PostScheduled, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	type duePost struct {
+		id        int
+		publishAt time.Time
+	}
+	var due []duePost
+	for rows.Next() {
+		var post duePost
+		if err := rows.Scan(&post.id, &post.publishAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, post)
+	}
+	rows.Close()
+
+	var published []int
+	for _, post := range due {
+		var result sql.Result
+		err := utils.RetryOnLocked(db, func() error {
+			var err error
+			result, err = db.Exec(`
+				UPDATE posts SET status = ?, timestamp = ?
+				WHERE id = ? AND status = ?
+				  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)`,
+				models.PostPublished, post.publishAt.Local(), post.id, models.PostScheduled)
+			return err
+		})
+		if err != nil {
+			return published, fmt.Errorf("failed to publish post %d: %w", post.id, err)
+		}
+		// Someone else got to it first, the author changed their mind or
+		// their account was deleted
+		if claimed, _ := result.RowsAffected(); claimed == 0 {
+			continue
+		}
+		published = append(published, post.id)
+	}
+	return published, nil
+}
+
+// ProcessScheduledPosts publishes scheduled posts as they fall due until ctx
+// is cancelled, calling announce for each one
+func ProcessScheduledPosts(ctx context.Context, db *sql.DB, announce func(postID int)) {
+	ticker := time.NewTicker(scheduledPostInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopping post scheduler...")
+			return
+		case <-ticker.C:
+			published, err := PublishDuePosts(db)
+			if err != nil {
+				logger.Error("Failed to publish scheduled posts: %v", err)
+			}
+			for _, postID := range published {
+				logger.Info("Published scheduled post %d", postID)
+				announce(postID)
+			}
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+const (
+	defaultReactorLimit = 50
+	maxReactorLimit     = 200
+)
+
+var (
+	ErrUnknownReaction = errors.New("unknown reaction")
+	ErrReactionTarget  = errors.New("nothing to react to")
+)
+
+// CanSeeReactionTarget reports whether user can see, and so react to, the
+// post, comment or message with id
+func CanSeeReactionTarget(db *sql.DB, entityType string, entityID, userID int) (bool, error) {
+	visible, err := VisibleReactionTargets(db, entityType, []int{entityID}, userID)
+	return len(visible) > 0, err
+}
+
+// VisibleReactionTargets keeps, in one query, the entityIDs of the posts,
+// comments or messages user can see: published posts and their comments by
+// visible users, and messages the user sent or received
+func VisibleReactionTargets(db *sql.DB, entityType string, entityIDs []int, userID int) ([]int, error) {
+	var query string
+	var args []interface{}
+	switch entityType {
+	case models.ReactionOnPost:
+		query = `
+			SELECT p.id FROM posts p JOIN users u ON u.id = p.user_id
+			WHERE p.status = 'published' AND u.content_hidden = FALSE AND p.id`
+	case models.ReactionOnComment:
+		query = `
+			SELECT c.id FROM comments c
+			JOIN users cu ON cu.id = c.user_id
+			JOIN posts p ON p.id = c.post_id
+			JOIN users u ON u.id = p.user_id
+			WHERE p.status = 'published' AND u.content_hidden = FALSE AND cu.content_hidden = FALSE AND c.id`
+	case models.ReactionOnMessage:
+		query = "SELECT id FROM messages WHERE (sender_id = ? OR recipient_id = ?) AND id"
+		args = append(args, userID, userID)
+	default:
+		return nil, ErrReactionTarget
+	}
+	if len(entityIDs) == 0 {
+		return []int{}, nil
+	}
+	for _, id := range entityIDs {
+		args = append(args, id)
+	}
+
+	rows, err := db.Query(query+" IN ("+strings.TrimSuffix(strings.Repeat("?,", len(entityIDs)), ",")+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s: %w", entityType, err)
+	}
+	defer rows.Close()
+
+	found := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", entityType, err)
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find %s: %w", entityType, err)
+	}
+
+	visible := []int{}
+	for _, id := range entityIDs {
+		if found[id] {
+			visible = append(visible, id)
+		}
+	}
+	return visible, nil
+}
+
+// ToggleReaction leaves reaction on a post, comment or message for user, or
+// takes it back if they had left it. It reports whether the reaction is now
+// there. A user can leave several different reactions on the same thing.
+func ToggleReaction(db *sql.DB, entityType string, entityID, userID int, reaction string) (bool, error) {
+	if _, ok := utils.FindReaction(reaction); !ok {
+		return false, ErrUnknownReaction
+	}
+	visible, err := CanSeeReactionTarget(db, entityType, entityID, userID)
+	if err != nil {
+		return false, err
+	}
+	if !visible {
+		return false, ErrReactionTarget
+	}
+
+	var reacted bool
+	err = utils.RetryOnLocked(db, func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		result, err := tx.Exec(`
+			DELETE FROM reactions
+			WHERE entity_type = ? AND entity_id = ? AND user_id = ? AND reaction = ?`,
+			entityType, entityID, userID, reaction)
+		if err != nil {
+			return fmt.Errorf("failed to remove reaction: %w", err)
+		}
+		removed, _ := result.RowsAffected()
+		reacted = removed == 0
+		if reacted {
+			_, err = tx.Exec(`
+				INSERT INTO reactions (entity_type, entity_id, user_id, reaction)
+				VALUES (?, ?, ?, ?)`,
+				entityType, entityID, userID, reaction)
+			if err != nil {
+				return fmt.Errorf("failed to add reaction: %w", err)
+			}
+		}
+		return tx.Commit()
+	})
+	return reacted, err
+}
+
+// ReactionAudience lists who is told about reactions on a message: its
+// sender and recipient. Posts and comments are public, so it lists nobody.
+func ReactionAudience(db *sql.DB, entityType string, entityID int) ([]int, error) {
+	if entityType != models.ReactionOnMessage {
+		return nil, nil
+	}
+	var senderID, recipientID int
+	err := db.QueryRow("SELECT sender_id, recipient_id FROM messages WHERE id = ?", entityID).Scan(&senderID, &recipientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find message: %w", err)
+	}
+	return []int{senderID, recipientID}, nil
+}
+
+// GetReactionCounts counts the reactions on each of the posts, comments or
+// messages with entityIDs in one query, in the order of the reaction set.
+// Reactions by hidden users, and ones no longer in the set, aren't counted.
+// Every id gets an entry, empty when nobody reacted; Reacted marks the
+// reactions viewerID left.
+func GetReactionCounts(db *sql.DB, entityType string, entityIDs []int, viewerID int) (map[int][]models.ReactionCount, error) {
+	counts := make(map[int][]models.ReactionCount, len(entityIDs))
+	if len(entityIDs) == 0 {
+		return counts, nil
+	}
+	args := []interface{}{viewerID, entityType}
+	for _, id := range entityIDs {
+		counts[id] = []models.ReactionCount{}
+		args = append(args, id)
+	}
+
+	rows, err := db.Query(`
+		SELECT r.entity_id, r.reaction, COUNT(*), MAX(r.user_id = ?)
+		FROM reactions r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.entity_type = ? AND u.content_hidden = FALSE
+		  AND r.entity_id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(entityIDs)), ",")+`)
+		GROUP BY r.entity_id, r.reaction`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count reactions: %w", err)
+	}
+	defer rows.Close()
+
+	found := map[int]map[string]models.ReactionCount{}
+	for rows.Next() {
+		var entityID int
+		var count models.ReactionCount
+		if err := rows.Scan(&entityID, &count.Name, &count.Count, &count.Reacted); err != nil {
+			return nil, fmt.Errorf("failed to scan reaction count: %w", err)
+		}
+		if found[entityID] == nil {
+			found[entityID] = map[string]models.ReactionCount{}
+		}
+		found[entityID][count.Name] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count reactions: %w", err)
+	}
+
+	for _, reaction := range utils.CurrentReactions() {
+		for entityID, byName := range found {
+			if count, ok := byName[reaction.Name]; ok {
+				count.Emoji = reaction.Emoji
+				counts[entityID] = append(counts[entityID], count)
+			}
+		}
+	}
+	return counts, nil
+}
+
+// GetReactors lists who left reaction on a post, comment or message, in the
+// order they reacted
+func GetReactors(db *sql.DB, entityType string, entityID int, reaction string, limit int) ([]models.Reactor, error) {
+	if _, ok := utils.FindReaction(reaction); !ok {
+		return nil, ErrUnknownReaction
+	}
+	if limit <= 0 {
+		limit = defaultReactorLimit
+	}
+	if limit > maxReactorLimit {
+		limit = maxReactorLimit
+	}
+
+	rows, err := db.Query(`
+		SELECT u.id, `+nicknameColumn("u")+`, u.avatar, r.created_at
+		FROM reactions r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.entity_type = ? AND r.entity_id = ? AND r.reaction = ? AND u.content_hidden = FALSE
+		ORDER BY r.created_at, u.id
+		LIMIT ?`, entityType, entityID, reaction, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reactors: %w", err)
+	}
+	defer rows.Close()
+
+	reactors := []models.Reactor{}
+	for rows.Next() {
+		var reactor models.Reactor
+		var avatar sql.NullString
+		if err := rows.Scan(&reactor.UserID, &reactor.Nickname, &avatar, &reactor.ReactedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reactor: %w", err)
+		}
+		if avatar.Valid {
+			reactor.Avatar = &avatar.String
+		}
+		reactors = append(reactors, reactor)
+	}
+	return reactors, rows.Err()
+}
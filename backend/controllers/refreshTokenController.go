@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"forum/backend/utils"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// RefreshResult describes the session a rotated refresh token belongs to
+type RefreshResult struct {
+	RefreshToken string
+	UserID       int
+	SessionID    string
+	ExpiresAt    time.Time
+}
+
+// IssueRefreshToken starts the refresh token family of a new session. The
+// family ID is the session's public ID, so revoking the family ends the session.
+func IssueRefreshToken(db *sql.DB, sessionToken, sessionID string, userID int, expiresAt time.Time) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = db.Exec(`
+		INSERT INTO refresh_tokens (token_hash, session_token, family_id, user_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		utils.HashToken(token), sessionToken, sessionID, userID, time.Now(), expiresAt)
+	if err != nil {
+		return "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return token, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same family.
+// Presenting a token that was already rotated revokes the whole family and
+// returns ErrRefreshTokenReused along with the revoked session. Tokens whose
+// session was logged out, revoked or has expired are invalid, whether or not
+// the foreign key cascade removed them.
+func RotateRefreshToken(db *sql.DB, presented string) (RefreshResult, error) {
+	var result RefreshResult
+	err := utils.RetryOnLocked(db, func() error {
+		result = RefreshResult{}
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		var id int
+		var sessionToken string
+		var usedAt sql.NullTime
+		var sessionExpiresAt time.Time
+		err = tx.QueryRow(`
+			SELECT rt.id, rt.session_token, rt.family_id, rt.user_id, rt.expires_at, rt.used_at, s.expires_at
+			FROM refresh_tokens rt
+			JOIN sessions s ON s.session_token = rt.session_token
+			WHERE rt.token_hash = ?`, utils.HashToken(presented)).
+			Scan(&id, &sessionToken, &result.SessionID, &result.UserID, &result.ExpiresAt, &usedAt, &sessionExpiresAt)
+		if err == sql.ErrNoRows {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up refresh token: %w", err)
+		}
+
+		if usedAt.Valid {
+			// Someone is replaying an old token: end the session it belongs to
+			if _, err := tx.Exec("DELETE FROM sessions WHERE session_token = ?", sessionToken); err != nil {
+				return fmt.Errorf("failed to revoke token family: %w", err)
+			}
+			if _, err := tx.Exec("DELETE FROM refresh_tokens WHERE family_id = ?", result.SessionID); err != nil {
+				return fmt.Errorf("failed to revoke token family: %w", err)
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			return ErrRefreshTokenReused
+		}
+
+		if time.Now().After(result.ExpiresAt) || time.Now().After(sessionExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		res, err := tx.Exec("UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL", time.Now(), id)
+		if err != nil {
+			return fmt.Errorf("failed to mark refresh token used: %w", err)
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return ErrInvalidRefreshToken
+		}
+
+		token, err := utils.GenerateOpaqueToken()
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO refresh_tokens (token_hash, session_token, family_id, user_id, created_at, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			utils.HashToken(token), sessionToken, result.SessionID, result.UserID, time.Now(), result.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to store refresh token: %w", err)
+		}
+		result.RefreshToken = token
+
+		return tx.Commit()
+	})
+	return result, err
+}
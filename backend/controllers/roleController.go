@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// Permission is an action beyond what every member may do with their own content
+type Permission string
+
+const (
+	PermDeleteAnyPost    Permission = "posts.delete_any"
+	PermEditAnyPost      Permission = "posts.edit_any"
+	PermLockPost         Permission = "posts.lock"
+	PermDeleteAnyComment Permission = "comments.delete_any"
+	PermSuspendUsers     Permission = "users.suspend"
+	PermManageRoles      Permission = "users.manage_roles"
+	PermViewAuditLog     Permission = "security_events.view"
+	PermImpersonate      Permission = "users.impersonate"
+	PermManageCategories Permission = "categories.manage"
+)
+
+var moderatorPermissions = []Permission{
+	PermDeleteAnyPost,
+	PermEditAnyPost,
+	PermLockPost,
+	PermDeleteAnyComment,
+	PermSuspendUsers,
+}
+
+// rolePermissions lists what each role may do. Members only act on their own content.
+var rolePermissions = map[string][]Permission{
+	models.RoleMember:    nil,
+	models.RoleModerator: moderatorPermissions,
+	models.RoleAdmin: append(append([]Permission{}, moderatorPermissions...),
+		PermManageRoles, PermViewAuditLog, PermImpersonate, PermManageCategories),
+}
+
+var (
+	ErrInvalidRole = errors.New("unknown role")
+	ErrLastAdmin   = errors.New("cannot remove the last admin")
+	ErrNotAllowed  = errors.New("not allowed")
+	ErrPostLocked  = errors.New("post is locked")
+)
+
+// roleRank orders roles so staff can only act against less privileged users
+var roleRank = map[string]int{
+	models.RoleMember:    0,
+	models.RoleModerator: 1,
+	models.RoleAdmin:     2,
+}
+
+// OutranksUser reports whether actorID's role is above targetID's
+func OutranksUser(db *sql.DB, actorID, targetID int) (bool, error) {
+	actorRole, err := GetUserRole(db, actorID)
+	if err != nil {
+		return false, err
+	}
+	targetRole, err := GetUserRole(db, targetID)
+	if err != nil {
+		return false, err
+	}
+	return roleRank[actorRole] > roleRank[targetRole], nil
+}
+
+// IsValidRole reports whether role is one of the known roles
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleHasPermission reports whether users with role may perform perm
+func RoleHasPermission(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUserRole returns the role of a user
+func GetUserRole(db *sql.DB, userID int) (string, error) {
+	var role string
+	err := db.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role)
+	return role, err
+}
+
+// UserHasPermission reports whether a user's role grants perm
+func UserHasPermission(db *sql.DB, userID int, perm Permission) (bool, error) {
+	role, err := GetUserRole(db, userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return RoleHasPermission(role, perm), nil
+}
+
+// SetUserRole changes a user's role. An admin can only be demoted while
+// another admin remains whose account is not being deleted, so there is
+// always someone left who can manage roles.
+func SetUserRole(db *sql.DB, userID int, role string) error {
+	if !IsValidRole(role) {
+		return ErrInvalidRole
+	}
+	return utils.RetryOnLocked(db, func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var current string
+		if err := tx.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&current); err != nil {
+			return err
+		}
+		if current == models.RoleAdmin && role != models.RoleAdmin {
+			var others int
+			err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE role = ? AND delete_after IS NULL AND id != ?",
+				models.RoleAdmin, userID).Scan(&others)
+			if err != nil {
+				return err
+			}
+			if others == 0 {
+				return ErrLastAdmin
+			}
+		}
+
+		if _, err := tx.Exec("UPDATE users SET role = ? WHERE id = ?", role, userID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// GetStaffUsers lists moderators and admins
+func GetStaffUsers(db *sql.DB) ([]models.User, error) {
+	rows, err := db.Query(`
+		SELECT id, nickname, email, role
+		FROM users
+		WHERE role != ?
+		ORDER BY role, nickname`, models.RoleMember)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Nickname, &user.Email, &user.Role); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// FindUserID resolves a numeric ID, nickname or email to a user ID
+func FindUserID(db *sql.DB, identifier string) (int, error) {
+	var userID int
+	err := db.QueryRow("SELECT id FROM users WHERE CAST(id AS TEXT) = ? OR nickname = ? OR email = ?",
+		identifier, identifier, identifier).Scan(&userID)
+	return userID, err
+}
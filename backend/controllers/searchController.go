@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"forum/backend/models"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 100
+	// maxSearchTerms bounds how many words of a query are looked for
+	maxSearchTerms = 10
+	// snippetRunes is about how much text the snippet of a LIKE match shows
+	snippetRunes = 160
+)
+
+// Markers put around matched words until the snippet is escaped as HTML
+const (
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+)
+
+var ErrEmptySearch = errors.New("search query is required")
+
+type SearchController struct {
+	DB *sql.DB
+	// fullText is set when the FTS5 indexes exist; otherwise search uses LIKE
+	fullText bool
+}
+
+func NewSearchController(db *sql.DB) *SearchController {
+	var triggers int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'posts_fts_insert'").
+		Scan(&triggers)
+	return &SearchController{DB: db, fullText: triggers > 0}
+}
+
+// Search finds the published posts and the comments on them that contain
+// every word of filter.Query, best matches first
+func (sc *SearchController) Search(filter models.SearchFilter) ([]models.SearchResult, error) {
+	terms := searchTerms(filter.Query)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultSearchLimit
+	}
+	if filter.Limit > maxSearchLimit {
+		filter.Limit = maxSearchLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	var parts []string
+	var args []interface{}
+	if filter.Type != models.SearchComment {
+		part, partArgs := sc.postSearch(terms, filter)
+		parts = append(parts, part)
+		args = append(args, partArgs...)
+	}
+	if filter.Type != models.SearchPost {
+		part, partArgs := sc.commentSearch(terms, filter)
+		parts = append(parts, part)
+		args = append(args, partArgs...)
+	}
+	query := strings.Join(parts, " UNION ALL ") + " ORDER BY score DESC, timestamp DESC LIMIT ? OFFSET ?"
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := sc.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var result models.SearchResult
+		var commentID sql.NullInt64
+		if err := rows.Scan(&result.Type, &result.PostID, &commentID, &result.Title, &result.Snippet,
+			&result.Author, &result.Category, &result.Timestamp, &result.Score); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		result.CommentID = int(commentID.Int64)
+		if !sc.fullText {
+			result.Snippet = likeSnippet(result.Snippet, terms)
+		}
+		result.Snippet = highlightSnippet(result.Snippet)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// postSearch selects the posts matching terms. With FTS5 they are ranked by
+// bm25, a title match counting more than one in the content; with LIKE, posts
+// with more of the words in their title come first.
+func (sc *SearchController) postSearch(terms []string, filter models.SearchFilter) (string, []interface{}) {
+	conditions := []string{"p.status = 'published'", "u.content_hidden = FALSE"}
+	var args []interface{}
+
+	columns := `'post' AS type, p.id, NULL, p.title, `
+	from := `FROM posts p JOIN users u ON u.id = p.user_id`
+	if sc.fullText {
+		columns += `snippet(posts_fts, 1, '` + snippetOpen + `', '` + snippetClose + `', '…', 24),
+			` + nicknameColumn("u") + `, p.category, p.timestamp AS timestamp, -bm25(posts_fts, 5.0, 1.0) AS score`
+		from = `FROM posts_fts JOIN posts p ON p.id = posts_fts.rowid JOIN users u ON u.id = p.user_id`
+		conditions = append(conditions, "posts_fts MATCH ?")
+		args = append(args, ftsQuery(terms))
+	} else {
+		var score []string
+		for _, term := range terms {
+			conditions = append(conditions, `(p.title LIKE ? ESCAPE '\' OR p.content LIKE ? ESCAPE '\')`)
+			args = append(args, likePattern(term), likePattern(term))
+			score = append(score, `(p.title LIKE ? ESCAPE '\')`)
+		}
+		columns += `p.content, ` + nicknameColumn("u") + `, p.category, p.timestamp AS timestamp,
+			` + strings.Join(score, " + ") + ` AS score`
+		// The score's placeholders come before the WHERE clause's
+		scoreArgs := make([]interface{}, 0, len(terms)+len(args))
+		for _, term := range terms {
+			scoreArgs = append(scoreArgs, likePattern(term))
+		}
+		args = append(scoreArgs, args...)
+	}
+
+	moreConditions, moreArgs := searchFilterConditions(filter, "p", "u")
+	conditions = append(conditions, moreConditions...)
+	args = append(args, moreArgs...)
+	return "SELECT " + columns + " " + from + " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// commentSearch selects the comments matching terms on published posts
+func (sc *SearchController) commentSearch(terms []string, filter models.SearchFilter) (string, []interface{}) {
+	conditions := []string{"p.status = 'published'", "u.content_hidden = FALSE", "cu.content_hidden = FALSE"}
+	var args []interface{}
+
+	columns := `'comment' AS type, c.post_id, c.id, p.title, `
+	from := `FROM comments c JOIN posts p ON p.id = c.post_id JOIN users u ON u.id = p.user_id JOIN users cu ON cu.id = c.user_id`
+	if sc.fullText {
+		columns += `snippet(comments_fts, 0, '` + snippetOpen + `', '` + snippetClose + `', '…', 24), ` +
+			nicknameColumn("cu") + `, p.category, c.timestamp AS timestamp, -bm25(comments_fts) AS score`
+		from = `FROM comments_fts JOIN comments c ON c.id = comments_fts.rowid
+			JOIN posts p ON p.id = c.post_id JOIN users u ON u.id = p.user_id JOIN users cu ON cu.id = c.user_id`
+		conditions = append(conditions, "comments_fts MATCH ?")
+		args = append(args, ftsQuery(terms))
+	} else {
+		for _, term := range terms {
+			conditions = append(conditions, `c.content LIKE ? ESCAPE '\'`)
+			args = append(args, likePattern(term))
+		}
+		columns += `c.content, ` + nicknameColumn("cu") + `, p.category, c.timestamp AS timestamp, 0 AS score`
+	}
+
+	moreConditions, moreArgs := searchFilterConditions(filter, "c", "cu")
+	conditions = append(conditions, moreConditions...)
+	args = append(args, moreArgs...)
+	return "SELECT " + columns + " " + from + " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// searchFilterConditions applies the filters to item, written by author. The
+// category is always that of the post p.
+func searchFilterConditions(filter models.SearchFilter, item, author string) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if filter.Category != "" {
+		conditions = append(conditions, "(',' || p.category || ',') LIKE ? ESCAPE '\\'")
+		args = append(args, "%,"+escapeLike(filter.Category)+",%")
+	}
+	if filter.Author != "" {
+		conditions = append(conditions, author+".nickname = ? COLLATE NOCASE AND "+author+".deleted_at IS NULL")
+		args = append(args, filter.Author)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, item+".timestamp >= ?")
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, item+".timestamp < ?")
+		args = append(args, filter.Until)
+	}
+	return conditions, args
+}
+
+// searchTerms splits a query into the words to look for, lower-cased and
+// without the quotes FTS5 would read as syntax
+func searchTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.Fields(strings.Map(unicode.ToLower, query)) {
+		word = strings.Trim(strings.ReplaceAll(word, `"`, ""), "*")
+		if word == "" {
+			continue
+		}
+		terms = append(terms, word)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// ftsQuery matches posts containing every term, each also as a prefix of a
+// longer word
+func ftsQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"*`
+	}
+	return strings.Join(quoted, " ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func likePattern(term string) string {
+	return "%" + escapeLike(term) + "%"
+}
+
+// likeSnippet cuts text down to the part around the first matched term and
+// marks every term in it, the way FTS5's snippet() does
+func likeSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	lower := []rune(strings.Map(unicode.ToLower, text))
+
+	first := len(runes)
+	for _, term := range terms {
+		if i := runeIndex(lower, []rune(term), 0); i >= 0 && i < first {
+			first = i
+		}
+	}
+	if first == len(runes) {
+		first = 0
+	}
+
+	start := max(first-snippetRunes/4, 0)
+	end := min(start+snippetRunes, len(runes))
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString("…")
+	}
+	for i := start; i < end; {
+		matched := 0
+		for _, term := range terms {
+			n := len([]rune(term))
+			if n > matched && i+n <= end && string(lower[i:i+n]) == term {
+				matched = n
+			}
+		}
+		if matched > 0 {
+			snippet.WriteString(snippetOpen + string(runes[i:i+matched]) + snippetClose)
+			i += matched
+			continue
+		}
+		snippet.WriteRune(runes[i])
+		i++
+	}
+	if end < len(runes) {
+		snippet.WriteString("…")
+	}
+	return snippet.String()
+}
+
+// runeIndex finds the first occurrence of sub in s at or after from, or -1
+func runeIndex(s, sub []rune, from int) int {
+	for i := from; i+len(sub) <= len(s); i++ {
+		if string(s[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// highlightSnippet escapes a snippet for HTML and turns its markers into <mark>
+func highlightSnippet(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	return strings.NewReplacer(snippetOpen, "<mark>", snippetClose, "</mark>").Replace(escaped)
+}
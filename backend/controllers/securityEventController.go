@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"forum/backend/logger"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// Security event types recorded in the audit log
+const (
+	EventAccountCreated           = "account_created"
+	EventLoginSucceeded           = "login_succeeded"
+	EventLoginFailed              = "login_failed"
+	EventLoginThrottled           = "login_throttled"
+	EventMagicLinkRequested       = "magic_link_requested"
+	EventLoginRefused             = "login_refused"
+	EventAccountLocked            = "account_locked"
+	EventTwoFactorFailed          = "two_factor_failed"
+	EventLogout                   = "logout"
+	EventPasswordChanged          = "password_changed"
+	EventPasswordChangeFailed     = "password_change_failed"
+	EventPasswordResetRequested   = "password_reset_requested"
+	EventPasswordReset            = "password_reset"
+	EventTwoFactorEnabled         = "two_factor_enabled"
+	EventTwoFactorDisabled        = "two_factor_disabled"
+	EventRecoveryCodesRegenerated = "recovery_codes_regenerated"
+	EventSessionRevoked           = "session_revoked"
+	EventRefreshTokenReused       = "refresh_token_reused"
+	EventAPITokenCreated          = "api_token_created"
+	EventAPITokenRevoked          = "api_token_revoked"
+	EventIdentityLinked           = "identity_linked"
+	EventRoleChanged              = "role_changed"
+	EventUserSuspended            = "user_suspended"
+	EventSuspensionLifted         = "suspension_lifted"
+	EventDataExportRequested      = "data_export_requested"
+	EventDataExportDownloaded     = "data_export_downloaded"
+	EventDeletionScheduled        = "account_deletion_scheduled"
+	EventDeletionCancelled        = "account_deletion_cancelled"
+	EventAccountDeleted           = "account_deleted"
+	EventImpersonationStarted     = "impersonation_started"
+	EventImpersonationEnded       = "impersonation_ended"
+	EventImpersonatedRequest      = "impersonated_request"
+	EventInvalidToken             = "invalid_token"
+	EventInvalidSession           = "invalid_session"
+)
+
+const (
+	securityEventRetention      = 180 * 24 * time.Hour
+	defaultSecurityEventLimit   = 50
+	maxSecurityEventLimit       = 200
+	maxSecurityEventFieldLength = 255
+
+	// anonymousEventWindow is how often one address gets an entry for the
+	// same kind of anonymous bad request; the ones in between are counted
+	anonymousEventWindow = 10 * time.Minute
+)
+
+// anonymousEventCount tracks the anonymous events of one type from one address
+type anonymousEventCount struct {
+	recordedAt time.Time
+	lastSeen   time.Time
+	skipped    int
+}
+
+var (
+	anonymousEvents   = map[string]*anonymousEventCount{}
+	anonymousEventsMu sync.Mutex
+)
+
+// RecordSecurityEvent adds an entry to the audit log, taking the IP address
+// and user agent from r when it is set. Events that only name an
+// identifier are attached to the account it belongs to. Failures are logged
+// rather than returned so auditing never breaks the request being audited.
+func RecordSecurityEvent(db *sql.DB, r *http.Request, event models.SecurityEvent) {
+	if r != nil {
+		event.IPAddress = utils.ClientIP(r)
+		event.UserAgent = r.UserAgent()
+	}
+	if event.UserID == 0 && event.Identifier != "" {
+		db.QueryRow("SELECT id FROM users WHERE email = ? OR nickname = ?", event.Identifier, event.Identifier).Scan(&event.UserID)
+	}
+
+	var userID sql.NullInt64
+	if event.UserID != 0 {
+		userID = sql.NullInt64{Int64: int64(event.UserID), Valid: true}
+	}
+	err := utils.RetryOnLocked(db, func() error {
+		_, err := db.Exec(`
+			INSERT INTO security_events (user_id, event_type, identifier, ip_address, user_agent, details, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			userID, event.EventType, truncate(event.Identifier), truncate(event.IPAddress),
+			truncate(event.UserAgent), truncate(event.Details), time.Now())
+		return err
+	})
+	if err != nil {
+		logger.Error("Failed to record security event %s for user %d: %v", event.EventType, event.UserID, err)
+	}
+}
+
+// RecordAnonymousSecurityEvent records an event no user is behind, such as a
+// request with a forged token. Each address gets at most one entry per event
+// type every anonymousEventWindow, and the next entry says how many were left
+// out, so a client looping over bad credentials cannot flood the audit log.
+func RecordAnonymousSecurityEvent(db *sql.DB, r *http.Request, event models.SecurityEvent) {
+	key := event.EventType + " " + utils.ClientIP(r)
+	now := time.Now()
+
+	anonymousEventsMu.Lock()
+	count, ok := anonymousEvents[key]
+	if ok && now.Sub(count.recordedAt) < anonymousEventWindow {
+		count.skipped++
+		count.lastSeen = now
+		anonymousEventsMu.Unlock()
+		return
+	}
+	if ok && count.skipped > 0 {
+		event.Details += fmt.Sprintf(" (and %d more since %s)", count.skipped, count.recordedAt.Format(time.RFC3339))
+	}
+	// Forget addresses that have gone quiet
+	for k, c := range anonymousEvents {
+		if now.Sub(c.lastSeen) > anonymousEventWindow {
+			delete(anonymousEvents, k)
+		}
+	}
+	anonymousEvents[key] = &anonymousEventCount{recordedAt: now, lastSeen: now}
+	anonymousEventsMu.Unlock()
+
+	RecordSecurityEvent(db, r, event)
+}
+
+// truncate caps client-supplied text stored in the audit log, cutting on a
+// character boundary so no character is split
+func truncate(value string) string {
+	if len(value) <= maxSecurityEventFieldLength {
+		return value
+	}
+	end := maxSecurityEventFieldLength
+	for end > 0 && !utf8.RuneStart(value[end]) {
+		end--
+	}
+	return value[:end]
+}
+
+// GetUserSecurityEvents returns a user's most recent security events, newest first
+func GetUserSecurityEvents(db *sql.DB, userID, limit int) ([]models.SecurityEvent, error) {
+	return SearchSecurityEvents(db, models.SecurityEventFilter{UserID: userID, Limit: limit})
+}
+
+// SearchSecurityEvents returns the events matching filter, newest first
+func SearchSecurityEvents(db *sql.DB, filter models.SecurityEventFilter) ([]models.SecurityEvent, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.UserID != 0 {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.EventType != "" {
+		conditions = append(conditions, "event_type = ?")
+		args = append(args, filter.EventType)
+	}
+	if filter.IPAddress != "" {
+		conditions = append(conditions, "ip_address = ?")
+		args = append(args, filter.IPAddress)
+	}
+	if filter.Identifier != "" {
+		conditions = append(conditions, "identifier = ?")
+		args = append(args, filter.Identifier)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.Until)
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultSecurityEventLimit
+	}
+	if filter.Limit > maxSecurityEventLimit {
+		filter.Limit = maxSecurityEventLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	query := `
+		SELECT id, user_id, event_type, identifier, ip_address, user_agent, details, created_at
+		FROM security_events`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.SecurityEvent{}
+	for rows.Next() {
+		var event models.SecurityEvent
+		var userID sql.NullInt64
+		var identifier, ipAddress, userAgent, details sql.NullString
+		if err := rows.Scan(&event.ID, &userID, &event.EventType, &identifier, &ipAddress,
+			&userAgent, &details, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.UserID = int(userID.Int64)
+		event.Identifier = identifier.String
+		event.IPAddress = ipAddress.String
+		event.UserAgent = userAgent.String
+		event.Details = details.String
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// cleanupSecurityEvents forgets events older than the retention period
+func cleanupSecurityEvents(tx *sql.Tx) error {
+	_, err := tx.Exec("DELETE FROM security_events WHERE created_at < ?", time.Now().Add(-securityEventRetention))
+	return err
+}
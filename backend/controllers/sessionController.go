@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"forum/backend/logger"
+	"forum/backend/models"
 	"forum/backend/utils"
 )
 
@@ -63,6 +64,24 @@ func DeleteUserSessions(db *sql.DB, userID int) error {
 	return err
 }
 
+// signOutUser ends every way a user is signed in, as part of tx. Refresh and
+// CSRF tokens are deleted along with the sessions they belong to, rather than
+// left to a foreign key cascade.
+func signOutUser(tx *sql.Tx, userID int) error {
+	for _, query := range []string{
+		"DELETE FROM refresh_tokens WHERE user_id = ?",
+		"DELETE FROM csrf_tokens WHERE session_token IN (SELECT session_token FROM sessions WHERE user_id = ?)",
+		"DELETE FROM sessions WHERE user_id = ?",
+		"DELETE FROM api_tokens WHERE user_id = ?",
+		"DELETE FROM login_challenges WHERE user_id = ?",
+	} {
+		if _, err := tx.Exec(query, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Cleanup expired sessions and mark associated users as offline
 func CleanupExpiredSessions(ctx context.Context, db *sql.DB) {
 	// Run cleanup immediately when the function is called
@@ -124,12 +143,72 @@ func cleanupExpiredSessionsAndMarkOffline(db *sql.DB) {
 		return
 	}
 
+	// Delete login challenges that were never completed
+	_, err = tx.Exec("DELETE FROM login_challenges WHERE expires_at < ?", time.Now())
+	if err != nil {
+		log.Printf("Failed to clean up expired login challenges: %v\n", err)
+		return
+	}
+
+	// Delete OIDC sign-ins that were never finished
+	_, err = tx.Exec("DELETE FROM oidc_states WHERE expires_at < ?", time.Now())
+	if err != nil {
+		log.Printf("Failed to clean up expired OIDC states: %v\n", err)
+		return
+	}
+
+	// Forget old failed login attempts
+	if err := cleanupLoginThrottles(tx); err != nil {
+		log.Printf("Failed to clean up login throttles: %v\n", err)
+		return
+	}
+
+	// Show the content of users whose suspension has run out
+	if err := cleanupExpiredSuspensions(tx); err != nil {
+		log.Printf("Failed to clean up expired suspensions: %v\n", err)
+		return
+	}
+
+	// Forget old audit log entries
+	if err := cleanupSecurityEvents(tx); err != nil {
+		log.Printf("Failed to clean up security events: %v\n", err)
+		return
+	}
+
+	// Delete refresh tokens whose session has gone
+	_, err = tx.Exec("DELETE FROM refresh_tokens WHERE expires_at < ?", time.Now())
+	if err != nil {
+		log.Printf("Failed to clean up expired refresh tokens: %v\n", err)
+		return
+	}
+
+	// Delete personal access tokens past their expiry
+	_, err = tx.Exec("DELETE FROM api_tokens WHERE expires_at < ?", time.Now())
+	if err != nil {
+		log.Printf("Failed to clean up expired API tokens: %v\n", err)
+		return
+	}
+
 	// Commit the transaction
 	if err := tx.Commit(); err != nil {
 		log.Printf("Failed to commit transaction: %v\n", err)
 		return
 	}
 
+	// Delete accounts whose grace period is over
+	if purged, err := PurgeDeletedAccounts(db); err != nil {
+		log.Printf("Failed to purge deleted accounts: %v\n", err)
+	} else if purged > 0 {
+		logger.Info("Deleted %d accounts after their grace period", purged)
+	}
+
+	// Forget mail that was sent or given up on
+	if pruned, err := PruneMailOutbox(db); err != nil {
+		log.Printf("Failed to prune mail outbox: %v\n", err)
+	} else if pruned > 0 {
+		logger.Info("Pruned %d old messages from the mail outbox", pruned)
+	}
+
 	var foundError bool
 	// Mark users as offline
 	if len(userIDs) > 0 {
@@ -145,8 +224,124 @@ func cleanupExpiredSessionsAndMarkOffline(db *sql.DB) {
 	}
 }
 
-func AddSessionWithToken(db *sql.DB, sessionToken string, userID int, expiresAt time.Time, jwtToken string) error {
-	_, err := db.Exec("INSERT INTO sessions (session_token, user_id, expires_at, jwt_token) VALUES (?, ?, ?, ?)",
-		sessionToken, userID, expiresAt, jwtToken)
+func AddSessionWithToken(db *sql.DB, sessionToken, jwtToken string, session models.Session) error {
+	_, err := db.Exec(`
+		INSERT INTO sessions (session_token, user_id, expires_at, jwt_token, id, user_agent, ip_address, created_at, last_used_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sessionToken, session.UserID, session.ExpiresAt, jwtToken, session.ID,
+		session.UserAgent, session.IPAddress, session.CreatedAt, session.CreatedAt)
+	return err
+}
+
+// GetSessionIDByToken returns the public ID of the session behind a session cookie
+func GetSessionIDByToken(db *sql.DB, sessionToken string) (string, error) {
+	var sessionID string
+	err := db.QueryRow("SELECT id FROM sessions WHERE session_token = ?", sessionToken).Scan(&sessionID)
+	return sessionID, err
+}
+
+// IsLiveSessionID reports whether userID still has the session with public
+// ID sessionID and it has not expired. Access tokens name their session this
+// way, for the places that must not outlive it.
+func IsLiveSessionID(db *sql.DB, sessionID string, userID int) (bool, error) {
+	var live bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sessions WHERE id = ? AND user_id = ? AND expires_at > ?)",
+		sessionID, userID, time.Now()).Scan(&live)
+	return live, err
+}
+
+// TouchSession records that a session was used, at most once a minute
+func TouchSession(db *sql.DB, sessionToken string) error {
+	now := time.Now()
+	_, err := db.Exec(`
+		UPDATE sessions SET last_used_at = ?
+		WHERE session_token = ? AND (last_used_at IS NULL OR last_used_at < ?)`,
+		now, sessionToken, now.Add(-1*time.Minute))
+	return err
+}
+
+// GetUserSessions lists the active sessions of a user, most recently used first
+func GetUserSessions(db *sql.DB, userID int) ([]models.Session, error) {
+	rows, err := db.Query(`
+		SELECT id, user_agent, ip_address, created_at, last_used_at, expires_at
+		FROM sessions
+		WHERE user_id = ? AND expires_at >= ?
+		ORDER BY COALESCE(last_used_at, created_at) DESC`, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		var userAgent, ipAddress sql.NullString
+		var createdAt, lastUsedAt sql.NullTime
+		if err := rows.Scan(&session.ID, &userAgent, &ipAddress, &createdAt, &lastUsedAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		session.UserID = userID
+		session.UserAgent = userAgent.String
+		session.IPAddress = ipAddress.String
+		session.CreatedAt = createdAt.Time
+		session.LastUsedAt = lastUsedAt.Time
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeUserSession deletes one session of a user by its public ID
+func RevokeUserSession(db *sql.DB, userID int, sessionID string) error {
+	result, err := db.Exec("DELETE FROM sessions WHERE id = ? AND user_id = ?", sessionID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeOtherUserSessions deletes every session of a user except keepSessionID
+// and returns the IDs of the revoked sessions
+func RevokeOtherUserSessions(db *sql.DB, userID int, keepSessionID string) ([]string, error) {
+	var revoked []string
+	err := utils.RetryOnLocked(db, func() error {
+		revoked = nil
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		rows, err := tx.Query("SELECT id FROM sessions WHERE user_id = ? AND id != ?", userID, keepSessionID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			revoked = append(revoked, id)
+		}
+		rows.Close()
+
+		if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ? AND id != ?", userID, keepSessionID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	return revoked, err
+}
+
+// SetSessionJWT records the latest access token issued for a session
+func SetSessionJWT(db *sql.DB, sessionID, jwtToken string) error {
+	_, err := db.Exec("UPDATE sessions SET jwt_token = ? WHERE id = ?", jwtToken, sessionID)
 	return err
 }
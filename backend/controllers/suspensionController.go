@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// Content policies decide what happens to a suspended user's posts and comments
+const (
+	ContentPolicyKeep = "keep"
+	ContentPolicyHide = "hide"
+)
+
+const maxSuspensionReasonLength = 500
+
+var (
+	ErrInvalidContentPolicy = errors.New("content policy must be keep or hide")
+	ErrSuspensionReason     = errors.New("a reason of at most 500 characters is required")
+	ErrSuspensionDuration   = errors.New("suspension duration cannot be negative")
+	ErrCannotSuspend        = errors.New("you can only suspend users with a lower role")
+	ErrNotSuspended         = errors.New("user is not suspended")
+)
+
+// AccountSuspendedError is returned when a suspended user tries to sign in
+type AccountSuspendedError struct {
+	Suspension models.Suspension
+}
+
+func (e *AccountSuspendedError) Error() string {
+	if e.Suspension.Permanent() {
+		return "account is banned"
+	}
+	return "account is suspended until " + e.Suspension.ExpiresAt.Format(time.RFC3339)
+}
+
+// SuspendUser suspends targetID for duration, or permanently when duration is
+// zero. Any running suspension is replaced. The user is signed out of every
+// session and their personal access tokens are revoked; closing their
+// WebSocket connections is left to the caller.
+func SuspendUser(db *sql.DB, actorID, targetID int, reason string, duration time.Duration, policy string) (models.Suspension, error) {
+	var suspension models.Suspension
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len(reason) > maxSuspensionReasonLength {
+		return suspension, ErrSuspensionReason
+	}
+	if duration < 0 {
+		return suspension, ErrSuspensionDuration
+	}
+	if policy == "" {
+		policy = ContentPolicyKeep
+	}
+	if policy != ContentPolicyKeep && policy != ContentPolicyHide {
+		return suspension, ErrInvalidContentPolicy
+	}
+
+	// actorID 0 is the command line, which may suspend anyone
+	if actorID != 0 {
+		outranks, err := OutranksUser(db, actorID, targetID)
+		if err != nil {
+			return suspension, err
+		}
+		if !outranks {
+			return suspension, ErrCannotSuspend
+		}
+	} else if _, err := GetUserRole(db, targetID); err != nil {
+		return suspension, err
+	}
+
+	now := time.Now()
+	suspension = models.Suspension{
+		UserID:        targetID,
+		Reason:        reason,
+		ContentPolicy: policy,
+		CreatedAt:     now,
+	}
+	var suspendedBy sql.NullInt64
+	if actorID != 0 {
+		suspension.SuspendedBy = &actorID
+		suspendedBy = sql.NullInt64{Int64: int64(actorID), Valid: true}
+	}
+	var expiresAt sql.NullTime
+	if duration > 0 {
+		expires := now.Add(duration)
+		suspension.ExpiresAt = &expires
+		expiresAt = sql.NullTime{Time: expires, Valid: true}
+	}
+
+	err := utils.RetryOnLocked(db, func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+			UPDATE user_suspensions SET lifted_at = ?, lifted_by = ?
+			WHERE user_id = ? AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`,
+			now, suspendedBy, targetID, now); err != nil {
+			return err
+		}
+		result, err := tx.Exec(`
+			INSERT INTO user_suspensions (user_id, suspended_by, reason, content_policy, created_at, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			targetID, suspendedBy, reason, policy, now, expiresAt)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		suspension.ID = int(id)
+
+		if _, err := tx.Exec("UPDATE users SET content_hidden = ? WHERE id = ?", policy == ContentPolicyHide, targetID); err != nil {
+			return err
+		}
+
+		if err := signOutUser(tx, targetID); err != nil {
+			return fmt.Errorf("failed to sign out suspended user: %w", err)
+		}
+		return tx.Commit()
+	})
+	return suspension, err
+}
+
+// LiftSuspension ends a user's running suspension early and shows their
+// content again
+func LiftSuspension(db *sql.DB, actorID, targetID int) error {
+	var liftedBy sql.NullInt64
+	if actorID != 0 {
+		liftedBy = sql.NullInt64{Int64: int64(actorID), Valid: true}
+	}
+	return utils.RetryOnLocked(db, func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		now := time.Now()
+		result, err := tx.Exec(`
+			UPDATE user_suspensions SET lifted_at = ?, lifted_by = ?
+			WHERE user_id = ? AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`,
+			now, liftedBy, targetID, now)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrNotSuspended
+		}
+
+		if _, err := tx.Exec("UPDATE users SET content_hidden = FALSE WHERE id = ?", targetID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// GetActiveSuspension returns the suspension currently keeping a user out,
+// or nil if there is none
+func GetActiveSuspension(db *sql.DB, userID int) (*models.Suspension, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, suspended_by, reason, content_policy, created_at, expires_at, lifted_at, lifted_by
+		FROM user_suspensions
+		WHERE user_id = ? AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY created_at DESC
+		LIMIT 1`, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	suspensions, err := scanSuspensions(rows)
+	if err != nil || len(suspensions) == 0 {
+		return nil, err
+	}
+	return &suspensions[0], nil
+}
+
+// GetUserSuspensions lists every suspension a user has had, newest first
+func GetUserSuspensions(db *sql.DB, userID int) ([]models.Suspension, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, suspended_by, reason, content_policy, created_at, expires_at, lifted_at, lifted_by
+		FROM user_suspensions
+		WHERE user_id = ?
+		ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSuspensions(rows)
+}
+
+func scanSuspensions(rows *sql.Rows) ([]models.Suspension, error) {
+	defer rows.Close()
+
+	suspensions := []models.Suspension{}
+	for rows.Next() {
+		var s models.Suspension
+		var suspendedBy, liftedBy sql.NullInt64
+		var expiresAt, liftedAt sql.NullTime
+		if err := rows.Scan(&s.ID, &s.UserID, &suspendedBy, &s.Reason, &s.ContentPolicy,
+			&s.CreatedAt, &expiresAt, &liftedAt, &liftedBy); err != nil {
+			return nil, err
+		}
+		if suspendedBy.Valid {
+			id := int(suspendedBy.Int64)
+			s.SuspendedBy = &id
+		}
+		if liftedBy.Valid {
+			id := int(liftedBy.Int64)
+			s.LiftedBy = &id
+		}
+		if expiresAt.Valid {
+			s.ExpiresAt = &expiresAt.Time
+		}
+		if liftedAt.Valid {
+			s.LiftedAt = &liftedAt.Time
+		}
+		suspensions = append(suspensions, s)
+	}
+	return suspensions, rows.Err()
+}
+
+// cleanupExpiredSuspensions shows the content of users whose suspension has run out
+func cleanupExpiredSuspensions(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		UPDATE users SET content_hidden = FALSE
+		WHERE content_hidden AND NOT EXISTS (
+			SELECT 1 FROM user_suspensions s
+			WHERE s.user_id = users.id AND s.content_policy = ? AND s.lifted_at IS NULL
+			  AND (s.expires_at IS NULL OR s.expires_at > ?)
+		)`, ContentPolicyHide, time.Now())
+	return err
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+const (
+	maxPostTags     = 10
+	defaultTagLimit = 20
+	maxTagLimit     = 100
+)
+
+var (
+	ErrInvalidTag  = errors.New("tags may only contain letters, digits and underscores, and need a letter")
+	ErrTooManyTags = errors.New("a post can have at most 10 tags")
+)
+
+// tagMatch is true when post alias carries the tag given as its placeholder
+func tagMatch(alias string) string {
+	return "EXISTS (SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = " +
+		alias + ".id AND t.name = ?)"
+}
+
+// postTagsColumn selects the comma separated tags of post alias
+func postTagsColumn(alias string) string {
+	return "(SELECT group_concat(t.name) FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = " +
+		alias + ".id)"
+}
+
+// splitTags turns the column postTagsColumn selects into sorted tags
+func splitTags(column sql.NullString) []string {
+	if !column.Valid || column.String == "" {
+		return []string{}
+	}
+	tags := strings.Split(column.String, ",")
+	sort.Strings(tags)
+	return tags
+}
+
+// ParseTags reads tags given by hand, separated by commas or spaces
+func ParseTags(value string) ([]string, error) {
+	var tags []string
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		tag, ok := utils.NormalizeTag(field)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidTag, field)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// ExplicitTags returns the tags of post that don't come from hashtags in its
+// content, the ones to keep when the content is edited
+func ExplicitTags(post models.Post) []string {
+	hashtags := map[string]bool{}
+	for _, tag := range utils.ParseHashtags(post.Content) {
+		hashtags[tag] = true
+	}
+	var tags []string
+	for _, tag := range post.Tags {
+		if !hashtags[tag] {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// postTags combines the hashtags in a post's content with post.Tags
+func postTags(post models.Post) ([]string, error) {
+	tags := utils.ParseHashtags(post.Content)
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		seen[tag] = true
+	}
+	for _, tag := range post.Tags {
+		tag, ok := utils.NormalizeTag(tag)
+		if !ok {
+			return nil, ErrInvalidTag
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > maxPostTags {
+		return nil, ErrTooManyTags
+	}
+	return tags, nil
+}
+
+// setPostTags makes tags the tags of a post, adding any tag not seen before
+func setPostTags(tx *sql.Tx, postID int, tags []string) error {
+	if _, err := tx.Exec("DELETE FROM post_tags WHERE post_id = ?", postID); err != nil {
+		return fmt.Errorf("failed to clear tags: %w", err)
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", tag); err != nil {
+			return fmt.Errorf("failed to add tag %s: %w", tag, err)
+		}
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO post_tags (post_id, tag_id)
+			SELECT ?, id FROM tags WHERE name = ?`, postID, tag)
+		if err != nil {
+			return fmt.Errorf("failed to tag post: %w", err)
+		}
+	}
+	return nil
+}
+
+// BackfillPostTags tags the posts written before tags existed with the
+// hashtags in their content, keeping the first maxPostTags. Only untagged
+// posts with a # in them are read, so once it has run there is next to
+// nothing left to do. It returns how many posts it tagged.
+func BackfillPostTags(db *sql.DB) (int, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.content FROM posts p
+		WHERE p.content LIKE '%#%' AND NOT EXISTS (SELECT 1 FROM post_tags pt WHERE pt.post_id = p.id)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find untagged posts: %w", err)
+	}
+	untagged := map[int][]string{}
+	for rows.Next() {
+		var postID int
+		var content string
+		if err := rows.Scan(&postID, &content); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan post: %w", err)
+		}
+		if tags := utils.ParseHashtags(content); len(tags) > 0 {
+			if len(tags) > maxPostTags {
+				tags = tags[:maxPostTags]
+			}
+			untagged[postID] = tags
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find untagged posts: %w", err)
+	}
+	if len(untagged) == 0 {
+		return 0, nil
+	}
+
+	err = utils.RetryOnLocked(db, func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		for postID, tags := range untagged {
+			if err := setPostTags(tx, postID, tags); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(untagged), nil
+}
+
+// GetTagPosts lists the published posts carrying tag
+func (pc *PostController) GetTagPosts(tag, cursor string, limit int) ([]models.Post, string, error) {
+	return pc.GetFeed(models.Feed{Tag: tag}, cursor, limit)
+}
+
+// GetTags lists the tags of published posts, most used first. With a prefix
+// only the tags starting with it are listed, for autocompletion.
+func GetTags(db *sql.DB, prefix string, limit int) ([]models.Tag, error) {
+	if limit <= 0 {
+		limit = defaultTagLimit
+	}
+	if limit > maxTagLimit {
+		limit = maxTagLimit
+	}
+
+	query := `
+		SELECT t.name, COUNT(*) AS uses
+		FROM tags t
+		JOIN post_tags pt ON pt.tag_id = t.id
+		JOIN posts p ON p.id = pt.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE p.status = 'published' AND u.content_hidden = FALSE`
+	args := []interface{}{}
+	if prefix != "" {
+		query += ` AND t.name LIKE ? ESCAPE '\'`
+		args = append(args, escapeLike(prefix)+"%")
+	}
+	query += " GROUP BY t.id ORDER BY uses DESC, t.name LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.Name, &tag.PostCount); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/utils"
+)
+
+// recordingMailer keeps sent messages in memory
+type recordingMailer struct {
+	sent []utils.MailMessage
+	fail bool
+}
+
+func (m *recordingMailer) Send(msg utils.MailMessage) error {
+	if m.fail {
+		return errors.New("smtp unavailable")
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestUserTokens_SingleUseAndExpiry(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	id, _ := utils.CreateTestUser(t, testDB.DB)
+	userID := int(id)
+
+	token, err := controllers.CreateUserToken(testDB.DB, userID, controllers.TokenPurposePasswordReset, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateUserToken() error = %v", err)
+	}
+
+	// A token only works for the purpose it was issued for
+	if _, err := controllers.ConsumeUserToken(testDB.DB, token, controllers.TokenPurposeEmailVerify); !errors.Is(err, controllers.ErrInvalidUserToken) {
+		t.Errorf("ConsumeUserToken(wrong purpose) error = %v, want %v", err, controllers.ErrInvalidUserToken)
+	}
+
+	got, err := controllers.ConsumeUserToken(testDB.DB, token, controllers.TokenPurposePasswordReset)
+	if err != nil || got != userID {
+		t.Fatalf("ConsumeUserToken() = %d, %v, want %d", got, err, userID)
+	}
+	if _, err := controllers.ConsumeUserToken(testDB.DB, token, controllers.TokenPurposePasswordReset); !errors.Is(err, controllers.ErrInvalidUserToken) {
+		t.Errorf("ConsumeUserToken(used) error = %v, want %v", err, controllers.ErrInvalidUserToken)
+	}
+
+	// Expired tokens are rejected
+	expired, err := controllers.CreateUserToken(testDB.DB, userID, controllers.TokenPurposeEmailVerify, -time.Minute)
+	if err != nil {
+		t.Fatalf("CreateUserToken() error = %v", err)
+	}
+	if _, err := controllers.ConsumeUserToken(testDB.DB, expired, controllers.TokenPurposeEmailVerify); !errors.Is(err, controllers.ErrInvalidUserToken) {
+		t.Errorf("ConsumeUserToken(expired) error = %v, want %v", err, controllers.ErrInvalidUserToken)
+	}
+
+	// Issuing a new token replaces the previous unused one
+	first, _ := controllers.CreateUserToken(testDB.DB, userID, controllers.TokenPurposeEmailVerify, time.Hour)
+	second, _ := controllers.CreateUserToken(testDB.DB, userID, controllers.TokenPurposeEmailVerify, time.Hour)
+	if _, err := controllers.ConsumeUserToken(testDB.DB, first, controllers.TokenPurposeEmailVerify); !errors.Is(err, controllers.ErrInvalidUserToken) {
+		t.Errorf("ConsumeUserToken(replaced) error = %v, want %v", err, controllers.ErrInvalidUserToken)
+	}
+	if _, err := controllers.ConsumeUserToken(testDB.DB, second, controllers.TokenPurposeEmailVerify); err != nil {
+		t.Errorf("ConsumeUserToken(latest) error = %v", err)
+	}
+}
+
+func TestAuthController_PasswordResetAndVerification(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	ac := controllers.NewAuthController(testDB.DB)
+	id, user := utils.CreateTestUser(t, testDB.DB)
+	userID := int(id)
+
+	// Unknown addresses succeed silently and send nothing
+	if err := ac.RequestPasswordReset("nobody@example.com"); err != nil {
+		t.Fatalf("RequestPasswordReset(unknown) error = %v", err)
+	}
+	if err := ac.RequestPasswordReset(user.Email); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v", err)
+	}
+
+	// Failed deliveries stay queued for a retry
+	mailer := &recordingMailer{fail: true}
+	if sent, err := controllers.DeliverQueuedMail(testDB.DB, mailer); err != nil || sent != 0 {
+		t.Fatalf("DeliverQueuedMail(failing) = %d, %v, want 0, nil", sent, err)
+	}
+	testDB.DB.Exec("UPDATE mail_outbox SET next_attempt_at = ?", time.Now().Add(-time.Second))
+	mailer.fail = false
+	if sent, err := controllers.DeliverQueuedMail(testDB.DB, mailer); err != nil || sent != 1 {
+		t.Fatalf("DeliverQueuedMail() = %d, %v, want 1, nil", sent, err)
+	}
+	if mailer.sent[0].To != user.Email {
+		t.Errorf("reset mail sent to %q, want %q", mailer.sent[0].To, user.Email)
+	}
+
+	// The link in the email resets the password once
+	body := mailer.sent[0].Body
+	start := strings.Index(body, "token=")
+	if start < 0 {
+		t.Fatalf("reset mail has no token link: %q", body)
+	}
+	token := strings.Fields(body[start+len("token="):])[0]
+	if _, err := ac.ResetPassword(token, "NewPassword123!"); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if _, err := ac.ResetPassword(token, "OtherPassword123!"); !errors.Is(err, controllers.ErrInvalidUserToken) {
+		t.Errorf("ResetPassword(reused) error = %v, want %v", err, controllers.ErrInvalidUserToken)
+	}
+
+	// Resetting through the emailed link also confirms the address
+	verified, err := controllers.IsEmailVerified(testDB.DB, userID)
+	if err != nil || !verified {
+		t.Errorf("IsEmailVerified() = %v, %v, want true", verified, err)
+	}
+	if err := ac.SendVerificationEmail(userID); !errors.Is(err, controllers.ErrEmailAlreadyVerified) {
+		t.Errorf("SendVerificationEmail(verified) error = %v, want %v", err, controllers.ErrEmailAlreadyVerified)
+	}
+}
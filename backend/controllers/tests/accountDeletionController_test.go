@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+func TestAccountDeletion_ScheduleAndCancel(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	id, _ := utils.CreateTestUser(t, testDB.DB)
+	userID := int(id)
+	if _, err := testDB.DB.Exec(`
+		INSERT INTO sessions (session_token, user_id, expires_at, id) VALUES ('tok', ?, ?, 'sid')`,
+		userID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+	if _, err := controllers.IssueRefreshToken(testDB.DB, "tok", "sid", userID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("IssueRefreshToken() error = %v", err)
+	}
+	if err := controllers.AddCSRFToken(testDB.DB, "tok", "csrf", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("AddCSRFToken() error = %v", err)
+	}
+
+	deleteAfter, err := controllers.ScheduleAccountDeletion(testDB.DB, userID)
+	if err != nil {
+		t.Fatalf("ScheduleAccountDeletion() error = %v", err)
+	}
+	if time.Until(deleteAfter) < controllers.AccountDeletionGracePeriod-time.Minute {
+		t.Errorf("deleteAfter = %v, want about %v from now", deleteAfter, controllers.AccountDeletionGracePeriod)
+	}
+	for _, table := range []string{"sessions", "refresh_tokens"} {
+		var count int
+		testDB.DB.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE user_id = ?", userID).Scan(&count)
+		if count != 0 {
+			t.Errorf("%d %s left after scheduling deletion", count, table)
+		}
+	}
+	if _, _, err := controllers.GetCSRFToken(testDB.DB, "tok"); err == nil {
+		t.Error("CSRF token left after scheduling deletion")
+	}
+
+	// Nothing is removed before the grace period is over
+	if purged, err := controllers.PurgeDeletedAccounts(testDB.DB); err != nil || purged != 0 {
+		t.Errorf("PurgeDeletedAccounts() = %d, %v, want 0", purged, err)
+	}
+
+	cancelled, err := controllers.CancelAccountDeletion(testDB.DB, userID)
+	if err != nil || !cancelled {
+		t.Fatalf("CancelAccountDeletion() = %v, %v, want true", cancelled, err)
+	}
+	if cancelled, _ := controllers.CancelAccountDeletion(testDB.DB, userID); cancelled {
+		t.Error("CancelAccountDeletion() cancelled twice")
+	}
+
+	// The last admin has to hand over first
+	admin := insertRoleUser(t, testDB.DB, "admin", models.RoleAdmin)
+	if _, err := controllers.ScheduleAccountDeletion(testDB.DB, admin); !errors.Is(err, controllers.ErrLastAdmin) {
+		t.Errorf("ScheduleAccountDeletion(last admin) error = %v, want ErrLastAdmin", err)
+	}
+}
+
+func TestAccountDeletion_PurgeKeepsSharedContent(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+	chdirTemp(t)
+
+	id, _ := utils.CreateTestUser(t, testDB.DB)
+	userID := int(id)
+	other := insertRoleUser(t, testDB.DB, "friend", models.RoleMember)
+
+	if err := os.MkdirAll(filepath.Join("uploads", "post_images"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	photo := filepath.Join("uploads", "post_images", "photo.jpg")
+	if err := os.WriteFile(photo, []byte("jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	postID := insertRolePost(t, testDB.DB, userID)
+	for _, stmt := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{"INSERT INTO post_images (post_id, image_url) VALUES (?, '/uploads/post_images/photo.jpg')", []interface{}{postID}},
+		{"INSERT INTO comments (post_id, user_id, author, content) VALUES (?, ?, 'friend', 'nice post')", []interface{}{postID, other}},
+		{"INSERT INTO comments (post_id, user_id, author, content) VALUES (?, ?, 'testuser', 'thanks')", []interface{}{postID, userID}},
+		{"INSERT INTO followers (follower_id, following_id) VALUES (?, ?)", []interface{}{other, userID}},
+		{"INSERT INTO user_about (user_id, bio) VALUES (?, 'my bio')", []interface{}{userID}},
+	} {
+		if _, err := testDB.DB.Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatalf("%s: %v", stmt.query, err)
+		}
+	}
+	draft := filepath.Join("uploads", "post_images", "draft.jpg")
+	if err := os.WriteFile(draft, []byte("jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	publishAt := time.Now().Add(time.Hour)
+	scheduledID, err := controllers.NewPostController(testDB.DB).InsertPost(models.Post{
+		UserID: userID, Author: "testuser", Title: "Coming soon", Content: "Not yet", Category: "General",
+		Timestamp: time.Now(), Status: models.PostScheduled, PublishAt: &publishAt,
+		Images: []string{"/uploads/post_images/draft.jpg"}, Tags: []string{"soon"},
+	})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+
+	mc := controllers.NewMessageController(testDB.DB)
+	if _, _, err := mc.SendMessageController(userID, other, "hello friend"); err != nil {
+		t.Fatalf("SendMessageController() error = %v", err)
+	}
+
+	// Mail to the account and audit log entries about it
+	if err := controllers.QueueMail(testDB.DB, utils.MailMessage{To: "test@example.com", Subject: "Reset", Body: "token=secret"}); err != nil {
+		t.Fatalf("QueueMail() error = %v", err)
+	}
+	if err := controllers.QueueMail(testDB.DB, utils.MailMessage{To: "friend@example.com", Subject: "Hi", Body: "Hello"}); err != nil {
+		t.Fatalf("QueueMail() error = %v", err)
+	}
+	login := httptest.NewRequest("POST", "/api/login", nil)
+	login.Header.Set("User-Agent", "TestBrowser/1.0")
+	controllers.RecordSecurityEvent(testDB.DB, login, models.SecurityEvent{Identifier: "test@example.com", EventType: controllers.EventLoginFailed})
+	controllers.RecordSecurityEvent(testDB.DB, login, models.SecurityEvent{UserID: other, Identifier: "testuser", EventType: controllers.EventImpersonationStarted})
+
+	if _, err := controllers.ScheduleAccountDeletion(testDB.DB, userID); err != nil {
+		t.Fatalf("ScheduleAccountDeletion() error = %v", err)
+	}
+	if _, err := testDB.DB.Exec("UPDATE users SET delete_after = ? WHERE id = ?", time.Now().Add(-time.Minute), userID); err != nil {
+		t.Fatal(err)
+	}
+	if purged, err := controllers.PurgeDeletedAccounts(testDB.DB); err != nil || purged != 1 {
+		t.Fatalf("PurgeDeletedAccounts() = %d, %v, want 1", purged, err)
+	}
+
+	// The post, the replies and the conversation are still there, anonymised
+	post, err := controllers.NewPostController(testDB.DB).GetPostByID(postID)
+	if err != nil {
+		t.Fatalf("GetPostByID() error = %v", err)
+	}
+	if post.User.Nickname != controllers.DeletedUserName {
+		t.Errorf("post author = %q, want %q", post.User.Nickname, controllers.DeletedUserName)
+	}
+	var comments int
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM comments WHERE post_id = ?", postID).Scan(&comments)
+	if comments != 2 {
+		t.Errorf("%d comments left, want 2", comments)
+	}
+	messages, err := mc.GetMessagesInConversation(other, userID, 0, 10)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("GetMessagesInConversation() = %v, %v, want one message", messages, err)
+	}
+	if messages[0].User.Nickname != controllers.DeletedUserName {
+		t.Errorf("conversation partner = %q, want %q", messages[0].User.Nickname, controllers.DeletedUserName)
+	}
+
+	// Personal data and uploads are gone
+	var email *string
+	testDB.DB.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email)
+	if email != nil {
+		t.Errorf("email = %q, want NULL", *email)
+	}
+	for _, check := range []struct {
+		query string
+		arg   int
+	}{
+		{"SELECT COUNT(*) FROM user_about WHERE user_id = ?", userID},
+		{"SELECT COUNT(*) FROM followers WHERE following_id = ?", userID},
+		{"SELECT COUNT(*) FROM post_images WHERE post_id = ?", postID},
+		// Unpublished posts go entirely
+		{"SELECT COUNT(*) FROM posts WHERE id = ?", scheduledID},
+		{"SELECT COUNT(*) FROM post_images WHERE post_id = ?", scheduledID},
+		{"SELECT COUNT(*) FROM post_tags WHERE post_id = ?", scheduledID},
+	} {
+		var count int
+		testDB.DB.QueryRow(check.query, check.arg).Scan(&count)
+		if count != 0 {
+			t.Errorf("%s = %d, want 0", check.query, count)
+		}
+	}
+	var mail []string
+	rows, err := testDB.DB.Query("SELECT recipient FROM mail_outbox")
+	if err != nil {
+		t.Fatalf("Failed to read outbox: %v", err)
+	}
+	for rows.Next() {
+		var recipient string
+		rows.Scan(&recipient)
+		mail = append(mail, recipient)
+	}
+	rows.Close()
+	if len(mail) != 1 || mail[0] != "friend@example.com" {
+		t.Errorf("outbox after purge = %v, want only the friend's mail", mail)
+	}
+	// Only the note that the account was deleted is left of its audit log,
+	// and the friend's entries no longer name it
+	var events, naming int
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM security_events WHERE user_id = ? AND event_type != ?", userID, controllers.EventAccountDeleted).Scan(&events)
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM security_events WHERE identifier IN ('testuser', 'test@example.com')").Scan(&naming)
+	if events != 0 || naming != 0 {
+		t.Errorf("after purge %d audit entries of the user and %d naming them are left, want 0", events, naming)
+	}
+	friendEvents, err := controllers.GetUserSecurityEvents(testDB.DB, other, 10)
+	if err != nil || len(friendEvents) != 1 {
+		t.Errorf("friend's audit log = %v, %v, want their one entry kept", friendEvents, err)
+	}
+
+	for _, file := range []string{photo, draft} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("uploaded %s still exists: %v", file, err)
+		}
+	}
+
+	users, err := controllers.NewUsersController(testDB.DB).SearchUsers("deleted", other, 1, 10)
+	if err != nil {
+		t.Fatalf("SearchUsers() error = %v", err)
+	}
+	if len(users) != 0 {
+		t.Errorf("deleted account shows up in search: %v", users)
+	}
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/utils"
+)
+
+func TestAPIToken_CreateAuthenticateRevoke(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	id, _ := utils.CreateTestUser(t, testDB.DB)
+	userID := int(id)
+
+	token, info, err := controllers.CreateAPIToken(testDB.DB, userID, "ci bot", []string{"posts:write", "posts:read", "posts:read"}, 0)
+	if err != nil {
+		t.Fatalf("CreateAPIToken() error = %v", err)
+	}
+	if !strings.HasPrefix(token, controllers.APITokenPrefix) || !strings.HasPrefix(token, info.Prefix) {
+		t.Errorf("token %q does not start with %q", token, info.Prefix)
+	}
+	if len(info.Scopes) != 2 {
+		t.Errorf("scopes = %v, want duplicates removed", info.Scopes)
+	}
+
+	// Only the hash is stored
+	var stored int
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM api_tokens WHERE token_hash = ?", token).Scan(&stored)
+	if stored != 0 {
+		t.Error("token stored in plain text")
+	}
+
+	gotUser, scopes, err := controllers.AuthenticateAPIToken(testDB.DB, token)
+	if err != nil || gotUser != userID {
+		t.Fatalf("AuthenticateAPIToken() = %d, %v, want %d", gotUser, err, userID)
+	}
+	if !controllers.HasScope(scopes, "posts:write") || controllers.HasScope(scopes, "messages:read") {
+		t.Errorf("scopes = %v", scopes)
+	}
+
+	tokens, err := controllers.GetAPITokens(testDB.DB, userID)
+	if err != nil || len(tokens) != 1 || tokens[0].LastUsedAt == nil {
+		t.Fatalf("GetAPITokens() = %+v, %v", tokens, err)
+	}
+
+	if err := controllers.RevokeAPIToken(testDB.DB, userID+1, info.ID); err == nil {
+		t.Error("another user revoked the token")
+	}
+	if err := controllers.RevokeAPIToken(testDB.DB, userID, info.ID); err != nil {
+		t.Fatalf("RevokeAPIToken() error = %v", err)
+	}
+	if _, _, err := controllers.AuthenticateAPIToken(testDB.DB, token); err != controllers.ErrInvalidAPIToken {
+		t.Errorf("revoked token error = %v, want ErrInvalidAPIToken", err)
+	}
+}
+
+func TestAPIToken_Validation(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	id, _ := utils.CreateTestUser(t, testDB.DB)
+
+	tests := []struct {
+		name     string
+		token    string
+		scopes   []string
+		lifetime time.Duration
+		wantErr  error
+	}{
+		{"empty name", " ", []string{"posts:read"}, 0, controllers.ErrInvalidTokenName},
+		{"unknown scope", "bot", []string{"admin:all"}, 0, controllers.ErrInvalidTokenScope},
+		{"no scopes", "bot", nil, 0, controllers.ErrInvalidTokenScope},
+		{"too long", "bot", []string{"posts:read"}, 400 * 24 * time.Hour, controllers.ErrInvalidTokenExpiry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := controllers.CreateAPIToken(testDB.DB, int(id), tt.token, tt.scopes, tt.lifetime)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateAPIToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Expired tokens are rejected
+	token, info, err := controllers.CreateAPIToken(testDB.DB, int(id), "old", []string{"posts:read"}, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("CreateAPIToken() error = %v", err)
+	}
+	testDB.DB.Exec("UPDATE api_tokens SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Minute), info.ID)
+	if _, _, err := controllers.AuthenticateAPIToken(testDB.DB, token); err != controllers.ErrInvalidAPIToken {
+		t.Errorf("expired token error = %v, want ErrInvalidAPIToken", err)
+	}
+}
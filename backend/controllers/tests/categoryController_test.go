@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+func TestCategories_Manage(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	categories, err := controllers.GetCategories(testDB.DB, false)
+	if err != nil {
+		t.Fatalf("GetCategories() error = %v", err)
+	}
+	if len(categories) != 6 || categories[0].Slug != "technology" {
+		t.Fatalf("GetCategories() = %+v, want the six default categories", categories)
+	}
+
+	category, err := controllers.CreateCategory(testDB.DB, models.Category{Name: "  Home & Garden ", Position: 7})
+	if err != nil {
+		t.Fatalf("CreateCategory() error = %v", err)
+	}
+	if category.Slug != "home-garden" || category.Name != "Home & Garden" {
+		t.Errorf("CreateCategory() = %+v, want slug home-garden", category)
+	}
+	if _, err := controllers.CreateCategory(testDB.DB, models.Category{Name: "home & garden", Slug: "garden"}); !errors.Is(err, controllers.ErrCategoryExists) {
+		t.Errorf("CreateCategory(same name) error = %v, want ErrCategoryExists", err)
+	}
+	if _, err := controllers.CreateCategory(testDB.DB, models.Category{Name: "Bad", Slug: "Bad Slug"}); !errors.Is(err, controllers.ErrCategorySlug) {
+		t.Errorf("CreateCategory(bad slug) error = %v, want ErrCategorySlug", err)
+	}
+	if _, err := controllers.CreateCategory(testDB.DB, models.Category{Name: " "}); !errors.Is(err, controllers.ErrCategoryName) {
+		t.Errorf("CreateCategory(no name) error = %v, want ErrCategoryName", err)
+	}
+
+	name := "Gardening"
+	category, err = controllers.UpdateCategory(testDB.DB, "home-garden", models.CategoryUpdate{Name: &name})
+	if err != nil {
+		t.Fatalf("UpdateCategory() error = %v", err)
+	}
+	if category.Name != "Gardening" || category.Slug != "home-garden" || category.Position != 7 {
+		t.Errorf("UpdateCategory() = %+v, want only the name changed", category)
+	}
+	if _, err := controllers.UpdateCategory(testDB.DB, "missing", models.CategoryUpdate{Name: &name}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateCategory(missing) error = %v, want sql.ErrNoRows", err)
+	}
+
+	// Posts are filed under slugs and counted per category
+	author := insertRoleUser(t, testDB.DB, "author", models.RoleMember)
+	pc := controllers.NewPostController(testDB.DB)
+	slugs, err := controllers.ResolveCategories(testDB.DB, "gardening, Design,home-garden", "")
+	if err != nil {
+		t.Fatalf("ResolveCategories() error = %v", err)
+	}
+	if slugs != "home-garden,design" {
+		t.Errorf("ResolveCategories() = %q, want home-garden,design", slugs)
+	}
+	postID := insertSearchPost(t, testDB.DB, author, "Tomatoes", "Grow them", slugs)
+	insertSearchPost(t, testDB.DB, author, "Fonts", "Pick two", "design")
+
+	category, err = controllers.GetCategory(testDB.DB, "home-garden")
+	if err != nil || category.PostCount != 1 {
+		t.Errorf("GetCategory() = %+v, %v, want 1 post", category, err)
+	}
+	posts, _, err := pc.GetCategoryPosts("design", "", 10)
+	if err != nil || len(posts) != 2 {
+		t.Errorf("GetCategoryPosts(design) = %d posts, %v, want 2", len(posts), err)
+	}
+	posts, _, err = pc.GetCategoryPosts("home-garden", "", 10)
+	if err != nil || len(posts) != 1 || posts[0].ID != postID {
+		t.Errorf("GetCategoryPosts(home-garden) = %d posts, %v, want the tomato post", len(posts), err)
+	}
+
+	if _, err := controllers.ResolveCategories(testDB.DB, "desgin", ""); !errors.Is(err, controllers.ErrUnknownCategory) {
+		t.Errorf("ResolveCategories(typo) error = %v, want ErrUnknownCategory", err)
+	}
+
+	// Archived categories stay on their posts but can't be picked anew
+	archived := true
+	if _, err := controllers.UpdateCategory(testDB.DB, "home-garden", models.CategoryUpdate{Archived: &archived}); err != nil {
+		t.Fatalf("UpdateCategory(archive) error = %v", err)
+	}
+	if _, err := controllers.ResolveCategories(testDB.DB, "home-garden", "design"); !errors.Is(err, controllers.ErrCategoryArchived) {
+		t.Errorf("ResolveCategories(archived) error = %v, want ErrCategoryArchived", err)
+	}
+	if _, err := controllers.ResolveCategories(testDB.DB, "home-garden", "home-garden,design"); err != nil {
+		t.Errorf("ResolveCategories(archived, kept) error = %v", err)
+	}
+	if categories, _ := controllers.GetCategories(testDB.DB, false); len(categories) != 6 {
+		t.Errorf("GetCategories() = %d categories, want the archived one left out", len(categories))
+	}
+
+	if err := controllers.DeleteCategory(testDB.DB, "home-garden"); !errors.Is(err, controllers.ErrCategoryInUse) {
+		t.Errorf("DeleteCategory(in use) error = %v, want ErrCategoryInUse", err)
+	}
+	if err := controllers.DeleteCategory(testDB.DB, "gaming"); err != nil {
+		t.Errorf("DeleteCategory(unused) error = %v", err)
+	}
+	if err := controllers.DeleteCategory(testDB.DB, "gaming"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteCategory(deleted) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Technology":        "technology",
+		"  Home & Garden  ": "home-garden",
+		"C++ / Go":          "c-go",
+		"Café Talk":         "café-talk",
+		"---":               "",
+	}
+	for name, want := range tests {
+		if got := controllers.Slugify(name); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
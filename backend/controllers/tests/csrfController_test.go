@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// csrfRequest builds a POST carrying the session cookie, an optional csrf_token
+// cookie and an optional X-CSRF-Token header
+func csrfRequest(sessionToken, cookieToken, headerToken string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+	r.AddCookie(&http.Cookie{Name: "session_token", Value: sessionToken})
+	if cookieToken != "" {
+		r.AddCookie(&http.Cookie{Name: controllers.CSRFCookieName, Value: cookieToken})
+	}
+	if headerToken != "" {
+		r.Header.Set("X-CSRF-Token", headerToken)
+	}
+	return r
+}
+
+func createCSRFTestSession(t *testing.T, testDB *utils.TestDB, sessionToken string) {
+	id, _ := utils.CreateTestUser(t, testDB.DB)
+	err := controllers.AddSessionWithToken(testDB.DB, sessionToken, "jwt", models.Session{
+		ID:        sessionToken + "-id",
+		UserID:    int(id),
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+}
+
+func TestCSRF_SessionMode(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	t.Setenv("CSRF_MODE", "")
+	createCSRFTestSession(t, testDB, "session-a")
+
+	token, err := controllers.IssueCSRFToken(testDB.DB, "session-a")
+	if err != nil {
+		t.Fatalf("IssueCSRFToken() error = %v", err)
+	}
+	if again, _ := controllers.IssueCSRFToken(testDB.DB, "session-a"); again != token {
+		t.Errorf("IssueCSRFToken() returned a new token while the old one is valid")
+	}
+
+	if !controllers.VerifyCSRFToken(testDB.DB, csrfRequest("session-a", "", token)) {
+		t.Error("valid token rejected")
+	}
+	if controllers.VerifyCSRFToken(testDB.DB, csrfRequest("session-a", "", "forged")) {
+		t.Error("forged token accepted")
+	}
+	// A forged request does not invalidate the real token
+	if !controllers.VerifyCSRFToken(testDB.DB, csrfRequest("session-a", "", token)) {
+		t.Error("valid token rejected after a forged attempt")
+	}
+
+	rotated, err := controllers.RotateCSRFToken(testDB.DB, "session-a")
+	if err != nil {
+		t.Fatalf("RotateCSRFToken() error = %v", err)
+	}
+	if rotated == token || controllers.VerifyCSRFToken(testDB.DB, csrfRequest("session-a", "", token)) {
+		t.Error("old token still valid after rotation")
+	}
+	if !controllers.VerifyCSRFToken(testDB.DB, csrfRequest("session-a", "", rotated)) {
+		t.Error("rotated token rejected")
+	}
+}
+
+func TestCSRF_DoubleSubmitMode(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	t.Setenv("CSRF_MODE", controllers.CSRFModeDoubleSubmit)
+	createCSRFTestSession(t, testDB, "session-b")
+
+	token, err := controllers.IssueCSRFToken(testDB.DB, "session-b")
+	if err != nil {
+		t.Fatalf("IssueCSRFToken() error = %v", err)
+	}
+	var stored int
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM csrf_tokens").Scan(&stored)
+	if stored != 0 {
+		t.Errorf("double submit mode stored %d tokens", stored)
+	}
+
+	tests := []struct {
+		name    string
+		session string
+		cookie  string
+		header  string
+		want    bool
+	}{
+		{"cookie and header match", "session-b", token, token, true},
+		{"header missing", "session-b", token, "", false},
+		{"cookie missing", "session-b", "", token, false},
+		{"cookie and header differ", "session-b", token, token + "x", false},
+		{"attacker's own signed value", "session-b", "abc.def", "abc.def", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := controllers.VerifyCSRFToken(testDB.DB, csrfRequest(tt.session, tt.cookie, tt.header))
+			if got != tt.want {
+				t.Errorf("VerifyCSRFToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
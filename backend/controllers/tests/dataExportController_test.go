@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// chdirTemp runs the rest of the test from an empty directory, since uploads
+// and exports are stored relative to the working directory
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd() error = %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir() error = %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+func readExport(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("OpenReader() error = %v", err)
+	}
+	defer zr.Close()
+
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("Read(%s) error = %v", f.Name, err)
+		}
+		files[f.Name] = data
+	}
+	return files
+}
+
+func TestDataExport_ContainsUserData(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+	dir := chdirTemp(t)
+
+	id, _ := utils.CreateTestUser(t, testDB.DB)
+	userID := int(id)
+	other := insertRoleUser(t, testDB.DB, "friend", models.RoleMember)
+
+	if err := os.MkdirAll(filepath.Join("uploads", "post_images"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("uploads", "post_images", "photo.jpg"), []byte("jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("secret.txt", []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	postID := insertRolePost(t, testDB.DB, userID)
+	for _, stmt := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{"UPDATE users SET avatar = '/uploads/profile_images/gone.png' WHERE id = ?", []interface{}{userID}},
+		{"INSERT INTO post_images (post_id, image_url) VALUES (?, '/uploads/post_images/photo.jpg')", []interface{}{postID}},
+		{"INSERT INTO post_images (post_id, image_url) VALUES (?, '/uploads/../secret.txt')", []interface{}{postID}},
+		{"INSERT INTO comments (post_id, user_id, author, content) VALUES (?, ?, 'testuser', 'my comment')", []interface{}{postID, userID}},
+		{"INSERT INTO reactions (entity_type, entity_id, user_id, reaction) VALUES ('post', ?, ?, 'like')", []interface{}{postID, userID}},
+		{"INSERT INTO followers (follower_id, following_id) VALUES (?, ?)", []interface{}{other, userID}},
+		{"INSERT INTO messages (sender_id, recipient_id, content) VALUES (?, ?, 'hello friend')", []interface{}{userID, other}},
+	} {
+		if _, err := testDB.DB.Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatalf("%s: %v", stmt.query, err)
+		}
+	}
+
+	// A small account is exported straight away
+	export, err := controllers.RequestDataExport(testDB.DB, userID)
+	if err != nil {
+		t.Fatalf("RequestDataExport() error = %v", err)
+	}
+	if export.Status != models.DataExportReady || export.ExpiresAt == nil {
+		t.Fatalf("export = %+v, want ready with an expiry", export)
+	}
+	if _, err := controllers.GetDownloadableDataExport(testDB.DB, other, export.ID); err == nil {
+		t.Error("another user can download the export")
+	}
+	export, err = controllers.GetDownloadableDataExport(testDB.DB, userID, export.ID)
+	if err != nil {
+		t.Fatalf("GetDownloadableDataExport() error = %v", err)
+	}
+
+	files := readExport(t, filepath.Join(dir, export.FilePath))
+	for _, name := range []string{"profile.json", "posts.json", "comments.json", "reactions.json",
+		"followers.json", "messages.json", "notifications.json", "manifest.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("export has no %s", name)
+		}
+	}
+	if string(files["uploads/post_images/photo.jpg"]) != "jpeg" {
+		t.Error("uploaded post image is missing from the export")
+	}
+	for name := range files {
+		if name == "secret.txt" || filepath.Base(name) == "secret.txt" {
+			t.Errorf("file outside uploads was exported as %s", name)
+		}
+	}
+
+	var profile struct {
+		User map[string]interface{} `json:"user"`
+	}
+	if err := json.Unmarshal(files["profile.json"], &profile); err != nil {
+		t.Fatalf("profile.json: %v", err)
+	}
+	if profile.User["email"] != "test@example.com" {
+		t.Errorf("profile user = %v", profile.User)
+	}
+	if _, ok := profile.User["password"]; ok {
+		t.Error("password hash was exported")
+	}
+
+	var posts []map[string]interface{}
+	if err := json.Unmarshal(files["posts.json"], &posts); err != nil {
+		t.Fatalf("posts.json: %v", err)
+	}
+	if len(posts) != 1 || len(posts[0]["images"].([]interface{})) != 2 {
+		t.Errorf("posts = %v, want one post with two images", posts)
+	}
+
+	var manifest struct {
+		Missing []string `json:"missing_files"`
+	}
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		t.Fatalf("manifest.json: %v", err)
+	}
+	if len(manifest.Missing) != 1 || manifest.Missing[0] != "uploads/profile_images/gone.png" {
+		t.Errorf("missing files = %v", manifest.Missing)
+	}
+
+	// Exports are rate limited
+	if _, err := controllers.RequestDataExport(testDB.DB, userID); !errors.Is(err, controllers.ErrDataExportTooSoon) {
+		t.Errorf("second RequestDataExport() error = %v, want ErrDataExportTooSoon", err)
+	}
+}
+
+func TestDataExport_BackgroundAndExpiry(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+	chdirTemp(t)
+
+	id, user := utils.CreateTestUser(t, testDB.DB)
+	userID := int(id)
+
+	// Large accounts are left for the background task
+	result, err := testDB.DB.Exec("INSERT INTO data_exports (user_id, status, created_at) VALUES (?, ?, ?)",
+		userID, models.DataExportPending, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to insert export: %v", err)
+	}
+	exportID, _ := result.LastInsertId()
+
+	if _, err := controllers.RequestDataExport(testDB.DB, userID); !errors.Is(err, controllers.ErrDataExportInProgress) {
+		t.Errorf("RequestDataExport() error = %v, want ErrDataExportInProgress", err)
+	}
+	if _, err := controllers.GetDownloadableDataExport(testDB.DB, userID, int(exportID)); !errors.Is(err, controllers.ErrDataExportNotReady) {
+		t.Errorf("GetDownloadableDataExport() error = %v, want ErrDataExportNotReady", err)
+	}
+
+	built, err := controllers.RunPendingDataExports(testDB.DB)
+	if err != nil || built != 1 {
+		t.Fatalf("RunPendingDataExports() = %d, %v, want 1", built, err)
+	}
+	export, err := controllers.GetDownloadableDataExport(testDB.DB, userID, int(exportID))
+	if err != nil {
+		t.Fatalf("GetDownloadableDataExport() error = %v", err)
+	}
+	if export.SizeBytes == 0 {
+		t.Error("export size was not recorded")
+	}
+
+	mailer := &recordingMailer{}
+	if _, err := controllers.DeliverQueuedMail(testDB.DB, mailer); err != nil {
+		t.Fatalf("DeliverQueuedMail() error = %v", err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].To != user.Email {
+		t.Errorf("sent = %v, want one mail to %s", mailer.sent, user.Email)
+	}
+
+	// Expired archives can no longer be downloaded
+	if _, err := testDB.DB.Exec("UPDATE data_exports SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Minute), exportID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := controllers.GetDownloadableDataExport(testDB.DB, userID, int(exportID)); !errors.Is(err, controllers.ErrDataExportNotReady) {
+		t.Errorf("expired GetDownloadableDataExport() error = %v, want ErrDataExportNotReady", err)
+	}
+	exports, err := controllers.GetDataExports(testDB.DB, userID)
+	if err != nil || len(exports) != 0 {
+		t.Errorf("GetDataExports() = %v, %v, want none", exports, err)
+	}
+}
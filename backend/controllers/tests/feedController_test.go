@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// agePost backdates a post and gives it likes
+func agePost(t *testing.T, db *sql.DB, postID int, age time.Duration, likes int) {
+	if _, err := db.Exec("UPDATE posts SET timestamp = ? WHERE id = ?", time.Now().Add(-age), postID); err != nil {
+		t.Fatalf("Failed to age post: %v", err)
+	}
+	reactToPost(t, db, postID, "like", likes)
+}
+
+// reactToPost leaves reaction on a post from count new users
+func reactToPost(t *testing.T, db *sql.DB, postID int, reaction string, count int) {
+	for i := 0; i < count; i++ {
+		userID := insertRoleUser(t, db, fmt.Sprintf("%s%d_%d", reaction, postID, i), models.RoleMember)
+		_, err := db.Exec("INSERT INTO reactions (entity_type, entity_id, user_id, reaction) VALUES ('post', ?, ?, ?)", postID, userID, reaction)
+		if err != nil {
+			t.Fatalf("Failed to react: %v", err)
+		}
+	}
+}
+
+func feedIDs(posts []models.Post) []int {
+	ids := []int{}
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+	return ids
+}
+
+func TestGetFeed(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	viewer := insertRoleUser(t, testDB.DB, "viewer", models.RoleMember)
+	alice := insertRoleUser(t, testDB.DB, "alice", models.RoleMember)
+	bob := insertRoleUser(t, testDB.DB, "bob", models.RoleMember)
+	if _, err := testDB.DB.Exec("INSERT INTO followers (follower_id, following_id) VALUES (?, ?)", viewer, alice); err != nil {
+		t.Fatalf("Failed to follow: %v", err)
+	}
+
+	ancient := insertSearchPost(t, testDB.DB, alice, "Ancient", "Liked long ago", "design")
+	agePost(t, testDB.DB, ancient, 10*24*time.Hour, 50)
+	yesterday := insertSearchPost(t, testDB.DB, alice, "Yesterday", "Liked yesterday", "design")
+	agePost(t, testDB.DB, yesterday, 30*time.Hour, 20)
+	discussed := insertSearchPost(t, testDB.DB, bob, "Discussed", "Liked and talked about", "gaming")
+	agePost(t, testDB.DB, discussed, 2*time.Hour, 5)
+	for i := 0; i < 2; i++ {
+		_, err := testDB.DB.Exec("INSERT INTO comments (post_id, user_id, author, content) VALUES (?, ?, 'viewer', 'Agreed')", discussed, viewer)
+		if err != nil {
+			t.Fatalf("Failed to comment: %v", err)
+		}
+	}
+	fresh := insertSearchPost(t, testDB.DB, bob, "Fresh", "Just posted", "gaming")
+	// Dislikes count against a post; without them it would be second hottest
+	reactToPost(t, testDB.DB, fresh, "dislike", 2)
+
+	pc := controllers.NewPostController(testDB.DB)
+	tests := []struct {
+		name string
+		feed models.Feed
+		want []int
+	}{
+		{"new", models.Feed{}, []int{fresh, discussed, yesterday, ancient}},
+		{"following", models.Feed{Mode: models.FeedFollowing, ViewerID: viewer}, []int{yesterday, ancient}},
+		{"following nobody", models.Feed{Mode: models.FeedFollowing, ViewerID: bob}, []int{}},
+		{"hot", models.Feed{Mode: models.FeedHot}, []int{discussed, yesterday, ancient, fresh}},
+		{"top of the week", models.Feed{Mode: models.FeedTop}, []int{yesterday, discussed, fresh}},
+		{"top of the day", models.Feed{Mode: models.FeedTop, Window: "day"}, []int{discussed, fresh}},
+		{"top of all time", models.Feed{Mode: models.FeedTop, Window: "all"}, []int{ancient, yesterday, discussed, fresh}},
+		{"hot in a category", models.Feed{Mode: models.FeedHot, Category: "design"}, []int{yesterday, ancient}},
+	}
+	for _, tt := range tests {
+		posts, _, err := pc.GetFeed(tt.feed, "", 10)
+		if err != nil {
+			t.Errorf("GetFeed(%s) error = %v", tt.name, err)
+			continue
+		}
+		if got := feedIDs(posts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetFeed(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+
+		// Paging one post at a time walks the same feed
+		paged := []int{}
+		cursor := ""
+		for page := 0; page < 10; page++ {
+			posts, next, err := pc.GetFeed(tt.feed, cursor, 1)
+			if err != nil {
+				t.Fatalf("GetFeed(%s) page %d error = %v", tt.name, page, err)
+			}
+			paged = append(paged, feedIDs(posts)...)
+			if cursor = next; cursor == "" {
+				break
+			}
+		}
+		if !reflect.DeepEqual(paged, tt.want) {
+			t.Errorf("GetFeed(%s) paged = %v, want %v", tt.name, paged, tt.want)
+		}
+	}
+
+	// Feeds count comments and carry images but leave the comments out
+	if _, err := testDB.DB.Exec("INSERT INTO post_images (post_id, image_url) VALUES (?, 'a.png'), (?, 'b.png')", discussed, discussed); err != nil {
+		t.Fatalf("Failed to add images: %v", err)
+	}
+	posts, _, err := pc.GetFeed(models.Feed{Mode: models.FeedHot}, "", 1)
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("GetFeed(hot) = %d posts, %v, want 1", len(posts), err)
+	}
+	if post := posts[0]; post.CommentCount != 2 || post.Comments != nil || !reflect.DeepEqual(post.Images, []string{"a.png", "b.png"}) {
+		t.Errorf("GetFeed(hot) post = %d comments counted, %d loaded, images %v, want 2, none, both images",
+			post.CommentCount, len(post.Comments), post.Images)
+	}
+
+	_, next, _ := pc.GetFeed(models.Feed{}, "", 1)
+	if _, _, err := pc.GetFeed(models.Feed{Mode: models.FeedHot}, next, 1); !errors.Is(err, controllers.ErrInvalidCursor) {
+		t.Errorf("GetFeed(hot, cursor of new) error = %v, want ErrInvalidCursor", err)
+	}
+	if _, _, err := pc.GetFeed(models.Feed{}, "not-a-cursor", 1); !errors.Is(err, controllers.ErrInvalidCursor) {
+		t.Errorf("GetFeed(garbage cursor) error = %v, want ErrInvalidCursor", err)
+	}
+
+	if _, _, err := pc.GetFeed(models.Feed{Mode: "best"}, "", 10); !errors.Is(err, controllers.ErrInvalidFeed) {
+		t.Errorf("GetFeed(best) error = %v, want ErrInvalidFeed", err)
+	}
+	if _, _, err := pc.GetFeed(models.Feed{Mode: models.FeedTop, Window: "year"}, "", 10); !errors.Is(err, controllers.ErrInvalidWindow) {
+		t.Errorf("GetFeed(top, year) error = %v, want ErrInvalidWindow", err)
+	}
+}
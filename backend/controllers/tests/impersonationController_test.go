@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+func TestImpersonation_StartAndEnd(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	admin := insertRoleUser(t, testDB.DB, "admin", models.RoleAdmin)
+	otherAdmin := insertRoleUser(t, testDB.DB, "admin2", models.RoleAdmin)
+	member := insertRoleUser(t, testDB.DB, "member", models.RoleMember)
+	if _, err := testDB.DB.Exec(`
+		INSERT INTO sessions (session_token, user_id, expires_at, id) VALUES ('admin-tok', ?, ?, 'admin-sid')`,
+		admin, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+
+	if _, _, err := controllers.StartImpersonation(testDB.DB, admin, "admin-tok", member, " ", "", ""); !errors.Is(err, controllers.ErrImpersonationReason) {
+		t.Errorf("StartImpersonation(no reason) error = %v, want ErrImpersonationReason", err)
+	}
+	if _, _, err := controllers.StartImpersonation(testDB.DB, admin, "admin-tok", otherAdmin, "ticket 42", "", ""); !errors.Is(err, controllers.ErrCannotImpersonate) {
+		t.Errorf("StartImpersonation(admin) error = %v, want ErrCannotImpersonate", err)
+	}
+
+	sessionToken, impersonation, err := controllers.StartImpersonation(testDB.DB, admin, "admin-tok", member, "ticket 42", "", "")
+	if err != nil {
+		t.Fatalf("StartImpersonation() error = %v", err)
+	}
+	if userID, valid := controllers.IsValidSession(testDB.DB, sessionToken); !valid || userID != member {
+		t.Errorf("IsValidSession() = %d, %v, want a session of user %d", userID, valid, member)
+	}
+	if time.Until(impersonation.ExpiresAt) > controllers.ImpersonationTTL {
+		t.Errorf("impersonation expires at %v, more than %v from now", impersonation.ExpiresAt, controllers.ImpersonationTTL)
+	}
+
+	active, err := controllers.GetSessionImpersonation(testDB.DB, sessionToken)
+	if err != nil || active == nil {
+		t.Fatalf("GetSessionImpersonation() = %v, %v, want the impersonation", active, err)
+	}
+	if active.AdminNickname != "admin" || active.UserID != member {
+		t.Errorf("impersonation = %+v, want admin acting as member", active)
+	}
+	if own, err := controllers.GetSessionImpersonation(testDB.DB, "admin-tok"); err != nil || own != nil {
+		t.Errorf("GetSessionImpersonation(own session) = %v, %v, want nil", own, err)
+	}
+
+	if byID, err := controllers.GetImpersonationBySessionID(testDB.DB, impersonation.SessionID); err != nil || byID == nil {
+		t.Errorf("GetImpersonationBySessionID() = %v, %v, want the impersonation", byID, err)
+	}
+	if own, err := controllers.GetImpersonationBySessionID(testDB.DB, "admin-sid"); err != nil || own != nil {
+		t.Errorf("GetImpersonationBySessionID(own session) = %v, %v, want nil", own, err)
+	}
+
+	ended, err := controllers.EndImpersonation(testDB.DB, sessionToken)
+	if err != nil {
+		t.Fatalf("EndImpersonation() error = %v", err)
+	}
+	if ended.AdminSessionToken != "admin-tok" {
+		t.Errorf("AdminSessionToken = %q, want the admin's session", ended.AdminSessionToken)
+	}
+	if _, valid := controllers.IsValidSession(testDB.DB, sessionToken); valid {
+		t.Error("impersonation session is still valid after it ended")
+	}
+	// A leftover access token of the impersonation must not pass as the user's own
+	if _, err := controllers.GetImpersonationBySessionID(testDB.DB, impersonation.SessionID); !errors.Is(err, controllers.ErrSessionEnded) {
+		t.Errorf("GetImpersonationBySessionID(ended) error = %v, want ErrSessionEnded", err)
+	}
+	if _, err := controllers.GetImpersonationBySessionID(testDB.DB, "no-such-session"); !errors.Is(err, controllers.ErrSessionEnded) {
+		t.Errorf("GetImpersonationBySessionID(unknown) error = %v, want ErrSessionEnded", err)
+	}
+	if _, err := controllers.EndImpersonation(testDB.DB, sessionToken); !errors.Is(err, controllers.ErrNotImpersonating) {
+		t.Errorf("second EndImpersonation() error = %v, want ErrNotImpersonating", err)
+	}
+
+	impersonations, err := controllers.GetImpersonations(testDB.DB, 0)
+	if err != nil || len(impersonations) != 1 {
+		t.Fatalf("GetImpersonations() = %v, %v, want one", impersonations, err)
+	}
+	if impersonations[0].EndedAt == nil || impersonations[0].Reason != "ticket 42" {
+		t.Errorf("impersonation = %+v, want ended with its reason", impersonations[0])
+	}
+}
+
+func TestImpersonation_Allows(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{"GET", "/api/posts", true},
+		{"POST", "/comments", true},
+		{"GET", "/messages/conversation", true},
+		{"POST", "/messages/send", false},
+		{"GET", "/messages/reactions", true},
+		{"POST", "/messages/reactions", false},
+		{"PUT", "/api/users/password", false},
+		{"POST", "/api/2fa/disable", false},
+		{"GET", "/api/tokens", true},
+		{"POST", "/api/tokens", false},
+		{"GET", "/api/admin/security-events", false},
+		{"GET", "/api/account/export/download", false},
+		{"DELETE", "/api/impersonation", true},
+	}
+	for _, tt := range tests {
+		if got := controllers.ImpersonationAllows(tt.method, tt.path); got != tt.want {
+			t.Errorf("ImpersonationAllows(%s %s) = %v, want %v", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
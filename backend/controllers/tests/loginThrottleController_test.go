@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/utils"
+)
+
+func TestLoginThrottle_BackoffAndLockout(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	id, user := utils.CreateTestUser(t, testDB.DB)
+
+	// The first failures are free
+	for i := 0; i < 3; i++ {
+		result, err := controllers.RecordLoginFailure(testDB.DB, user.Nickname, "10.0.0.1")
+		if err != nil {
+			t.Fatalf("RecordLoginFailure() error = %v", err)
+		}
+		if result.RetryAfter != 0 {
+			t.Fatalf("failure %d: RetryAfter = %v, want 0", i+1, result.RetryAfter)
+		}
+	}
+
+	// Then each failure doubles the wait, whichever identifier is used
+	var previous time.Duration
+	for i := 4; i < 10; i++ {
+		identifier := user.Nickname
+		if i%2 == 0 {
+			identifier = user.Email
+		}
+		result, err := controllers.RecordLoginFailure(testDB.DB, identifier, "10.0.0.1")
+		if err != nil {
+			t.Fatalf("RecordLoginFailure() error = %v", err)
+		}
+		if result.RetryAfter <= previous {
+			t.Errorf("failure %d: RetryAfter = %v, want more than %v", i, result.RetryAfter, previous)
+		}
+		if result.LockedUserID != 0 {
+			t.Errorf("failure %d locked the account too early", i)
+		}
+		previous = result.RetryAfter
+	}
+
+	wait, err := controllers.CheckLoginAllowed(testDB.DB, user.Email, "10.0.0.2")
+	if err != nil || wait <= 0 {
+		t.Errorf("CheckLoginAllowed() = %v, %v, want a wait from another address", wait, err)
+	}
+
+	// The tenth failure locks the account and names it for notification
+	result, err := controllers.RecordLoginFailure(testDB.DB, user.Nickname, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("RecordLoginFailure() error = %v", err)
+	}
+	if result.LockedUserID != int(id) || result.RetryAfter < 15*time.Minute {
+		t.Errorf("RecordLoginFailure() = %+v, want user %d locked for 15m", result, id)
+	}
+
+	// A successful login clears the account, but not the address
+	if err := controllers.RecordLoginSuccess(testDB.DB, int(id)); err != nil {
+		t.Fatalf("RecordLoginSuccess() error = %v", err)
+	}
+	if wait, _ := controllers.CheckLoginAllowed(testDB.DB, user.Email, "10.0.0.2"); wait != 0 {
+		t.Errorf("CheckLoginAllowed() after success = %v, want 0", wait)
+	}
+	if wait, _ := controllers.CheckLoginAllowed(testDB.DB, "someone-else", "10.0.0.1"); wait != 0 {
+		t.Errorf("CheckLoginAllowed(other account) = %v, want 0 before the address limit", wait)
+	}
+}
+
+func TestLoginThrottle_SecondFactor(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	id, user := utils.CreateTestUser(t, testDB.DB)
+	userID := int(id)
+
+	challenge, err := controllers.CreateLoginChallenge(testDB.DB, userID)
+	if err != nil {
+		t.Fatalf("CreateLoginChallenge() error = %v", err)
+	}
+	if got, err := controllers.LoginChallengeUser(testDB.DB, challenge); err != nil || got != userID {
+		t.Errorf("LoginChallengeUser() = %d, %v, want %d", got, err, userID)
+	}
+	if _, err := controllers.LoginChallengeUser(testDB.DB, "not-a-challenge"); !errors.Is(err, controllers.ErrInvalidLoginChallenge) {
+		t.Errorf("LoginChallengeUser(unknown) error = %v, want ErrInvalidLoginChallenge", err)
+	}
+
+	// Wrong codes back off the account across challenges and addresses, and
+	// hold back the password step too
+	for i := 0; i < 4; i++ {
+		if _, err := controllers.RecordTwoFactorFailure(testDB.DB, userID, "10.0.0.1"); err != nil {
+			t.Fatalf("RecordTwoFactorFailure() error = %v", err)
+		}
+	}
+	if wait, err := controllers.CheckTwoFactorAllowed(testDB.DB, userID, "10.0.0.2"); err != nil || wait <= 0 {
+		t.Errorf("CheckTwoFactorAllowed() = %v, %v, want a wait from another address", wait, err)
+	}
+	if wait, _ := controllers.CheckLoginAllowed(testDB.DB, user.Nickname, "10.0.0.2"); wait <= 0 {
+		t.Errorf("CheckLoginAllowed() after wrong codes = %v, want a wait", wait)
+	}
+
+	// Invalid challenges only count against the address, past its free attempts
+	for i := 0; i < 11; i++ {
+		if _, err := controllers.RecordTwoFactorFailure(testDB.DB, 0, "10.0.0.3"); err != nil {
+			t.Fatalf("RecordTwoFactorFailure() error = %v", err)
+		}
+	}
+	if wait, _ := controllers.CheckLoginAllowed(testDB.DB, "someone-else", "10.0.0.3"); wait <= 0 {
+		t.Errorf("CheckLoginAllowed() from an address guessing challenges = %v, want a wait", wait)
+	}
+
+	if err := controllers.RecordLoginSuccess(testDB.DB, userID); err != nil {
+		t.Fatalf("RecordLoginSuccess() error = %v", err)
+	}
+	if wait, _ := controllers.CheckTwoFactorAllowed(testDB.DB, userID, "10.0.0.2"); wait != 0 {
+		t.Errorf("CheckTwoFactorAllowed() after a completed login = %v, want 0", wait)
+	}
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/utils"
+)
+
+// magicLinkToken delivers queued mail and returns the token of the last sign-in link
+func magicLinkToken(t *testing.T, mailer *recordingMailer, testDB *utils.TestDB) string {
+	t.Helper()
+	if _, err := controllers.DeliverQueuedMail(testDB.DB, mailer); err != nil {
+		t.Fatalf("DeliverQueuedMail() error = %v", err)
+	}
+	if len(mailer.sent) == 0 {
+		t.Fatal("no sign-in mail was sent")
+	}
+	body := mailer.sent[len(mailer.sent)-1].Body
+	start := strings.Index(body, "/magicLoginPage?token=")
+	if start < 0 {
+		t.Fatalf("mail has no sign-in link: %q", body)
+	}
+	token, err := url.QueryUnescape(strings.Fields(body[start+len("/magicLoginPage?token="):])[0])
+	if err != nil {
+		t.Fatalf("bad token in link: %v", err)
+	}
+	return token
+}
+
+func TestMagicLink_SignInOnce(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	ac := controllers.NewAuthController(testDB.DB)
+	id, user := utils.CreateTestUser(t, testDB.DB)
+	mailer := &recordingMailer{}
+
+	// Unknown addresses succeed silently and send nothing
+	if err := ac.RequestMagicLink("nobody@example.com"); err != nil {
+		t.Fatalf("RequestMagicLink(unknown) error = %v", err)
+	}
+	if sent, _ := controllers.DeliverQueuedMail(testDB.DB, mailer); sent != 0 {
+		t.Fatalf("%d mails sent for an unknown address", sent)
+	}
+
+	if err := ac.RequestMagicLink(user.Email); err != nil {
+		t.Fatalf("RequestMagicLink() error = %v", err)
+	}
+	token := magicLinkToken(t, mailer, testDB)
+
+	// Sign-in tokens cannot reset passwords
+	if _, err := ac.ResetPassword(token, "NewPassword123!"); !errors.Is(err, controllers.ErrInvalidUserToken) {
+		t.Errorf("ResetPassword(sign-in token) error = %v, want %v", err, controllers.ErrInvalidUserToken)
+	}
+
+	signedIn, err := ac.ConsumeMagicLink(token)
+	if err != nil || signedIn.ID != int(id) {
+		t.Fatalf("ConsumeMagicLink() = %+v, %v", signedIn, err)
+	}
+	if !signedIn.EmailVerified {
+		t.Error("following the sign-in link did not verify the email")
+	}
+	if _, err := ac.ConsumeMagicLink(token); !errors.Is(err, controllers.ErrInvalidUserToken) {
+		t.Errorf("ConsumeMagicLink(reused) error = %v, want %v", err, controllers.ErrInvalidUserToken)
+	}
+}
+
+func TestMagicLink_RateLimitAndSuspension(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	ac := controllers.NewAuthController(testDB.DB)
+	id, user := utils.CreateTestUser(t, testDB.DB)
+	mailer := &recordingMailer{}
+
+	if err := ac.RequestMagicLink(user.Email); err != nil {
+		t.Fatalf("RequestMagicLink() error = %v", err)
+	}
+	// A second request within a minute sends nothing
+	if err := ac.RequestMagicLink(user.Email); err != nil {
+		t.Fatalf("RequestMagicLink() error = %v", err)
+	}
+	if sent, _ := controllers.DeliverQueuedMail(testDB.DB, mailer); sent != 1 {
+		t.Fatalf("DeliverQueuedMail() = %d, want 1", sent)
+	}
+
+	testDB.DB.Exec("UPDATE user_tokens SET created_at = ?", time.Now().Add(-2*time.Minute))
+	if err := ac.RequestMagicLink(user.Email); err != nil {
+		t.Fatalf("RequestMagicLink() error = %v", err)
+	}
+	token := magicLinkToken(t, mailer, testDB)
+
+	// Suspended users cannot sign in by link either
+	if _, err := controllers.SuspendUser(testDB.DB, 0, int(id), "spam", time.Hour, ""); err != nil {
+		t.Fatalf("SuspendUser() error = %v", err)
+	}
+	var suspended *controllers.AccountSuspendedError
+	if _, err := ac.ConsumeMagicLink(token); !errors.As(err, &suspended) {
+		t.Errorf("ConsumeMagicLink(suspended) error = %v, want AccountSuspendedError", err)
+	}
+}
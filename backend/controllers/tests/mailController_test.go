@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/utils"
+)
+
+func TestMailOutbox_BlankedAndPruned(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	for _, to := range []string{"sent@example.com", "abandoned@example.com"} {
+		msg := utils.MailMessage{To: to, Subject: "Reset", Body: "https://forum.example/reset?token=secret"}
+		if err := controllers.QueueMail(testDB.DB, msg); err != nil {
+			t.Fatalf("QueueMail() error = %v", err)
+		}
+	}
+	// The second message is on its last try
+	if _, err := testDB.DB.Exec("UPDATE mail_outbox SET attempts = 7 WHERE recipient = 'abandoned@example.com'"); err != nil {
+		t.Fatalf("Failed to set attempts: %v", err)
+	}
+
+	mailer := &recordingMailer{fail: true}
+	if _, err := controllers.DeliverQueuedMail(testDB.DB, mailer); err != nil {
+		t.Fatalf("DeliverQueuedMail(failing) error = %v", err)
+	}
+	testDB.DB.Exec("UPDATE mail_outbox SET next_attempt_at = ?", time.Now().Add(-time.Second))
+	mailer.fail = false
+	if sent, err := controllers.DeliverQueuedMail(testDB.DB, mailer); err != nil || sent != 1 {
+		t.Fatalf("DeliverQueuedMail() = %d, %v, want 1, nil", sent, err)
+	}
+	if err := controllers.QueueMail(testDB.DB, utils.MailMessage{To: "pending@example.com", Subject: "Hi", Body: "Hello"}); err != nil {
+		t.Fatalf("QueueMail() error = %v", err)
+	}
+
+	// Sent and abandoned mail loses its body straight away
+	bodies := map[string]string{}
+	rows, err := testDB.DB.Query("SELECT recipient, body FROM mail_outbox")
+	if err != nil {
+		t.Fatalf("Failed to read outbox: %v", err)
+	}
+	for rows.Next() {
+		var recipient, body string
+		rows.Scan(&recipient, &body)
+		bodies[recipient] = body
+	}
+	rows.Close()
+	if bodies["sent@example.com"] != "" || bodies["abandoned@example.com"] != "" || bodies["pending@example.com"] != "Hello" {
+		t.Errorf("outbox bodies = %q, want only the pending one kept", bodies)
+	}
+
+	// and is deleted once it is old
+	if pruned, err := controllers.PruneMailOutbox(testDB.DB); err != nil || pruned != 0 {
+		t.Errorf("PruneMailOutbox() of recent mail = %d, %v, want 0, nil", pruned, err)
+	}
+	testDB.DB.Exec("UPDATE mail_outbox SET created_at = ?", time.Now().Add(-8*24*time.Hour))
+	if pruned, err := controllers.PruneMailOutbox(testDB.DB); err != nil || pruned != 2 {
+		t.Errorf("PruneMailOutbox() = %d, %v, want 2, nil", pruned, err)
+	}
+	var recipient string
+	if err := testDB.DB.QueryRow("SELECT recipient FROM mail_outbox").Scan(&recipient); err != nil || recipient != "pending@example.com" {
+		t.Errorf("outbox after pruning has %q, %v, want only the pending mail", recipient, err)
+	}
+}
@@ -0,0 +1,373 @@
+package controllers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/utils"
+)
+
+// mockIssuer is a minimal OpenID Connect provider. It hands out one
+// authorization code per login and checks the PKCE verifier on exchange.
+type mockIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	// Claims for the next ID token
+	subject       string
+	email         string
+	emailVerified bool
+	audience      string
+	nonceOverride string
+
+	codes map[string]url.Values
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	m := &mockIssuer{t: t, key: key, audience: "forum-client", codes: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		params, ok := m.codes[r.PostForm.Get("code")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		delete(m.codes, r.PostForm.Get("code"))
+
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != params.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "PKCE mismatch"})
+			return
+		}
+
+		nonce := params.Get("nonce")
+		if m.nonceOverride != "" {
+			nonce = m.nonceOverride
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"id_token": m.sign(map[string]interface{}{
+				"iss":            m.server.URL,
+				"sub":            m.subject,
+				"aud":            m.audience,
+				"exp":            time.Now().Add(5 * time.Minute).Unix(),
+				"iat":            time.Now().Unix(),
+				"nonce":          nonce,
+				"email":          m.email,
+				"email_verified": m.emailVerified,
+			}),
+		})
+	})
+	m.server = httptest.NewServer(mux)
+	return m
+}
+
+func (m *mockIssuer) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test-key", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		m.t.Fatalf("failed to sign ID token: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// authorize plays the browser's visit to the provider and returns the state
+// and code the provider would redirect back with
+func (m *mockIssuer) authorize(authURL string) (string, string) {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatalf("invalid auth URL: %v", err)
+	}
+	params := parsed.Query()
+	if params.Get("code_challenge_method") != "S256" || params.Get("code_challenge") == "" {
+		m.t.Fatalf("auth URL is missing PKCE parameters: %s", authURL)
+	}
+	code, _ := utils.GenerateOpaqueToken()
+	m.codes[code] = params
+	return params.Get("state"), code
+}
+
+func (m *mockIssuer) provider() *utils.OIDCProvider {
+	return utils.NewOIDCProvider(utils.OIDCConfig{
+		Name:        "Test",
+		Issuer:      m.server.URL,
+		ClientID:    "forum-client",
+		RedirectURL: "http://localhost/auth/oidc/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+	})
+}
+
+func TestOIDCLogin_CreatesUserAndLogsBackIn(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	issuer := newMockIssuer(t)
+	defer issuer.server.Close()
+	issuer.subject = "subject-1"
+	issuer.email = "sso.user@example.com"
+	issuer.emailVerified = true
+	provider := issuer.provider()
+	ctx := context.Background()
+
+	authURL, _, err := controllers.BeginOIDCLogin(ctx, testDB.DB, provider, 0)
+	if err != nil {
+		t.Fatalf("BeginOIDCLogin() error = %v", err)
+	}
+	state, code := issuer.authorize(authURL)
+
+	first, err := controllers.CompleteOIDCLogin(ctx, testDB.DB, provider, state, state, code)
+	if err != nil {
+		t.Fatalf("CompleteOIDCLogin() error = %v", err)
+	}
+	if first.UserID == 0 || first.Linked {
+		t.Fatalf("CompleteOIDCLogin() = %+v, want a new unlinked login", first)
+	}
+
+	var email string
+	var verified bool
+	if err := testDB.DB.QueryRow("SELECT email, email_verified FROM users WHERE id = ?", first.UserID).Scan(&email, &verified); err != nil {
+		t.Fatalf("failed to load created user: %v", err)
+	}
+	if email != issuer.email || !verified {
+		t.Errorf("created user email = %q verified = %v", email, verified)
+	}
+
+	// The state cannot be used a second time
+	if _, err := controllers.CompleteOIDCLogin(ctx, testDB.DB, provider, state, state, code); err != controllers.ErrInvalidOIDCState {
+		t.Errorf("replayed state error = %v, want ErrInvalidOIDCState", err)
+	}
+
+	// The same subject signs in to the same account
+	authURL, _, _ = controllers.BeginOIDCLogin(ctx, testDB.DB, provider, 0)
+	state, code = issuer.authorize(authURL)
+	second, err := controllers.CompleteOIDCLogin(ctx, testDB.DB, provider, state, state, code)
+	if err != nil {
+		t.Fatalf("second CompleteOIDCLogin() error = %v", err)
+	}
+	if second.UserID != first.UserID {
+		t.Errorf("second login user = %d, want %d", second.UserID, first.UserID)
+	}
+
+	identities, err := controllers.GetUserIdentities(testDB.DB, first.UserID)
+	if err != nil || len(identities) != 1 || identities[0].Issuer != issuer.server.URL {
+		t.Errorf("GetUserIdentities() = %+v, %v", identities, err)
+	}
+}
+
+func TestOIDCLogin_LinksLoggedInUser(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	id, _ := utils.CreateTestUser(t, testDB.DB)
+
+	issuer := newMockIssuer(t)
+	defer issuer.server.Close()
+	issuer.subject = "subject-2"
+	issuer.email = "someone.else@example.com"
+	provider := issuer.provider()
+	ctx := context.Background()
+
+	authURL, _, err := controllers.BeginOIDCLogin(ctx, testDB.DB, provider, int(id))
+	if err != nil {
+		t.Fatalf("BeginOIDCLogin() error = %v", err)
+	}
+	state, code := issuer.authorize(authURL)
+	result, err := controllers.CompleteOIDCLogin(ctx, testDB.DB, provider, state, state, code)
+	if err != nil {
+		t.Fatalf("CompleteOIDCLogin() error = %v", err)
+	}
+	if !result.Linked || result.UserID != int(id) {
+		t.Errorf("CompleteOIDCLogin() = %+v, want linked to user %d", result, id)
+	}
+}
+
+func TestOIDCLogin_RejectsBadIDToken(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	issuer := newMockIssuer(t)
+	defer issuer.server.Close()
+	issuer.subject = "subject-3"
+	issuer.email = "bad.token@example.com"
+	provider := issuer.provider()
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		nonce    string
+		audience string
+	}{
+		{name: "wrong nonce", nonce: "not-the-nonce", audience: "forum-client"},
+		{name: "wrong audience", audience: "another-client"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer.nonceOverride = tt.nonce
+			issuer.audience = tt.audience
+
+			authURL, _, err := controllers.BeginOIDCLogin(ctx, testDB.DB, provider, 0)
+			if err != nil {
+				t.Fatalf("BeginOIDCLogin() error = %v", err)
+			}
+			state, code := issuer.authorize(authURL)
+			if _, err := controllers.CompleteOIDCLogin(ctx, testDB.DB, provider, state, state, code); err == nil {
+				t.Error("CompleteOIDCLogin() accepted a bad ID token")
+			}
+		})
+	}
+
+	var users int
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&users)
+	if users != 0 {
+		t.Errorf("users created = %d, want 0", users)
+	}
+}
+
+func TestOIDCLogin_RequiresBrowserState(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	issuer := newMockIssuer(t)
+	defer issuer.server.Close()
+	issuer.subject = "subject-4"
+	issuer.email = "victim@example.com"
+	issuer.emailVerified = true
+	provider := issuer.provider()
+	ctx := context.Background()
+
+	// A callback started in another browser carries no or another state cookie
+	for _, browserState := range []string{"", "another-state"} {
+		authURL, _, err := controllers.BeginOIDCLogin(ctx, testDB.DB, provider, 0)
+		if err != nil {
+			t.Fatalf("BeginOIDCLogin() error = %v", err)
+		}
+		state, code := issuer.authorize(authURL)
+		if _, err := controllers.CompleteOIDCLogin(ctx, testDB.DB, provider, state, browserState, code); err != controllers.ErrInvalidOIDCState {
+			t.Errorf("CompleteOIDCLogin(browser state %q) error = %v, want ErrInvalidOIDCState", browserState, err)
+		}
+	}
+
+	var users int
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&users)
+	if users != 0 {
+		t.Errorf("users created = %d, want 0", users)
+	}
+}
+
+func TestOIDCLogin_LinksByEmailOnlyWhenBothVerified(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	id, user := utils.CreateTestUser(t, testDB.DB)
+
+	issuer := newMockIssuer(t)
+	defer issuer.server.Close()
+	issuer.email = user.Email
+	provider := issuer.provider()
+	ctx := context.Background()
+
+	tests := []struct {
+		name             string
+		providerVerified bool
+		localVerified    bool
+		wantErr          error
+	}{
+		{name: "neither verified", wantErr: controllers.ErrOIDCEmailTaken},
+		{name: "only provider verified", providerVerified: true, wantErr: controllers.ErrOIDCEmailTaken},
+		{name: "only account verified", localVerified: true, wantErr: controllers.ErrOIDCEmailTaken},
+		{name: "both verified", providerVerified: true, localVerified: true},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer.subject = fmt.Sprintf("subject-email-%d", i)
+			issuer.emailVerified = tt.providerVerified
+			if _, err := testDB.DB.Exec("UPDATE users SET email_verified = ? WHERE id = ?", tt.localVerified, id); err != nil {
+				t.Fatalf("failed to set email_verified: %v", err)
+			}
+
+			authURL, _, err := controllers.BeginOIDCLogin(ctx, testDB.DB, provider, 0)
+			if err != nil {
+				t.Fatalf("BeginOIDCLogin() error = %v", err)
+			}
+			state, code := issuer.authorize(authURL)
+			result, err := controllers.CompleteOIDCLogin(ctx, testDB.DB, provider, state, state, code)
+			if err != tt.wantErr {
+				t.Fatalf("CompleteOIDCLogin() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && result.UserID != int(id) {
+				t.Errorf("CompleteOIDCLogin() user = %d, want %d", result.UserID, id)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := utils.Argon2idHasher{Params: utils.Argon2Params{
+		Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32,
+	}}
+
+	hash, err := hasher.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Hash() = %q, want PHC argon2id string", hash)
+	}
+
+	if ok, err := hasher.Verify(hash, "correct horse"); !ok || err != nil {
+		t.Errorf("Verify(correct) = %v, %v", ok, err)
+	}
+	if ok, _ := hasher.Verify(hash, "wrong horse"); ok {
+		t.Error("Verify(wrong) = true")
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Error("NeedsRehash() = true for a hash with the same settings")
+	}
+
+	stronger := hasher
+	stronger.Params.Iterations = 2
+	if !stronger.NeedsRehash(hash) {
+		t.Error("NeedsRehash() = false after raising iterations")
+	}
+
+	for _, bad := range []string{"$argon2id$v=19$m=1024,t=1,p=1$", "$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5"} {
+		if _, err := hasher.Verify(bad, "x"); err == nil {
+			t.Errorf("Verify(%q) accepted a malformed hash", bad)
+		}
+	}
+}
+
+func TestAuthenticateUser_UpgradesBcryptHash(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	// The test helper stores a bcrypt hash, as older versions did
+	_, user := utils.CreateTestUser(t, testDB.DB)
+	ac := controllers.NewAuthController(testDB.DB)
+
+	if _, err := ac.AuthenticateUser(models.LoginRequest{Identifier: user.Email, Password: "wrong"}); err == nil {
+		t.Fatal("AuthenticateUser() accepted a wrong password")
+	}
+	var stored string
+	testDB.DB.QueryRow("SELECT password FROM users WHERE email = ?", user.Email).Scan(&stored)
+	if !strings.HasPrefix(stored, "$2") {
+		t.Fatalf("hash changed after a failed login: %q", stored)
+	}
+
+	if _, err := ac.AuthenticateUser(models.LoginRequest{Identifier: user.Email, Password: "testpassword"}); err != nil {
+		t.Fatalf("AuthenticateUser() error = %v", err)
+	}
+	testDB.DB.QueryRow("SELECT password FROM users WHERE email = ?", user.Email).Scan(&stored)
+	if !strings.HasPrefix(stored, "$argon2id$") {
+		t.Fatalf("hash after login = %q, want argon2id", stored)
+	}
+
+	// The upgraded hash still logs in
+	if _, err := ac.AuthenticateUser(models.LoginRequest{Identifier: user.Email, Password: "testpassword"}); err != nil {
+		t.Fatalf("AuthenticateUser() after upgrade error = %v", err)
+	}
+}
+
+func TestCheckPassword_EmptyHash(t *testing.T) {
+	// Accounts created through single sign-on have no password to log in with
+	if _, err := utils.CheckPassword("", ""); err != utils.ErrPasswordMismatch {
+		t.Errorf("CheckPassword(empty) error = %v, want ErrPasswordMismatch", err)
+	}
+}
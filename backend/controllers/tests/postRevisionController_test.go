@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+func TestPostRevisions_UpdateKeepsHistory(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	author := insertRoleUser(t, testDB.DB, "author", models.RoleMember)
+	moderator := insertRoleUser(t, testDB.DB, "mod", models.RoleModerator)
+	postID := insertRolePost(t, testDB.DB, author)
+	pc := controllers.NewPostController(testDB.DB)
+
+	post, err := pc.GetPostByID(postID)
+	if err != nil {
+		t.Fatalf("GetPostByID() error = %v", err)
+	}
+	if post.EditedAt != nil || post.RevisionCount != 0 {
+		t.Errorf("new post edited_at = %v, revision_count = %d, want unedited", post.EditedAt, post.RevisionCount)
+	}
+
+	post.Content = "Content\nwith a second line"
+	if err := pc.UpdatePost(post, author); err != nil {
+		t.Fatalf("UpdatePost() error = %v", err)
+	}
+	post.Title = "Better title"
+	if err := pc.UpdatePost(post, moderator); err != nil {
+		t.Fatalf("UpdatePost() error = %v", err)
+	}
+	// Saving without changes adds no revision
+	if err := pc.UpdatePost(post, author); err != nil {
+		t.Fatalf("UpdatePost() error = %v", err)
+	}
+
+	post, err = pc.GetPostByID(postID)
+	if err != nil {
+		t.Fatalf("GetPostByID() error = %v", err)
+	}
+	if post.EditedAt == nil || post.RevisionCount != 3 {
+		t.Errorf("edited post edited_at = %v, revision_count = %d, want 3 revisions", post.EditedAt, post.RevisionCount)
+	}
+
+	revisions, err := pc.GetPostRevisions(postID)
+	if err != nil {
+		t.Fatalf("GetPostRevisions() error = %v", err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("got %d revisions, want 3", len(revisions))
+	}
+	if revisions[0].Content != "Content" || revisions[0].EditedBy.ID != author {
+		t.Errorf("revision 1 = %+v, want the original by the author", revisions[0])
+	}
+	if revisions[2].Title != "Better title" || revisions[2].EditedBy.Nickname != "mod" {
+		t.Errorf("revision 3 = %+v, want the moderator's edit", revisions[2])
+	}
+
+	diff, err := pc.DiffPostRevisions(postID, 1, 3)
+	if err != nil {
+		t.Fatalf("DiffPostRevisions() error = %v", err)
+	}
+	wantContent := []models.DiffLine{
+		{Op: models.DiffEqual, Text: "Content"},
+		{Op: models.DiffInsert, Text: "with a second line"},
+	}
+	if !reflect.DeepEqual(diff.Content, wantContent) {
+		t.Errorf("content diff = %v, want %v", diff.Content, wantContent)
+	}
+	wantTitle := []models.DiffLine{
+		{Op: models.DiffDelete, Text: "Title"},
+		{Op: models.DiffInsert, Text: "Better title"},
+	}
+	if !reflect.DeepEqual(diff.Title, wantTitle) {
+		t.Errorf("title diff = %v, want %v", diff.Title, wantTitle)
+	}
+
+	if _, err := pc.DiffPostRevisions(postID, 1, 4); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DiffPostRevisions(missing revision) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []models.DiffLine
+	}{
+		{"unchanged", "a\nb", "a\nb", []models.DiffLine{{Op: "equal", Text: "a"}, {Op: "equal", Text: "b"}}},
+		{"line replaced", "a\nb\nc", "a\nx\nc", []models.DiffLine{
+			{Op: "equal", Text: "a"}, {Op: "delete", Text: "b"}, {Op: "insert", Text: "x"}, {Op: "equal", Text: "c"},
+		}},
+		{"lines moved", "a\nb\nc", "b\nc\na", []models.DiffLine{
+			{Op: "delete", Text: "a"}, {Op: "equal", Text: "b"}, {Op: "equal", Text: "c"}, {Op: "insert", Text: "a"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := utils.DiffLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+func TestPostDrafts_PrivateUntilPublished(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	author := insertRoleUser(t, testDB.DB, "author", models.RoleMember)
+	reader := insertRoleUser(t, testDB.DB, "reader", models.RoleMember)
+	pc := controllers.NewPostController(testDB.DB)
+
+	draftID, err := pc.InsertPost(models.Post{
+		UserID:    author,
+		Author:    "author",
+		Title:     "Half a thought",
+		Timestamp: time.Now(),
+		Status:    models.PostDraft,
+		Images:    []string{"/uploads/one.png"},
+	})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+
+	posts, _, err := pc.GetAllPosts("", 10)
+	if err != nil {
+		t.Fatalf("GetAllPosts() error = %v", err)
+	}
+	if len(posts) != 0 {
+		t.Errorf("GetAllPosts() returned %d posts, want drafts hidden", len(posts))
+	}
+	if _, err := pc.GetPostByID(draftID); err == nil {
+		t.Error("GetPostByID() found a draft, want it hidden")
+	}
+	if _, err := pc.GetAuthorPost(draftID, reader); err == nil {
+		t.Error("GetAuthorPost() found another user's draft")
+	}
+
+	draft, err := pc.GetAuthorPost(draftID, author)
+	if err != nil {
+		t.Fatalf("GetAuthorPost() error = %v", err)
+	}
+	if draft.Status != models.PostDraft || len(draft.Images) != 1 {
+		t.Errorf("draft = %+v, want a draft with one image", draft)
+	}
+
+	// Drafts are edited without keeping history
+	draft.Content = "A whole thought"
+	draft.Category = "General"
+	draft.Images = []string{"/uploads/two.png"}
+	if err := pc.UpdatePost(draft, author); err != nil {
+		t.Fatalf("UpdatePost() error = %v", err)
+	}
+	draft, err = pc.GetAuthorPost(draftID, author)
+	if err != nil {
+		t.Fatalf("GetAuthorPost() error = %v", err)
+	}
+	if draft.EditedAt != nil || draft.RevisionCount != 0 {
+		t.Errorf("edited draft edited_at = %v, revision_count = %d, want no history", draft.EditedAt, draft.RevisionCount)
+	}
+	if len(draft.Images) != 1 || draft.Images[0] != "/uploads/two.png" {
+		t.Errorf("draft images = %v, want the replacement", draft.Images)
+	}
+
+	drafts, err := pc.GetUserDrafts(author)
+	if err != nil {
+		t.Fatalf("GetUserDrafts() error = %v", err)
+	}
+	if len(drafts) != 1 || drafts[0].ID != draftID {
+		t.Errorf("GetUserDrafts() = %+v, want the draft", drafts)
+	}
+
+	if _, err := controllers.ToggleReaction(testDB.DB, models.ReactionOnPost, draftID, reader, "like"); !errors.Is(err, controllers.ErrReactionTarget) {
+		t.Errorf("ToggleReaction(draft) error = %v, want ErrReactionTarget", err)
+	}
+	cc := controllers.NewCommentController(testDB.DB)
+	comment := models.Comment{PostID: draftID, UserID: reader, Author: "reader", Content: "Nice"}
+	if _, err := cc.CreateComment(comment); !errors.Is(err, controllers.ErrPostNotPublished) {
+		t.Errorf("CreateComment(draft) error = %v, want ErrPostNotPublished", err)
+	}
+
+	published, err := pc.SetPostStatus(draftID, models.PostPublished, nil)
+	if err != nil || !published {
+		t.Fatalf("SetPostStatus(published) = %v, %v, want published", published, err)
+	}
+	if _, err := pc.GetPostByID(draftID); err != nil {
+		t.Errorf("GetPostByID() after publishing error = %v", err)
+	}
+	if _, err := pc.SetPostStatus(draftID, models.PostDraft, nil); !errors.Is(err, controllers.ErrPostPublished) {
+		t.Errorf("SetPostStatus(draft) on a published post error = %v, want ErrPostPublished", err)
+	}
+}
+
+func TestValidatePostStatus(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name      string
+		status    string
+		publishAt *time.Time
+		want      error
+	}{
+		{"draft", models.PostDraft, nil, nil},
+		{"published", models.PostPublished, nil, nil},
+		{"scheduled", models.PostScheduled, &future, nil},
+		{"scheduled without time", models.PostScheduled, nil, controllers.ErrPublishAtRequired},
+		{"scheduled in the past", models.PostScheduled, &past, controllers.ErrPublishAtRequired},
+		{"draft with time", models.PostDraft, &future, controllers.ErrInvalidPostStatus},
+		{"unknown status", "archived", nil, controllers.ErrInvalidPostStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := controllers.ValidatePostStatus(tt.status, tt.publishAt); !errors.Is(err, tt.want) {
+				t.Errorf("ValidatePostStatus() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishDuePosts(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	author := insertRoleUser(t, testDB.DB, "author", models.RoleMember)
+	pc := controllers.NewPostController(testDB.DB)
+
+	publishAt := time.Now().Add(time.Hour)
+	dueID, err := pc.InsertPost(models.Post{
+		UserID: author, Author: "author", Title: "Due", Content: "Soon", Category: "General",
+		Timestamp: time.Now(), Status: models.PostScheduled, PublishAt: &publishAt,
+	})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+	laterID, err := pc.InsertPost(models.Post{
+		UserID: author, Author: "author", Title: "Later", Content: "Not yet", Category: "General",
+		Timestamp: time.Now(), Status: models.PostScheduled, PublishAt: &publishAt,
+	})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+
+	// A time sent with an offset far west of the server's is not published
+	// early, and one sent from far east is stored in the server's offset too
+	_, localOffset := time.Now().Zone()
+	westAt := time.Now().Add(time.Hour).In(time.FixedZone("west", localOffset-12*60*60))
+	westID, err := pc.InsertPost(models.Post{
+		UserID: author, Author: "author", Title: "West", Content: "Not yet either", Category: "General",
+		Timestamp: time.Now(), Status: models.PostScheduled, PublishAt: &westAt,
+	})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+
+	published, err := controllers.PublishDuePosts(testDB.DB)
+	if err != nil || len(published) != 0 {
+		t.Fatalf("PublishDuePosts() before the time = %v, %v, want nothing", published, err)
+	}
+	eastAt := time.Now().Add(time.Hour).In(time.FixedZone("east", localOffset+12*60*60))
+	if _, err := pc.SetPostStatus(westID, models.PostScheduled, &eastAt); err != nil {
+		t.Fatalf("SetPostStatus() error = %v", err)
+	}
+	var storedLocal bool
+	testDB.DB.QueryRow("SELECT publish_at = ? FROM posts WHERE id = ?", eastAt.Local(), westID).Scan(&storedLocal)
+	if !storedLocal {
+		t.Error("SetPostStatus() stored publish_at with the client's offset")
+	}
+
+	// Let the first post fall due
+	due := time.Now().Add(-time.Minute)
+	if _, err := testDB.DB.Exec("UPDATE posts SET publish_at = ? WHERE id = ?", due, dueID); err != nil {
+		t.Fatalf("Failed to move publish_at: %v", err)
+	}
+	published, err = controllers.PublishDuePosts(testDB.DB)
+	if err != nil {
+		t.Fatalf("PublishDuePosts() error = %v", err)
+	}
+	if len(published) != 1 || published[0] != dueID {
+		t.Fatalf("PublishDuePosts() = %v, want [%d]", published, dueID)
+	}
+
+	post, err := pc.GetPostByID(dueID)
+	if err != nil {
+		t.Fatalf("GetPostByID() error = %v", err)
+	}
+	if !post.Timestamp.Equal(due) {
+		t.Errorf("published post timestamp = %v, want the scheduled time %v", post.Timestamp, due)
+	}
+	if _, err := pc.GetPostByID(laterID); err == nil {
+		t.Error("GetPostByID() found a post scheduled for later")
+	}
+
+	// A second run publishes nothing again
+	if published, err := controllers.PublishDuePosts(testDB.DB); err != nil || len(published) != 0 {
+		t.Errorf("PublishDuePosts() second run = %v, %v, want nothing", published, err)
+	}
+
+	// Nor does a post falling due once its author's account is deleted
+	if _, err := testDB.DB.Exec("UPDATE users SET deleted_at = ? WHERE id = ?", time.Now(), author); err != nil {
+		t.Fatalf("Failed to delete author: %v", err)
+	}
+	if _, err := testDB.DB.Exec("UPDATE posts SET publish_at = ? WHERE id = ?", due, laterID); err != nil {
+		t.Fatalf("Failed to move publish_at: %v", err)
+	}
+	if published, err := controllers.PublishDuePosts(testDB.DB); err != nil || len(published) != 0 {
+		t.Errorf("PublishDuePosts() for a deleted author = %v, %v, want nothing", published, err)
+	}
+}
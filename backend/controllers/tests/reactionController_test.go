@@ -0,0 +1,342 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"forum/backend/controllers"
+	"forum/backend/database"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// countsOf flattens reaction counts into name=count strings, with a * on the
+// ones the viewer left
+func countsOf(counts []models.ReactionCount) []string {
+	out := []string{}
+	for _, count := range counts {
+		entry := count.Name + "=" + strconv.Itoa(count.Count)
+		if count.Reacted {
+			entry += "*"
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+func toggle(t *testing.T, db *sql.DB, entityType string, entityID, userID int, reaction string) bool {
+	reacted, err := controllers.ToggleReaction(db, entityType, entityID, userID, reaction)
+	if err != nil {
+		t.Fatalf("ToggleReaction(%s %d, %s) error = %v", entityType, entityID, reaction, err)
+	}
+	return reacted
+}
+
+func TestParseReactions(t *testing.T) {
+	set, err := utils.ParseReactions(" like:👍, party_time:🎉 ,")
+	want := []models.Reaction{{Name: "like", Emoji: "👍"}, {Name: "party_time", Emoji: "🎉"}}
+	if err != nil || !reflect.DeepEqual(set, want) {
+		t.Errorf("ParseReactions() = %v, %v, want %v", set, err, want)
+	}
+	if _, err := utils.ParseReactions(utils.DefaultReactions); err != nil {
+		t.Errorf("ParseReactions(DefaultReactions) error = %v", err)
+	}
+
+	for _, value := range []string{"", "like", "like:", "Like:👍", "like:👍,like:❤️", "a-b:👍"} {
+		if _, err := utils.ParseReactions(value); err == nil {
+			t.Errorf("ParseReactions(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestReactions(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	alice := insertRoleUser(t, testDB.DB, "alice", models.RoleMember)
+	bob := insertRoleUser(t, testDB.DB, "bob", models.RoleMember)
+	carol := insertRoleUser(t, testDB.DB, "carol", models.RoleMember)
+	post := insertSearchPost(t, testDB.DB, alice, "Reactions", "React to me", "General")
+
+	// Toggling leaves a reaction, toggling again takes it back
+	if !toggle(t, testDB.DB, models.ReactionOnPost, post, alice, "love") {
+		t.Error("first toggle didn't leave the reaction")
+	}
+	toggle(t, testDB.DB, models.ReactionOnPost, post, bob, "love")
+	toggle(t, testDB.DB, models.ReactionOnPost, post, bob, "like")
+	toggle(t, testDB.DB, models.ReactionOnPost, post, carol, "laugh")
+	if toggle(t, testDB.DB, models.ReactionOnPost, post, carol, "laugh") {
+		t.Error("second toggle didn't take the reaction back")
+	}
+
+	counts, err := controllers.GetReactionCounts(testDB.DB, models.ReactionOnPost, []int{post, post + 100}, bob)
+	if err != nil {
+		t.Fatalf("GetReactionCounts() error = %v", err)
+	}
+	// In the order of the reaction set, not of who reacted first
+	if got, want := countsOf(counts[post]), []string{"like=1*", "love=2*"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetReactionCounts() = %v, want %v", got, want)
+	}
+	if counts[post][1].Emoji != "❤️" {
+		t.Errorf("love emoji = %q, want ❤️", counts[post][1].Emoji)
+	}
+	if got, ok := counts[post+100]; !ok || len(got) != 0 {
+		t.Errorf("GetReactionCounts() of a post nobody reacted to = %v, want an empty entry", got)
+	}
+
+	reactors, err := controllers.GetReactors(testDB.DB, models.ReactionOnPost, post, "love", 0)
+	if err != nil || len(reactors) != 2 || reactors[0].UserID != alice || reactors[1].UserID != bob {
+		t.Errorf("GetReactors(love) = %v, %v, want alice then bob", reactors, err)
+	}
+	if _, err := controllers.GetReactors(testDB.DB, models.ReactionOnPost, post, "shrug", 0); !errors.Is(err, controllers.ErrUnknownReaction) {
+		t.Errorf("GetReactors(shrug) error = %v, want ErrUnknownReaction", err)
+	}
+
+	// Hidden users' reactions aren't counted
+	if _, err := testDB.DB.Exec("UPDATE users SET content_hidden = TRUE WHERE id = ?", alice); err != nil {
+		t.Fatalf("Failed to hide user: %v", err)
+	}
+	counts, _ = controllers.GetReactionCounts(testDB.DB, models.ReactionOnPost, []int{post}, carol)
+	if got, want := countsOf(counts[post]), []string{"like=1", "love=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetReactionCounts() with alice hidden = %v, want %v", got, want)
+	}
+	if _, err := testDB.DB.Exec("UPDATE users SET content_hidden = FALSE WHERE id = ?", alice); err != nil {
+		t.Fatalf("Failed to unhide user: %v", err)
+	}
+
+	// The feed carries the counts, marked for its viewer
+	posts, _, err := controllers.NewPostController(testDB.DB).GetFeed(models.Feed{ViewerID: alice}, "", 10)
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("GetFeed() = %d posts, %v, want 1", len(posts), err)
+	}
+	if got, want := countsOf(posts[0].Reactions), []string{"like=1", "love=2*"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFeed() reactions = %v, want %v", got, want)
+	}
+
+	if _, err := controllers.ToggleReaction(testDB.DB, models.ReactionOnPost, post, bob, "shrug"); !errors.Is(err, controllers.ErrUnknownReaction) {
+		t.Errorf("ToggleReaction(shrug) error = %v, want ErrUnknownReaction", err)
+	}
+	if _, err := controllers.ToggleReaction(testDB.DB, "story", post, bob, "like"); !errors.Is(err, controllers.ErrReactionTarget) {
+		t.Errorf("ToggleReaction(story) error = %v, want ErrReactionTarget", err)
+	}
+
+	// Drafts can't be reacted to
+	if _, err := testDB.DB.Exec("UPDATE posts SET status = ? WHERE id = ?", models.PostDraft, post); err != nil {
+		t.Fatalf("Failed to make draft: %v", err)
+	}
+	if _, err := controllers.ToggleReaction(testDB.DB, models.ReactionOnPost, post, bob, "wow"); !errors.Is(err, controllers.ErrReactionTarget) {
+		t.Errorf("ToggleReaction(draft) error = %v, want ErrReactionTarget", err)
+	}
+	if _, err := testDB.DB.Exec("UPDATE posts SET status = ? WHERE id = ?", models.PostPublished, post); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	// Only the two people in a conversation can react to its messages
+	result, err := testDB.DB.Exec("INSERT INTO messages (sender_id, recipient_id, content) VALUES (?, ?, 'Hi')", alice, bob)
+	if err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+	messageID, _ := result.LastInsertId()
+	message := int(messageID)
+	toggle(t, testDB.DB, models.ReactionOnMessage, message, bob, "love")
+	if _, err := controllers.ToggleReaction(testDB.DB, models.ReactionOnMessage, message, carol, "love"); !errors.Is(err, controllers.ErrReactionTarget) {
+		t.Errorf("ToggleReaction(someone else's message) error = %v, want ErrReactionTarget", err)
+	}
+	if audience, err := controllers.ReactionAudience(testDB.DB, models.ReactionOnMessage, message); err != nil || !reflect.DeepEqual(audience, []int{alice, bob}) {
+		t.Errorf("ReactionAudience(message) = %v, %v, want [alice bob]", audience, err)
+	}
+
+	// Deleting a comment or post takes its reactions with it
+	result, err = testDB.DB.Exec("INSERT INTO comments (post_id, user_id, author, content) VALUES (?, ?, 'bob', 'Nice')", post, bob)
+	if err != nil {
+		t.Fatalf("Failed to comment: %v", err)
+	}
+	commentID, _ := result.LastInsertId()
+	comment := int(commentID)
+	toggle(t, testDB.DB, models.ReactionOnComment, comment, alice, "like")
+	toggle(t, testDB.DB, models.ReactionOnComment, comment, carol, "like")
+
+	if err := controllers.NewCommentController(testDB.DB).DeleteComment(comment, bob); err != nil {
+		t.Fatalf("DeleteComment() error = %v", err)
+	}
+	var left int
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM reactions WHERE entity_type = ?", models.ReactionOnComment).Scan(&left)
+	if left != 0 {
+		t.Errorf("%d reactions left on the deleted comment, want 0", left)
+	}
+
+	if err := controllers.NewPostController(testDB.DB).DeletePost(post, alice); err != nil {
+		t.Fatalf("DeletePost() error = %v", err)
+	}
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM reactions WHERE entity_type = ?", models.ReactionOnPost).Scan(&left)
+	if left != 0 {
+		t.Errorf("%d reactions left on the deleted post, want 0", left)
+	}
+}
+
+func TestReactions_DeletedWithCommentThread(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	alice := insertRoleUser(t, testDB.DB, "alice", models.RoleMember)
+	bob := insertRoleUser(t, testDB.DB, "bob", models.RoleMember)
+	post := insertRolePost(t, testDB.DB, alice)
+
+	// A comment with a reply, a reply to that and an unrelated comment
+	addComment := func(parentID interface{}) int {
+		result, err := testDB.DB.Exec("INSERT INTO comments (post_id, user_id, author, content, parent_id) VALUES (?, ?, 'bob', 'hi', ?)",
+			post, bob, parentID)
+		if err != nil {
+			t.Fatalf("Failed to insert comment: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		return int(id)
+	}
+	top := addComment(nil)
+	reply := addComment(top)
+	nested := addComment(reply)
+	other := addComment(nil)
+	for _, comment := range []int{top, reply, nested, other} {
+		toggle(t, testDB.DB, models.ReactionOnComment, comment, alice, "like")
+	}
+
+	if err := controllers.NewCommentController(testDB.DB).DeleteComment(top, bob); err != nil {
+		t.Fatalf("DeleteComment() error = %v", err)
+	}
+
+	var comments, reactions int
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM comments WHERE post_id = ?", post).Scan(&comments)
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM reactions WHERE entity_type = ?", models.ReactionOnComment).Scan(&reactions)
+	if comments != 1 || reactions != 1 {
+		t.Errorf("after DeleteComment() %d comments and %d reactions left, want only the unrelated one of each", comments, reactions)
+	}
+}
+
+func TestReactions_MigratedFromVotes(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	alice := insertRoleUser(t, testDB.DB, "alice", models.RoleMember)
+	bob := insertRoleUser(t, testDB.DB, "bob", models.RoleMember)
+	post := insertRolePost(t, testDB.DB, alice)
+
+	// An older database still has votes and the post counters they fed;
+	// bob had already liked the post with a reaction too
+	for _, stmt := range []string{
+		`CREATE TABLE user_votes (id INTEGER PRIMARY KEY AUTOINCREMENT, post_id INTEGER NOT NULL, user_id INTEGER NOT NULL, user_vote TEXT)`,
+		`ALTER TABLE posts ADD COLUMN likes INTEGER DEFAULT 0`,
+		`ALTER TABLE posts ADD COLUMN dislikes INTEGER DEFAULT 0`,
+	} {
+		if _, err := testDB.DB.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	_, err := testDB.DB.Exec("INSERT INTO user_votes (post_id, user_id, user_vote) VALUES (?, ?, 'like'), (?, ?, 'dislike')",
+		post, bob, post, alice)
+	if err != nil {
+		t.Fatalf("Failed to insert votes: %v", err)
+	}
+	toggle(t, testDB.DB, models.ReactionOnPost, post, bob, "like")
+
+	if err := database.ApplyMigrations(testDB.DB); err != nil {
+		t.Fatalf("ApplyMigrations() error = %v", err)
+	}
+	if err := database.ApplyMigrations(testDB.DB); err != nil {
+		t.Fatalf("second ApplyMigrations() error = %v", err)
+	}
+
+	counts, err := controllers.GetReactionCounts(testDB.DB, models.ReactionOnPost, []int{post}, bob)
+	if err != nil {
+		t.Fatalf("GetReactionCounts() error = %v", err)
+	}
+	if got, want := countsOf(counts[post]), []string{"like=1*", "dislike=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reactions after migrating = %v, want %v", got, want)
+	}
+
+	var votesTable, counterColumns int
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'user_votes'").Scan(&votesTable)
+	testDB.DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info('posts') WHERE name IN ('likes', 'dislikes')").Scan(&counterColumns)
+	if votesTable != 0 || counterColumns != 0 {
+		t.Errorf("after migrating user_votes tables = %d, post counter columns = %d, want both gone", votesTable, counterColumns)
+	}
+}
+
+func TestVisibleReactionTargets(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	alice := insertRoleUser(t, testDB.DB, "alice", models.RoleMember)
+	bob := insertRoleUser(t, testDB.DB, "bob", models.RoleMember)
+	carol := insertRoleUser(t, testDB.DB, "carol", models.RoleMember)
+	hidden := insertRoleUser(t, testDB.DB, "hidden", models.RoleMember)
+	if _, err := testDB.DB.Exec("UPDATE users SET content_hidden = TRUE WHERE id = ?", hidden); err != nil {
+		t.Fatalf("Failed to hide user: %v", err)
+	}
+
+	published := insertRolePost(t, testDB.DB, alice)
+	draft := insertRolePost(t, testDB.DB, alice)
+	byHidden := insertRolePost(t, testDB.DB, hidden)
+	if _, err := testDB.DB.Exec("UPDATE posts SET status = ? WHERE id = ?", models.PostDraft, draft); err != nil {
+		t.Fatalf("Failed to make draft: %v", err)
+	}
+
+	insert := func(query string, args ...interface{}) int {
+		result, err := testDB.DB.Exec(query, args...)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		id, _ := result.LastInsertId()
+		return int(id)
+	}
+	const addComment = "INSERT INTO comments (post_id, user_id, author, content) VALUES (?, ?, 'someone', 'hi')"
+	comment := insert(addComment, published, bob)
+	hiddenComment := insert(addComment, published, hidden)
+	draftComment := insert(addComment, draft, bob)
+	const addMessage = "INSERT INTO messages (sender_id, recipient_id, content) VALUES (?, ?, 'hi')"
+	sent := insert(addMessage, bob, alice)
+	received := insert(addMessage, alice, bob)
+	others := insert(addMessage, alice, carol)
+
+	tests := []struct {
+		entityType string
+		ids        []int
+		want       []int
+	}{
+		{models.ReactionOnPost, []int{byHidden, published, draft, published + 100}, []int{published}},
+		{models.ReactionOnComment, []int{draftComment, hiddenComment, comment}, []int{comment}},
+		{models.ReactionOnMessage, []int{others, received, sent}, []int{received, sent}},
+		{models.ReactionOnPost, nil, []int{}},
+	}
+	for _, tt := range tests {
+		got, err := controllers.VisibleReactionTargets(testDB.DB, tt.entityType, tt.ids, bob)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("VisibleReactionTargets(%s, %v) = %v, %v, want %v", tt.entityType, tt.ids, got, err, tt.want)
+		}
+	}
+	if _, err := controllers.VisibleReactionTargets(testDB.DB, "story", []int{published}, bob); !errors.Is(err, controllers.ErrReactionTarget) {
+		t.Errorf("VisibleReactionTargets(story) error = %v, want ErrReactionTarget", err)
+	}
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+func TestRefreshToken_RotationAndReuse(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	userID, _ := utils.CreateTestUser(t, testDB.DB)
+
+	// Create a session with its first refresh token
+	now := time.Now()
+	session := models.Session{
+		ID:        "session-1",
+		UserID:    int(userID),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	if err := controllers.AddSessionWithToken(testDB.DB, "cookie-1", "", session); err != nil {
+		t.Fatalf("AddSessionWithToken() error = %v", err)
+	}
+	first, err := controllers.IssueRefreshToken(testDB.DB, "cookie-1", session.ID, int(userID), session.ExpiresAt)
+	if err != nil {
+		t.Fatalf("IssueRefreshToken() error = %v", err)
+	}
+
+	// A valid token is exchanged for a new one in the same family
+	result, err := controllers.RotateRefreshToken(testDB.DB, first)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken() error = %v", err)
+	}
+	if result.RefreshToken == "" || result.RefreshToken == first {
+		t.Errorf("RotateRefreshToken() returned token %q, want a new token", result.RefreshToken)
+	}
+	if result.SessionID != session.ID || result.UserID != int(userID) {
+		t.Errorf("RotateRefreshToken() = session %q user %d, want %q %d",
+			result.SessionID, result.UserID, session.ID, userID)
+	}
+
+	// An unknown token is rejected
+	if _, err := controllers.RotateRefreshToken(testDB.DB, "not-a-token"); !errors.Is(err, controllers.ErrInvalidRefreshToken) {
+		t.Errorf("RotateRefreshToken(unknown) error = %v, want %v", err, controllers.ErrInvalidRefreshToken)
+	}
+
+	// Replaying the first token revokes the session and its newer token
+	if _, err := controllers.RotateRefreshToken(testDB.DB, first); !errors.Is(err, controllers.ErrRefreshTokenReused) {
+		t.Fatalf("RotateRefreshToken(reused) error = %v, want %v", err, controllers.ErrRefreshTokenReused)
+	}
+	if _, err := controllers.GetSessionIDByToken(testDB.DB, "cookie-1"); err == nil {
+		t.Error("session still exists after refresh token reuse")
+	}
+	if _, err := controllers.RotateRefreshToken(testDB.DB, result.RefreshToken); !errors.Is(err, controllers.ErrInvalidRefreshToken) {
+		t.Errorf("RotateRefreshToken(after revoke) error = %v, want %v", err, controllers.ErrInvalidRefreshToken)
+	}
+
+	// A token outlives neither a logged out nor an expired session
+	ended := []struct {
+		cookie    string
+		expiresAt time.Time
+		logout    bool
+	}{
+		{"cookie-logged-out", now.Add(time.Hour), true},
+		{"cookie-expired", now.Add(-time.Minute), false},
+	}
+	for _, tt := range ended {
+		session := models.Session{ID: "session-" + tt.cookie, UserID: int(userID), CreatedAt: now, ExpiresAt: tt.expiresAt}
+		if err := controllers.AddSessionWithToken(testDB.DB, tt.cookie, "", session); err != nil {
+			t.Fatalf("AddSessionWithToken() error = %v", err)
+		}
+		token, err := controllers.IssueRefreshToken(testDB.DB, tt.cookie, session.ID, int(userID), now.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("IssueRefreshToken() error = %v", err)
+		}
+		if tt.logout {
+			if err := controllers.DeleteSession(testDB.DB, tt.cookie); err != nil {
+				t.Fatalf("DeleteSession() error = %v", err)
+			}
+		}
+		if _, err := controllers.RotateRefreshToken(testDB.DB, token); !errors.Is(err, controllers.ErrInvalidRefreshToken) {
+			t.Errorf("RotateRefreshToken(%s) error = %v, want %v", tt.cookie, err, controllers.ErrInvalidRefreshToken)
+		}
+	}
+}
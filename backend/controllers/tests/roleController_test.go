@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+func insertRoleUser(t *testing.T, db *sql.DB, nickname, role string) int {
+	result, err := db.Exec(`
+		INSERT INTO users (nickname, email, password, first_name, last_name, age, gender, role)
+		VALUES (?, ?, '', 'Test', 'User', 25, 'male', ?)`,
+		nickname, nickname+"@example.com", role)
+	if err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return int(id)
+}
+
+func insertRolePost(t *testing.T, db *sql.DB, userID int) int {
+	result, err := db.Exec(`
+		INSERT INTO posts (user_id, author, title, content, category, timestamp)
+		VALUES (?, 'author', 'Title', 'Content', 'General', ?)`, userID, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return int(id)
+}
+
+func TestRoleHasPermission(t *testing.T) {
+	tests := []struct {
+		role string
+		perm controllers.Permission
+		want bool
+	}{
+		{models.RoleMember, controllers.PermDeleteAnyPost, false},
+		{models.RoleMember, controllers.PermManageRoles, false},
+		{models.RoleModerator, controllers.PermDeleteAnyPost, true},
+		{models.RoleModerator, controllers.PermDeleteAnyComment, true},
+		{models.RoleModerator, controllers.PermLockPost, true},
+		{models.RoleModerator, controllers.PermManageRoles, false},
+		{models.RoleAdmin, controllers.PermEditAnyPost, true},
+		{models.RoleAdmin, controllers.PermManageRoles, true},
+		{models.RoleModerator, controllers.PermViewAuditLog, false},
+		{models.RoleAdmin, controllers.PermViewAuditLog, true},
+		{"owner", controllers.PermDeleteAnyPost, false},
+	}
+	for _, tt := range tests {
+		if got := controllers.RoleHasPermission(tt.role, tt.perm); got != tt.want {
+			t.Errorf("RoleHasPermission(%q, %q) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestRoles_ModeratorDeletesOthersContent(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	pc := controllers.NewPostController(testDB.DB)
+	cc := controllers.NewCommentController(testDB.DB)
+
+	author := insertRoleUser(t, testDB.DB, "author", models.RoleMember)
+	member := insertRoleUser(t, testDB.DB, "member", models.RoleMember)
+	moderator := insertRoleUser(t, testDB.DB, "moderator", models.RoleModerator)
+
+	postID := insertRolePost(t, testDB.DB, author)
+	commentID, err := cc.CreateComment(models.Comment{PostID: postID, UserID: author, Author: "author", Content: "hi"})
+	if err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+
+	if err := cc.DeleteComment(commentID, member); err == nil {
+		t.Error("member deleted another user's comment")
+	}
+	if err := pc.DeletePost(postID, member); !errors.Is(err, controllers.ErrNotAllowed) {
+		t.Errorf("member DeletePost() error = %v, want ErrNotAllowed", err)
+	}
+	if allowed, _ := pc.CanModifyPost(postID, member, controllers.PermEditAnyPost); allowed {
+		t.Error("member may edit another user's post")
+	}
+	if allowed, _ := pc.CanModifyPost(postID, moderator, controllers.PermEditAnyPost); !allowed {
+		t.Error("moderator may not edit another user's post")
+	}
+
+	if err := cc.DeleteComment(commentID, moderator); err != nil {
+		t.Errorf("moderator DeleteComment() error = %v", err)
+	}
+	if err := pc.DeletePost(postID, moderator); err != nil {
+		t.Fatalf("moderator DeletePost() error = %v", err)
+	}
+	if _, err := pc.GetPostByID(postID); err == nil {
+		t.Error("post still exists after moderator deleted it")
+	}
+}
+
+func TestRoles_LockedPostRejectsComments(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	pc := controllers.NewPostController(testDB.DB)
+	cc := controllers.NewCommentController(testDB.DB)
+
+	author := insertRoleUser(t, testDB.DB, "author", models.RoleMember)
+	postID := insertRolePost(t, testDB.DB, author)
+
+	if err := pc.SetPostLocked(postID, true); err != nil {
+		t.Fatalf("SetPostLocked() error = %v", err)
+	}
+	post, err := pc.GetPostByID(postID)
+	if err != nil || !post.Locked {
+		t.Fatalf("GetPostByID() locked = %v, %v", post.Locked, err)
+	}
+
+	_, err = cc.CreateComment(models.Comment{PostID: postID, UserID: author, Author: "author", Content: "hi"})
+	if !errors.Is(err, controllers.ErrPostLocked) {
+		t.Errorf("CreateComment() on locked post error = %v, want ErrPostLocked", err)
+	}
+
+	if err := pc.SetPostLocked(postID, false); err != nil {
+		t.Fatalf("SetPostLocked() error = %v", err)
+	}
+	if _, err := cc.CreateComment(models.Comment{PostID: postID, UserID: author, Author: "author", Content: "hi"}); err != nil {
+		t.Errorf("CreateComment() on unlocked post error = %v", err)
+	}
+
+	if err := pc.SetPostLocked(postID+100, true); err != sql.ErrNoRows {
+		t.Errorf("SetPostLocked() on missing post error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestRoles_SetUserRole(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	admin := insertRoleUser(t, testDB.DB, "admin", models.RoleMember)
+	other := insertRoleUser(t, testDB.DB, "other", models.RoleMember)
+
+	if err := controllers.SetUserRole(testDB.DB, admin, "owner"); !errors.Is(err, controllers.ErrInvalidRole) {
+		t.Errorf("SetUserRole(owner) error = %v, want ErrInvalidRole", err)
+	}
+	if err := controllers.SetUserRole(testDB.DB, admin, models.RoleAdmin); err != nil {
+		t.Fatalf("SetUserRole(admin) error = %v", err)
+	}
+	if err := controllers.SetUserRole(testDB.DB, admin, models.RoleMember); !errors.Is(err, controllers.ErrLastAdmin) {
+		t.Errorf("demoting last admin error = %v, want ErrLastAdmin", err)
+	}
+
+	// An admin whose account is being deleted doesn't count, though they may
+	// step down themselves
+	if err := controllers.SetUserRole(testDB.DB, other, models.RoleAdmin); err != nil {
+		t.Fatalf("SetUserRole(admin) error = %v", err)
+	}
+	if _, err := testDB.DB.Exec("UPDATE users SET delete_after = ? WHERE id = ?", time.Now().Add(time.Hour), other); err != nil {
+		t.Fatalf("Failed to schedule deletion: %v", err)
+	}
+	if err := controllers.SetUserRole(testDB.DB, admin, models.RoleMember); !errors.Is(err, controllers.ErrLastAdmin) {
+		t.Errorf("demoting the admin beside one being deleted error = %v, want ErrLastAdmin", err)
+	}
+	if err := controllers.SetUserRole(testDB.DB, other, models.RoleMember); err != nil {
+		t.Errorf("demoting the admin being deleted error = %v", err)
+	}
+	if _, err := testDB.DB.Exec("UPDATE users SET delete_after = NULL WHERE id = ?", other); err != nil {
+		t.Fatalf("Failed to cancel deletion: %v", err)
+	}
+
+	// With a second admin the first may step down
+	if err := controllers.SetUserRole(testDB.DB, other, models.RoleAdmin); err != nil {
+		t.Fatalf("SetUserRole(admin) error = %v", err)
+	}
+	if err := controllers.SetUserRole(testDB.DB, admin, models.RoleModerator); err != nil {
+		t.Errorf("demoting one of two admins error = %v", err)
+	}
+
+	if id, err := controllers.FindUserID(testDB.DB, "admin@example.com"); err != nil || id != admin {
+		t.Errorf("FindUserID(email) = %d, %v, want %d", id, err, admin)
+	}
+	staff, err := controllers.GetStaffUsers(testDB.DB)
+	if err != nil || len(staff) != 2 {
+		t.Errorf("GetStaffUsers() = %+v, %v, want 2 users", staff, err)
+	}
+	if err := controllers.SetUserRole(testDB.DB, other+100, models.RoleModerator); err != sql.ErrNoRows {
+		t.Errorf("SetUserRole() on missing user error = %v, want sql.ErrNoRows", err)
+	}
+}
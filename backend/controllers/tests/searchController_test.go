@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+func insertSearchPost(t *testing.T, db *sql.DB, userID int, title, content, category string) int {
+	pc := controllers.NewPostController(db)
+	postID, err := pc.InsertPost(models.Post{
+		UserID: userID, Author: "author", Title: title, Content: content, Category: category, Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+	return postID
+}
+
+// The tests run against FTS5 when built with -tags sqlite_fts5, and against
+// the LIKE fallback otherwise
+func TestSearch_PostsAndComments(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	author := insertRoleUser(t, testDB.DB, "author", models.RoleMember)
+	commenter := insertRoleUser(t, testDB.DB, "commenter", models.RoleMember)
+
+	gardenID := insertSearchPost(t, testDB.DB, author, "Growing tomatoes", "Tomatoes need <b>sun</b> and water", "Garden")
+	insertSearchPost(t, testDB.DB, author, "Cooking pasta", "Boil water, add salt", "Food")
+	draftID, err := controllers.NewPostController(testDB.DB).InsertPost(models.Post{
+		UserID: author, Author: "author", Title: "Secret tomatoes", Timestamp: time.Now(), Status: models.PostDraft,
+	})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+	cc := controllers.NewCommentController(testDB.DB)
+	commentID, err := cc.CreateComment(models.Comment{PostID: gardenID, UserID: commenter, Author: "commenter", Content: "My tomatoes got blight"})
+	if err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+
+	sc := controllers.NewSearchController(testDB.DB)
+	results, err := sc.Search(models.SearchFilter{Query: "Tomatoes"})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Search() returned %d results, want the post and the comment: %+v", len(results), results)
+	}
+	for _, result := range results {
+		if result.PostID == draftID {
+			t.Errorf("Search() found a draft")
+		}
+		if !strings.Contains(result.Snippet, "<mark>") {
+			t.Errorf("snippet %q has no highlighted match", result.Snippet)
+		}
+		if strings.Contains(result.Snippet, "<b>") {
+			t.Errorf("snippet %q is not escaped", result.Snippet)
+		}
+	}
+	// The title match ranks the post above the comment
+	if results[0].Type != models.SearchPost || results[0].PostID != gardenID {
+		t.Errorf("first result = %+v, want the post", results[0])
+	}
+	if results[1].Type != models.SearchComment || results[1].CommentID != commentID || results[1].Author != "commenter" {
+		t.Errorf("second result = %+v, want the comment", results[1])
+	}
+
+	// Every word has to match
+	if results, _ := sc.Search(models.SearchFilter{Query: "water salt"}); len(results) != 1 || results[0].Title != "Cooking pasta" {
+		t.Errorf("Search(water salt) = %+v, want the pasta post", results)
+	}
+
+	tests := []struct {
+		name   string
+		filter models.SearchFilter
+		want   int
+	}{
+		{"posts only", models.SearchFilter{Query: "tomatoes", Type: models.SearchPost}, 1},
+		{"comments only", models.SearchFilter{Query: "tomatoes", Type: models.SearchComment}, 1},
+		{"category", models.SearchFilter{Query: "water", Category: "Food"}, 1},
+		{"author", models.SearchFilter{Query: "tomatoes", Author: "Commenter"}, 1},
+		{"since", models.SearchFilter{Query: "tomatoes", Since: time.Now().Add(time.Hour)}, 0},
+		{"until", models.SearchFilter{Query: "tomatoes", Until: time.Now().Add(time.Hour)}, 2},
+		{"no match", models.SearchFilter{Query: "cucumber"}, 0},
+		{"FTS syntax is plain text", models.SearchFilter{Query: `"tomatoes OR (`}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := sc.Search(tt.filter)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if len(results) != tt.want {
+				t.Errorf("Search() returned %d results, want %d: %+v", len(results), tt.want, results)
+			}
+		})
+	}
+
+	if _, err := sc.Search(models.SearchFilter{Query: ` " * `}); !errors.Is(err, controllers.ErrEmptySearch) {
+		t.Errorf("Search(empty) error = %v, want ErrEmptySearch", err)
+	}
+}
+
+func TestSearch_FollowsEditsAndDeletes(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	author := insertRoleUser(t, testDB.DB, "author", models.RoleMember)
+	postID := insertSearchPost(t, testDB.DB, author, "Bicycle repair", "Fixing a flat tyre", "General")
+	pc := controllers.NewPostController(testDB.DB)
+	sc := controllers.NewSearchController(testDB.DB)
+
+	post, err := pc.GetPostByID(postID)
+	if err != nil {
+		t.Fatalf("GetPostByID() error = %v", err)
+	}
+	post.Content = "Adjusting the brakes"
+	if err := pc.UpdatePost(post, author); err != nil {
+		t.Fatalf("UpdatePost() error = %v", err)
+	}
+	if results, _ := sc.Search(models.SearchFilter{Query: "tyre"}); len(results) != 0 {
+		t.Errorf("Search(old content) = %+v, want nothing", results)
+	}
+	if results, _ := sc.Search(models.SearchFilter{Query: "brakes"}); len(results) != 1 {
+		t.Errorf("Search(new content) = %+v, want the post", results)
+	}
+
+	if err := pc.DeletePost(postID, author); err != nil {
+		t.Fatalf("DeletePost() error = %v", err)
+	}
+	if results, _ := sc.Search(models.SearchFilter{Query: "brakes"}); len(results) != 0 {
+		t.Errorf("Search() after delete = %+v, want nothing", results)
+	}
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+func TestSecurityEvents_RecordAndList(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	id, user := utils.CreateTestUser(t, testDB.DB)
+	userID := int(id)
+
+	r := httptest.NewRequest("POST", "/login", nil)
+	r.RemoteAddr = "203.0.113.7:5555"
+	r.Header.Set("User-Agent", "test-agent")
+
+	// Failures that only name an identifier are attached to its account
+	controllers.RecordSecurityEvent(testDB.DB, r, models.SecurityEvent{
+		EventType:  controllers.EventLoginFailed,
+		Identifier: user.Nickname,
+	})
+	controllers.RecordSecurityEvent(testDB.DB, r, models.SecurityEvent{
+		EventType:  controllers.EventLoginFailed,
+		Identifier: "nobody",
+	})
+	controllers.RecordSecurityEvent(testDB.DB, r, models.SecurityEvent{
+		UserID:    userID,
+		EventType: controllers.EventLoginSucceeded,
+		Details:   strings.Repeat("é", 500),
+	})
+
+	events, err := controllers.GetUserSecurityEvents(testDB.DB, userID, 0)
+	if err != nil {
+		t.Fatalf("GetUserSecurityEvents() error = %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("GetUserSecurityEvents() = %d events, want 2", len(events))
+	}
+	if events[0].EventType != controllers.EventLoginSucceeded || events[1].EventType != controllers.EventLoginFailed {
+		t.Errorf("events = %s, %s, want newest first", events[0].EventType, events[1].EventType)
+	}
+	if events[1].IPAddress != "203.0.113.7" || events[1].UserAgent != "test-agent" {
+		t.Errorf("event client = %q %q", events[1].IPAddress, events[1].UserAgent)
+	}
+	// Cut to fit without splitting the two-byte character at byte 255
+	if details := events[0].Details; len(details) != 254 || !utf8.ValidString(details) {
+		t.Errorf("details stored with %d bytes, valid UTF-8 %v, want 254 and true", len(details), utf8.ValidString(details))
+	}
+}
+
+func TestSecurityEvents_Search(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	first := insertRoleUser(t, testDB.DB, "first", models.RoleMember)
+	second := insertRoleUser(t, testDB.DB, "second", models.RoleMember)
+
+	record := func(userID int, eventType, ip string) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = ip + ":1234"
+		controllers.RecordSecurityEvent(testDB.DB, r, models.SecurityEvent{UserID: userID, EventType: eventType})
+	}
+	record(first, controllers.EventLoginFailed, "198.51.100.1")
+	record(first, controllers.EventLoginSucceeded, "198.51.100.1")
+	record(second, controllers.EventLoginFailed, "198.51.100.2")
+	record(0, controllers.EventInvalidToken, "198.51.100.2")
+
+	tests := []struct {
+		name   string
+		filter models.SecurityEventFilter
+		want   int
+	}{
+		{"everything", models.SecurityEventFilter{}, 4},
+		{"by user", models.SecurityEventFilter{UserID: first}, 2},
+		{"by type", models.SecurityEventFilter{EventType: controllers.EventLoginFailed}, 2},
+		{"by ip", models.SecurityEventFilter{IPAddress: "198.51.100.2"}, 2},
+		{"by user and type", models.SecurityEventFilter{UserID: first, EventType: controllers.EventLoginFailed}, 1},
+		{"since the future", models.SecurityEventFilter{Since: time.Now().Add(time.Hour)}, 0},
+		{"until the future", models.SecurityEventFilter{Until: time.Now().Add(time.Hour)}, 4},
+		{"limit", models.SecurityEventFilter{Limit: 3}, 3},
+		{"offset", models.SecurityEventFilter{Offset: 3}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := controllers.SearchSecurityEvents(testDB.DB, tt.filter)
+			if err != nil {
+				t.Fatalf("SearchSecurityEvents() error = %v", err)
+			}
+			if len(events) != tt.want {
+				t.Errorf("SearchSecurityEvents() = %d events, want %d", len(events), tt.want)
+			}
+		})
+	}
+}
+
+func TestSecurityEvents_AnonymousAreAggregated(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	record := func(eventType, ip string) {
+		r := httptest.NewRequest("GET", "/api/posts", nil)
+		r.RemoteAddr = ip + ":1234"
+		controllers.RecordAnonymousSecurityEvent(testDB.DB, r, models.SecurityEvent{EventType: eventType, Details: "GET /api/posts"})
+	}
+	// A client looping over a forged token gets one entry per event type
+	for i := 0; i < 20; i++ {
+		record(controllers.EventInvalidToken, "192.0.2.10")
+		record(controllers.EventInvalidSession, "192.0.2.10")
+	}
+	record(controllers.EventInvalidToken, "192.0.2.11")
+
+	tests := []struct {
+		name   string
+		filter models.SecurityEventFilter
+		want   int
+	}{
+		{"looping address", models.SecurityEventFilter{IPAddress: "192.0.2.10"}, 2},
+		{"looping address and type", models.SecurityEventFilter{IPAddress: "192.0.2.10", EventType: controllers.EventInvalidToken}, 1},
+		{"other address", models.SecurityEventFilter{IPAddress: "192.0.2.11"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := controllers.SearchSecurityEvents(testDB.DB, tt.filter)
+			if err != nil {
+				t.Fatalf("SearchSecurityEvents() error = %v", err)
+			}
+			if len(events) != tt.want {
+				t.Errorf("SearchSecurityEvents() = %d events, want %d", len(events), tt.want)
+			}
+		})
+	}
+}
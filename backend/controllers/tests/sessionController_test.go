@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// addDevice signs a user in on another device
+func addDevice(t *testing.T, db *sql.DB, userID int, id, userAgent string, lastUsed time.Time) {
+	session := models.Session{
+		ID:        id,
+		UserID:    userID,
+		UserAgent: userAgent,
+		IPAddress: "127.0.0.1",
+		CreatedAt: lastUsed,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := controllers.AddSessionWithToken(db, "cookie-"+id, "", session); err != nil {
+		t.Fatalf("AddSessionWithToken(%s) error = %v", id, err)
+	}
+}
+
+func sessionIDs(t *testing.T, db *sql.DB, userID int) []string {
+	sessions, err := controllers.GetUserSessions(db, userID)
+	if err != nil {
+		t.Fatalf("GetUserSessions() error = %v", err)
+	}
+	ids := []string{}
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+	return ids
+}
+
+func TestUserSessions_ListAndRevoke(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	alice := insertRoleUser(t, testDB.DB, "alice", models.RoleMember)
+	bob := insertRoleUser(t, testDB.DB, "bob", models.RoleMember)
+	now := time.Now()
+	addDevice(t, testDB.DB, alice, "laptop", "Firefox", now.Add(-2*time.Hour))
+	addDevice(t, testDB.DB, alice, "phone", "Safari", now.Add(-time.Hour))
+	addDevice(t, testDB.DB, alice, "tablet", "Chrome", now.Add(-3*time.Hour))
+	addDevice(t, testDB.DB, bob, "desktop", "Edge", now)
+
+	// Sessions on every device coexist, most recently used first
+	if got, want := sessionIDs(t, testDB.DB, alice), []string{"phone", "laptop", "tablet"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUserSessions(alice) = %v, want %v", got, want)
+	}
+	if err := controllers.TouchSession(testDB.DB, "cookie-tablet"); err != nil {
+		t.Fatalf("TouchSession() error = %v", err)
+	}
+	sessions, _ := controllers.GetUserSessions(testDB.DB, alice)
+	if sessions[0].ID != "tablet" || sessions[0].UserAgent != "Chrome" || sessions[0].IPAddress != "127.0.0.1" {
+		t.Errorf("GetUserSessions() after use = %+v, want tablet first with its device details", sessions[0])
+	}
+
+	// Nobody can revoke someone else's session
+	if err := controllers.RevokeUserSession(testDB.DB, alice, "desktop"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RevokeUserSession(bob's session) error = %v, want sql.ErrNoRows", err)
+	}
+	if got := sessionIDs(t, testDB.DB, bob); !reflect.DeepEqual(got, []string{"desktop"}) {
+		t.Errorf("GetUserSessions(bob) = %v, want [desktop]", got)
+	}
+
+	if err := controllers.RevokeUserSession(testDB.DB, alice, "laptop"); err != nil {
+		t.Fatalf("RevokeUserSession() error = %v", err)
+	}
+	if _, valid := controllers.IsValidSession(testDB.DB, "cookie-laptop"); valid {
+		t.Error("revoked session is still valid")
+	}
+	// Access tokens name their session by ID; a revoked one is no longer
+	// live, and neither is another user's
+	for _, tt := range []struct {
+		sessionID string
+		userID    int
+		want      bool
+	}{
+		{"phone", alice, true},
+		{"laptop", alice, false},
+		{"desktop", alice, false},
+	} {
+		if live, err := controllers.IsLiveSessionID(testDB.DB, tt.sessionID, tt.userID); err != nil || live != tt.want {
+			t.Errorf("IsLiveSessionID(%s) = %v, %v, want %v", tt.sessionID, live, err, tt.want)
+		}
+	}
+
+	// Signing out every other device keeps the current one, and nobody else's
+	revoked, err := controllers.RevokeOtherUserSessions(testDB.DB, alice, "phone")
+	if err != nil {
+		t.Fatalf("RevokeOtherUserSessions() error = %v", err)
+	}
+	if !reflect.DeepEqual(revoked, []string{"tablet"}) {
+		t.Errorf("RevokeOtherUserSessions() revoked %v, want [tablet]", revoked)
+	}
+	if got := sessionIDs(t, testDB.DB, alice); !reflect.DeepEqual(got, []string{"phone"}) {
+		t.Errorf("GetUserSessions(alice) after signing out others = %v, want [phone]", got)
+	}
+	if got := sessionIDs(t, testDB.DB, bob); !reflect.DeepEqual(got, []string{"desktop"}) {
+		t.Errorf("GetUserSessions(bob) after alice signed out others = %v, want [desktop]", got)
+	}
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+func TestSuspension_BlocksLoginAndRevokesAccess(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	ac := controllers.NewAuthController(testDB.DB)
+	id, user := utils.CreateTestUser(t, testDB.DB)
+	userID := int(id)
+	moderator := insertRoleUser(t, testDB.DB, "moderator", models.RoleModerator)
+
+	if err := controllers.AddSessionWithToken(testDB.DB, "session-token", "jwt", models.Session{
+		ID: "session-id", UserID: userID, ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now(),
+	}); err != nil {
+		t.Fatalf("AddSessionWithToken() error = %v", err)
+	}
+	if _, err := controllers.IssueRefreshToken(testDB.DB, "session-token", "session-id", userID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("IssueRefreshToken() error = %v", err)
+	}
+	if err := controllers.AddCSRFToken(testDB.DB, "session-token", "csrf", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("AddCSRFToken() error = %v", err)
+	}
+	if _, _, err := controllers.CreateAPIToken(testDB.DB, userID, "bot", []string{"posts:read"}, 0); err != nil {
+		t.Fatalf("CreateAPIToken() error = %v", err)
+	}
+
+	suspension, err := controllers.SuspendUser(testDB.DB, moderator, userID, "spam", 24*time.Hour, "")
+	if err != nil {
+		t.Fatalf("SuspendUser() error = %v", err)
+	}
+	if suspension.ContentPolicy != controllers.ContentPolicyKeep || suspension.Permanent() {
+		t.Errorf("suspension = %+v, want temporary with keep policy", suspension)
+	}
+
+	for _, table := range []string{"sessions", "refresh_tokens", "api_tokens"} {
+		var count int
+		testDB.DB.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE user_id = ?", userID).Scan(&count)
+		if count != 0 {
+			t.Errorf("%d %s left after suspension", count, table)
+		}
+	}
+	if _, _, err := controllers.GetCSRFToken(testDB.DB, "session-token"); err == nil {
+		t.Error("CSRF token left after suspension")
+	}
+
+	_, err = ac.AuthenticateUser(models.LoginRequest{Identifier: user.Email, Password: "testpassword"})
+	var suspended *controllers.AccountSuspendedError
+	if !errors.As(err, &suspended) || suspended.Suspension.Reason != "spam" {
+		t.Fatalf("AuthenticateUser() error = %v, want AccountSuspendedError", err)
+	}
+	// A wrong password must not reveal the suspension
+	_, err = ac.AuthenticateUser(models.LoginRequest{Identifier: user.Email, Password: "wrong"})
+	if errors.As(err, &suspended) {
+		t.Error("wrong password revealed the suspension")
+	}
+
+	if err := controllers.LiftSuspension(testDB.DB, moderator, userID); err != nil {
+		t.Fatalf("LiftSuspension() error = %v", err)
+	}
+	if _, err := ac.AuthenticateUser(models.LoginRequest{Identifier: user.Email, Password: "testpassword"}); err != nil {
+		t.Errorf("AuthenticateUser() after lift error = %v", err)
+	}
+	if err := controllers.LiftSuspension(testDB.DB, moderator, userID); !errors.Is(err, controllers.ErrNotSuspended) {
+		t.Errorf("second LiftSuspension() error = %v, want ErrNotSuspended", err)
+	}
+
+	history, err := controllers.GetUserSuspensions(testDB.DB, userID)
+	if err != nil || len(history) != 1 || history[0].LiftedAt == nil {
+		t.Errorf("GetUserSuspensions() = %+v, %v", history, err)
+	}
+}
+
+func TestSuspension_ExpiresAndBans(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	member := insertRoleUser(t, testDB.DB, "member", models.RoleMember)
+
+	// The command line suspends as actor 0
+	if _, err := controllers.SuspendUser(testDB.DB, 0, member, "cool down", time.Hour, ""); err != nil {
+		t.Fatalf("SuspendUser() error = %v", err)
+	}
+	testDB.DB.Exec("UPDATE user_suspensions SET expires_at = ? WHERE user_id = ?", time.Now().Add(-time.Minute), member)
+	if s, err := controllers.GetActiveSuspension(testDB.DB, member); err != nil || s != nil {
+		t.Errorf("GetActiveSuspension() after expiry = %+v, %v, want nil", s, err)
+	}
+
+	ban, err := controllers.SuspendUser(testDB.DB, 0, member, "repeat offender", 0, "")
+	if err != nil || !ban.Permanent() {
+		t.Fatalf("SuspendUser() ban = %+v, %v", ban, err)
+	}
+	active, err := controllers.GetActiveSuspension(testDB.DB, member)
+	if err != nil || active == nil || active.ID != ban.ID {
+		t.Errorf("GetActiveSuspension() = %+v, %v, want the ban", active, err)
+	}
+}
+
+func TestSuspension_Validation(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	member := insertRoleUser(t, testDB.DB, "member", models.RoleMember)
+	moderator := insertRoleUser(t, testDB.DB, "moderator", models.RoleModerator)
+	admin := insertRoleUser(t, testDB.DB, "admin", models.RoleAdmin)
+
+	tests := []struct {
+		name    string
+		actor   int
+		target  int
+		reason  string
+		policy  string
+		wantErr error
+	}{
+		{"member cannot suspend", member, moderator, "spam", "", controllers.ErrCannotSuspend},
+		{"moderator cannot suspend moderator", moderator, moderator, "spam", "", controllers.ErrCannotSuspend},
+		{"moderator cannot suspend admin", moderator, admin, "spam", "", controllers.ErrCannotSuspend},
+		{"reason required", admin, member, "  ", "", controllers.ErrSuspensionReason},
+		{"unknown policy", admin, member, "spam", "delete", controllers.ErrInvalidContentPolicy},
+		{"admin suspends moderator", admin, moderator, "spam", controllers.ContentPolicyKeep, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := controllers.SuspendUser(testDB.DB, tt.actor, tt.target, tt.reason, time.Hour, tt.policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SuspendUser() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSuspension_HideContentPolicy(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	pc := controllers.NewPostController(testDB.DB)
+	cc := controllers.NewCommentController(testDB.DB)
+
+	author := insertRoleUser(t, testDB.DB, "author", models.RoleMember)
+	spammer := insertRoleUser(t, testDB.DB, "spammer", models.RoleMember)
+	admin := insertRoleUser(t, testDB.DB, "admin", models.RoleAdmin)
+
+	postID := insertRolePost(t, testDB.DB, author)
+	spamPostID := insertRolePost(t, testDB.DB, spammer)
+	if _, err := cc.CreateComment(models.Comment{PostID: postID, UserID: spammer, Author: "spammer", Content: "buy now"}); err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+
+	if _, err := controllers.SuspendUser(testDB.DB, admin, spammer, "spam", 0, controllers.ContentPolicyHide); err != nil {
+		t.Fatalf("SuspendUser() error = %v", err)
+	}
+
+	posts, _, err := pc.GetAllPosts("", 10)
+	if err != nil || len(posts) != 1 || posts[0].ID != postID {
+		t.Fatalf("GetAllPosts() = %d posts, %v, want only the author's", len(posts), err)
+	}
+	if posts[0].CommentCount != 0 {
+		t.Errorf("hidden user's comment still counted")
+	}
+	if _, err := pc.GetPostByID(spamPostID); err == nil {
+		t.Error("GetPostByID() returned a hidden post")
+	}
+
+	if err := controllers.LiftSuspension(testDB.DB, admin, spammer); err != nil {
+		t.Fatalf("LiftSuspension() error = %v", err)
+	}
+	posts, _, err = pc.GetAllPosts("", 10)
+	if err != nil || len(posts) != 2 {
+		t.Errorf("GetAllPosts() after lift = %d posts, %v, want 2", len(posts), err)
+	}
+}
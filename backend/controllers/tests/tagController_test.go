@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+func TestParseHashtags(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Learning #Go and #rust, then #go again", []string{"go", "rust"}},
+		{"#start of text and end #tag_2.", []string{"start", "tag_2"}},
+		{"C# and page#anchor and ##double and &#39; are not tags", nil},
+		{"#123 needs a letter but #web3 has one", []string{"web3"}},
+		{"Unicode #café works", []string{"café"}},
+	}
+	for _, tt := range tests {
+		if got := utils.ParseHashtags(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseHashtags(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPostTags(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	author := insertRoleUser(t, testDB.DB, "author", models.RoleMember)
+	pc := controllers.NewPostController(testDB.DB)
+
+	explicit, err := controllers.ParseTags("#Beginners, help")
+	if err != nil {
+		t.Fatalf("ParseTags() error = %v", err)
+	}
+	postID, err := pc.InsertPost(models.Post{
+		UserID: author, Author: "author", Title: "Starting out", Content: "Where do I start with #golang?",
+		Category: "programming", Timestamp: time.Now(), Tags: explicit,
+	})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+	otherID, err := pc.InsertPost(models.Post{
+		UserID: author, Author: "author", Title: "Generics", Content: "#golang generics are neat",
+		Category: "programming", Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+
+	post, err := pc.GetPostByID(postID)
+	if err != nil {
+		t.Fatalf("GetPostByID() error = %v", err)
+	}
+	if want := []string{"beginners", "golang", "help"}; !reflect.DeepEqual(post.Tags, want) {
+		t.Errorf("post tags = %v, want %v", post.Tags, want)
+	}
+
+	posts, _, err := pc.GetTagPosts("golang", "", 10)
+	if err != nil || len(posts) != 2 {
+		t.Errorf("GetTagPosts(golang) = %d posts, %v, want 2", len(posts), err)
+	}
+
+	tags, err := controllers.GetTags(testDB.DB, "", 10)
+	if err != nil {
+		t.Fatalf("GetTags() error = %v", err)
+	}
+	if len(tags) != 3 || tags[0] != (models.Tag{Name: "golang", PostCount: 2}) {
+		t.Errorf("GetTags() = %+v, want golang first with 2 posts", tags)
+	}
+	if tags, _ := controllers.GetTags(testDB.DB, "he", 10); len(tags) != 1 || tags[0].Name != "help" {
+		t.Errorf("GetTags(he) = %+v, want help", tags)
+	}
+
+	// Editing the content swaps its hashtags and keeps the hand-given tags
+	post.Tags = controllers.ExplicitTags(post)
+	post.Content = "Settled on #rust instead"
+	if err := pc.UpdatePost(post, author); err != nil {
+		t.Fatalf("UpdatePost() error = %v", err)
+	}
+	post, _ = pc.GetPostByID(postID)
+	if want := []string{"beginners", "help", "rust"}; !reflect.DeepEqual(post.Tags, want) {
+		t.Errorf("edited post tags = %v, want %v", post.Tags, want)
+	}
+
+	if err := pc.DeletePost(otherID, author); err != nil {
+		t.Fatalf("DeletePost() error = %v", err)
+	}
+	if tags, _ := controllers.GetTags(testDB.DB, "go", 10); len(tags) != 0 {
+		t.Errorf("GetTags(go) after deleting its posts = %+v, want none", tags)
+	}
+
+	if _, err := controllers.ParseTags("ok, not-ok"); !errors.Is(err, controllers.ErrInvalidTag) {
+		t.Errorf("ParseTags(invalid) error = %v, want ErrInvalidTag", err)
+	}
+	_, err = pc.InsertPost(models.Post{
+		UserID: author, Author: "author", Title: "Spam", Content: "#a1 #a2 #a3 #a4 #a5 #a6 #a7 #a8 #a9 #a10 #a11",
+		Category: "other", Timestamp: time.Now(),
+	})
+	if !errors.Is(err, controllers.ErrTooManyTags) {
+		t.Errorf("InsertPost(11 tags) error = %v, want ErrTooManyTags", err)
+	}
+}
+
+func TestBackfillPostTags(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	author := insertRoleUser(t, testDB.DB, "author", models.RoleMember)
+	pc := controllers.NewPostController(testDB.DB)
+
+	// Posts written before tags existed have hashtags but no tags
+	insertOld := func(content string) int {
+		result, err := testDB.DB.Exec(`
+			INSERT INTO posts (user_id, author, title, content, category, timestamp)
+			VALUES (?, 'author', 'Old', ?, 'programming', ?)`, author, content, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		return int(id)
+	}
+	old := insertOld("Learning #Golang and #sql")
+	crowded := insertOld("#a1 #a2 #a3 #a4 #a5 #a6 #a7 #a8 #a9 #a10 #a11 #a12")
+	insertOld("Issue #1 is fixed")
+	insertOld("No tags here")
+	tagged, err := pc.InsertPost(models.Post{
+		UserID: author, Author: "author", Title: "New", Content: "Tagged by hand", Category: "programming",
+		Timestamp: time.Now(), Tags: []string{"help"},
+	})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+
+	if count, err := controllers.BackfillPostTags(testDB.DB); err != nil || count != 2 {
+		t.Fatalf("BackfillPostTags() = %d, %v, want 2", count, err)
+	}
+	for _, tt := range []struct {
+		postID int
+		want   []string
+	}{
+		{old, []string{"golang", "sql"}},
+		{crowded, []string{"a1", "a10", "a2", "a3", "a4", "a5", "a6", "a7", "a8", "a9"}},
+		{tagged, []string{"help"}},
+	} {
+		post, err := pc.GetPostByID(tt.postID)
+		if err != nil {
+			t.Fatalf("GetPostByID() error = %v", err)
+		}
+		if !reflect.DeepEqual(post.Tags, tt.want) {
+			t.Errorf("post %d tags = %v, want %v", tt.postID, post.Tags, tt.want)
+		}
+	}
+
+	// Running it again finds nothing to do
+	if count, err := controllers.BackfillPostTags(testDB.DB); err != nil || count != 0 {
+		t.Errorf("second BackfillPostTags() = %d, %v, want 0", count, err)
+	}
+}
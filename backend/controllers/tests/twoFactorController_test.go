@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/utils"
+)
+
+func TestGenerateTOTPCode_RFC6238(t *testing.T) {
+	// RFC 6238 appendix B, SHA1 key "12345678901234567890", truncated to 6 digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := utils.GenerateTOTPCode(secret, utils.TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("GenerateTOTPCode() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("GenerateTOTPCode(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTwoFactor_EnrollmentAndLogin(t *testing.T) {
+	// Initialize logger for tests
+	helper := utils.NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// Setup test database
+	testDB := utils.SetupTestDB(t)
+	defer testDB.Cleanup()
+
+	id, _ := utils.CreateTestUser(t, testDB.DB)
+	userID := int(id)
+
+	// Enabling requires setup first
+	if _, err := controllers.EnableTOTP(testDB.DB, userID, "123456"); !errors.Is(err, controllers.ErrTOTPNotStarted) {
+		t.Fatalf("EnableTOTP() before setup error = %v, want %v", err, controllers.ErrTOTPNotStarted)
+	}
+
+	secret, uri, err := controllers.BeginTOTPEnrollment(testDB.DB, userID)
+	if err != nil {
+		t.Fatalf("BeginTOTPEnrollment() error = %v", err)
+	}
+	if uri == "" || secret == "" {
+		t.Fatal("BeginTOTPEnrollment() returned an empty secret or URI")
+	}
+
+	// A wrong code does not enable 2FA
+	if _, err := controllers.EnableTOTP(testDB.DB, userID, "000000"); !errors.Is(err, controllers.ErrInvalidTwoFactorCode) {
+		t.Errorf("EnableTOTP(wrong code) error = %v, want %v", err, controllers.ErrInvalidTwoFactorCode)
+	}
+
+	now := utils.TOTPStep(time.Now())
+	code, _ := utils.GenerateTOTPCode(secret, now)
+	recoveryCodes, err := controllers.EnableTOTP(testDB.DB, userID, code)
+	if err != nil {
+		t.Fatalf("EnableTOTP() error = %v", err)
+	}
+	if len(recoveryCodes) != 10 {
+		t.Errorf("EnableTOTP() returned %d recovery codes, want 10", len(recoveryCodes))
+	}
+
+	// The code used to enable cannot be replayed at login
+	challenge, err := controllers.CreateLoginChallenge(testDB.DB, userID)
+	if err != nil {
+		t.Fatalf("CreateLoginChallenge() error = %v", err)
+	}
+	if _, err := controllers.CompleteLoginChallenge(testDB.DB, challenge, code); !errors.Is(err, controllers.ErrInvalidTwoFactorCode) {
+		t.Errorf("CompleteLoginChallenge(replayed code) error = %v, want %v", err, controllers.ErrInvalidTwoFactorCode)
+	}
+
+	// The next code completes the challenge, which then cannot be used again
+	next, _ := utils.GenerateTOTPCode(secret, now+1)
+	got, err := controllers.CompleteLoginChallenge(testDB.DB, challenge, next)
+	if err != nil || got != userID {
+		t.Fatalf("CompleteLoginChallenge() = %d, %v, want %d", got, err, userID)
+	}
+	if _, err := controllers.CompleteLoginChallenge(testDB.DB, challenge, next); !errors.Is(err, controllers.ErrInvalidLoginChallenge) {
+		t.Errorf("CompleteLoginChallenge(used challenge) error = %v, want %v", err, controllers.ErrInvalidLoginChallenge)
+	}
+
+	// Recovery codes work once
+	if err := controllers.VerifySecondFactor(testDB.DB, userID, recoveryCodes[0]); err != nil {
+		t.Errorf("VerifySecondFactor(recovery code) error = %v", err)
+	}
+	if err := controllers.VerifySecondFactor(testDB.DB, userID, recoveryCodes[0]); !errors.Is(err, controllers.ErrInvalidTwoFactorCode) {
+		t.Errorf("VerifySecondFactor(used recovery code) error = %v, want %v", err, controllers.ErrInvalidTwoFactorCode)
+	}
+
+	status, err := controllers.GetTwoFactorStatus(testDB.DB, userID)
+	if err != nil {
+		t.Fatalf("GetTwoFactorStatus() error = %v", err)
+	}
+	if !status.Enabled || status.RecoveryCodesRemaining != 9 {
+		t.Errorf("GetTwoFactorStatus() = %+v, want enabled with 9 codes", status)
+	}
+}
@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum/backend/utils"
+)
+
+const (
+	recoveryCodeCount = 10
+	// LoginChallengeTTL is how long a user has to enter their code after the password step
+	LoginChallengeTTL         = 5 * time.Minute
+	maxLoginChallengeAttempts = 5
+)
+
+var (
+	ErrTOTPAlreadyEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnabled        = errors.New("two-factor authentication is not enabled")
+	ErrTOTPNotStarted        = errors.New("two-factor setup has not been started")
+	ErrInvalidTwoFactorCode  = errors.New("invalid two-factor code")
+	ErrInvalidLoginChallenge = errors.New("login challenge is invalid or has expired")
+)
+
+// TwoFactorStatus describes a user's second factor settings
+type TwoFactorStatus struct {
+	Enabled                bool `json:"enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// GetTwoFactorStatus reports whether TOTP is on and how many recovery codes are unused
+func GetTwoFactorStatus(db *sql.DB, userID int) (TwoFactorStatus, error) {
+	var status TwoFactorStatus
+	err := db.QueryRow("SELECT totp_enabled FROM users WHERE id = ?", userID).Scan(&status.Enabled)
+	if err != nil {
+		return status, err
+	}
+	err = db.QueryRow("SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = ? AND used_at IS NULL", userID).
+		Scan(&status.RecoveryCodesRemaining)
+	return status, err
+}
+
+// IsTOTPEnabled reports whether login requires a second factor for the user
+func IsTOTPEnabled(db *sql.DB, userID int) (bool, error) {
+	var enabled bool
+	err := db.QueryRow("SELECT totp_enabled FROM users WHERE id = ?", userID).Scan(&enabled)
+	return enabled, err
+}
+
+// BeginTOTPEnrollment stores a new, not yet enabled secret for the user and
+// returns it with the provisioning URI for their authenticator app
+func BeginTOTPEnrollment(db *sql.DB, userID int) (string, string, error) {
+	var email string
+	var enabled bool
+	err := db.QueryRow("SELECT email, totp_enabled FROM users WHERE id = ?", userID).Scan(&email, &enabled)
+	if err != nil {
+		return "", "", err
+	}
+	if enabled {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	_, err = db.Exec("UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE id = ?", secret, userID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to store TOTP secret: %w", err)
+	}
+	return secret, utils.TOTPProvisioningURI(utils.TOTPIssuer(), email, secret), nil
+}
+
+// EnableTOTP turns on 2FA once the user proves their app produces valid codes,
+// and returns a fresh set of recovery codes to show them once
+func EnableTOTP(db *sql.DB, userID int, code string) ([]string, error) {
+	var secret sql.NullString
+	var enabled bool
+	err := db.QueryRow("SELECT totp_secret, totp_enabled FROM users WHERE id = ?", userID).Scan(&secret, &enabled)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if !secret.Valid || secret.String == "" {
+		return nil, ErrTOTPNotStarted
+	}
+
+	step, ok := utils.ValidateTOTP(secret.String, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET totp_enabled = TRUE, totp_last_step = ? WHERE id = ?", step, userID); err != nil {
+		return nil, fmt.Errorf("failed to enable TOTP: %w", err)
+	}
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// DisableTOTP turns off 2FA after checking a current code or recovery code
+func DisableTOTP(db *sql.DB, userID int, code string) error {
+	if err := VerifySecondFactor(db, userID, code); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0 WHERE id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("failed to disable TOTP: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return tx.Commit()
+}
+
+// RegenerateRecoveryCodes replaces all of the user's recovery codes
+func RegenerateRecoveryCodes(db *sql.DB, userID int, code string) ([]string, error) {
+	if err := VerifySecondFactor(db, userID, code); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// VerifySecondFactor accepts either a TOTP code, which may not be reused, or
+// an unused recovery code, which is consumed
+func VerifySecondFactor(db *sql.DB, userID int, code string) error {
+	var secret sql.NullString
+	var enabled bool
+	var lastStep int64
+	err := db.QueryRow("SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = ?", userID).
+		Scan(&secret, &enabled, &lastStep)
+	if err != nil {
+		return err
+	}
+	if !enabled || !secret.Valid {
+		return ErrTOTPNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) != utils.TOTPDigits {
+		return consumeRecoveryCode(db, userID, code)
+	}
+
+	step, ok := utils.ValidateTOTP(secret.String, code, time.Now())
+	if !ok || step <= lastStep {
+		return ErrInvalidTwoFactorCode
+	}
+	// Record the step so the same code cannot be replayed
+	res, err := db.Exec("UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?", step, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to record TOTP step: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// CreateLoginChallenge records that the user passed the password step and
+// returns the token the client presents with their code
+func CreateLoginChallenge(db *sql.DB, userID int) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = db.Exec("INSERT INTO login_challenges (token_hash, user_id, expires_at) VALUES (?, ?, ?)",
+		utils.HashToken(token), userID, time.Now().Add(LoginChallengeTTL))
+	if err != nil {
+		return "", fmt.Errorf("failed to store login challenge: %w", err)
+	}
+	return token, nil
+}
+
+// LoginChallengeUser returns the user a pending login challenge belongs to,
+// so the attempt can be throttled before any code is checked
+func LoginChallengeUser(db *sql.DB, token string) (int, error) {
+	var userID int
+	var expiresAt time.Time
+	err := db.QueryRow("SELECT user_id, expires_at FROM login_challenges WHERE token_hash = ?", utils.HashToken(token)).
+		Scan(&userID, &expiresAt)
+	if err == sql.ErrNoRows || (err == nil && time.Now().After(expiresAt)) {
+		return 0, ErrInvalidLoginChallenge
+	}
+	return userID, err
+}
+
+// CompleteLoginChallenge checks the second factor for a pending login and
+// returns the user it belongs to. A challenge allows a few wrong codes before
+// the user has to enter their password again. A wrong code still returns the
+// user, so the failure can be recorded against their account.
+func CompleteLoginChallenge(db *sql.DB, token, code string) (int, error) {
+	tokenHash := utils.HashToken(token)
+
+	var userID, attempts int
+	var expiresAt time.Time
+	err := db.QueryRow("SELECT user_id, attempts, expires_at FROM login_challenges WHERE token_hash = ?", tokenHash).
+		Scan(&userID, &attempts, &expiresAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidLoginChallenge
+	}
+	if err != nil {
+		return 0, err
+	}
+	if time.Now().After(expiresAt) || attempts >= maxLoginChallengeAttempts {
+		db.Exec("DELETE FROM login_challenges WHERE token_hash = ?", tokenHash)
+		return 0, ErrInvalidLoginChallenge
+	}
+
+	if err := VerifySecondFactor(db, userID, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			db.Exec("UPDATE login_challenges SET attempts = attempts + 1 WHERE token_hash = ?", tokenHash)
+			return userID, err
+		}
+		return 0, err
+	}
+
+	res, err := db.Exec("DELETE FROM login_challenges WHERE token_hash = ?", tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		// Another request completed the same challenge first
+		return 0, ErrInvalidLoginChallenge
+	}
+	return userID, nil
+}
+
+func consumeRecoveryCode(db *sql.DB, userID int, code string) error {
+	res, err := db.Exec(`
+		UPDATE user_recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		time.Now(), userID, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec("INSERT INTO user_recovery_codes (user_id, code_hash) VALUES (?, ?)",
+			userID, utils.HashToken(normalizeRecoveryCode(code)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to store recovery code: %w", err)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code like "k7f2q-9xw3m"
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+	return s[:5] + "-" + s[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"forum/backend/utils"
+)
+
+// Purposes of single-use tokens sent to a user by email
+const (
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeEmailVerify   = "email_verify"
+	TokenPurposeMagicLogin    = "magic_login"
+)
+
+var ErrInvalidUserToken = errors.New("link is invalid or has expired")
+
+// CreateUserToken issues a single-use token for the purpose. Earlier unused
+// tokens for the same purpose stop working, so only the latest email's link is valid.
+func CreateUserToken(db *sql.DB, userID int, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM user_tokens WHERE user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose)
+	if err != nil {
+		return "", fmt.Errorf("failed to revoke previous tokens: %w", err)
+	}
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO user_tokens (token_hash, user_id, purpose, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		utils.HashToken(token), userID, purpose, now, now.Add(ttl))
+	if err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+	return token, tx.Commit()
+}
+
+// ConsumeUserToken marks a token as used and returns its user. A token can
+// only be consumed once, and only for the purpose it was issued for.
+func ConsumeUserToken(db *sql.DB, token, purpose string) (int, error) {
+	tokenHash := utils.HashToken(token)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE user_tokens SET used_at = ?
+		WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?`,
+		time.Now(), tokenHash, purpose, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to use token: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return 0, ErrInvalidUserToken
+	}
+
+	var userID int
+	if err := tx.QueryRow("SELECT user_id FROM user_tokens WHERE token_hash = ?", tokenHash).Scan(&userID); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
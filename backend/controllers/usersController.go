@@ -8,8 +8,6 @@ import (
 
 	"forum/backend/models"
 	"forum/backend/utils"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UsersController struct {
@@ -29,11 +27,12 @@ func (uc *UsersController) VerifyPassword(userID int, currentPassword string) er
 		return err
 	}
 
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(currentPassword))
+	_, err = utils.CheckPassword(hashedPassword, currentPassword)
+	return err
 }
 
 func (uc *UsersController) UpdatePassword(userID int, newPassword string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashedPassword, err := utils.HashPassword(newPassword)
 	if err != nil {
 		return err
 	}
@@ -63,7 +62,7 @@ func (uc *UsersController) GetUsers(currentUserID, page, limit int) ([]models.Us
 			) as unread_messages_count
 		FROM users u
 		LEFT JOIN user_status us ON u.id = us.user_id
-		WHERE u.id != ?
+		WHERE u.id != ? AND u.deleted_at IS NULL
 		ORDER BY u.id DESC
 		LIMIT ? OFFSET ?
 	`
@@ -146,7 +145,7 @@ func (uc *UsersController) GetUserStats(userID int) (models.UserStats, error) {
 
 	err := utils.RetryOnLocked(uc.db, func() error {
 		// Get posts count
-		err := uc.db.QueryRow("SELECT COUNT(*) FROM posts WHERE user_id = ?", userID).Scan(&stats.PostsCount)
+		err := uc.db.QueryRow("SELECT COUNT(*) FROM posts WHERE user_id = ? AND status = 'published'", userID).Scan(&stats.PostsCount)
 		if err != nil {
 			return fmt.Errorf("failed to get posts count: %w", err)
 		}
@@ -185,7 +184,7 @@ func (uc *UsersController) SearchUsers(query string, currentUserID, page, limit
 			COALESCE(us.last_seen, datetime(u.created_at)) as last_seen
 		FROM users u
 		LEFT JOIN user_status us ON u.id = us.user_id
-		WHERE u.id != ? AND (
+		WHERE u.id != ? AND u.deleted_at IS NULL AND (
 			u.nickname LIKE ? OR
 			u.first_name LIKE ? OR
 			u.last_name LIKE ?
@@ -662,28 +661,10 @@ func (uc *UsersController) GetUserFriends(userID, offset, limit int) (map[string
 	return result, nil
 }
 
-func (uc *UsersController) DeleteUser(userID int) error {
-	// Start a transaction to ensure all deletions are atomic
-	tx, err := uc.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	// Since we have ON DELETE CASCADE set up in the database,
-	// we only need to delete from the users table and the rest will cascade
-	query := "DELETE FROM users WHERE id = ?"
-	_, err = tx.Exec(query, userID)
-	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
-	}
-
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	return nil
+// DeleteUser schedules the account for deletion. Nothing is removed until
+// the grace period is over, and signing in again cancels it.
+func (uc *UsersController) DeleteUser(userID int) (time.Time, error) {
+	return ScheduleAccountDeletion(uc.db, userID)
 }
 
 func (uc *UsersController) GetUserPhotos(userID int) ([]string, error) {
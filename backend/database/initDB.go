@@ -2,10 +2,14 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"forum/backend/logger"
 )
 
 var (
@@ -23,7 +27,15 @@ var (
 			profession TEXT,
 			avatar TEXT,
 			cover_image TEXT,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			totp_secret TEXT,
+			totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			totp_last_step INTEGER NOT NULL DEFAULT 0,
+			email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+			role TEXT NOT NULL DEFAULT 'member',
+			content_hidden BOOLEAN NOT NULL DEFAULT FALSE,
+			delete_after DATETIME,
+			deleted_at DATETIME
 		);
 
 		CREATE INDEX IF NOT EXISTS idx_users_nickname ON users(nickname);
@@ -49,11 +61,13 @@ var (
             author TEXT NOT NULL,
             user_id INTEGER NOT NULL,
             category TEXT NOT NULL,
-            likes INTEGER DEFAULT 0,
-            dislikes INTEGER DEFAULT 0,
             content TEXT NOT NULL,
             video_url TEXT,
             timestamp DATETIME NOT NULL,
+            locked BOOLEAN NOT NULL DEFAULT FALSE,
+            edited_at DATETIME,
+            status TEXT NOT NULL DEFAULT 'published' CHECK(status IN ('draft', 'scheduled', 'published')),
+            publish_at DATETIME,
             FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
         );
 
@@ -64,15 +78,6 @@ var (
             FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
         );
 
-		CREATE TABLE IF NOT EXISTS user_votes (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-            post_id INTEGER NOT NULL,
-            user_id INTEGER NOT NULL,
-            user_vote TEXT CHECK(user_vote IN ('like', 'dislike')),
-            FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
-			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
-		);
-
 		CREATE TABLE IF NOT EXISTS comments (
             id INTEGER PRIMARY KEY AUTOINCREMENT,
             post_id INTEGER NOT NULL,
@@ -93,9 +98,232 @@ var (
 			jwt_token TEXT,
 			user_id INTEGER NOT NULL,
 			expires_at DATETIME NOT NULL,
+			id TEXT,
+			user_agent TEXT,
+			ip_address TEXT,
+			created_at DATETIME,
+			last_used_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			token_hash TEXT NOT NULL UNIQUE,
+			session_token TEXT NOT NULL,
+			family_id TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			used_at DATETIME,
+			FOREIGN KEY (session_token) REFERENCES sessions (session_token) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
+
+		CREATE TABLE IF NOT EXISTS user_recovery_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			code_hash TEXT NOT NULL,
+			used_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user ON user_recovery_codes(user_id);
+
+		CREATE TABLE IF NOT EXISTS login_challenges (
+			token_hash TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			expires_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS user_tokens (
+			token_hash TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			purpose TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			used_at DATETIME,
 			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
 		);
 
+		CREATE INDEX IF NOT EXISTS idx_user_tokens_user ON user_tokens(user_id, purpose);
+
+		CREATE TABLE IF NOT EXISTS mail_outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recipient TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			body TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			next_attempt_at DATETIME NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			sent_at DATETIME
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_mail_outbox_pending ON mail_outbox(sent_at, next_attempt_at);
+
+		CREATE TABLE IF NOT EXISTS login_throttles (
+			scope TEXT NOT NULL,
+			throttle_key TEXT NOT NULL,
+			failures INTEGER NOT NULL DEFAULT 0,
+			last_failure_at DATETIME NOT NULL,
+			locked_until DATETIME,
+			PRIMARY KEY (scope, throttle_key)
+		);
+
+		CREATE TABLE IF NOT EXISTS user_identities (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			issuer TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			email TEXT,
+			created_at DATETIME NOT NULL,
+			UNIQUE (issuer, subject),
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS oidc_states (
+			state_hash TEXT PRIMARY KEY,
+			code_verifier TEXT NOT NULL,
+			nonce TEXT NOT NULL,
+			link_user_id INTEGER,
+			expires_at DATETIME NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS api_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			token_prefix TEXT NOT NULL,
+			scopes TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			last_used_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_api_tokens_user ON api_tokens(user_id);
+
+		CREATE TABLE IF NOT EXISTS user_suspensions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			suspended_by INTEGER,
+			reason TEXT NOT NULL,
+			content_policy TEXT NOT NULL DEFAULT 'keep',
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME,
+			lifted_at DATETIME,
+			lifted_by INTEGER,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+			FOREIGN KEY (suspended_by) REFERENCES users (id) ON DELETE SET NULL,
+			FOREIGN KEY (lifted_by) REFERENCES users (id) ON DELETE SET NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_user_suspensions_user ON user_suspensions(user_id);
+
+		CREATE TABLE IF NOT EXISTS security_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER,
+			event_type TEXT NOT NULL,
+			identifier TEXT,
+			ip_address TEXT,
+			user_agent TEXT,
+			details TEXT,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_security_events_user ON security_events(user_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_security_events_created ON security_events(created_at);
+
+		CREATE TABLE IF NOT EXISTS data_exports (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'processing', 'ready', 'failed')),
+			file_path TEXT,
+			size_bytes INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			created_at DATETIME NOT NULL,
+			completed_at DATETIME,
+			expires_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id);
+		CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports(status);
+
+		CREATE TABLE IF NOT EXISTS impersonations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			admin_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			session_id TEXT NOT NULL UNIQUE,
+			admin_session_token TEXT,
+			reason TEXT NOT NULL,
+			started_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			ended_at DATETIME,
+			FOREIGN KEY (admin_id) REFERENCES users (id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_impersonations_admin ON impersonations(admin_id, started_at);
+
+		CREATE TABLE IF NOT EXISTS post_revisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			post_id INTEGER NOT NULL,
+			revision INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			content TEXT NOT NULL,
+			category TEXT NOT NULL,
+			edited_by INTEGER,
+			created_at DATETIME NOT NULL,
+			UNIQUE (post_id, revision),
+			FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+			FOREIGN KEY (edited_by) REFERENCES users (id) ON DELETE SET NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS categories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			slug TEXT NOT NULL UNIQUE,
+			name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+			description TEXT NOT NULL DEFAULT '',
+			position INTEGER NOT NULL DEFAULT 0,
+			archived BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS post_tags (
+			post_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (post_id, tag_id),
+			FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+			FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_post_tags_tag_id ON post_tags(tag_id);
+
+		CREATE TABLE IF NOT EXISTS reactions (
+			entity_type TEXT NOT NULL CHECK(entity_type IN ('post', 'comment', 'message')),
+			entity_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			reaction TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (entity_type, entity_id, user_id, reaction),
+			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_reactions_user_id ON reactions(user_id);
+
 		CREATE TABLE IF NOT EXISTS csrf_tokens (
 			session_token TEXT NOT NULL,
 			csrf_token TEXT NOT NULL,
@@ -178,8 +406,118 @@ var (
 		CREATE INDEX IF NOT EXISTS idx_notifications_recipient ON notifications(recipient_id);
 		CREATE INDEX IF NOT EXISTS idx_notifications_created_at ON notifications(created_at);
 	`
+
+	// MigrationQueries bring databases created by older versions up to the
+	// current schema. Each statement must be safe to run more than once.
+	MigrationQueries = []string{
+		`ALTER TABLE sessions ADD COLUMN id TEXT`,
+		`ALTER TABLE sessions ADD COLUMN user_agent TEXT`,
+		`ALTER TABLE sessions ADD COLUMN ip_address TEXT`,
+		`ALTER TABLE sessions ADD COLUMN created_at DATETIME`,
+		`ALTER TABLE sessions ADD COLUMN last_used_at DATETIME`,
+		`UPDATE sessions SET id = lower(hex(randomblob(16))) WHERE id IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_id ON sessions(id)`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)`,
+		`ALTER TABLE users ADD COLUMN totp_secret TEXT`,
+		`ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'member'`,
+		`ALTER TABLE posts ADD COLUMN locked BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE users ADD COLUMN content_hidden BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE users ADD COLUMN delete_after DATETIME`,
+		`ALTER TABLE users ADD COLUMN deleted_at DATETIME`,
+		`ALTER TABLE posts ADD COLUMN edited_at DATETIME`,
+		`ALTER TABLE posts ADD COLUMN status TEXT NOT NULL DEFAULT 'published' CHECK(status IN ('draft', 'scheduled', 'published'))`,
+		`ALTER TABLE posts ADD COLUMN publish_at DATETIME`,
+		`CREATE INDEX IF NOT EXISTS idx_posts_status_publish_at ON posts(status, publish_at)`,
+		// Likes and dislikes on posts are reactions now; see migrateVotes
+		`ALTER TABLE posts DROP COLUMN likes`,
+		`ALTER TABLE posts DROP COLUMN dislikes`,
+	}
 )
 
+// defaultCategories are the categories a new forum starts with
+var defaultCategories = []struct{ slug, name string }{
+	{"technology", "Technology"},
+	{"design", "Design"},
+	{"programming", "Programming"},
+	{"lifestyle", "Lifestyle"},
+	{"gaming", "Gaming"},
+	{"other", "Other"},
+}
+
+// SeedCategories adds the default categories while there are none
+func SeedCategories(db *sql.DB) error {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM categories").Scan(&count); err != nil {
+		return fmt.Errorf("failed to count categories: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	for i, category := range defaultCategories {
+		if _, err := db.Exec("INSERT INTO categories (slug, name, position) VALUES (?, ?, ?)",
+			category.slug, category.name, i+1); err != nil {
+			return fmt.Errorf("failed to add category %s: %w", category.slug, err)
+		}
+	}
+	return nil
+}
+
+// ApplyMigrations runs MigrationQueries, skipping columns that already exist
+// or are already gone. Votes are moved into reactions before the post
+// counters they fed are dropped.
+func ApplyMigrations(db *sql.DB) error {
+	if err := migrateVotes(db); err != nil {
+		return err
+	}
+	for _, query := range MigrationQueries {
+		if _, err := db.Exec(query); err != nil {
+			if strings.Contains(err.Error(), "duplicate column name") || strings.Contains(err.Error(), "no such column") {
+				continue
+			}
+			return fmt.Errorf("migration %q failed: %w", query, err)
+		}
+	}
+	return nil
+}
+
+// migrateVotes moves the likes and dislikes of the old user_votes table into
+// reactions, then drops the table. Databases that never had it are left alone.
+func migrateVotes(db *sql.DB) error {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'user_votes')").Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to look for user_votes: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO reactions (entity_type, entity_id, user_id, reaction)
+		SELECT 'post', post_id, user_id, user_vote FROM user_votes WHERE user_vote IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to move votes into reactions: %w", err)
+	}
+	if _, err := tx.Exec("DROP TABLE user_votes"); err != nil {
+		return fmt.Errorf("failed to drop user_votes: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	moved, _ := result.RowsAffected()
+	logger.Info("Moved %d post votes into reactions", moved)
+	return nil
+}
+
 // InitializeDatabase creates all necessary tables if they don't exist
 func InitializeDatabase() (*sql.DB, error) {
 	// Create database directory in backend folder
@@ -188,18 +526,14 @@ func InitializeDatabase() (*sql.DB, error) {
 		log.Fatal("Failed to create database directory:", err)
 	}
 
-	// Open database connection with absolute path
+	// Open database connection with absolute path. Foreign keys are enabled
+	// in the DSN so every pooled connection enforces them, not just the first.
 	dbPath := filepath.Join(dbDir, "forum.db")
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
 	if err != nil {
 		log.Fatal("Failed to open database:", err)
 	}
 
-	_, err = db.Exec("PRAGMA foreign_keys = ON;")
-	if err != nil {
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
-	}
-
 	GloabalDB = db
 	// Create tables
 	_, err = db.Exec(TableQueries)
@@ -207,6 +541,28 @@ func InitializeDatabase() (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to create tables: %w", err)
 	}
 
+	if err := ApplyMigrations(db); err != nil {
+		return nil, err
+	}
+
+	if err := SeedCategories(db); err != nil {
+		return nil, err
+	}
+
+	fullText, err := SetupSearchIndex(db)
+	if err != nil {
+		return nil, err
+	}
+	// A build without the tag would quietly give worse search, so it has to
+	// be asked for
+	if !fullText {
+		if os.Getenv("SEARCH_FALLBACK") != "like" {
+			return nil, errors.New("SQLite was built without FTS5: build with -tags sqlite_fts5 (see the Makefile), " +
+				"or set SEARCH_FALLBACK=like to search with LIKE matching instead")
+		}
+		logger.Warning("SQLite was built without FTS5, search falls back to LIKE matching")
+	}
+
 	// Enable WAL mode
 	_, err = db.Exec("PRAGMA journal_mode=WAL")
 	if err != nil {
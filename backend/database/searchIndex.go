@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SearchIndexQueries create the full-text indexes of posts and comments and
+// the triggers that keep them in step with their tables. They need SQLite
+// built with FTS5, which go-sqlite3 enables with the sqlite_fts5 build tag.
+const SearchIndexQueries = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(
+		title, content,
+		content='posts', content_rowid='id',
+		tokenize='unicode61 remove_diacritics 2'
+	);
+
+	CREATE TRIGGER IF NOT EXISTS posts_fts_insert AFTER INSERT ON posts BEGIN
+		INSERT INTO posts_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts BEGIN
+		INSERT INTO posts_fts (posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+		INSERT INTO posts_fts (posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+		INSERT INTO posts_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+	END;
+
+	CREATE VIRTUAL TABLE IF NOT EXISTS comments_fts USING fts5(
+		content,
+		content='comments', content_rowid='id',
+		tokenize='unicode61 remove_diacritics 2'
+	);
+
+	CREATE TRIGGER IF NOT EXISTS comments_fts_insert AFTER INSERT ON comments BEGIN
+		INSERT INTO comments_fts (rowid, content) VALUES (new.id, new.content);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS comments_fts_delete AFTER DELETE ON comments BEGIN
+		INSERT INTO comments_fts (comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS comments_fts_update AFTER UPDATE OF content ON comments BEGIN
+		INSERT INTO comments_fts (comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+		INSERT INTO comments_fts (rowid, content) VALUES (new.id, new.content);
+	END;
+`
+
+// searchIndexTriggers would break every write to posts and comments if they
+// were left behind by a build with FTS5 and run by one without it
+var searchIndexTriggers = []string{
+	"posts_fts_insert", "posts_fts_delete", "posts_fts_update",
+	"comments_fts_insert", "comments_fts_delete", "comments_fts_update",
+}
+
+// SetupSearchIndex creates the full-text indexes and reports whether they are
+// available. Without FTS5 it removes any triggers an earlier build created,
+// and search falls back to matching with LIKE. Indexes that were missing or
+// out of date are rebuilt from the posts and comments tables.
+func SetupSearchIndex(db *sql.DB) (bool, error) {
+	var triggers int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'posts_fts_insert'").
+		Scan(&triggers)
+	if err != nil {
+		return false, fmt.Errorf("failed to check search index: %w", err)
+	}
+
+	var fts5 bool
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		return false, fmt.Errorf("failed to check for FTS5: %w", err)
+	}
+	if !fts5 {
+		for _, trigger := range searchIndexTriggers {
+			if _, err := db.Exec("DROP TRIGGER IF EXISTS " + trigger); err != nil {
+				return false, fmt.Errorf("failed to drop trigger %s: %w", trigger, err)
+			}
+		}
+		return false, nil
+	}
+
+	if _, err := db.Exec(SearchIndexQueries); err != nil {
+		return false, fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	// Without the triggers in place the indexes missed every change
+	if triggers == 0 {
+		for _, table := range []string{"posts_fts", "comments_fts"} {
+			if _, err := db.Exec(fmt.Sprintf("INSERT INTO %[1]s (%[1]s) VALUES ('rebuild')", table)); err != nil {
+				return false, fmt.Errorf("failed to rebuild %s: %w", table, err)
+			}
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// ForgotPasswordHandler emails a password reset link. The response is the
+// same whether or not the address belongs to an account.
+func ForgotPasswordHandler(ac *controllers.AuthController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var requestData struct {
+			Email string `json:"email"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil || !ac.IsValidEmail(requestData.Email) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "A valid email is required"})
+			return
+		}
+
+		if err := ac.RequestPasswordReset(ac.SanitizeInput(requestData.Email)); err != nil {
+			logger.Error("Failed to start password reset: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to send reset email"})
+			return
+		}
+		controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{
+			EventType:  controllers.EventPasswordResetRequested,
+			Identifier: requestData.Email,
+		})
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "If an account uses that email, a reset link has been sent",
+		})
+	}
+}
+
+// ResetPasswordHandler sets a new password from a reset link and ends all of the user's sessions
+func ResetPasswordHandler(ac *controllers.AuthController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var requestData struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil || requestData.Token == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
+			return
+		}
+		if !ac.IsValidPassword(requestData.Password) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Password does not meet the requirements"})
+			return
+		}
+
+		userID, err := ac.ResetPassword(requestData.Token, ac.SanitizeInput(requestData.Password))
+		if errors.Is(err, controllers.ErrInvalidUserToken) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to reset password: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to reset password"})
+			return
+		}
+
+		// Sessions were removed with the old password; drop their sockets too
+		utils.CloseUserConnections(userID)
+		utils.MarkUserOffline(userID)
+
+		logger.Info("User %d reset their password", userID)
+		controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{UserID: userID, EventType: controllers.EventPasswordReset})
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Password updated, please log in",
+		})
+	}
+}
+
+// SendVerificationEmailHandler sends a new confirmation link to the current user
+func SendVerificationEmailHandler(ac *controllers.AuthController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		err = ac.SendVerificationEmail(userID)
+		if errors.Is(err, controllers.ErrEmailAlreadyVerified) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to send verification email: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to send verification email"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Verification email sent",
+		})
+	}
+}
+
+// VerifyEmailHandler is the target of the emailed link. It confirms the
+// address and sends the browser back to the app with the outcome.
+func VerifyEmailHandler(ac *controllers.AuthController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, err := ac.VerifyEmail(r.URL.Query().Get("token"))
+		if err != nil {
+			if !errors.Is(err, controllers.ErrInvalidUserToken) {
+				logger.Error("Failed to verify email: %v", err)
+			}
+			http.Redirect(w, r, "/?email_verified=0", http.StatusSeeOther)
+			return
+		}
+
+		logger.Info("User %d verified their email address", userID)
+		http.Redirect(w, r, "/?email_verified=1", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	"forum/backend/models"
+)
+
+// GetAPITokensHandler lists the user's personal access tokens and the scopes they can have
+func GetAPITokensHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			logger.Error("Invalid user ID: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		tokens, err := controllers.GetAPITokens(db, userID)
+		if err != nil {
+			logger.Error("Failed to get API tokens: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch API tokens"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"tokens":           tokens,
+			"available_scopes": controllers.APITokenScopes,
+		})
+	}
+}
+
+// CreateAPITokenHandler mints a token from {"name", "scopes", "expires_in_days"}.
+// The token is in the response once and cannot be shown again.
+func CreateAPITokenHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			logger.Error("Invalid user ID: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		var req struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`
+			ExpiresInDays int      `json:"expires_in_days"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+			return
+		}
+
+		lifetime := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+		token, info, err := controllers.CreateAPIToken(db, userID, req.Name, req.Scopes, lifetime)
+		switch {
+		case errors.Is(err, controllers.ErrInvalidTokenName),
+			errors.Is(err, controllers.ErrInvalidTokenScope),
+			errors.Is(err, controllers.ErrInvalidTokenExpiry),
+			errors.Is(err, controllers.ErrTooManyAPITokens):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			logger.Error("Failed to create API token: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create API token"})
+			return
+		}
+
+		logger.Info("User %d created API token %d with scopes %v", userID, info.ID, info.Scopes)
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+			UserID:    userID,
+			EventType: controllers.EventAPITokenCreated,
+			Details:   fmt.Sprintf("%s (%s) %s", info.Name, info.Prefix, strings.Join(info.Scopes, " ")),
+		})
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":   token,
+			"details": info,
+		})
+	}
+}
+
+// RevokeAPITokenHandler deletes the token given by ?id=
+func RevokeAPITokenHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			logger.Error("Invalid user ID: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		tokenID, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Token id is required"})
+			return
+		}
+
+		err = controllers.RevokeAPIToken(db, userID, tokenID)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Token not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to revoke API token: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to revoke API token"})
+			return
+		}
+
+		logger.Info("User %d revoked API token %d", userID, tokenID)
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+			UserID:    userID,
+			EventType: controllers.EventAPITokenRevoked,
+			Details:   fmt.Sprintf("token %d", tokenID),
+		})
+		json.NewEncoder(w).Encode(map[string]string{"message": "Token revoked successfully"})
+	}
+}
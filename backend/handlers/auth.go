@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"forum/backend/controllers"
 	"forum/backend/database"
@@ -54,6 +59,12 @@ func RegisterHandler(ac *controllers.AuthController) http.HandlerFunc {
 		}
 
 		logger.Info("User registered successfully userID: %d (nickname: %s, email: %s)", userID, user.Nickname, user.Email)
+		controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{UserID: int(userID), EventType: controllers.EventAccountCreated})
+
+		// Ask the user to confirm their address; registration succeeds even if this fails
+		if err := ac.SendVerificationEmail(int(userID)); err != nil {
+			logger.Error("Failed to send verification email to user %d: %v", userID, err)
+		}
 
 		// After successful registration, broadcast new user
 		newUserEvent := map[string]interface{}{
@@ -113,10 +124,46 @@ func LoginHandler(ac *controllers.AuthController) http.HandlerFunc {
 		credentials.Identifier = ac.SanitizeInput(credentials.Identifier)
 		credentials.Password = ac.SanitizeInput(credentials.Password)
 
+		// Refuse attempts while the account or address is backing off
+		clientIP := utils.ClientIP(r)
+		retryAfter, err := controllers.CheckLoginAllowed(ac.DB, credentials.Identifier, clientIP)
+		if err != nil {
+			logger.Error("Failed to check login throttle: %v", err)
+		}
+		if retryAfter > 0 {
+			controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{
+				EventType:  controllers.EventLoginThrottled,
+				Identifier: credentials.Identifier,
+			})
+			writeTooManyAttempts(w, retryAfter)
+			return
+		}
+
 		// Authenticate user
 		user, err := ac.AuthenticateUser(credentials)
+		var suspended *controllers.AccountSuspendedError
+		if errors.As(err, &suspended) {
+			// The password was right, so this is not a failed attempt
+			controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{
+				UserID:    suspended.Suspension.UserID,
+				EventType: controllers.EventLoginRefused,
+				Details:   "account suspended",
+			})
+			writeAccountSuspended(w, suspended.Suspension)
+			return
+		}
 		if err != nil {
 			logger.Error("Authentication failed: %v", err)
+			controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{
+				EventType:  controllers.EventLoginFailed,
+				Identifier: credentials.Identifier,
+				Details:    err.Error(),
+			})
+			throttle, throttleErr := controllers.RecordLoginFailure(ac.DB, credentials.Identifier, clientIP)
+			if throttleErr != nil {
+				logger.Error("Failed to record login failure: %v", throttleErr)
+			}
+			reportLockout(ac.DB, r, throttle, clientIP)
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]string{
@@ -124,27 +171,343 @@ func LoginHandler(ac *controllers.AuthController) http.HandlerFunc {
 			})
 			return
 		}
+		beginSession(ac, w, r, user)
+	}
+}
+
+// LoginTwoFactorHandler is the second login step for accounts with 2FA. It
+// takes the challenge from the password step and a TOTP or recovery code.
+func LoginTwoFactorHandler(ac *controllers.AuthController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var requestData struct {
+			Challenge string `json:"challenge"`
+			Code      string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil || requestData.Challenge == "" || requestData.Code == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Challenge and code are required",
+			})
+			return
+		}
+
+		// Codes are throttled like passwords: the challenge allows a few
+		// wrong codes, and the account and address back off across challenges
+		clientIP := utils.ClientIP(r)
+		userID, err := controllers.LoginChallengeUser(ac.DB, requestData.Challenge)
+		if err == nil {
+			retryAfter, throttleErr := controllers.CheckTwoFactorAllowed(ac.DB, userID, clientIP)
+			if throttleErr != nil {
+				logger.Error("Failed to check login throttle: %v", throttleErr)
+			}
+			if retryAfter > 0 {
+				controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{
+					UserID:    userID,
+					EventType: controllers.EventLoginThrottled,
+					Details:   "second factor",
+				})
+				writeTooManyAttempts(w, retryAfter)
+				return
+			}
+			userID, err = controllers.CompleteLoginChallenge(ac.DB, requestData.Challenge, requestData.Code)
+		}
+		if errors.Is(err, controllers.ErrInvalidTwoFactorCode) || errors.Is(err, controllers.ErrInvalidLoginChallenge) {
+			logger.Warning("Second login step failed: %v", err)
+			controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{
+				UserID:    userID,
+				EventType: controllers.EventTwoFactorFailed,
+				Details:   err.Error(),
+			})
+			throttle, throttleErr := controllers.RecordTwoFactorFailure(ac.DB, userID, clientIP)
+			if throttleErr != nil {
+				logger.Error("Failed to record login failure: %v", throttleErr)
+			}
+			reportLockout(ac.DB, r, throttle, clientIP)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to verify second factor: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to log in",
+			})
+			return
+		}
 
-		token, err := auth.CreateSession(ac.DB, w, int(user.ID))
+		user, err := ac.GetUserByID(userID)
 		if err != nil {
-			logger.Error("Failed to create session: %v", err)
+			logger.Error("Failed to load user %d after second factor: %v", userID, err)
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Failed to create session",
+				"error": "Failed to log in",
 			})
 			return
 		}
-		logger.Info("User logged in successfully userID: %d (nickname: %s, email: %s)", user.ID, user.Nickname, user.Email)
 
-		// Generate token
+		completeLogin(ac, w, r, user)
+	}
+}
+
+// MagicLinkRequestHandler emails a single-use sign-in link to {"email"}. The
+// answer is the same whether or not an account uses the address.
+func MagicLinkRequestHandler(ac *controllers.AuthController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var requestData struct {
+			Email string `json:"email"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil || !ac.IsValidEmail(requestData.Email) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "A valid email is required"})
+			return
+		}
+		email := ac.SanitizeInput(requestData.Email)
+
+		// A locked account cannot get around the lockout by email
+		retryAfter, err := controllers.CheckLoginAllowed(ac.DB, email, utils.ClientIP(r))
+		if err != nil {
+			logger.Error("Failed to check login throttle: %v", err)
+		}
+		if retryAfter > 0 {
+			controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{
+				EventType:  controllers.EventLoginThrottled,
+				Identifier: email,
+				Details:    "sign-in link",
+			})
+			writeTooManyAttempts(w, retryAfter)
+			return
+		}
+
+		if err := ac.RequestMagicLink(email); err != nil {
+			logger.Error("Failed to send sign-in link: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to send sign-in link"})
+			return
+		}
+		controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{
+			EventType:  controllers.EventMagicLinkRequested,
+			Identifier: email,
+		})
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "If an account uses that email, a sign-in link has been sent",
+		})
+	}
+}
+
+// MagicLinkLoginHandler signs in with the token from a sign-in link and
+// answers like LoginHandler. The emailed link opens a page that posts the
+// token here, so mail scanners following the link do not use it up.
+func MagicLinkLoginHandler(ac *controllers.AuthController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var requestData struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil || requestData.Token == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Token is required"})
+			return
+		}
+
+		user, err := ac.ConsumeMagicLink(requestData.Token)
+		var suspended *controllers.AccountSuspendedError
+		if errors.As(err, &suspended) {
+			controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{
+				UserID:    suspended.Suspension.UserID,
+				EventType: controllers.EventLoginRefused,
+				Details:   "account suspended",
+			})
+			writeAccountSuspended(w, suspended.Suspension)
+			return
+		}
+		if errors.Is(err, controllers.ErrInvalidUserToken) {
+			controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{
+				EventType: controllers.EventLoginFailed,
+				Details:   "invalid sign-in link",
+			})
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to sign in with link: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to log in"})
+			return
+		}
+
+		beginSession(ac, w, r, user)
+	}
+}
+
+// CurrentUserHandler returns the account fields the app keeps after login
+func CurrentUserHandler(ac *controllers.AuthController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		user, err := ac.GetUserByID(userID)
+		if err != nil {
+			logger.Error("Failed to load user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load user"})
+			return
+		}
+		json.NewEncoder(w).Encode(user)
+	}
+}
 
+// writeTooManyAttempts answers a throttled login with 429 and Retry-After in whole seconds
+func writeTooManyAttempts(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       fmt.Sprintf("Too many failed login attempts. Try again in %d seconds.", seconds),
+		"retry_after": seconds,
+	})
+}
+
+// writeAccountSuspended answers a login by a suspended user with 403, the reason and when it ends
+func writeAccountSuspended(w http.ResponseWriter, suspension models.Suspension) {
+	message := "Your account has been banned"
+	if !suspension.Permanent() {
+		message = "Your account is suspended until " + suspension.ExpiresAt.Format(time.RFC1123)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      message,
+		"suspended":  true,
+		"reason":     suspension.Reason,
+		"expires_at": suspension.ExpiresAt,
+	})
+}
+
+// beginSession signs in a user whose first factor has been checked: accounts
+// with 2FA get a challenge for the second step, everyone else a session
+func beginSession(ac *controllers.AuthController, w http.ResponseWriter, r *http.Request, user *models.User) {
+	// Accounts with 2FA get a challenge instead of a session
+	challenge, err := secondFactorChallenge(ac.DB, int(user.ID))
+	if err != nil {
+		logger.Error("Failed to start second factor: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to log in",
+		})
+		return
+	}
+	if challenge != "" {
+		logger.Info("First factor accepted, waiting for second factor userID: %d", user.ID)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"message":  "Login successful",
-			"userData": user,
-			"token":    token,
+			"two_factor_required": true,
+			"challenge":           challenge,
 		})
+		return
 	}
+
+	completeLogin(ac, w, r, user)
+}
+
+// secondFactorChallenge creates the 2FA challenge for an account that has 2FA
+// enabled, and returns "" for one that can log straight in. Every way of
+// logging in goes through it before a session is created.
+func secondFactorChallenge(db *sql.DB, userID int) (string, error) {
+	totpEnabled, err := controllers.IsTOTPEnabled(db, userID)
+	if err != nil || !totpEnabled {
+		return "", err
+	}
+	return controllers.CreateLoginChallenge(db, userID)
+}
+
+// reportLockout notifies the owner and records the event when a failed
+// attempt locked their account
+func reportLockout(db *sql.DB, r *http.Request, throttle controllers.LoginThrottleResult, clientIP string) {
+	if throttle.LockedUserID == 0 {
+		return
+	}
+	logger.Warning("Locked sign-in for user %d after repeated failures from %s", throttle.LockedUserID, clientIP)
+	controllers.NotifyAccountLocked(db, throttle.LockedUserID, clientIP)
+	controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+		UserID:    throttle.LockedUserID,
+		EventType: controllers.EventAccountLocked,
+	})
+}
+
+// completeLogin creates the session for an authenticated user and writes the login response
+func completeLogin(ac *controllers.AuthController, w http.ResponseWriter, r *http.Request, user *models.User) {
+	tokens, err := auth.CreateSession(ac.DB, w, r, int(user.ID))
+	if err != nil {
+		logger.Error("Failed to create session: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to create session",
+		})
+		return
+	}
+	// Only a finished login, past any second factor, clears the failures
+	if err := controllers.RecordLoginSuccess(ac.DB, user.ID); err != nil {
+		logger.Error("Failed to reset login throttle: %v", err)
+	}
+	logger.Info("User logged in successfully userID: %d (nickname: %s, email: %s)", user.ID, user.Nickname, user.Email)
+	controllers.RecordSecurityEvent(ac.DB, r, models.SecurityEvent{UserID: user.ID, EventType: controllers.EventLoginSucceeded})
+	deletionCancelled := keepAccount(ac.DB, r, user.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":            "Login successful",
+		"userData":           user,
+		"token":              tokens.AccessToken,
+		"refresh_token":      tokens.RefreshToken,
+		"expires_in":         tokens.ExpiresIn,
+		"csrf_token":         tokens.CSRFToken,
+		"deletion_cancelled": deletionCancelled,
+	})
+}
+
+// keepAccount cancels a pending deletion of an account that was just signed
+// in to and reports whether there was one
+func keepAccount(db *sql.DB, r *http.Request, userID int) bool {
+	cancelled, err := controllers.CancelAccountDeletion(db, userID)
+	if err != nil {
+		logger.Error("Failed to cancel deletion of user %d: %v", userID, err)
+		return false
+	}
+	if cancelled {
+		logger.Info("User %d signed in and kept their account", userID)
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{UserID: userID, EventType: controllers.EventDeletionCancelled})
+	}
+	return cancelled
 }
 
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
@@ -166,6 +529,10 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Delete the session from the database
 	sessionToken := cookie.Value
+	sessionID, err := controllers.GetSessionIDByToken(database.GloabalDB, sessionToken)
+	if err != nil {
+		logger.Warning("Logout for unknown session: %v", err)
+	}
 	err = controllers.DeleteSession(database.GloabalDB, sessionToken)
 	if err != nil {
 		logger.Error("Failed to delete session: %v", err)
@@ -173,33 +540,14 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Remove user from WebSocket connections and mark as offline
-	utils.Mutex.Lock()
-	for conn, usrID := range utils.Clients {
-		if usrID == userID {
-			conn.Close()
-			delete(utils.Clients, conn)
-			break
-		}
+	// Close this device's WebSocket connections
+	if sessionID != "" {
+		utils.CloseSessionConnections(sessionID)
 	}
-	utils.Mutex.Unlock()
-
-	// Mark user as offline in database and broadcast status
-	utils.MarkUserOffline(userID)
 
-	// Broadcast offline status
-	offlineEvent := map[string]interface{}{
-		"type": "user_offline",
-		"payload": map[string]interface{}{
-			"userId":   userID,
-			"isOnline": false,
-		},
-	}
-	msgBytes, err := json.Marshal(offlineEvent)
-	if err != nil {
-		logger.Error("Error creating offline message: %v", err)
-	} else {
-		utils.Broadcast(msgBytes)
+	// Mark user as offline and broadcast status unless another device is still connected
+	if !utils.UserHasConnections(userID) {
+		utils.MarkUserOffline(userID)
 	}
 
 	// Clear the session cookie on the client
@@ -212,8 +560,11 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		Secure:   true,
 		SameSite: http.SameSiteStrictMode,
 	})
+	auth.ClearRefreshCookie(w)
+	auth.ClearCSRFCookie(w)
 
 	logger.Info("User successfully logged out")
+	controllers.RecordSecurityEvent(database.GloabalDB, r, models.SecurityEvent{UserID: userID, EventType: controllers.EventLogout})
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
@@ -234,18 +585,104 @@ func ValidateTokenHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := utils.ValidateJWT(requestData.Token)
+	w.Header().Set("Content-Type", "application/json")
+	payload, err := utils.ValidateJWT(requestData.Token)
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   err.Error(),
+			"expired": errors.Is(err, utils.ErrTokenExpired),
 		})
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]bool{
-		"valid": true,
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"valid":      true,
+		"expires_at": payload.ExpiresAt,
 	})
 }
+
+// RefreshTokenHandler exchanges a refresh token for a new access token and a
+// new refresh token. The token is read from the JSON body or the refresh
+// cookie. Replaying a token that was already exchanged ends the session.
+func RefreshTokenHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+			return
+		}
+
+		var requestData struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+				return
+			}
+		}
+		if requestData.RefreshToken == "" {
+			if cookie, err := r.Cookie("refresh_token"); err == nil {
+				requestData.RefreshToken = cookie.Value
+			}
+		}
+		if requestData.RefreshToken == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Refresh token required"})
+			return
+		}
+
+		result, err := controllers.RotateRefreshToken(db, requestData.RefreshToken)
+		if errors.Is(err, controllers.ErrRefreshTokenReused) {
+			logger.Warning("Refresh token reuse detected, revoked session %s of user %d", result.SessionID, result.UserID)
+			controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+				UserID:    result.UserID,
+				EventType: controllers.EventRefreshTokenReused,
+				Details:   "session " + result.SessionID + " revoked",
+			})
+			utils.CloseSessionConnections(result.SessionID)
+			if !utils.UserHasConnections(result.UserID) {
+				utils.MarkUserOffline(result.UserID)
+			}
+			auth.ClearRefreshCookie(w)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Session revoked"})
+			return
+		}
+		if errors.Is(err, controllers.ErrInvalidRefreshToken) {
+			auth.ClearRefreshCookie(w)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid refresh token"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to rotate refresh token: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to refresh token"})
+			return
+		}
+
+		accessToken, err := utils.GenerateJWT(strconv.Itoa(result.UserID), result.SessionID)
+		if err != nil {
+			logger.Error("Failed to generate access token: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to refresh token"})
+			return
+		}
+		if err := controllers.SetSessionJWT(db, result.SessionID, accessToken); err != nil {
+			logger.Warning("Failed to record access token for session %s: %v", result.SessionID, err)
+		}
+
+		auth.SetRefreshCookie(w, result.RefreshToken, result.ExpiresAt)
+		json.NewEncoder(w).Encode(models.AuthTokens{
+			AccessToken:  accessToken,
+			RefreshToken: result.RefreshToken,
+			ExpiresIn:    int64(utils.AccessTokenTTL.Seconds()),
+		})
+	}
+}
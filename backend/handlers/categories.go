@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	"forum/backend/models"
+)
+
+// GetCategoriesHandler lists the categories with their post counts. Archived
+// ones are included with ?archived=true.
+func GetCategoriesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		categories, err := controllers.GetCategories(db, r.URL.Query().Get("archived") == "true")
+		if err != nil {
+			logger.Error("Failed to get categories: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch categories"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"categories": categories})
+	}
+}
+
+// CreateCategoryHandler adds a category from {name, slug, description, position, archived}
+func CreateCategoryHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req models.Category
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+			return
+		}
+
+		category, err := controllers.CreateCategory(db, req)
+		if err != nil {
+			writeCategoryError(w, err, "create")
+			return
+		}
+		logger.Info("Category %s created by user %v", category.Slug, r.Context().Value(models.UserIDKey))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(category)
+	}
+}
+
+// UpdateCategoryHandler changes the category ?slug= with the fields given
+func UpdateCategoryHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req models.CategoryUpdate
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+			return
+		}
+
+		slug := r.URL.Query().Get("slug")
+		category, err := controllers.UpdateCategory(db, slug, req)
+		if err != nil {
+			writeCategoryError(w, err, "update")
+			return
+		}
+		logger.Info("Category %s updated by user %v", slug, r.Context().Value(models.UserIDKey))
+		json.NewEncoder(w).Encode(category)
+	}
+}
+
+// DeleteCategoryHandler removes the unused category ?slug=
+func DeleteCategoryHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		slug := r.URL.Query().Get("slug")
+		if err := controllers.DeleteCategory(db, slug); err != nil {
+			writeCategoryError(w, err, "delete")
+			return
+		}
+		logger.Info("Category %s deleted by user %v", slug, r.Context().Value(models.UserIDKey))
+		json.NewEncoder(w).Encode(map[string]string{"message": "Category deleted"})
+	}
+}
+
+// writeCategoryError answers a failed change to a category
+func writeCategoryError(w http.ResponseWriter, err error, action string) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Category not found"})
+	case errors.Is(err, controllers.ErrCategoryExists), errors.Is(err, controllers.ErrCategoryInUse):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	case errors.Is(err, controllers.ErrCategoryName),
+		errors.Is(err, controllers.ErrCategorySlug),
+		errors.Is(err, controllers.ErrCategoryDesc):
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	default:
+		logger.Error("Failed to %s category: %v", action, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to " + action + " category"})
+	}
+}
@@ -104,6 +104,22 @@ func CreateCommentHandler(cc *controllers.CommentController) http.HandlerFunc {
 		}
 
 		commentID, err := cc.CreateComment(commentInsert)
+		if err == controllers.ErrPostLocked {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "This post is locked",
+			})
+			return
+		}
+		if err == controllers.ErrPostNotPublished {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Post not found",
+			})
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to create comment: %v - remote_addr: %s, method: %s, path: %s",
 				err,
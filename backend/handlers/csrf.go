@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	auth "forum/backend/sessions"
+)
+
+// CSRFTokenHandler returns the CSRF token of the caller's session in the
+// X-CSRF-Token header, the csrf_token cookie and the body
+func CSRFTokenHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		sessionToken, err := controllers.GetSessionToken(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "No active session"})
+			return
+		}
+
+		token, err := controllers.IssueCSRFToken(db, sessionToken)
+		if err != nil {
+			logger.Error("Failed to issue CSRF token: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to issue CSRF token"})
+			return
+		}
+
+		auth.SetCSRFCookie(w, token)
+		json.NewEncoder(w).Encode(map[string]string{"csrf_token": token})
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	"forum/backend/models"
+)
+
+// GetDataExportsHandler lists the user's data exports and their status
+func GetDataExportsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			logger.Error("Invalid user ID: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		exports, err := controllers.GetDataExports(db, userID)
+		if err != nil {
+			logger.Error("Failed to get data exports of user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch data exports"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"exports": exports})
+	}
+}
+
+// RequestDataExportHandler starts an export of the user's data. It answers
+// 201 when the archive is ready and 202 when it is built in the background.
+func RequestDataExportHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			logger.Error("Invalid user ID: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		export, err := controllers.RequestDataExport(db, userID)
+		switch {
+		case errors.Is(err, controllers.ErrDataExportInProgress),
+			errors.Is(err, controllers.ErrDataExportTooSoon):
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			logger.Error("Failed to export data of user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to export your data"})
+			return
+		}
+
+		logger.Info("User %d requested data export %d", userID, export.ID)
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+			UserID:    userID,
+			EventType: controllers.EventDataExportRequested,
+			Details:   fmt.Sprintf("export %d", export.ID),
+		})
+		if export.Status == models.DataExportReady {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		json.NewEncoder(w).Encode(export)
+	}
+}
+
+// DownloadDataExportHandler sends the archive of the export given by ?id=
+func DownloadDataExportHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			logger.Error("Invalid user ID: %v", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		exportID, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Export id is required"})
+			return
+		}
+
+		export, err := controllers.GetDownloadableDataExport(db, userID, exportID)
+		switch {
+		case err == sql.ErrNoRows:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Export not found"})
+			return
+		case errors.Is(err, controllers.ErrDataExportNotReady):
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Export is not ready", "status": export.Status})
+			return
+		case err != nil:
+			logger.Error("Failed to get data export %d: %v", exportID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch export"})
+			return
+		}
+
+		file, err := os.Open(export.FilePath)
+		if err != nil {
+			logger.Error("Failed to open data export %d: %v", exportID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch export"})
+			return
+		}
+		defer file.Close()
+
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+			UserID:    userID,
+			EventType: controllers.EventDataExportDownloaded,
+			Details:   fmt.Sprintf("export %d", exportID),
+		})
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="forum-data-%s.zip"`, export.CreatedAt.Format("2006-01-02")))
+		http.ServeContent(w, r, "", export.CreatedAt, file)
+	}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	"forum/backend/models"
+	auth "forum/backend/sessions"
+	"forum/backend/utils"
+)
+
+// GetImpersonationsHandler lists recent impersonations for the audit trail.
+// What was done during each one is in the security events of the user.
+func GetImpersonationsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		impersonations, err := controllers.GetImpersonations(db, limit)
+		if err != nil {
+			logger.Error("Failed to get impersonations: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch impersonations"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"impersonations": impersonations})
+	}
+}
+
+// StartImpersonationHandler signs the admin in as another user from
+// {"user_id", "reason"}. The admin's own session is kept for when they stop.
+func StartImpersonationHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		adminID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+		adminSessionToken, err := controllers.GetSessionToken(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Session required"})
+			return
+		}
+
+		var req struct {
+			UserID int    `json:"user_id"`
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+			return
+		}
+
+		sessionToken, impersonation, err := controllers.StartImpersonation(db, adminID, adminSessionToken,
+			req.UserID, req.Reason, r.UserAgent(), utils.ClientIP(r))
+		switch {
+		case err == sql.ErrNoRows, errors.Is(err, controllers.ErrAccountDeleted):
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+			return
+		case errors.Is(err, controllers.ErrCannotImpersonate):
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		case errors.Is(err, controllers.ErrImpersonationReason):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			logger.Error("Admin %d failed to impersonate user %d: %v", adminID, req.UserID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to start impersonation"})
+			return
+		}
+
+		tokens, err := auth.IssueSessionTokens(db, w, sessionToken, impersonation.SessionID,
+			impersonation.UserID, impersonation.ExpiresAt, false)
+		if err != nil {
+			logger.Error("Failed to sign admin %d in as user %d: %v", adminID, req.UserID, err)
+			controllers.DeleteSession(db, sessionToken)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to start impersonation"})
+			return
+		}
+
+		logger.Warning("Admin %d is acting as user %d: %s", adminID, req.UserID, impersonation.Reason)
+		details := fmt.Sprintf("impersonation %d: %s", impersonation.ID, impersonation.Reason)
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+			UserID:    adminID,
+			EventType: controllers.EventImpersonationStarted,
+			Details:   fmt.Sprintf("as %s, %s", impersonation.UserNickname, details),
+		})
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+			UserID:     impersonation.UserID,
+			EventType:  controllers.EventImpersonationStarted,
+			Identifier: impersonation.AdminNickname,
+			Details:    details,
+		})
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"impersonation": impersonation,
+			"token":         tokens.AccessToken,
+			"expires_in":    tokens.ExpiresIn,
+			"csrf_token":    tokens.CSRFToken,
+		})
+	}
+}
+
+// GetImpersonationHandler tells the client whether an admin is acting as the
+// signed-in user, so it can show it
+func GetImpersonationHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		sessionToken, err := controllers.GetSessionToken(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Session required"})
+			return
+		}
+		impersonation, err := controllers.GetSessionImpersonation(db, sessionToken)
+		if err != nil {
+			logger.Error("Failed to get impersonation: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch impersonation"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"impersonating": impersonation != nil,
+			"impersonation": impersonation,
+		})
+	}
+}
+
+// EndImpersonationHandler signs the admin out of the user's account and back
+// into their own session, when it is still valid
+func EndImpersonationHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		sessionToken, err := controllers.GetSessionToken(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Session required"})
+			return
+		}
+		impersonation, err := controllers.EndImpersonation(db, sessionToken)
+		if errors.Is(err, controllers.ErrNotImpersonating) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "You are not acting as another user"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to end impersonation: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to end impersonation"})
+			return
+		}
+		utils.CloseSessionConnections(impersonation.SessionID)
+
+		logger.Info("Admin %d stopped acting as user %d", impersonation.AdminID, impersonation.UserID)
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+			UserID:    impersonation.AdminID,
+			EventType: controllers.EventImpersonationEnded,
+			Details:   fmt.Sprintf("as %s, impersonation %d", impersonation.UserNickname, impersonation.ID),
+		})
+
+		// Resume the admin's own session if it has not expired meanwhile
+		if userID, valid := controllers.IsValidSession(db, impersonation.AdminSessionToken); valid && userID == impersonation.AdminID {
+			_, expiresAt, err := controllers.GetSession(db, impersonation.AdminSessionToken)
+			var sessionID string
+			if err == nil {
+				sessionID, err = controllers.GetSessionIDByToken(db, impersonation.AdminSessionToken)
+			}
+			var tokens *models.AuthTokens
+			if err == nil {
+				tokens, err = auth.IssueSessionTokens(db, w, impersonation.AdminSessionToken, sessionID,
+					impersonation.AdminID, expiresAt, true)
+			}
+			if err == nil {
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"message":       "You are signed in as yourself again",
+					"token":         tokens.AccessToken,
+					"refresh_token": tokens.RefreshToken,
+					"expires_in":    tokens.ExpiresIn,
+					"csrf_token":    tokens.CSRFToken,
+				})
+				return
+			}
+			logger.Error("Failed to resume session of admin %d: %v", impersonation.AdminID, err)
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:   "session_token",
+			MaxAge: -1,
+		})
+		auth.ClearCSRFCookie(w)
+		auth.ClearRefreshCookie(w)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Impersonation ended. Please sign in again.",
+		})
+	}
+}
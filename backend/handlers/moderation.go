@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// LockPostHandler locks or unlocks a post from {"post_id", "locked"}
+func LockPostHandler(pc *controllers.PostController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req struct {
+			PostID int  `json:"post_id"`
+			Locked bool `json:"locked"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PostID <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+			return
+		}
+
+		err := pc.SetPostLocked(req.PostID, req.Locked)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Post not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to lock post %d: %v", req.PostID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to lock post"})
+			return
+		}
+
+		logger.Info("User %s set post %d locked=%v", r.Context().Value(models.UserIDKey), req.PostID, req.Locked)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"post_id": req.PostID,
+			"locked":  req.Locked,
+		})
+	}
+}
+
+// GetStaffHandler lists moderators and admins
+func GetStaffHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		users, err := controllers.GetStaffUsers(db)
+		if err != nil {
+			logger.Error("Failed to get staff: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch staff"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"users": users})
+	}
+}
+
+// SetUserRoleHandler changes a user's role from {"user_id", "role"}
+func SetUserRoleHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		adminID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		var req struct {
+			UserID int    `json:"user_id"`
+			Role   string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+			return
+		}
+
+		err = controllers.SetUserRole(db, req.UserID, req.Role)
+		switch {
+		case err == sql.ErrNoRows:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+			return
+		case errors.Is(err, controllers.ErrInvalidRole), errors.Is(err, controllers.ErrLastAdmin):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			logger.Error("Failed to set role of user %d: %v", req.UserID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update role"})
+			return
+		}
+
+		logger.Info("Admin %d set role of user %d to %s", adminID, req.UserID, req.Role)
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+			UserID:    req.UserID,
+			EventType: controllers.EventRoleChanged,
+			Details:   fmt.Sprintf("set to %s by user %d", req.Role, adminID),
+		})
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"user_id": req.UserID,
+			"role":    req.Role,
+		})
+	}
+}
+
+// GetSuspensionsHandler lists the suspensions of ?user_id=, newest first
+func GetSuspensionsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+		if err != nil || userID <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		suspensions, err := controllers.GetUserSuspensions(db, userID)
+		if err != nil {
+			logger.Error("Failed to get suspensions of user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch suspensions"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"suspensions": suspensions})
+	}
+}
+
+// SuspendUserHandler suspends a user from {"user_id", "reason", "duration_hours",
+// "content_policy"}. A duration of 0 bans the user permanently.
+func SuspendUserHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		actorID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		var req struct {
+			UserID        int    `json:"user_id"`
+			Reason        string `json:"reason"`
+			DurationHours int    `json:"duration_hours"`
+			ContentPolicy string `json:"content_policy"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+			return
+		}
+		if req.UserID == actorID {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "You cannot suspend yourself"})
+			return
+		}
+
+		duration := time.Duration(req.DurationHours) * time.Hour
+		suspension, err := controllers.SuspendUser(db, actorID, req.UserID, req.Reason, duration, req.ContentPolicy)
+		switch {
+		case err == sql.ErrNoRows:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+			return
+		case errors.Is(err, controllers.ErrCannotSuspend):
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		case errors.Is(err, controllers.ErrSuspensionReason),
+			errors.Is(err, controllers.ErrSuspensionDuration),
+			errors.Is(err, controllers.ErrInvalidContentPolicy):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			logger.Error("Failed to suspend user %d: %v", req.UserID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to suspend user"})
+			return
+		}
+
+		// Their sessions are gone; drop the live connections too
+		utils.CloseUserConnections(req.UserID)
+		utils.MarkUserOffline(req.UserID)
+
+		logger.Warning("User %d suspended user %d (hours=%d, content=%s): %s",
+			actorID, req.UserID, req.DurationHours, suspension.ContentPolicy, suspension.Reason)
+		details := fmt.Sprintf("by user %d for %d hours, content %s: %s",
+			actorID, req.DurationHours, suspension.ContentPolicy, suspension.Reason)
+		if suspension.Permanent() {
+			details = fmt.Sprintf("banned by user %d, content %s: %s", actorID, suspension.ContentPolicy, suspension.Reason)
+		}
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+			UserID:    req.UserID,
+			EventType: controllers.EventUserSuspended,
+			Details:   details,
+		})
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(suspension)
+	}
+}
+
+// LiftSuspensionHandler ends the running suspension of ?user_id=
+func LiftSuspensionHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		actorID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+		userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+		if err != nil || userID <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		err = controllers.LiftSuspension(db, actorID, userID)
+		if errors.Is(err, controllers.ErrNotSuspended) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to lift suspension of user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to lift suspension"})
+			return
+		}
+
+		logger.Info("User %d lifted the suspension of user %d", actorID, userID)
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+			UserID:    userID,
+			EventType: controllers.EventSuspensionLifted,
+			Details:   fmt.Sprintf("by user %d", actorID),
+		})
+		json.NewEncoder(w).Encode(map[string]string{"message": "Suspension lifted"})
+	}
+}
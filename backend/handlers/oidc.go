@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	"forum/backend/models"
+	auth "forum/backend/sessions"
+	"forum/backend/utils"
+)
+
+// OIDCConfigHandler tells the login page whether to offer single sign-on
+func OIDCConfigHandler(provider *utils.OIDCProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if provider == nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"enabled": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"enabled": true,
+			"name":    provider.Config.Name,
+		})
+	}
+}
+
+// OIDCLoginHandler sends the browser to the identity provider
+func OIDCLoginHandler(db *sql.DB, provider *utils.OIDCProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if provider == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		authURL, state, err := controllers.BeginOIDCLogin(r.Context(), db, provider, 0)
+		if err != nil {
+			logger.Error("Failed to start OIDC login: %v", err)
+			redirectOIDCError(w, r, "Single sign-on is unavailable right now")
+			return
+		}
+		setOIDCStateCookie(w, state)
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// OIDCLinkHandler starts a sign-in that links the provider account to the current user
+func OIDCLinkHandler(db *sql.DB, provider *utils.OIDCProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if provider == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Single sign-on is not configured"})
+			return
+		}
+
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		authURL, state, err := controllers.BeginOIDCLogin(r.Context(), db, provider, userID)
+		if err != nil {
+			logger.Error("Failed to start OIDC link: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Single sign-on is unavailable right now"})
+			return
+		}
+		setOIDCStateCookie(w, state)
+		json.NewEncoder(w).Encode(map[string]string{"url": authURL})
+	}
+}
+
+// OIDCCallbackHandler is where the provider sends the browser back. A new
+// login creates the normal session and lets the app pick up its access token
+// through the refresh cookie, or hands accounts with 2FA their challenge.
+func OIDCCallbackHandler(db *sql.DB, provider *utils.OIDCProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if provider == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		// The state cookie is good for this one callback, whatever the outcome
+		var browserState string
+		if cookie, err := r.Cookie(oidcStateCookie); err == nil {
+			browserState = cookie.Value
+		}
+		clearOIDCStateCookie(w)
+
+		query := r.URL.Query()
+		if providerErr := query.Get("error"); providerErr != "" {
+			logger.Warning("OIDC provider returned error: %s %s", providerErr, query.Get("error_description"))
+			redirectOIDCError(w, r, "Sign-in was cancelled or refused")
+			return
+		}
+
+		result, err := controllers.CompleteOIDCLogin(r.Context(), db, provider, query.Get("state"), browserState, query.Get("code"))
+		if err != nil {
+			logger.Warning("OIDC login failed: %v", err)
+			switch {
+			case errors.Is(err, controllers.ErrInvalidOIDCState),
+				errors.Is(err, controllers.ErrIdentityLinkedElsewhere),
+				errors.Is(err, controllers.ErrOIDCEmailRequired),
+				errors.Is(err, controllers.ErrOIDCEmailTaken):
+				redirectOIDCError(w, r, err.Error())
+			default:
+				redirectOIDCError(w, r, "Sign-in failed, please try again")
+			}
+			return
+		}
+
+		if result.Linked {
+			logger.Info("User %d linked an external account", result.UserID)
+			controllers.RecordSecurityEvent(db, r, models.SecurityEvent{UserID: result.UserID, EventType: controllers.EventIdentityLinked})
+			http.Redirect(w, r, "/profilePage?oidc_linked=1", http.StatusSeeOther)
+			return
+		}
+
+		suspension, err := controllers.GetActiveSuspension(db, result.UserID)
+		if err != nil {
+			logger.Error("Failed to check suspension after OIDC login: %v", err)
+			redirectOIDCError(w, r, "Sign-in failed, please try again")
+			return
+		}
+		if suspension != nil {
+			logger.Warning("OIDC login refused - user %d is suspended", result.UserID)
+			controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+				UserID:    result.UserID,
+				EventType: controllers.EventLoginRefused,
+				Details:   "account suspended",
+			})
+			redirectOIDCError(w, r, (&controllers.AccountSuspendedError{Suspension: *suspension}).Error())
+			return
+		}
+
+		// Accounts with 2FA still need their code; the fragment keeps the
+		// challenge out of server logs and referrers
+		challenge, err := secondFactorChallenge(db, result.UserID)
+		if err != nil {
+			logger.Error("Failed to start second factor after OIDC login: %v", err)
+			redirectOIDCError(w, r, "Sign-in failed, please try again")
+			return
+		}
+		if challenge != "" {
+			logger.Info("OIDC sign-in accepted, waiting for second factor userID: %d", result.UserID)
+			http.Redirect(w, r, "/oidcLoginPage#challenge="+url.QueryEscape(challenge), http.StatusSeeOther)
+			return
+		}
+
+		if _, err := auth.CreateSession(db, w, r, result.UserID); err != nil {
+			logger.Error("Failed to create session after OIDC login: %v", err)
+			redirectOIDCError(w, r, "Failed to create session")
+			return
+		}
+		if err := controllers.RecordLoginSuccess(db, result.UserID); err != nil {
+			logger.Error("Failed to reset login throttle: %v", err)
+		}
+		logger.Info("User logged in with OIDC userID: %d", result.UserID)
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+			UserID:    result.UserID,
+			EventType: controllers.EventLoginSucceeded,
+			Details:   "single sign-on",
+		})
+		keepAccount(db, r, result.UserID)
+		http.Redirect(w, r, "/oidcLoginPage", http.StatusSeeOther)
+	}
+}
+
+// GetIdentitiesHandler lists the external accounts linked to the current user
+func GetIdentitiesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		identities, err := controllers.GetUserIdentities(db, userID)
+		if err != nil {
+			logger.Error("Failed to get identities: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch linked accounts"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"identities": identities})
+	}
+}
+
+// oidcStateCookie ties a sign-in to the browser that started it
+const oidcStateCookie = "oidc_state"
+
+// setOIDCStateCookie keeps the state of a new sign-in for the callback. Lax
+// lets it ride along on the provider's top-level redirect back.
+func setOIDCStateCookie(w http.ResponseWriter, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc/callback",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(controllers.OIDCStateTTL.Seconds()),
+	})
+}
+
+func clearOIDCStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		Path:     "/auth/oidc/callback",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	})
+}
+
+func redirectOIDCError(w http.ResponseWriter, r *http.Request, message string) {
+	http.Redirect(w, r, "/loginPage?oidc_error="+url.QueryEscape(message), http.StatusSeeOther)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+)
+
+// GetPostRevisionsHandler lists the edit history of ?post_id=, oldest first
+func GetPostRevisionsHandler(pc *controllers.PostController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID, err := strconv.Atoi(r.URL.Query().Get("post_id"))
+		if err != nil || postID <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid post ID"})
+			return
+		}
+
+		revisions, err := pc.GetPostRevisions(postID)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Post not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to get revisions of post %d: %v", postID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch revisions"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"post_id":   postID,
+			"revisions": revisions,
+		})
+	}
+}
+
+// DiffPostRevisionsHandler compares revisions ?from= and ?to= of ?post_id=
+func DiffPostRevisionsHandler(pc *controllers.PostController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		query := r.URL.Query()
+		postID, err := strconv.Atoi(query.Get("post_id"))
+		if err != nil || postID <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid post ID"})
+			return
+		}
+		from, errFrom := strconv.Atoi(query.Get("from"))
+		to, errTo := strconv.Atoi(query.Get("to"))
+		if errFrom != nil || errTo != nil || from <= 0 || to <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "from and to must be revision numbers"})
+			return
+		}
+
+		diff, err := pc.DiffPostRevisions(postID, from, to)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Revision not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to diff revisions %d and %d of post %d: %v", from, to, postID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to compare revisions"})
+			return
+		}
+		json.NewEncoder(w).Encode(diff)
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -29,19 +30,45 @@ type VoteRequest struct {
 
 func GetPostsHandler(pc *controllers.PostController) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-
-		if page < 1 {
-			page = 1
-		}
+		query := r.URL.Query()
+		limit, _ := strconv.Atoi(query.Get("limit"))
 		if limit < 1 || limit > 50 {
 			limit = 10
 		}
 
-		offset := (page - 1) * limit
+		feed := models.Feed{
+			Mode:     query.Get("feed"),
+			Window:   query.Get("window"),
+			Category: query.Get("category"),
+		}
+		if feed.Category != "" {
+			if _, err := controllers.GetCategory(pc.DB, feed.Category); err != nil {
+				writeCategoryError(w, err, "find")
+				return
+			}
+		}
+		if tag := query.Get("tag"); tag != "" {
+			feed.Tag, _ = utils.NormalizeTag(tag)
+		}
+		// The viewer's own reactions are marked, and the following feed needs them
+		userIDStr, _ := r.Context().Value(models.UserIDKey).(string)
+		feed.ViewerID, _ = strconv.Atoi(userIDStr)
+		if feed.Mode == models.FeedFollowing && feed.ViewerID == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Log in to see the posts of who you follow"})
+			return
+		}
 
-		posts, err := pc.GetAllPosts(offset, limit)
+		posts, nextCursor, err := pc.GetFeed(feed, query.Get("cursor"), limit)
+		if errors.Is(err, controllers.ErrInvalidFeed) ||
+			errors.Is(err, controllers.ErrInvalidWindow) ||
+			errors.Is(err, controllers.ErrInvalidCursor) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to fetch posts: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -50,8 +77,8 @@ func GetPostsHandler(pc *controllers.PostController) http.HandlerFunc {
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"posts": posts,
-			"page":  page,
+			"posts":       posts,
+			"next_cursor": nextCursor,
 		})
 	}
 }
@@ -109,8 +136,26 @@ func CreatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 		content := r.FormValue("content")
 		category := r.FormValue("category")
 
-		// Validate required fields
-		if title == "" {
+		// Posts are published straight away unless saved as a draft or scheduled
+		status, publishAt, err := parsePostStatus(r)
+		if status == "" {
+			status = models.PostPublished
+		}
+		if err == nil {
+			err = controllers.ValidatePostStatus(status, publishAt)
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		isDraft := status == models.PostDraft
+
+		// Validate required fields; drafts may be incomplete
+		if title == "" && !isDraft {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusBadRequest)
 			json.NewEncoder(w).Encode(map[string]string{
@@ -119,7 +164,7 @@ func CreatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			return
 		}
 
-		if category == "" {
+		if category == "" && !isDraft {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusBadRequest)
 			json.NewEncoder(w).Encode(map[string]string{
@@ -128,6 +173,23 @@ func CreatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			return
 		}
 
+		category, err = controllers.ResolveCategories(pc.DB, category, "")
+		if err != nil {
+			writePostCategoryError(w, err)
+			return
+		}
+
+		// Tags given besides the hashtags in the content
+		tags, err := controllers.ParseTags(r.FormValue("tags"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
 		// Check for video upload
 		var videoPath string
 		if form := r.MultipartForm; form != nil && form.File["post-video"] != nil && len(form.File["post-video"]) > 0 {
@@ -168,7 +230,7 @@ func CreatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			}
 		}
 
-		if content == "" && len(imagePaths) == 0 && videoPath == "" {
+		if content == "" && len(imagePaths) == 0 && videoPath == "" && !isDraft {
 			logger.Warning("Invalid post creation request: missing content, images, and video")
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusBadRequest)
@@ -197,67 +259,61 @@ func CreatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 				String: videoPath,
 				Valid:  videoPath != "",
 			},
-			Images: imagePaths,
+			Images:    imagePaths,
+			Status:    status,
+			PublishAt: publishAt,
+			Tags:      tags,
 		}
 
 		// Insert post
 		postID, err := pc.InsertPost(post)
-		if err != nil {
-			logger.Error("Failed to insert post: %v", err)
+		if errors.Is(err, controllers.ErrTooManyTags) || errors.Is(err, controllers.ErrInvalidTag) {
 			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(http.StatusBadRequest)
 			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Failed to create post",
+				"error": err.Error(),
 			})
 			return
 		}
-
-		createdPost, err := pc.GetPostByID(postID)
 		if err != nil {
-			logger.Error("Failed to get Post by ID: %v", err)
+			logger.Error("Failed to insert post: %v", err)
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]string{
 				"error": "Failed to create post",
 			})
+			return
 		}
 
-		BroadcastNewPost(createdPost)
-
-		// After successfully creating the post and broadcasting it
-		postCount, err := pc.GetUserPostCount(userID)
-		if err != nil {
-			logger.Error("Failed to get user post count: %v", err)
-		} else {
-			// Create post count update message
-			postCountEvent := map[string]interface{}{
-				"type": "post_count_update",
-				"payload": map[string]interface{}{
-					"postCount": postCount,
-				},
-			}
-
-			// Convert to JSON
-			msgBytes, err := json.Marshal(postCountEvent)
-			if err != nil {
-				logger.Error("Error creating post count message: %v", err)
-			} else {
-				// Send only to the post creator
-				SendToUser(userID, msgBytes)
-			}
+		// Drafts and scheduled posts are announced once they are published
+		message := "Post created successfully"
+		switch status {
+		case models.PostPublished:
+			AnnounceNewPost(pc)(postID)
+		case models.PostDraft:
+			message = "Draft saved"
+		case models.PostScheduled:
+			message = "Post scheduled"
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"message": "Post created successfully",
+			"message": message,
+			"post_id": postID,
+			"status":  status,
 		})
 	}
 }
 
 func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID := r.Context().Value("userID").(int)
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			logger.Error("Failed to convert userID to int: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 		postIDStr := r.URL.Query().Get("postID")
 
 		postID, err := strconv.Atoi(postIDStr)
@@ -282,8 +338,11 @@ func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			return
 		}
 
-		// Get existing post
-		existingPost, err := pc.GetPostByID(postID)
+		// Get existing post; authors also get their drafts
+		existingPost, err := pc.GetAuthorPost(postID, userID)
+		if err != nil {
+			existingPost, err = pc.GetPostByID(postID)
+		}
 		if err != nil {
 			logger.Error("Failed to fetch post: %v", err)
 			w.Header().Set("Content-Type", "application/json")
@@ -294,9 +353,9 @@ func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			return
 		}
 
-		// Verify post ownership
-		isAuthor, err := pc.IsPostAuthor(existingPost.ID, userID)
-		if err != nil || !isAuthor {
+		// Authors may edit their posts, moderators anyone's
+		allowed, err := pc.CanModifyPost(existingPost.ID, userID, controllers.PermEditAnyPost)
+		if err != nil || !allowed {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]string{
@@ -310,6 +369,37 @@ func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 		content := r.FormValue("content")
 		category := r.FormValue("category")
 
+		// Drafts can be published or scheduled; published posts stay published
+		status, publishAt, err := parsePostStatus(r)
+		if err == nil && status != "" {
+			err = controllers.ValidatePostStatus(status, publishAt)
+		}
+		if err == nil && status != "" && status != models.PostPublished && existingPost.Status == models.PostPublished {
+			err = controllers.ErrPostPublished
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		// Hand-given tags stay unless new ones are sent; hashtags follow the content
+		existingPost.Tags = controllers.ExplicitTags(existingPost)
+		if _, given := r.Form["tags"]; given {
+			existingPost.Tags, err = controllers.ParseTags(r.FormValue("tags"))
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": err.Error(),
+				})
+				return
+			}
+		}
+
 		// Update fields if provided
 		if title != "" {
 			existingPost.Title = title
@@ -318,7 +408,11 @@ func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			existingPost.Content = content
 		}
 		if category != "" {
-			existingPost.Category = category
+			existingPost.Category, err = controllers.ResolveCategories(pc.DB, category, existingPost.Category)
+			if err != nil {
+				writePostCategoryError(w, err)
+				return
+			}
 		}
 
 		// Handle video upload
@@ -364,8 +458,37 @@ func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			}
 		}
 
+		// A draft has to be complete before it is scheduled or published
+		if status == models.PostScheduled || status == models.PostPublished {
+			var missing string
+			switch {
+			case existingPost.Title == "":
+				missing = "Title is required"
+			case existingPost.Category == "":
+				missing = "Category is required"
+			case existingPost.Content == "" && len(existingPost.Images) == 0 && !existingPost.VideoUrl.Valid:
+				missing = "Either content, images, or video is required"
+			}
+			if missing != "" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": missing,
+				})
+				return
+			}
+		}
+
 		// Update post
-		err = pc.UpdatePost(existingPost)
+		err = pc.UpdatePost(existingPost, userID)
+		if errors.Is(err, controllers.ErrTooManyTags) || errors.Is(err, controllers.ErrInvalidTag) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to update post: %v", err)
 			w.Header().Set("Content-Type", "application/json")
@@ -376,10 +499,29 @@ func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			return
 		}
 
+		if status != "" {
+			published, err := pc.SetPostStatus(postID, status, publishAt)
+			if err != nil {
+				logger.Error("Failed to set status of post %d: %v", postID, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Failed to update post",
+				})
+				return
+			}
+			if published {
+				AnnounceNewPost(pc)(postID)
+			}
+		} else {
+			status = existingPost.Status
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{
 			"message": "Post updated successfully",
+			"status":  status,
 		})
 	}
 }
@@ -419,9 +561,9 @@ func DeletePostHandler(pc *controllers.PostController) http.HandlerFunc {
 		}
 
 		logger.Warning("Received delete request for post ID: %d", postID)
-		// Verify post ownership
-		isAuthor, err := pc.IsPostAuthor(postID, userID)
-		if err != nil || !isAuthor {
+		// Authors may delete their posts, moderators anyone's
+		allowed, err := pc.CanModifyPost(postID, userID, controllers.PermDeleteAnyPost)
+		if err != nil || !allowed {
 			logger.Error("Failed to verify post ownership: %v", err)
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
@@ -431,7 +573,7 @@ func DeletePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			return
 		}
 
-		logger.Warning("Post ownership verified, deleting post ID: %d", postID)
+		logger.Warning("User %d allowed to delete post ID: %d", userID, postID)
 		// Delete post
 		err = pc.DeletePost(postID, userID)
 		if err != nil {
@@ -454,6 +596,9 @@ func DeletePostHandler(pc *controllers.PostController) http.HandlerFunc {
 	}
 }
 
+// HandleVotePost likes or dislikes a post, or takes the vote back. Votes are
+// the like and dislike reactions, so they are counted and broadcast like any
+// other reaction.
 func HandleVotePost(pc *controllers.PostController) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get user ID from context
@@ -497,18 +642,12 @@ func HandleVotePost(pc *controllers.PostController) http.HandlerFunc {
 			return
 		}
 
-		// Handle the vote
-		sameVote, err := pc.HandleVote(req.PostID, userID, req.VoteType)
+		reacted, err := controllers.ToggleReaction(pc.DB, models.ReactionOnPost, req.PostID, userID, req.VoteType)
 		if err != nil {
-			logger.Error("Failed to handle vote: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Failed to process vote",
-			})
+			writeReactionError(w, err)
 			return
 		}
 
-		// Get updated post to return new counts
 		post, err := pc.GetPostByID(req.PostID)
 		if err != nil {
 			logger.Error("Failed to get updated post: %v", err)
@@ -520,7 +659,7 @@ func HandleVotePost(pc *controllers.PostController) http.HandlerFunc {
 		}
 
 		// Only create and send notification if the recipient is not the current user
-		if post.User.ID != userID && !sameVote {
+		if post.User.ID != userID && reacted {
 
 			// Get username of voter
 			userName, err := controllers.GetUsernameByID(pc.DB, userID)
@@ -563,26 +702,116 @@ func HandleVotePost(pc *controllers.PostController) http.HandlerFunc {
 			}
 		}
 
-		// Get user's current vote status
-		currentVote, err := pc.GetUserVote(req.PostID, userID)
+		counts, err := controllers.GetReactionCounts(pc.DB, models.ReactionOnPost, []int{req.PostID}, userID)
 		if err != nil {
-			logger.Error("Failed to get user vote: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Failed to get vote status",
-			})
+			writeReactionError(w, err)
 			return
 		}
 
 		// Broadcast the update via WebSocket
-		BroadcastPostReaction(req.PostID, post.Likes, post.Dislikes)
+		BroadcastReaction(models.ReactionOnPost, req.PostID, counts[req.PostID])
 
 		// Return response to original requester
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"likes":       post.Likes,
-			"dislikes":    post.Dislikes,
-			"currentVote": currentVote,
+			"reacted":   reacted,
+			"reactions": counts[req.PostID],
+		})
+	}
+}
+
+// AnnounceNewPost returns a function that broadcasts a just-published post to
+// everyone and sends its author their new post count. The scheduler calls it
+// for scheduled posts when they go live.
+func AnnounceNewPost(pc *controllers.PostController) func(postID int) {
+	return func(postID int) {
+		post, err := pc.GetPostByID(postID)
+		if err != nil {
+			logger.Error("Failed to get Post by ID: %v", err)
+			return
+		}
+
+		BroadcastNewPost(post)
+
+		postCount, err := pc.GetUserPostCount(post.UserID)
+		if err != nil {
+			logger.Error("Failed to get user post count: %v", err)
+			return
+		}
+
+		// Create post count update message
+		postCountEvent := map[string]interface{}{
+			"type": "post_count_update",
+			"payload": map[string]interface{}{
+				"postCount": postCount,
+			},
+		}
+
+		// Convert to JSON
+		msgBytes, err := json.Marshal(postCountEvent)
+		if err != nil {
+			logger.Error("Error creating post count message: %v", err)
+			return
+		}
+		// Send only to the post creator
+		SendToUser(post.UserID, msgBytes)
+	}
+}
+
+// parsePostStatus reads the optional "status" and "publish_at" (RFC 3339)
+// form fields of a post. An empty status means none was given.
+func parsePostStatus(r *http.Request) (string, *time.Time, error) {
+	status := r.FormValue("status")
+	value := r.FormValue("publish_at")
+	if value == "" {
+		return status, nil, nil
+	}
+	publishAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return status, nil, errors.New("publish_at must be an RFC 3339 time")
+	}
+	// Whatever offset the client sent, keep the server's like other timestamps
+	publishAt = publishAt.Local()
+	return status, &publishAt, nil
+}
+
+// GetDraftsHandler lists the current user's drafts and scheduled posts
+func GetDraftsHandler(pc *controllers.PostController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userIDStr, _ := r.Context().Value(models.UserIDKey).(string)
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+			return
+		}
+
+		drafts, err := pc.GetUserDrafts(userID)
+		if err != nil {
+			logger.Error("Failed to get drafts of user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch drafts"})
+			return
+		}
+		json.NewEncoder(w).Encode(drafts)
+	}
+}
+
+// writePostCategoryError answers a post filed under categories that can't be used
+func writePostCategoryError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if errors.Is(err, controllers.ErrUnknownCategory) || errors.Is(err, controllers.ErrCategoryArchived) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
 		})
+		return
 	}
+	logger.Error("Failed to check post categories: %v", err)
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "Failed to check categories",
+	})
 }
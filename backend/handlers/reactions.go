@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// maxReactionIDs is how many posts, comments or messages one request can
+// ask the reactions of
+const maxReactionIDs = 100
+
+// ReactionRequest leaves or takes back a reaction
+type ReactionRequest struct {
+	ID       int    `json:"id"`
+	Reaction string `json:"reaction"`
+}
+
+// GetReactionSetHandler lists the reactions users can leave
+func GetReactionSetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"reactions": utils.CurrentReactions()})
+	}
+}
+
+// GetReactionsHandler counts the reactions on the posts, comments or
+// messages of entityType given as ?ids=1,2,3. Ones the user can't see are
+// left out of the answer.
+func GetReactionsHandler(db *sql.DB, entityType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, ok := reactingUser(w, r)
+		if !ok {
+			return
+		}
+
+		var ids []int
+		for _, value := range strings.Split(r.URL.Query().Get("ids"), ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || id <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "ids must be a comma separated list of ids"})
+				return
+			}
+			ids = append(ids, id)
+		}
+		if len(ids) > maxReactionIDs {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Ask for at most 100 ids at a time"})
+			return
+		}
+
+		visible, err := controllers.VisibleReactionTargets(db, entityType, ids, userID)
+		if err != nil {
+			writeReactionError(w, err)
+			return
+		}
+
+		counts, err := controllers.GetReactionCounts(db, entityType, visible, userID)
+		if err != nil {
+			writeReactionError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"reactions": counts})
+	}
+}
+
+// ToggleReactionHandler leaves the reaction in {id, reaction} on a post,
+// comment or message of entityType, or takes it back if the user had left
+// it, and tells everyone who can see it the new counts
+func ToggleReactionHandler(db *sql.DB, entityType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, ok := reactingUser(w, r)
+		if !ok {
+			return
+		}
+
+		var req ReactionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+			return
+		}
+
+		reacted, err := controllers.ToggleReaction(db, entityType, req.ID, userID, req.Reaction)
+		if err != nil {
+			writeReactionError(w, err)
+			return
+		}
+
+		counts, err := controllers.GetReactionCounts(db, entityType, []int{req.ID}, userID)
+		if err != nil {
+			writeReactionError(w, err)
+			return
+		}
+		recipients, err := controllers.ReactionAudience(db, entityType, req.ID)
+		if err != nil {
+			logger.Error("Failed to find who sees %s %d: %v", entityType, req.ID, err)
+		} else {
+			BroadcastReaction(entityType, req.ID, counts[req.ID], recipients...)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"reacted":   reacted,
+			"reactions": counts[req.ID],
+		})
+	}
+}
+
+// GetReactorsHandler lists who left ?reaction= on the post, comment or
+// message of entityType with ?id=
+func GetReactorsHandler(db *sql.DB, entityType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, ok := reactingUser(w, r)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid id"})
+			return
+		}
+		visible, err := controllers.CanSeeReactionTarget(db, entityType, id, userID)
+		if err == nil && !visible {
+			err = controllers.ErrReactionTarget
+		}
+		if err != nil {
+			writeReactionError(w, err)
+			return
+		}
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		reactors, err := controllers.GetReactors(db, entityType, id, r.URL.Query().Get("reaction"), limit)
+		if err != nil {
+			writeReactionError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"users": reactors})
+	}
+}
+
+// reactingUser reads the signed in user, answering 401 when there is none
+func reactingUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userIDStr, _ := r.Context().Value(models.UserIDKey).(string)
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// writeReactionError answers a failed reaction request
+func writeReactionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, controllers.ErrUnknownReaction):
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	case errors.Is(err, controllers.ErrReactionTarget):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Not found"})
+	default:
+		logger.Error("Failed to handle reaction: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to process reaction"})
+	}
+}
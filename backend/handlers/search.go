@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	"forum/backend/models"
+)
+
+// SearchHandler searches posts and comments for q, optionally only of one
+// type (post or comment), in a category, by an author, and since or until a
+// time (RFC 3339, or a date for the whole day), page by page
+func SearchHandler(sc *controllers.SearchController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		query := r.URL.Query()
+		filter := models.SearchFilter{
+			Query:    query.Get("q"),
+			Type:     query.Get("type"),
+			Category: query.Get("category"),
+			Author:   query.Get("author"),
+		}
+		if filter.Type != "" && filter.Type != models.SearchPost && filter.Type != models.SearchComment {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "type must be post or comment"})
+			return
+		}
+
+		for name, target := range map[string]*time.Time{
+			"since": &filter.Since,
+			"until": &filter.Until,
+		} {
+			value := query.Get(name)
+			if value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				// A date covers the whole day, also when it is the end of the range
+				t, err = time.ParseInLocation(time.DateOnly, value, time.Local)
+				if err == nil && name == "until" {
+					t = t.AddDate(0, 0, 1)
+				}
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Invalid " + name + ", expected RFC 3339 or YYYY-MM-DD"})
+				return
+			}
+			*target = t
+		}
+
+		page, _ := strconv.Atoi(query.Get("page"))
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 || limit > 50 {
+			limit = 10
+		}
+		// One extra result tells whether there is another page
+		filter.Limit = limit + 1
+		filter.Offset = (page - 1) * limit
+
+		results, err := sc.Search(filter)
+		if errors.Is(err, controllers.ErrEmptySearch) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Search query is required"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to search for %q: %v", filter.Query, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to search"})
+			return
+		}
+
+		hasMore := len(results) > limit
+		if hasMore {
+			results = results[:limit]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"results":  results,
+			"page":     page,
+			"has_more": hasMore,
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	"forum/backend/models"
+)
+
+// GetSecurityActivityHandler lists the current user's recent security events
+func GetSecurityActivityHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		events, err := controllers.GetUserSecurityEvents(db, userID, limit)
+		if err != nil {
+			logger.Error("Failed to get security events of user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch activity"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"events": events})
+	}
+}
+
+// SearchSecurityEventsHandler searches the audit log by user_id, type, ip,
+// identifier, since and until (RFC 3339), with limit and offset
+func SearchSecurityEventsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		query := r.URL.Query()
+		filter := models.SecurityEventFilter{
+			EventType:  query.Get("type"),
+			IPAddress:  query.Get("ip"),
+			Identifier: query.Get("identifier"),
+		}
+
+		var err error
+		for name, target := range map[string]*int{
+			"user_id": &filter.UserID,
+			"limit":   &filter.Limit,
+			"offset":  &filter.Offset,
+		} {
+			if value := query.Get(name); value != "" {
+				if *target, err = strconv.Atoi(value); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(map[string]string{"error": "Invalid " + name})
+					return
+				}
+			}
+		}
+		for name, target := range map[string]*time.Time{
+			"since": &filter.Since,
+			"until": &filter.Until,
+		} {
+			if value := query.Get(name); value != "" {
+				if *target, err = time.Parse(time.RFC3339, value); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(map[string]string{"error": "Invalid " + name + ", expected RFC 3339"})
+					return
+				}
+			}
+		}
+
+		events, err := controllers.SearchSecurityEvents(db, filter)
+		if err != nil {
+			logger.Error("Failed to search security events: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to search security events"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"events": events})
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	"forum/backend/models"
+	"forum/backend/utils"
+)
+
+// currentSessionID returns the public ID of the session behind the request's cookie
+func currentSessionID(db *sql.DB, r *http.Request) string {
+	sessionToken, err := controllers.GetSessionToken(r)
+	if err != nil {
+		return ""
+	}
+	sessionID, err := controllers.GetSessionIDByToken(db, sessionToken)
+	if err != nil {
+		return ""
+	}
+	return sessionID
+}
+
+// GetSessionsHandler lists the devices the user is signed in on
+func GetSessionsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			logger.Error("Invalid user ID: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		sessions, err := controllers.GetUserSessions(db, userID)
+		if err != nil {
+			logger.Error("Failed to get sessions: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch sessions"})
+			return
+		}
+
+		current := currentSessionID(db, r)
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == current
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sessions": sessions,
+		})
+	}
+}
+
+// RevokeSessionHandler signs out one device (?id=) or every other device (?scope=others)
+func RevokeSessionHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+		if err != nil {
+			logger.Error("Invalid user ID: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+			return
+		}
+
+		current := currentSessionID(db, r)
+		var revoked []string
+
+		switch {
+		case r.URL.Query().Get("scope") == "others":
+			revoked, err = controllers.RevokeOtherUserSessions(db, userID, current)
+			if err != nil {
+				logger.Error("Failed to revoke other sessions: %v", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Failed to revoke sessions"})
+				return
+			}
+
+		case r.URL.Query().Get("id") != "":
+			sessionID := r.URL.Query().Get("id")
+			err = controllers.RevokeUserSession(db, userID, sessionID)
+			if err == sql.ErrNoRows {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Session not found"})
+				return
+			}
+			if err != nil {
+				logger.Error("Failed to revoke session: %v", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Failed to revoke session"})
+				return
+			}
+			revoked = []string{sessionID}
+
+			if sessionID == current {
+				http.SetCookie(w, &http.Cookie{
+					Name:     "session_token",
+					Value:    "",
+					Path:     "/",
+					MaxAge:   -1,
+					HttpOnly: true,
+					Secure:   true,
+					SameSite: http.SameSiteStrictMode,
+				})
+			}
+
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Session id or scope=others is required"})
+			return
+		}
+
+		utils.CloseSessionConnections(revoked...)
+		if !utils.UserHasConnections(userID) {
+			utils.MarkUserOffline(userID)
+		}
+
+		logger.Info("User %d revoked %d session(s)", userID, len(revoked))
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{
+			UserID:    userID,
+			EventType: controllers.EventSessionRevoked,
+			Details:   fmt.Sprintf("%d session(s)", len(revoked)),
+		})
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Sessions revoked successfully",
+			"revoked": len(revoked),
+		})
+	}
+}
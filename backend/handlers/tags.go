@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+)
+
+// GetTagsHandler lists the most used tags and how many posts carry each
+func GetTagsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		tags, err := controllers.GetTags(db, "", limit)
+		if err != nil {
+			logger.Error("Failed to get tags: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch tags"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"tags": tags})
+	}
+}
+
+// SuggestTagsHandler completes the tag started in ?q=, most used tags first
+func SuggestTagsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		prefix := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(r.URL.Query().Get("q")), "#"))
+		if prefix == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "q is required"})
+			return
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 {
+			limit = 10
+		}
+
+		tags, err := controllers.GetTags(db, prefix, limit)
+		if err != nil {
+			logger.Error("Failed to suggest tags for %q: %v", prefix, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch tags"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"tags": tags})
+	}
+}
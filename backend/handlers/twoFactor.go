@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"forum/backend/controllers"
+	"forum/backend/logger"
+	"forum/backend/models"
+)
+
+// twoFactorError maps controller errors to a client response
+func twoFactorError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, controllers.ErrInvalidTwoFactorCode):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, controllers.ErrTOTPAlreadyEnabled),
+		errors.Is(err, controllers.ErrTOTPNotEnabled),
+		errors.Is(err, controllers.ErrTOTPNotStarted):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		logger.Error("Failed to %s: %v", action, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to " + action})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// decodeTwoFactorCode reads {"code": "..."} from the request body
+func decodeTwoFactorCode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var requestData struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil || requestData.Code == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Code is required"})
+		return "", false
+	}
+	return requestData.Code, true
+}
+
+func twoFactorUserID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID, err := strconv.Atoi(r.Context().Value(models.UserIDKey).(string))
+	if err != nil {
+		logger.Error("Invalid user ID: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// TwoFactorStatusHandler reports whether 2FA is enabled for the current user
+func TwoFactorStatusHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, ok := twoFactorUserID(w, r)
+		if !ok {
+			return
+		}
+
+		status, err := controllers.GetTwoFactorStatus(db, userID)
+		if err != nil {
+			twoFactorError(w, err, "fetch two-factor status")
+			return
+		}
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// TwoFactorSetupHandler starts enrollment and returns the secret and provisioning URI
+func TwoFactorSetupHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, ok := twoFactorUserID(w, r)
+		if !ok {
+			return
+		}
+
+		secret, uri, err := controllers.BeginTOTPEnrollment(db, userID)
+		if err != nil {
+			twoFactorError(w, err, "start two-factor setup")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"secret":           secret,
+			"provisioning_uri": uri,
+		})
+	}
+}
+
+// TwoFactorEnableHandler verifies the first code from the app and turns 2FA on
+func TwoFactorEnableHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, ok := twoFactorUserID(w, r)
+		if !ok {
+			return
+		}
+		code, ok := decodeTwoFactorCode(w, r)
+		if !ok {
+			return
+		}
+
+		codes, err := controllers.EnableTOTP(db, userID, code)
+		if err != nil {
+			twoFactorError(w, err, "enable two-factor authentication")
+			return
+		}
+		logger.Info("User %d enabled two-factor authentication", userID)
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{UserID: userID, EventType: controllers.EventTwoFactorEnabled})
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":        "Two-factor authentication enabled",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// TwoFactorDisableHandler turns 2FA off after checking a code
+func TwoFactorDisableHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, ok := twoFactorUserID(w, r)
+		if !ok {
+			return
+		}
+		code, ok := decodeTwoFactorCode(w, r)
+		if !ok {
+			return
+		}
+
+		if err := controllers.DisableTOTP(db, userID, code); err != nil {
+			twoFactorError(w, err, "disable two-factor authentication")
+			return
+		}
+		logger.Info("User %d disabled two-factor authentication", userID)
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{UserID: userID, EventType: controllers.EventTwoFactorDisabled})
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Two-factor authentication disabled",
+		})
+	}
+}
+
+// TwoFactorRecoveryCodesHandler replaces the user's recovery codes
+func TwoFactorRecoveryCodesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, ok := twoFactorUserID(w, r)
+		if !ok {
+			return
+		}
+		code, ok := decodeTwoFactorCode(w, r)
+		if !ok {
+			return
+		}
+
+		codes, err := controllers.RegenerateRecoveryCodes(db, userID, code)
+		if err != nil {
+			twoFactorError(w, err, "regenerate recovery codes")
+			return
+		}
+		controllers.RecordSecurityEvent(db, r, models.SecurityEvent{UserID: userID, EventType: controllers.EventRecoveryCodesRegenerated})
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"recovery_codes": codes,
+		})
+	}
+}
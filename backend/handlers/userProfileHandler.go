@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"forum/backend/controllers"
+	"forum/backend/database"
 	"forum/backend/logger"
 	"forum/backend/models"
+	auth "forum/backend/sessions"
 	"forum/backend/utils"
 )
 
@@ -394,8 +398,13 @@ func DeleteUserHandler(uc *controllers.UsersController) http.HandlerFunc {
 			return
 		}
 
-		// Delete the user and all related data
-		err = uc.DeleteUser(userIDInt)
+		// Schedule the account for deletion; signing in before then keeps it
+		deleteAfter, err := uc.DeleteUser(userIDInt)
+		if errors.Is(err, controllers.ErrLastAdmin) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Make someone else an admin before deleting your account"})
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to delete user: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -403,10 +412,22 @@ func DeleteUserHandler(uc *controllers.UsersController) http.HandlerFunc {
 			return
 		}
 
-		// Return success response
+		// Their sessions are gone; drop the live connections too
+		utils.CloseUserConnections(userIDInt)
+		utils.MarkUserOffline(userIDInt)
+		auth.ClearRefreshCookie(w)
+		auth.ClearCSRFCookie(w)
+
+		logger.Info("User %d scheduled their account for deletion after %s", userIDInt, deleteAfter.Format(time.RFC3339))
+		controllers.RecordSecurityEvent(database.GloabalDB, r, models.SecurityEvent{
+			UserID:    userIDInt,
+			EventType: controllers.EventDeletionScheduled,
+			Details:   "delete after " + deleteAfter.Format(time.RFC3339),
+		})
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{
-			"message": "User and all related data deleted successfully",
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":      "Your account will be deleted. Sign in before then to keep it.",
+			"delete_after": deleteAfter,
 		})
 	}
 }
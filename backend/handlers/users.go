@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"forum/backend/controllers"
+	"forum/backend/database"
 	"forum/backend/logger"
 	"forum/backend/models"
 )
@@ -207,6 +208,7 @@ func UpdatePasswordHandler(uc *controllers.UsersController) http.HandlerFunc {
 
 		// Verify current password
 		if err := uc.VerifyPassword(userID, req.CurrentPassword); err != nil {
+			controllers.RecordSecurityEvent(database.GloabalDB, r, models.SecurityEvent{UserID: userID, EventType: controllers.EventPasswordChangeFailed})
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Current password is incorrect"})
 			return
@@ -219,6 +221,7 @@ func UpdatePasswordHandler(uc *controllers.UsersController) http.HandlerFunc {
 			return
 		}
 
+		controllers.RecordSecurityEvent(database.GloabalDB, r, models.SecurityEvent{UserID: userID, EventType: controllers.EventPasswordChanged})
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{"message": "Password updated successfully"})
 	}
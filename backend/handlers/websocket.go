@@ -3,10 +3,13 @@ package handlers
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"forum/backend/controllers"
+	"forum/backend/database"
 	"forum/backend/logger"
 	"forum/backend/models"
 	"forum/backend/utils"
@@ -34,7 +37,7 @@ func SendToUser(userID int, message []byte) {
 			if err != nil {
 				logger.Error("Error sending message to user %d: %v", userID, err)
 				conn.Close()
-				delete(utils.Clients, conn)
+				utils.UnregisterClient(conn)
 			}
 		}
 	}
@@ -46,35 +49,78 @@ func SendToUser(userID int, message []byte) {
 
 // WebSocketHandler manages WebSocket connections
 func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	userID := r.Context().Value(models.UserIDKey).(string)
+	userIDInt, err := strconv.Atoi(userID)
 	if err != nil {
-		logger.Error("Failed to upgrade connection: %v", err)
-		http.Error(w, "Failed to upgrade connection", http.StatusInternalServerError)
+		logger.Error("Invalid user ID: %v", err)
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
 
-	userID := r.Context().Value(models.UserIDKey).(string)
-	userIDInt, err := strconv.Atoi(userID)
+	// The token outlives its session, which may have been logged out or
+	// revoked since; a socket would keep receiving the user's messages
+	sessionID, _ := r.Context().Value(models.SessionIDKey).(string)
+	live, err := controllers.IsLiveSessionID(database.GloabalDB, sessionID, userIDInt)
 	if err != nil {
-		logger.Error("Invalid user ID: %v", err)
-		conn.Close()
+		logger.Error("Failed to check session %s: %v", sessionID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !live {
+		logger.Warning("Refused WebSocket connection from user %d with ended session %s", userIDInt, sessionID)
+		http.Error(w, "Session ended", http.StatusUnauthorized)
 		return
 	}
 
-	// Store the user ID with the connection
-	utils.Mutex.Lock()
-	utils.Clients[conn] = userIDInt
-	utils.Mutex.Unlock()
+	// Suspended users may still hold an unexpired access token
+	suspension, err := controllers.GetActiveSuspension(database.GloabalDB, userIDInt)
+	if err != nil {
+		logger.Error("Failed to check suspension of user %d: %v", userIDInt, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if suspension != nil {
+		logger.Warning("Refused WebSocket connection from suspended user %d", userIDInt)
+		http.Error(w, "Account suspended", http.StatusForbidden)
+		return
+	}
+
+	// Admins acting as the user only receive; they cannot send on their behalf
+	impersonation, err := controllers.GetImpersonationBySessionID(database.GloabalDB, sessionID)
+	if errors.Is(err, controllers.ErrSessionEnded) {
+		logger.Warning("Refused WebSocket connection from user %d with ended session %s", userIDInt, sessionID)
+		http.Error(w, "Session ended", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to check session %s for impersonation: %v", sessionID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if impersonation != nil {
+		controllers.RecordImpersonatedRequest(database.GloabalDB, r, impersonation, http.StatusSwitchingProtocols)
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Error("Failed to upgrade connection: %v", err)
+		http.Error(w, "Failed to upgrade connection", http.StatusInternalServerError)
+		return
+	}
+
+	// Store the user and session IDs with the connection
+	utils.RegisterClient(conn, userIDInt, sessionID)
 
 	// Mark user as online
 	utils.MarkUserOnline(userIDInt)
 
 	// Start a goroutine to handle this client's messages
-	go handleClientMessages(conn, userIDInt)
+	go handleClientMessages(conn, userIDInt, impersonation != nil)
 }
 
-// handleClientMessages processes messages from a single client
-func handleClientMessages(conn *websocket.Conn, userIDInt int) {
+// handleClientMessages processes messages from a single client. Read-only
+// clients may only ping.
+func handleClientMessages(conn *websocket.Conn, userIDInt int, readOnly bool) {
 	// Set initial ping deadline
 	conn.SetReadDeadline(time.Now().Add(40 * time.Second))
 
@@ -97,9 +143,12 @@ func handleClientMessages(conn *websocket.Conn, userIDInt int) {
 
 	defer func() {
 		utils.Mutex.Lock()
-		delete(utils.Clients, conn)
+		utils.UnregisterClient(conn)
 		utils.Mutex.Unlock()
-		utils.MarkUserOffline(userIDInt)
+		// Stay online while another device is still connected
+		if !utils.UserHasConnections(userIDInt) {
+			utils.MarkUserOffline(userIDInt)
+		}
 		conn.Close()
 	}()
 
@@ -125,6 +174,10 @@ func handleClientMessages(conn *websocket.Conn, userIDInt int) {
 			utils.MarkUserOnline(userIDInt)
 			continue
 		}
+		if readOnly {
+			logger.Warning("Dropped WebSocket message from impersonated user %d", userIDInt)
+			continue
+		}
 
 		utils.Broadcast(message)
 	}
@@ -157,35 +210,36 @@ func BroadcastNewPost(post models.Post) {
 	utils.Broadcast(msgBytes)
 }
 
-// Add new function to broadcast post reactions
-func BroadcastPostReaction(postID int, likes int, dislikes int) {
-	message := struct {
-		Type    string `json:"type"`
-		Payload struct {
-			PostID   int `json:"post_id"`
-			Likes    int `json:"likes"`
-			Dislikes int `json:"dislikes"`
-		} `json:"payload"`
-	}{
-		Type: "post_reaction",
-		Payload: struct {
-			PostID   int `json:"post_id"`
-			Likes    int `json:"likes"`
-			Dislikes int `json:"dislikes"`
-		}{
-			PostID:   postID,
-			Likes:    likes,
-			Dislikes: dislikes,
-		},
+// BroadcastReaction sends the new reaction counts of a post, comment or
+// message. Who reacted is per user, so Reacted is left out; counts on a
+// message only go to the recipients, the two people in its conversation.
+func BroadcastReaction(entityType string, entityID int, counts []models.ReactionCount, recipients ...int) {
+	shared := make([]models.ReactionCount, len(counts))
+	for i, count := range counts {
+		count.Reacted = false
+		shared[i] = count
 	}
 
-	messageBytes, err := json.Marshal(message)
+	messageBytes, err := json.Marshal(map[string]interface{}{
+		"type": "reaction",
+		"payload": map[string]interface{}{
+			"entity_type": entityType,
+			"entity_id":   entityID,
+			"reactions":   shared,
+		},
+	})
 	if err != nil {
-		logger.Error("Failed to marshal post reaction message: %v", err)
+		logger.Error("Failed to marshal reaction message: %v", err)
 		return
 	}
 
-	utils.Broadcast(messageBytes)
+	if len(recipients) == 0 {
+		utils.Broadcast(messageBytes)
+		return
+	}
+	for _, userID := range recipients {
+		SendToUser(userID, messageBytes)
+	}
 }
 
 // Add this function to broadcast unread count updates
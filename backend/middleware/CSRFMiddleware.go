@@ -9,7 +9,8 @@ import (
 	"forum/backend/logger"
 )
 
-// VerifyCSRFMiddleware is a middleware function to verify CSRF tokens
+// VerifyCSRFMiddleware is a middleware function to verify CSRF tokens. It must
+// be listed before JWTAuthMiddleware, SessionAuthMiddleware and APITokenAuth.
 func VerifyCSRFMiddleware(db *sql.DB) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -19,6 +20,12 @@ func VerifyCSRFMiddleware(db *sql.DB) func(http.Handler) http.Handler {
 				return
 			}
 
+			// Browsers never attach personal access tokens on their own
+			if authenticatedByAPIToken(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			// Verify the CSRF token
 			if !controllers.VerifyCSRFToken(db, r) {
 				logger.Warning("Invalid CSRF token in request - remote_addr: %s, method: %s, path: %s",
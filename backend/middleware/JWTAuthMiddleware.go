@@ -2,9 +2,12 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
+	"forum/backend/controllers"
+	"forum/backend/database"
 	"forum/backend/logger"
 	"forum/backend/models"
 	"forum/backend/utils"
@@ -12,6 +15,12 @@ import (
 
 func JWTAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Personal access tokens were already checked by APITokenAuth
+		if authenticatedByAPIToken(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Try to get the token from the Authorization header
 		authHeader := r.Header.Get("Authorization")
 		var tokenString string
@@ -42,12 +51,20 @@ func JWTAuthMiddleware(next http.Handler) http.Handler {
 				r.Method,
 				r.URL.Path,
 			)
+			// Expired tokens are routine; anything else may be tampering
+			if !errors.Is(err, utils.ErrTokenExpired) {
+				controllers.RecordAnonymousSecurityEvent(database.GloabalDB, r, models.SecurityEvent{
+					EventType: controllers.EventInvalidToken,
+					Details:   r.Method + " " + r.URL.Path,
+				})
+			}
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
 		// Attach the user ID to the request context
 		ctx := context.WithValue(r.Context(), models.UserIDKey, claims.UserID)
+		ctx = context.WithValue(ctx, models.SessionIDKey, claims.SessionID)
 		r = r.WithContext(ctx)
 
 		// Pass the request to the next handler
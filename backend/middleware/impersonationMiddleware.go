@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"forum/backend/controllers"
+	"forum/backend/database"
+	"forum/backend/logger"
+	"forum/backend/models"
+)
+
+// serveImpersonated handles a request made while an admin acts as another
+// user. Every response is flagged with the admin's nickname, account and
+// messaging actions are refused, and each request is written to the user's
+// audit log.
+func serveImpersonated(w http.ResponseWriter, r *http.Request, next http.Handler, impersonation *models.Impersonation) {
+	w.Header().Set("X-Impersonated-By", impersonation.AdminNickname)
+
+	if !controllers.ImpersonationAllows(r.Method, r.URL.Path) {
+		logger.Warning("Admin %d was refused %s %s while acting as user %d",
+			impersonation.AdminID, r.Method, r.URL.Path, impersonation.UserID)
+		controllers.RecordImpersonatedRequest(database.GloabalDB, r, impersonation, http.StatusForbidden)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "This is not available while acting as another user",
+		})
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), models.ImpersonatorIDKey, strconv.Itoa(impersonation.AdminID))
+	rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(rw, r.WithContext(ctx))
+	controllers.RecordImpersonatedRequest(database.GloabalDB, r, impersonation, rw.status)
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"forum/backend/controllers"
+	"forum/backend/database"
+	"forum/backend/logger"
+	"forum/backend/models"
+)
+
+// RequirePermission rejects requests from users whose role does not grant
+// perm. It must run after JWTAuthMiddleware.
+func RequirePermission(perm controllers.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userIDStr, _ := r.Context().Value(models.UserIDKey).(string)
+			userID, err := strconv.Atoi(userIDStr)
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			allowed, err := controllers.UserHasPermission(database.GloabalDB, userID, perm)
+			if err != nil {
+				logger.Error("Failed to check permission %s for user %d: %v", perm, userID, err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if !allowed {
+				logger.Warning("User %d lacks permission %s - path: %s", userID, perm, r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "You do not have permission to do this",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,9 +2,13 @@ package middleware
 
 import (
 	"context"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
+
+	"forum/backend/utils"
 )
 
 type Visitor struct {
@@ -52,7 +56,8 @@ func (rl *RateLimiter) CleanupVisitors(interval time.Duration) {
 func (rl *RateLimiter) RateLimit(next http.Handler) http.Handler {
 	go rl.CleanupVisitors(time.Minute)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
+		// RemoteAddr includes the source port, which changes per connection
+		ip := utils.ClientIP(r)
 
 		rl.Mu.Lock()
 		v, exists := rl.Visitors[ip]
@@ -75,6 +80,7 @@ func (rl *RateLimiter) RateLimit(next http.Handler) http.Handler {
 
 		if v.Count > rl.Rate {
 			rl.Mu.Unlock()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rl.Interval.Seconds()))))
 			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
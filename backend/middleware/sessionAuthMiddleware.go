@@ -6,11 +6,18 @@ import (
 	"forum/backend/controllers"
 	"forum/backend/database"
 	"forum/backend/logger"
+	"forum/backend/models"
 )
 
 // Middleware to check if the user is authenticated
 func SessionAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Personal access tokens do not come with a session cookie
+		if authenticatedByAPIToken(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Check if the user is authenticated
 		sessionCookie, err := r.Cookie("session_token")
 		if err != nil || sessionCookie == nil || sessionCookie.Value == "" {
@@ -24,7 +31,14 @@ func SessionAuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		// Validating the session token
-		if _, valid := controllers.IsValidSession(database.GloabalDB, sessionCookie.Value); !valid {
+		if userID, valid := controllers.IsValidSession(database.GloabalDB, sessionCookie.Value); !valid {
+			// Expired sessions are routine; unknown ones were revoked or made up
+			if userID == 0 {
+				controllers.RecordAnonymousSecurityEvent(database.GloabalDB, r, models.SecurityEvent{
+					EventType: controllers.EventInvalidSession,
+					Details:   r.Method + " " + r.URL.Path,
+				})
+			}
 			logger.Warning("Unauthorized attempt  Invalid Session - remote_addr: %s, method: %s, path: %s",
 				r.RemoteAddr,
 				r.Method,
@@ -34,6 +48,22 @@ func SessionAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if err := controllers.TouchSession(database.GloabalDB, sessionCookie.Value); err != nil {
+			logger.Error("Failed to update session last use: %v", err)
+		}
+
+		// An admin acting as the user gets a restricted, audited session
+		impersonation, err := controllers.GetSessionImpersonation(database.GloabalDB, sessionCookie.Value)
+		if err != nil {
+			logger.Error("Failed to check session for impersonation: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if impersonation != nil {
+			serveImpersonated(w, r, next, impersonation)
+			return
+		}
+
 		// User is authenticated, call the next handler
 		next.ServeHTTP(w, r)
 	})
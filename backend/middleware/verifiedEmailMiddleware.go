@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"forum/backend/controllers"
+	"forum/backend/database"
+	"forum/backend/logger"
+	"forum/backend/models"
+)
+
+// Features that REQUIRE_VERIFIED_EMAIL can restrict to users with a confirmed address
+const (
+	FeaturePosting   = "posting"
+	FeatureMessaging = "messaging"
+)
+
+// verifiedEmailRequired reports whether REQUIRE_VERIFIED_EMAIL (a comma
+// separated list such as "posting,messaging") includes the feature
+func verifiedEmailRequired(feature string) bool {
+	for _, f := range strings.Split(os.Getenv("REQUIRE_VERIFIED_EMAIL"), ",") {
+		if strings.TrimSpace(f) == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireVerifiedEmail rejects writes to a feature from users who have not
+// confirmed their email, when the feature is listed in REQUIRE_VERIFIED_EMAIL.
+// Reads and deletions always pass. It must run after JWTAuthMiddleware.
+func RequireVerifiedEmail(feature string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !verifiedEmailRequired(feature) {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodDelete {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userIDStr, _ := r.Context().Value(models.UserIDKey).(string)
+			userID, err := strconv.Atoi(userIDStr)
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			verified, err := controllers.IsEmailVerified(database.GloabalDB, userID)
+			if err != nil {
+				logger.Error("Failed to check email verification for user %d: %v", userID, err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if !verified {
+				logger.Warning("Blocked %s by unverified user %d - path: %s", feature, userID, r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error":                       "Please verify your email address first",
+					"email_verification_required": true,
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -17,9 +17,45 @@ type User struct {
 	IsOnline       bool      `json:"is_online"`
 	UnreadMessages int       `json:"unread_messages"`
 	LastSeen       time.Time `json:"last_seen"`
+	EmailVerified  bool      `json:"email_verified"`
+	Role           string    `json:"role"`
 }
 
+// User roles, from least to most privileged
+const (
+	RoleMember    = "member"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type LoginRequest struct {
 	Identifier string `json:"identifier"`
 	Password   string `json:"password"`
 }
+
+// AuthTokens is returned to a client when a session is created or refreshed
+type AuthTokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	CSRFToken    string `json:"csrf_token,omitempty"`
+}
+
+// UserIdentity is an external OpenID Connect account linked to a user
+type UserIdentity struct {
+	Issuer   string    `json:"issuer"`
+	Email    string    `json:"email"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+// APIToken is a personal access token as shown to its owner. The token
+// itself is only returned once, when it is created.
+type APIToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Category groups posts. Posts keep the slugs of their categories, so a
+// category can be renamed without touching them. Archived categories keep
+// their posts but can't be picked for new ones.
+type Category struct {
+	ID          int       `json:"id"`
+	Slug        string    `json:"slug"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Position    int       `json:"position"`
+	Archived    bool      `json:"archived"`
+	PostCount   int       `json:"post_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CategoryUpdate changes the fields of a category that are set
+type CategoryUpdate struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Position    *int    `json:"position"`
+	Archived    *bool   `json:"archived"`
+}
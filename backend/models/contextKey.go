@@ -4,4 +4,14 @@ package models
 type contextKey string
 
 const UserIDKey contextKey = "userID"
-	
\ No newline at end of file
+
+// SessionIDKey holds the public ID of the session the request's JWT belongs to
+const SessionIDKey contextKey = "sessionID"
+	
+// APITokenScopesKey holds the scopes of the personal access token that
+// authenticated the request. It is absent for browser sessions.
+const APITokenScopesKey contextKey = "apiTokenScopes"
+
+// ImpersonatorIDKey holds the ID of the admin acting as the request's user.
+// It is absent unless the session is an impersonation.
+const ImpersonatorIDKey contextKey = "impersonatorID"
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Impersonation is a short session an admin opened to act as another user
+type Impersonation struct {
+	ID                int        `json:"id"`
+	AdminID           int        `json:"admin_id"`
+	AdminNickname     string     `json:"admin_nickname"`
+	UserID            int        `json:"user_id"`
+	UserNickname      string     `json:"user_nickname"`
+	SessionID         string     `json:"-"`
+	AdminSessionToken string     `json:"-"`
+	Reason            string     `json:"reason"`
+	StartedAt         time.Time  `json:"started_at"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	EndedAt           *time.Time `json:"ended_at,omitempty"`
+}
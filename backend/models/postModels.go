@@ -5,6 +5,34 @@ import (
 	"time"
 )
 
+// Post statuses. Drafts and scheduled posts are only shown to their author.
+const (
+	PostDraft     = "draft"
+	PostScheduled = "scheduled"
+	PostPublished = "published"
+)
+
+// Feed modes of the post feed
+const (
+	FeedNew       = "new"
+	FeedFollowing = "following"
+	FeedHot       = "hot"
+	FeedTop       = "top"
+)
+
+// Feed picks and orders the posts of the post feed. Zero values show every
+// post, newest first.
+type Feed struct {
+	Mode string
+	// Window limits the top feed to the last day, week or month, or all
+	Window string
+	// ViewerID is who reads the feed: whose follows make up the following
+	// feed, and whose reactions are marked
+	ViewerID int
+	Category string
+	Tag      string
+}
+
 type Post struct {
 	ID        int            `json:"id"`
 	User      User           `json:"user"`
@@ -12,11 +40,56 @@ type Post struct {
 	Title     string         `json:"title"`
 	UserID    int            `json:"user_id"`
 	Category  string         `json:"category"`
-	Likes     int            `json:"likes"`
-	Dislikes  int            `json:"dislikes"`
 	Content   string         `json:"content"`
 	Timestamp time.Time      `json:"timestamp"`
 	VideoUrl  sql.NullString `json:"video_url"`
 	Images    []string       `json:"images"`
-	Comments  []Comment      `json:"comments"`
+	// Comments are only loaded with a single post; feeds carry CommentCount
+	Comments     []Comment `json:"comments"`
+	CommentCount int       `json:"comment_count"`
+	Locked       bool      `json:"locked"`
+	// EditedAt is when the title, content or category last changed
+	EditedAt      *time.Time `json:"edited_at"`
+	RevisionCount int        `json:"revision_count"`
+	Status        string     `json:"status"`
+	// PublishAt is when a scheduled post goes live
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// Tags are the post's hashtags, without the #
+	Tags      []string        `json:"tags"`
+	Reactions []ReactionCount `json:"reactions"`
+}
+
+// PostRevision is one saved version of a post. Revision 1 is the post as it
+// was first published; it is stored when the post is first edited.
+type PostRevision struct {
+	PostID    int       `json:"post_id"`
+	Revision  int       `json:"revision"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Category  string    `json:"category"`
+	EditedBy  *User     `json:"edited_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Diff operations of a DiffLine
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffLine is a line kept, added or removed between two versions of a text
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// PostRevisionDiff lists the changes between two revisions of a post
+type PostRevisionDiff struct {
+	PostID   int        `json:"post_id"`
+	From     int        `json:"from"`
+	To       int        `json:"to"`
+	Title    []DiffLine `json:"title"`
+	Content  []DiffLine `json:"content"`
+	Category []DiffLine `json:"category"`
 }
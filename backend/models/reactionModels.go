@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Things users can react to
+const (
+	ReactionOnPost    = "post"
+	ReactionOnComment = "comment"
+	ReactionOnMessage = "message"
+)
+
+// Reaction is one of the emoji reactions users can leave
+type Reaction struct {
+	Name  string `json:"name"`
+	Emoji string `json:"emoji"`
+}
+
+// ReactionCount is how many users left a reaction, and whether the user
+// asking is one of them
+type ReactionCount struct {
+	Reaction
+	Count   int  `json:"count"`
+	Reacted bool `json:"reacted"`
+}
+
+// Reactor is a user who left a reaction
+type Reactor struct {
+	UserID    int       `json:"user_id"`
+	Nickname  string    `json:"nickname"`
+	Avatar    *string   `json:"avatar"`
+	ReactedAt time.Time `json:"reacted_at"`
+}
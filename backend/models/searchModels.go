@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Kinds of SearchResult
+const (
+	SearchPost    = "post"
+	SearchComment = "comment"
+)
+
+// SearchResult is a post or comment matching a search. Snippet is HTML: the
+// text is escaped and the matched words are wrapped in <mark>.
+type SearchResult struct {
+	Type      string    `json:"type"`
+	PostID    int       `json:"post_id"`
+	CommentID int       `json:"comment_id,omitempty"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"`
+	Author    string    `json:"author"`
+	Category  string    `json:"category"`
+	Timestamp time.Time `json:"timestamp"`
+	// Score orders the results, higher is better
+	Score float64 `json:"score"`
+}
+
+// SearchFilter narrows a search of posts and comments. Zero values match everything.
+type SearchFilter struct {
+	Query string
+	// Type limits the results to SearchPost or SearchComment
+	Type     string
+	Category string
+	// Author is the nickname of whoever wrote the post or comment
+	Author string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
+}
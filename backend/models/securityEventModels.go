@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// SecurityEvent is one entry of the security audit log
+type SecurityEvent struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id,omitempty"`
+	EventType  string    `json:"event_type"`
+	Identifier string    `json:"identifier,omitempty"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	Details    string    `json:"details,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SecurityEventFilter narrows a search of the audit log. Zero values match everything.
+type SecurityEventFilter struct {
+	UserID     int
+	EventType  string
+	IPAddress  string
+	Identifier string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Suspension keeps a user from signing in until it expires or is lifted.
+// A suspension without ExpiresAt is a permanent ban.
+type Suspension struct {
+	ID            int        `json:"id"`
+	UserID        int        `json:"user_id"`
+	SuspendedBy   *int       `json:"suspended_by"`
+	Reason        string     `json:"reason"`
+	ContentPolicy string     `json:"content_policy"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	LiftedAt      *time.Time `json:"lifted_at,omitempty"`
+	LiftedBy      *int       `json:"lifted_by,omitempty"`
+}
+
+// Permanent reports whether the suspension never expires
+func (s Suspension) Permanent() bool {
+	return s.ExpiresAt == nil
+}
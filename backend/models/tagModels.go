@@ -0,0 +1,7 @@
+package models
+
+// Tag is a hashtag with the number of published posts carrying it
+type Tag struct {
+	Name      string `json:"name"`
+	PostCount int    `json:"post_count"`
+}
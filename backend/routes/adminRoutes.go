@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+
+	"forum/backend/controllers"
+	"forum/backend/handlers"
+	"forum/backend/middleware"
+)
+
+// AdminRoutes registers the role and moderation endpoints for staff
+func AdminRoutes(db *sql.DB) {
+	http.Handle("/api/admin/roles", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				handlers.GetStaffHandler(db).ServeHTTP(w, r)
+			case http.MethodPut:
+				handlers.SetUserRoleHandler(db).ServeHTTP(w, r)
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}),
+		middleware.RequirePermission(controllers.PermManageRoles),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/api/admin/suspensions", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				handlers.GetSuspensionsHandler(db).ServeHTTP(w, r)
+			case http.MethodPost:
+				handlers.SuspendUserHandler(db).ServeHTTP(w, r)
+			case http.MethodDelete:
+				handlers.LiftSuspensionHandler(db).ServeHTTP(w, r)
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}),
+		middleware.RequirePermission(controllers.PermSuspendUsers),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/api/admin/categories", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost:
+				handlers.CreateCategoryHandler(db).ServeHTTP(w, r)
+			case http.MethodPut:
+				handlers.UpdateCategoryHandler(db).ServeHTTP(w, r)
+			case http.MethodDelete:
+				handlers.DeleteCategoryHandler(db).ServeHTTP(w, r)
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}),
+		middleware.RequirePermission(controllers.PermManageCategories),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/api/admin/security-events", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.SearchSecurityEventsHandler(db).ServeHTTP(w, r)
+		}),
+		middleware.RequirePermission(controllers.PermViewAuditLog),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/api/admin/impersonations", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				handlers.GetImpersonationsHandler(db).ServeHTTP(w, r)
+			case http.MethodPost:
+				handlers.StartImpersonationHandler(db).ServeHTTP(w, r)
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}),
+		middleware.RequirePermission(controllers.PermImpersonate),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+}
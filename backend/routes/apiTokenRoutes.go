@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+
+	"forum/backend/handlers"
+	"forum/backend/middleware"
+)
+
+// APITokenRoutes lets users manage their personal access tokens. Tokens
+// cannot manage tokens, so these routes need a browser session.
+func APITokenRoutes(db *sql.DB) {
+	http.Handle("/api/tokens", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				handlers.GetAPITokensHandler(db).ServeHTTP(w, r)
+			case http.MethodPost:
+				handlers.CreateAPITokenHandler(db).ServeHTTP(w, r)
+			case http.MethodDelete:
+				handlers.RevokeAPITokenHandler(db).ServeHTTP(w, r)
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+}
@@ -22,9 +22,69 @@ func UserRegAndLogin(db *sql.DB) {
 		middleware.CORSMiddleware,
 	))
 
+	http.Handle("/login/2fa", middleware.ApplyMiddleware(
+		http.HandlerFunc(handlers.LoginTwoFactorHandler(AuthController)),
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/login/magic", middleware.ApplyMiddleware(
+		http.HandlerFunc(handlers.MagicLinkRequestHandler(AuthController)),
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/login/magic/verify", middleware.ApplyMiddleware(
+		http.HandlerFunc(handlers.MagicLinkLoginHandler(AuthController)),
+		middleware.CORSMiddleware,
+	))
+
 	http.Handle("/validate-token", http.HandlerFunc(handlers.ValidateTokenHandler))
+	http.Handle("/refresh-token", middleware.ApplyMiddleware(
+		http.HandlerFunc(handlers.RefreshTokenHandler(db)),
+		middleware.CORSMiddleware,
+	))
+	http.Handle("/password/forgot", middleware.ApplyMiddleware(
+		http.HandlerFunc(handlers.ForgotPasswordHandler(AuthController)),
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/password/reset", middleware.ApplyMiddleware(
+		http.HandlerFunc(handlers.ResetPasswordHandler(AuthController)),
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/verify-email", http.HandlerFunc(handlers.VerifyEmailHandler(AuthController)))
+
+	http.Handle("/api/email/verify", middleware.ApplyMiddleware(
+		http.HandlerFunc(handlers.SendVerificationEmailHandler(AuthController)),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/api/me", middleware.ApplyMiddleware(
+		http.HandlerFunc(handlers.CurrentUserHandler(AuthController)),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+
+	// Same origin only: no CORS headers, so other sites cannot read the token
+	http.Handle("/csrf-token", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.CSRFTokenHandler(db)(w, r)
+		}),
+		middleware.SessionAuthMiddleware,
+	))
+
 	http.Handle("/logout", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.LogoutHandler),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.SessionAuthMiddleware,
 		middleware.JWTAuthMiddleware,
 	))
@@ -25,7 +25,10 @@ func SetupCommentRoutes(db *sql.DB) {
 				w.WriteHeader(http.StatusMethodNotAllowed)
 			}
 		}),
+		middleware.RequireVerifiedEmail(middleware.FeaturePosting),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
+		middleware.APITokenAuth("comments"),
 	))
 }
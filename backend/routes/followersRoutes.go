@@ -14,6 +14,7 @@ func SetupFollowersRoutes(db *sql.DB) {
 
 	http.Handle("/api/followers/follow", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.FollowUserHandler(followerController)),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 		middleware.ErrorHandler(handlers.ServeErrorPage),
@@ -22,6 +23,7 @@ func SetupFollowersRoutes(db *sql.DB) {
 
 	http.Handle("/api/followers/unfollow", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.UnfollowUserHandler(followerController)),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 		middleware.ErrorHandler(handlers.ServeErrorPage),
@@ -30,6 +32,7 @@ func SetupFollowersRoutes(db *sql.DB) {
 
 	http.Handle("/api/followers/counts", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.GetFollowCountsHandler(followerController)),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 		middleware.ErrorHandler(handlers.ServeErrorPage),
@@ -38,6 +41,7 @@ func SetupFollowersRoutes(db *sql.DB) {
 
 	http.Handle("/api/followers/following", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.GetUserFollowingListHandler(followerController)),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 		middleware.ErrorHandler(handlers.ServeErrorPage),
@@ -46,6 +50,7 @@ func SetupFollowersRoutes(db *sql.DB) {
 
 	http.Handle("/api/users/followers", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.GetUserFollowersHandler(followerController)),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 		middleware.ErrorHandler(handlers.ServeErrorPage),
@@ -54,6 +59,7 @@ func SetupFollowersRoutes(db *sql.DB) {
 
 	http.Handle("/api/users/following", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.GetUserFollowingHandler(followerController)),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 		middleware.ErrorHandler(handlers.ServeErrorPage),
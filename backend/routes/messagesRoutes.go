@@ -14,46 +14,59 @@ func MessagesRoutes(db *sql.DB) {
 
 	http.Handle("/messages", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.GetAllMessagesHandler(mc)),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.SessionAuthMiddleware,
 		middleware.JWTAuthMiddleware,
+		middleware.APITokenAuth("messages"),
 		middleware.CORSMiddleware,
 		middleware.SetCSPHeaders,
 	))
 	http.Handle("/messages/conversation", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.GetMessagesConversationHandler(mc)),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.SessionAuthMiddleware,
 		middleware.JWTAuthMiddleware,
+		middleware.APITokenAuth("messages"),
 		middleware.CORSMiddleware,
 		middleware.SetCSPHeaders,
 	))
 
 	http.Handle("/messages/send", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.SendMessageHandler(mc)),
+		middleware.RequireVerifiedEmail(middleware.FeatureMessaging),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.SessionAuthMiddleware,
 		middleware.JWTAuthMiddleware,
+		middleware.APITokenAuth("messages"),
 		middleware.CORSMiddleware,
 		middleware.SetCSPHeaders,
 	))
 	http.Handle("/messages/mark-as-read", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.MarkMessageAsReadHandler(mc)),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.SessionAuthMiddleware,
 		middleware.JWTAuthMiddleware,
+		middleware.APITokenAuth("messages"),
 		middleware.CORSMiddleware,
 		middleware.SetCSPHeaders,
 	))
 
 	http.Handle("/messages/unread-count", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.GetUnreadCountHandler(mc)),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.SessionAuthMiddleware,
 		middleware.JWTAuthMiddleware,
+		middleware.APITokenAuth("messages"),
 		middleware.CORSMiddleware,
 		middleware.SetCSPHeaders,
 	))
 
 	http.Handle("/messages/typing-status", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.TypingStatusHandler),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.SessionAuthMiddleware,
 		middleware.JWTAuthMiddleware,
+		middleware.APITokenAuth("messages"),
 		middleware.CORSMiddleware,
 		middleware.SetCSPHeaders,
 	))
@@ -21,20 +21,26 @@ func NotificationRoutes(db *sql.DB) {
 				w.WriteHeader(http.StatusMethodNotAllowed)
 			}
 		}),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.SessionAuthMiddleware,
 		middleware.CORSMiddleware,
 		middleware.JWTAuthMiddleware,
+		middleware.APITokenAuth("notifications"),
 	))
 	http.Handle("/api/notifications/clear", middleware.ApplyMiddleware(
 		handlers.ClearNotificationsHandler(nc),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.SessionAuthMiddleware,
 		middleware.CORSMiddleware,
 		middleware.JWTAuthMiddleware,
+		middleware.APITokenAuth("notifications"),
 	))
 	http.Handle("/api/notifications/read", middleware.ApplyMiddleware(
 		handlers.MarkNotificationAsReadHandler(nc),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.SessionAuthMiddleware,
 		middleware.CORSMiddleware,
 		middleware.JWTAuthMiddleware,
+		middleware.APITokenAuth("notifications"),
 	))
 }
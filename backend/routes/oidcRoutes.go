@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+
+	"forum/backend/handlers"
+	"forum/backend/middleware"
+	"forum/backend/utils"
+)
+
+// OIDCRoutes registers single sign-on. provider is nil when it is not configured.
+func OIDCRoutes(db *sql.DB, provider *utils.OIDCProvider) {
+	http.Handle("/auth/oidc", http.HandlerFunc(handlers.OIDCConfigHandler(provider)))
+	http.Handle("/auth/oidc/login", http.HandlerFunc(handlers.OIDCLoginHandler(db, provider)))
+	http.Handle("/auth/oidc/callback", http.HandlerFunc(handlers.OIDCCallbackHandler(db, provider)))
+
+	http.Handle("/api/oidc/link", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.OIDCLinkHandler(db, provider)(w, r)
+		}),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/api/oidc/identities", middleware.ApplyMiddleware(
+		http.HandlerFunc(handlers.GetIdentitiesHandler(db)),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+}
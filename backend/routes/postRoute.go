@@ -27,16 +27,127 @@ func PostRoute(db *sql.DB) {
 				w.WriteHeader(http.StatusMethodNotAllowed)
 			}
 		}),
+		middleware.RequireVerifiedEmail(middleware.FeaturePosting),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
+		middleware.APITokenAuth("posts"),
 		middleware.CORSMiddleware,
 		middleware.SetCSPHeaders,
 	))
 
 	http.Handle("/api/posts/vote", middleware.ApplyMiddleware(
 		handlers.HandleVotePost(PostController),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
+		middleware.APITokenAuth("posts"),
+		middleware.CORSMiddleware,
+		middleware.SetCSPHeaders,
+	))
+
+	http.Handle("/api/posts/lock", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.LockPostHandler(PostController)(w, r)
+		}),
+		middleware.RequirePermission(controllers.PermLockPost),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.SetCSPHeaders,
+	))
+
+	http.Handle("/api/categories", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.GetCategoriesHandler(db)(w, r)
+		}),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.APITokenAuth("posts"),
+		middleware.CORSMiddleware,
+		middleware.SetCSPHeaders,
+	))
+
+	http.Handle("/api/tags", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.GetTagsHandler(db)(w, r)
+		}),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.APITokenAuth("posts"),
+		middleware.CORSMiddleware,
+		middleware.SetCSPHeaders,
+	))
+
+	http.Handle("/api/tags/suggest", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.SuggestTagsHandler(db)(w, r)
+		}),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.APITokenAuth("posts"),
+		middleware.CORSMiddleware,
+		middleware.SetCSPHeaders,
+	))
+
+	http.Handle("/api/posts/drafts", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.GetDraftsHandler(PostController)(w, r)
+		}),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.APITokenAuth("posts"),
+		middleware.CORSMiddleware,
+		middleware.SetCSPHeaders,
+	))
+
+	http.Handle("/api/posts/revisions", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.GetPostRevisionsHandler(PostController)(w, r)
+		}),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.APITokenAuth("posts"),
+		middleware.CORSMiddleware,
+		middleware.SetCSPHeaders,
+	))
+
+	http.Handle("/api/posts/revisions/diff", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.DiffPostRevisionsHandler(PostController)(w, r)
+		}),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.APITokenAuth("posts"),
 		middleware.CORSMiddleware,
 		middleware.SetCSPHeaders,
 	))
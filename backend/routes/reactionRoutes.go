@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+
+	"forum/backend/handlers"
+	"forum/backend/middleware"
+	"forum/backend/models"
+)
+
+func ReactionRoutes(db *sql.DB) {
+	http.Handle("/api/reactions", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.GetReactionSetHandler()(w, r)
+		}),
+		middleware.CORSMiddleware,
+		middleware.SetCSPHeaders,
+	))
+
+	targets := []struct {
+		path       string
+		entityType string
+		resource   string
+	}{
+		{"/api/posts/reactions", models.ReactionOnPost, "posts"},
+		{"/comments/reactions", models.ReactionOnComment, "comments"},
+		{"/messages/reactions", models.ReactionOnMessage, "messages"},
+	}
+	for _, target := range targets {
+		entityType := target.entityType
+
+		http.Handle(target.path, middleware.ApplyMiddleware(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodGet:
+					handlers.GetReactionsHandler(db, entityType)(w, r)
+				case http.MethodPost:
+					handlers.ToggleReactionHandler(db, entityType)(w, r)
+				default:
+					w.WriteHeader(http.StatusMethodNotAllowed)
+				}
+			}),
+			middleware.VerifyCSRFMiddleware(db),
+			middleware.JWTAuthMiddleware,
+			middleware.SessionAuthMiddleware,
+			middleware.APITokenAuth(target.resource),
+			middleware.CORSMiddleware,
+			middleware.SetCSPHeaders,
+		))
+
+		http.Handle(target.path+"/users", middleware.ApplyMiddleware(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+				handlers.GetReactorsHandler(db, entityType)(w, r)
+			}),
+			middleware.JWTAuthMiddleware,
+			middleware.SessionAuthMiddleware,
+			middleware.APITokenAuth(target.resource),
+			middleware.CORSMiddleware,
+			middleware.SetCSPHeaders,
+		))
+	}
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+
+	"forum/backend/controllers"
+	"forum/backend/handlers"
+	"forum/backend/middleware"
+)
+
+func SearchRoute(db *sql.DB) {
+	searchController := controllers.NewSearchController(db)
+
+	http.Handle("/api/search", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.SearchHandler(searchController)(w, r)
+		}),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.APITokenAuth("posts"),
+		middleware.CORSMiddleware,
+		middleware.SetCSPHeaders,
+	))
+}
@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"database/sql"
+	"net/http"
+
+	"forum/backend/handlers"
+	"forum/backend/middleware"
+)
+
+func SessionRoutes(db *sql.DB) {
+	http.Handle("/api/sessions", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				handlers.GetSessionsHandler(db).ServeHTTP(w, r)
+			case http.MethodDelete:
+				handlers.RevokeSessionHandler(db).ServeHTTP(w, r)
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+
+	// Ending an impersonation is done from the impersonated session, which
+	// has none of the admin's permissions
+	http.Handle("/api/impersonation", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				handlers.GetImpersonationHandler(db).ServeHTTP(w, r)
+			case http.MethodDelete:
+				handlers.EndImpersonationHandler(db).ServeHTTP(w, r)
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/api/account/activity", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.GetSecurityActivityHandler(db).ServeHTTP(w, r)
+		}),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/api/account/export", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				handlers.GetDataExportsHandler(db).ServeHTTP(w, r)
+			case http.MethodPost:
+				handlers.RequestDataExportHandler(db).ServeHTTP(w, r)
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+
+	http.Handle("/api/account/export/download", middleware.ApplyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			handlers.DownloadDataExportHandler(db).ServeHTTP(w, r)
+		}),
+		middleware.JWTAuthMiddleware,
+		middleware.SessionAuthMiddleware,
+		middleware.CORSMiddleware,
+	))
+}
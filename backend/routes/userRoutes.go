@@ -15,6 +15,7 @@ func SetupUserRoutes(db *sql.DB) {
 	http.Handle("/api/users/stats", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.GetUserStatsHandler(userController)),
 		middleware.CORSMiddleware,
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 		middleware.ErrorHandler(handlers.ServeErrorPage),
@@ -24,6 +25,7 @@ func SetupUserRoutes(db *sql.DB) {
 	http.Handle("/api/users", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.GetUsersHandler(userController)),
 		middleware.CORSMiddleware,
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 		middleware.ErrorHandler(handlers.ServeErrorPage),
@@ -33,6 +35,7 @@ func SetupUserRoutes(db *sql.DB) {
 	http.Handle("/api/users/search", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.SearchUsersHandler(userController)),
 		middleware.CORSMiddleware,
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 	))
@@ -50,6 +53,7 @@ func SetupUserRoutes(db *sql.DB) {
 			}
 		}),
 		middleware.CORSMiddleware,
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 	))
@@ -71,6 +75,7 @@ func SetupUserRoutes(db *sql.DB) {
 			}
 		}),
 		middleware.CORSMiddleware,
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 	))
@@ -79,6 +84,7 @@ func SetupUserRoutes(db *sql.DB) {
 	http.Handle("/api/users/friends", middleware.ApplyMiddleware(
 		handlers.GetUserFriendsHandler(userController),
 		middleware.CORSMiddleware,
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 	))
@@ -93,12 +99,14 @@ func SetupUserRoutes(db *sql.DB) {
 			handlers.DeleteUserHandler(userController)(w, r)
 		}),
 		middleware.CORSMiddleware,
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 	))
 
 	http.Handle("/api/users/photos", middleware.ApplyMiddleware(
 		handlers.GetUserPhotosHandler(userController),
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.CORSMiddleware,
 		middleware.SessionAuthMiddleware,
@@ -107,6 +115,7 @@ func SetupUserRoutes(db *sql.DB) {
 	http.Handle("/api/users/password", middleware.ApplyMiddleware(
 		http.HandlerFunc(handlers.UpdatePasswordHandler(userController)),
 		middleware.CORSMiddleware,
+		middleware.VerifyCSRFMiddleware(db),
 		middleware.JWTAuthMiddleware,
 		middleware.SessionAuthMiddleware,
 	))
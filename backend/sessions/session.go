@@ -10,37 +10,54 @@ import (
 
 	"forum/backend/controllers"
 	"forum/backend/logger"
+	"forum/backend/models"
 	"forum/backend/utils"
 
 	"github.com/google/uuid"
 )
 
-// CreateSession creates a new session for a user
-func CreateSession(db *sql.DB, w http.ResponseWriter, userID int) (string, error) {
-	if userID <= 0 {
-		return "", errors.New("invalid user ID")
-	}
+// RefreshCookiePath limits the refresh token cookie to the refresh endpoint
+const RefreshCookiePath = "/refresh-token"
 
-	// Delete all existing sessions for the user
-	err := controllers.DeleteUserSessions(db, userID)
-	if err != nil {
-		return "", err
+// CreateSession creates a new session for the device making the request and
+// returns a short-lived access token together with the session's first refresh
+// token. Sessions on the user's other devices are left untouched.
+func CreateSession(db *sql.DB, w http.ResponseWriter, r *http.Request, userID int) (*models.AuthTokens, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
 	}
 
 	// Create a new session
 	sessionToken := uuid.New().String()
-	expiresAt := time.Now().Add(24 * time.Hour)
+	now := time.Now()
+	session := models.Session{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		UserAgent: r.UserAgent(),
+		IPAddress: utils.ClientIP(r),
+		CreatedAt: now,
+		ExpiresAt: now.Add(24 * time.Hour),
+	}
 
-	// Generate JWT token
-	jwtToken, err := utils.GenerateJWT(fmt.Sprintf("%d", userID))
-	if err != nil {
-		return "", err
+	// Store the session, then hand its tokens to the client
+	if err := controllers.AddSessionWithToken(db, sessionToken, "", session); err != nil {
+		return nil, err
 	}
+	return IssueSessionTokens(db, w, sessionToken, session.ID, userID, session.ExpiresAt, true)
+}
 
-	// Store session and JWT token in the database
-	err = controllers.AddSessionWithToken(db, sessionToken, userID, expiresAt, jwtToken)
+// IssueSessionTokens signs the client into an existing session: it sets the
+// session cookie and returns a fresh access token and CSRF token. A refresh
+// token is only started when withRefresh is set; without one the session
+// ends when the access token expires.
+func IssueSessionTokens(db *sql.DB, w http.ResponseWriter, sessionToken, sessionID string, userID int, expiresAt time.Time, withRefresh bool) (*models.AuthTokens, error) {
+	// Generate JWT token
+	jwtToken, err := utils.GenerateJWT(fmt.Sprintf("%d", userID), sessionID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if err := controllers.SetSessionJWT(db, sessionID, jwtToken); err != nil {
+		return nil, err
 	}
 
 	// Set the session cookie
@@ -51,10 +68,86 @@ func CreateSession(db *sql.DB, w http.ResponseWriter, userID int) (string, error
 		HttpOnly: true,
 		Secure:   true,
 		SameSite: http.SameSiteStrictMode,
-		MaxAge:   86400, // 24 hours
+		MaxAge:   int(time.Until(expiresAt).Seconds()),
 	})
 
-	return jwtToken, nil
+	// Start the session's refresh token family
+	var refreshToken string
+	if withRefresh {
+		refreshToken, err = controllers.IssueRefreshToken(db, sessionToken, sessionID, userID, expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		SetRefreshCookie(w, refreshToken, expiresAt)
+	} else {
+		ClearRefreshCookie(w)
+	}
+
+	// Every login starts with a fresh CSRF token
+	csrfToken, err := controllers.RotateCSRFToken(db, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	SetCSRFCookie(w, csrfToken)
+
+	return &models.AuthTokens{
+		AccessToken:  jwtToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(utils.AccessTokenTTL.Seconds()),
+		CSRFToken:    csrfToken,
+	}, nil
+}
+
+// SetCSRFCookie hands the CSRF token to the client in the X-CSRF-Token header
+// and in a cookie scripts can read, for double-submit clients
+func SetCSRFCookie(w http.ResponseWriter, csrfToken string) {
+	w.Header().Set("X-CSRF-Token", csrfToken)
+	http.SetCookie(w, &http.Cookie{
+		Name:     controllers.CSRFCookieName,
+		Value:    csrfToken,
+		Path:     "/",
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   86400, // 24 hours, like the session
+	})
+}
+
+// SetRefreshCookie stores the refresh token in a cookie only sent to the refresh endpoint
+func SetRefreshCookie(w http.ResponseWriter, refreshToken string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		Path:     RefreshCookiePath,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(time.Until(expiresAt).Seconds()),
+	})
+}
+
+// ClearRefreshCookie removes the refresh token cookie from the client
+func ClearRefreshCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    "",
+		Path:     RefreshCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ClearCSRFCookie removes the CSRF token cookie from the client
+func ClearCSRFCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     controllers.CSRFCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
 }
 
 func DeleteSession(db *sql.DB, w http.ResponseWriter, cookie *http.Cookie) {
@@ -74,4 +167,6 @@ func DeleteSession(db *sql.DB, w http.ResponseWriter, cookie *http.Cookie) {
 		Name:   "session_token",
 		MaxAge: -1,
 	})
+	ClearCSRFCookie(w)
+	ClearRefreshCookie(w)
 }
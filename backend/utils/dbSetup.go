@@ -28,7 +28,7 @@ func SetupTestDB(t *testing.T) *TestDB {
 
 	// Create test database path
 	dbPath := filepath.Join(tmpDir, "test.db")
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
 	if err != nil {
 		os.RemoveAll(tmpDir)
 		t.Fatalf("Failed to open test database: %v", err)
@@ -63,12 +63,16 @@ func SetupTestDB(t *testing.T) *TestDB {
 
 // createTestTables creates all the necessary tables for testing
 func createTestTables(db *sql.DB) error {
-	// Enable foreign keys
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+	// Create tables (using the same schema as in initDB.go)
+	if _, err := db.Exec(database.TableQueries); err != nil {
 		return err
 	}
-
-	// Create tables (using the same schema as in initDB.go)
-	_, err := db.Exec(database.TableQueries)
+	if err := database.ApplyMigrations(db); err != nil {
+		return err
+	}
+	if err := database.SeedCategories(db); err != nil {
+		return err
+	}
+	_, err := database.SetupSearchIndex(db)
 	return err
 }
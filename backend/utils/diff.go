@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"strings"
+
+	"forum/backend/models"
+)
+
+// maxDiffCells bounds the size of the table DiffLines builds. Larger changes
+// are shown as every old line removed and every new line added.
+const maxDiffCells = 4_000_000
+
+// DiffLines compares two texts line by line and returns the lines of both in
+// order, marked as kept, removed from a or added in b
+func DiffLines(a, b string) []models.DiffLine {
+	oldLines := strings.Split(a, "\n")
+	newLines := strings.Split(b, "\n")
+
+	// Unchanged lines at either end need no table
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	diff := make([]models.DiffLine, 0, len(oldLines)+len(newLines))
+	for _, line := range oldLines[:prefix] {
+		diff = append(diff, models.DiffLine{Op: models.DiffEqual, Text: line})
+	}
+	diff = append(diff, diffMiddle(oldLines[prefix:len(oldLines)-suffix], newLines[prefix:len(newLines)-suffix])...)
+	for _, line := range oldLines[len(oldLines)-suffix:] {
+		diff = append(diff, models.DiffLine{Op: models.DiffEqual, Text: line})
+	}
+	return diff
+}
+
+// diffMiddle diffs the changed part of two texts using their longest common
+// subsequence of lines
+func diffMiddle(oldLines, newLines []string) []models.DiffLine {
+	var diff []models.DiffLine
+	n, m := len(oldLines), len(newLines)
+	if n*m > maxDiffCells {
+		for _, line := range oldLines {
+			diff = append(diff, models.DiffLine{Op: models.DiffDelete, Text: line})
+		}
+		for _, line := range newLines {
+			diff = append(diff, models.DiffLine{Op: models.DiffInsert, Text: line})
+		}
+		return diff
+	}
+
+	// lcs[i][j] is the length of the common subsequence of oldLines[i:] and newLines[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case oldLines[i] == newLines[j]:
+			diff = append(diff, models.DiffLine{Op: models.DiffEqual, Text: oldLines[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, models.DiffLine{Op: models.DiffDelete, Text: oldLines[i]})
+			i++
+		default:
+			diff = append(diff, models.DiffLine{Op: models.DiffInsert, Text: newLines[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		diff = append(diff, models.DiffLine{Op: models.DiffDelete, Text: oldLines[i]})
+	}
+	for ; j < m; j++ {
+		diff = append(diff, models.DiffLine{Op: models.DiffInsert, Text: newLines[j]})
+	}
+	return diff
+}
@@ -3,26 +3,35 @@ package utils
 import (
 	"crypto/hmac"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
-
-	"forum/backend/database"
 )
 
-var secretKey = []byte("your-secret-key") // In production, use environment variable
+// AccessTokenTTL is how long a JWT is accepted. Clients renew it through the
+// refresh token endpoint while their session lasts.
+//
+// Access tokens are stateless: ValidateJWT checks only the signature and the
+// expiry, never the sessions table. A logged out or revoked session stops
+// working at once wherever the session cookie is checked, or the token's
+// session ID is, as when opening a WebSocket, and can no longer refresh. A
+// bearer token already issued for it lasts out its TTL everywhere else.
+const AccessTokenTTL = 15 * time.Minute
+
+var ErrTokenExpired = errors.New("token has expired")
 
 type Header struct {
 	Alg string `json:"alg"`
 	Typ string `json:"typ"`
+	Kid string `json:"kid"`
 }
 
 type Payload struct {
 	UserID    string `json:"user_id"`
+	SessionID string `json:"sid,omitempty"`
 	ExpiresAt int64  `json:"exp"`
 	IssuedAt  int64  `json:"iat"`
 }
@@ -38,17 +47,24 @@ func base64URLDecode(str string) ([]byte, error) {
 	return base64.URLEncoding.DecodeString(str)
 }
 
-func GenerateJWT(userID string) (string, error) {
+func GenerateJWT(userID, sessionID string) (string, error) {
+	key, secret, err := activeSigningKey()
+	if err != nil {
+		return "", err
+	}
+
 	// Create header
 	header := Header{
 		Alg: "HS256",
 		Typ: "JWT",
+		Kid: key.ID,
 	}
 
 	// Create payload
 	payload := Payload{
 		UserID:    userID,
-		ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
+		SessionID: sessionID,
+		ExpiresAt: time.Now().Add(AccessTokenTTL).Unix(),
 		IssuedAt:  time.Now().Unix(),
 	}
 
@@ -69,7 +85,7 @@ func GenerateJWT(userID string) (string, error) {
 
 	// Create signature
 	signatureInput := encodedHeader + "." + encodedPayload
-	h := hmac.New(sha256.New, secretKey)
+	h := hmac.New(sha256.New, secret)
 	h.Write([]byte(signatureInput))
 	signature := base64URLEncode(h.Sum(nil))
 
@@ -78,6 +94,8 @@ func GenerateJWT(userID string) (string, error) {
 	return token, nil
 }
 
+// ValidateJWT checks a token's signature, key and expiry, without touching
+// the database
 func ValidateJWT(tokenString string) (*Payload, error) {
 	// Split the token
 	parts := strings.Split(tokenString, ".")
@@ -85,13 +103,32 @@ func ValidateJWT(tokenString string) (*Payload, error) {
 		return nil, errors.New("invalid token format")
 	}
 
+	// Decode header to find which key signed the token
+	headerJSON, err := base64URLDecode(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var header Header
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "HS256" {
+		return nil, errors.New("unsupported signing algorithm")
+	}
+
+	secret, err := verificationKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
 	// Verify signature
 	signatureInput := parts[0] + "." + parts[1]
-	h := hmac.New(sha256.New, secretKey)
+	h := hmac.New(sha256.New, secret)
 	h.Write([]byte(signatureInput))
 	expectedSignature := base64URLEncode(h.Sum(nil))
 
-	if expectedSignature != parts[2] {
+	if !hmac.Equal([]byte(expectedSignature), []byte(parts[2])) {
 		return nil, errors.New("invalid signature")
 	}
 
@@ -108,21 +145,7 @@ func ValidateJWT(tokenString string) (*Payload, error) {
 
 	// Check expiration
 	if time.Now().Unix() > payload.ExpiresAt {
-		return nil, errors.New("token has expired")
-	}
-
-	// Check if token exists in database and is not expired
-	var expiresAt time.Time
-	err = database.GloabalDB.QueryRow("SELECT expires_at FROM sessions WHERE jwt_token = ?", tokenString).Scan(&expiresAt)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("token not found in database")
-		}
-		return nil, err
-	}
-
-	if time.Now().After(expiresAt) {
-		return nil, errors.New("token has expired")
+		return nil, ErrTokenExpired
 	}
 
 	return &payload, nil
@@ -0,0 +1,312 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"forum/backend/logger"
+)
+
+// minSigningKeyBytes is the smallest HS256 secret we accept (256 bits)
+const minSigningKeyBytes = 32
+
+// SigningKey is a single HMAC secret used to sign JWTs, identified by its kid
+type SigningKey struct {
+	ID        string     `json:"kid"`
+	Secret    string     `json:"secret"` // base64url encoded
+	CreatedAt time.Time  `json:"created_at"`
+	RetireAt  *time.Time `json:"retire_at,omitempty"`
+}
+
+// KeyRing holds every known signing key and which one signs new tokens
+type KeyRing struct {
+	Active string       `json:"active"`
+	Keys   []SigningKey `json:"keys"`
+}
+
+var (
+	keyRing   *KeyRing
+	keyRingMu sync.RWMutex
+)
+
+// SigningKeysPath returns the location of the key ring file, configurable
+// through the JWT_KEYS_FILE environment variable
+func SigningKeysPath() string {
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		return path
+	}
+	return filepath.Join(".", "data", "jwt_keys.json")
+}
+
+// IsRetired reports whether the key can no longer be used to verify tokens
+func (k SigningKey) IsRetired(now time.Time) bool {
+	return k.RetireAt != nil && !now.Before(*k.RetireAt)
+}
+
+func (k SigningKey) secretBytes() ([]byte, error) {
+	secret, err := base64.RawURLEncoding.DecodeString(k.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s is not valid base64: %w", k.ID, err)
+	}
+	if len(secret) < minSigningKeyBytes {
+		return nil, fmt.Errorf("signing key %s is shorter than %d bytes", k.ID, minSigningKeyBytes)
+	}
+	return secret, nil
+}
+
+// Find returns the key with the given kid
+func (kr *KeyRing) Find(kid string) (SigningKey, bool) {
+	for _, key := range kr.Keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+func (kr *KeyRing) validate() error {
+	if len(kr.Keys) == 0 {
+		return errors.New("key ring has no keys")
+	}
+	seen := make(map[string]bool)
+	for _, key := range kr.Keys {
+		if key.ID == "" {
+			return errors.New("key ring contains a key without kid")
+		}
+		if seen[key.ID] {
+			return fmt.Errorf("key ring contains duplicate kid %s", key.ID)
+		}
+		seen[key.ID] = true
+		if _, err := key.secretBytes(); err != nil {
+			return err
+		}
+	}
+	active, ok := kr.Find(kr.Active)
+	if !ok {
+		return fmt.Errorf("active key %s not found in key ring", kr.Active)
+	}
+	if active.IsRetired(time.Now()) {
+		return fmt.Errorf("active key %s is retired", kr.Active)
+	}
+	return nil
+}
+
+// NewSigningKey generates a fresh random signing key
+func NewSigningKey() (SigningKey, error) {
+	secret := make([]byte, minSigningKeyBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return SigningKey{}, err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return SigningKey{}, err
+	}
+	now := time.Now().UTC()
+	return SigningKey{
+		ID:        fmt.Sprintf("%s-%s", now.Format("20060102"), hex.EncodeToString(suffix)),
+		Secret:    base64.RawURLEncoding.EncodeToString(secret),
+		CreatedAt: now,
+	}, nil
+}
+
+// ReadKeyRing loads a key ring file without installing it
+func ReadKeyRing(path string) (*KeyRing, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var kr KeyRing
+	if err := json.Unmarshal(data, &kr); err != nil {
+		return nil, fmt.Errorf("failed to parse key ring %s: %w", path, err)
+	}
+	if err := kr.validate(); err != nil {
+		return nil, fmt.Errorf("invalid key ring %s: %w", path, err)
+	}
+	return &kr, nil
+}
+
+// WriteKeyRing atomically replaces the key ring file
+func WriteKeyRing(path string, kr *KeyRing) error {
+	if err := kr.validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(kr, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadSigningKeys installs the key ring used by GenerateJWT and ValidateJWT.
+// When the file does not exist yet it is bootstrapped, using JWT_SECRET as the
+// first key if provided and a random secret otherwise.
+func LoadSigningKeys(path string) error {
+	kr, err := ReadKeyRing(path)
+	if errors.Is(err, os.ErrNotExist) {
+		kr, err = bootstrapKeyRing(path)
+	}
+	if err != nil {
+		return err
+	}
+	SetKeyRing(kr)
+	return nil
+}
+
+func bootstrapKeyRing(path string) (*KeyRing, error) {
+	key, err := NewSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		key.Secret = base64.RawURLEncoding.EncodeToString([]byte(secret))
+	}
+	kr := &KeyRing{Active: key.ID, Keys: []SigningKey{key}}
+	if err := WriteKeyRing(path, kr); err != nil {
+		return nil, fmt.Errorf("failed to create key ring %s: %w", path, err)
+	}
+	logger.Info("Created JWT key ring %s with key %s", path, key.ID)
+	return kr, nil
+}
+
+// SetKeyRing replaces the in-memory key ring
+func SetKeyRing(kr *KeyRing) {
+	keyRingMu.Lock()
+	defer keyRingMu.Unlock()
+	keyRing = kr
+}
+
+// activeSigningKey returns the key new tokens are signed with
+func activeSigningKey() (SigningKey, []byte, error) {
+	keyRingMu.RLock()
+	defer keyRingMu.RUnlock()
+	if keyRing == nil {
+		return SigningKey{}, nil, errors.New("signing keys are not loaded")
+	}
+	key, ok := keyRing.Find(keyRing.Active)
+	if !ok || key.IsRetired(time.Now()) {
+		return SigningKey{}, nil, errors.New("no active signing key")
+	}
+	secret, err := key.secretBytes()
+	return key, secret, err
+}
+
+// verificationKey returns the secret for kid if the key is still accepted
+func verificationKey(kid string) ([]byte, error) {
+	keyRingMu.RLock()
+	defer keyRingMu.RUnlock()
+	if keyRing == nil {
+		return nil, errors.New("signing keys are not loaded")
+	}
+	key, ok := keyRing.Find(kid)
+	if !ok || key.IsRetired(time.Now()) {
+		return nil, errors.New("unknown or retired signing key")
+	}
+	return key.secretBytes()
+}
+
+// RotateSigningKey adds a new active key and schedules every other key still
+// in service for retirement once retireAfter has elapsed
+func RotateSigningKey(path string, retireAfter time.Duration) (SigningKey, error) {
+	kr, err := ReadKeyRing(path)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	key, err := NewSigningKey()
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	retireAt := time.Now().UTC().Add(retireAfter)
+	for i := range kr.Keys {
+		if kr.Keys[i].RetireAt == nil || kr.Keys[i].RetireAt.After(retireAt) {
+			kr.Keys[i].RetireAt = &retireAt
+		}
+	}
+	kr.Keys = append(kr.Keys, key)
+	kr.Active = key.ID
+
+	if err := WriteKeyRing(path, kr); err != nil {
+		return SigningKey{}, err
+	}
+	return key, nil
+}
+
+// RetireSigningKey schedules a key to stop being accepted at the given time
+func RetireSigningKey(path, kid string, at time.Time) error {
+	kr, err := ReadKeyRing(path)
+	if err != nil {
+		return err
+	}
+	if kid == kr.Active {
+		return errors.New("cannot retire the active key, rotate first")
+	}
+	for i := range kr.Keys {
+		if kr.Keys[i].ID == kid {
+			at = at.UTC()
+			kr.Keys[i].RetireAt = &at
+			return WriteKeyRing(path, kr)
+		}
+	}
+	return fmt.Errorf("key %s not found", kid)
+}
+
+// PruneSigningKeys removes keys that were retired more than olderThan ago
+func PruneSigningKeys(path string, olderThan time.Duration) (int, error) {
+	kr, err := ReadKeyRing(path)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	kept := kr.Keys[:0]
+	removed := 0
+	for _, key := range kr.Keys {
+		if key.ID != kr.Active && key.RetireAt != nil && key.RetireAt.Before(cutoff) {
+			removed++
+			continue
+		}
+		kept = append(kept, key)
+	}
+	kr.Keys = kept
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, WriteKeyRing(path, kr)
+}
+
+// ReloadSigningKeys periodically re-reads the key ring file so keys added
+// or retired with the jwt-keys command take effect without a restart
+func ReloadSigningKeys(ctx context.Context, path string) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopping JWT key ring reload task...")
+			return
+		case <-ticker.C:
+			kr, err := ReadKeyRing(path)
+			if err != nil {
+				logger.Error("Failed to reload JWT key ring: %v", err)
+				continue
+			}
+			SetKeyRing(kr)
+		}
+	}
+}
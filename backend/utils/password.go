@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrPasswordMismatch  = errors.New("password does not match")
+	ErrUnknownHashFormat = errors.New("unknown password hash format")
+	ErrInvalidArgon2Hash = errors.New("invalid argon2id hash")
+)
+
+// PasswordHasher creates and checks stored password hashes
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded, a hash this hasher produced
+	Verify(encoded, password string) (bool, error)
+	// NeedsRehash reports whether encoded was made with other settings than the hasher's
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2Params are the argon2id cost settings. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the second recommendation of RFC 9106
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2idHasher stores hashes in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+type Argon2idHasher struct {
+	Params Argon2Params
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Params.Memory, h.Params.Iterations, h.Params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Verify(encoded, password string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(key, computed) == 1, nil
+}
+
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, _, _, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params != h.Params
+}
+
+// decodeArgon2id parses a PHC argon2id string
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidArgon2Hash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// BcryptHasher is kept for hashes created before argon2id was introduced
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(hash), err
+}
+
+func (h BcryptHasher) Verify(encoded, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+var (
+	passwordHasherOnce sync.Once
+	passwordHasher     PasswordHasher
+)
+
+// PasswordHasherFromEnv builds the hasher for new passwords.
+// PASSWORD_HASHER picks argon2id (default) or bcrypt; ARGON2_MEMORY (KiB),
+// ARGON2_ITERATIONS and ARGON2_PARALLELISM tune argon2id and BCRYPT_COST bcrypt.
+func PasswordHasherFromEnv() PasswordHasher {
+	if os.Getenv("PASSWORD_HASHER") == "bcrypt" {
+		return BcryptHasher{Cost: envInt("BCRYPT_COST", bcrypt.DefaultCost)}
+	}
+	params := DefaultArgon2Params
+	params.Memory = uint32(envInt("ARGON2_MEMORY", int(params.Memory)))
+	params.Iterations = uint32(envInt("ARGON2_ITERATIONS", int(params.Iterations)))
+	params.Parallelism = uint8(envInt("ARGON2_PARALLELISM", int(params.Parallelism)))
+	return Argon2idHasher{Params: params}
+}
+
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// CurrentPasswordHasher returns the hasher new passwords are stored with
+func CurrentPasswordHasher() PasswordHasher {
+	passwordHasherOnce.Do(func() {
+		passwordHasher = PasswordHasherFromEnv()
+	})
+	return passwordHasher
+}
+
+// HashPassword hashes a new password with the current hasher
+func HashPassword(password string) (string, error) {
+	return CurrentPasswordHasher().Hash(password)
+}
+
+// hasherFor picks the hasher that understands a stored hash. Verification
+// reads the cost settings from the hash itself.
+func hasherFor(encoded string) (PasswordHasher, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return Argon2idHasher{}, nil
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return BcryptHasher{}, nil
+	}
+	return nil, ErrUnknownHashFormat
+}
+
+// CheckPassword compares a password with its stored hash, whichever
+// algorithm made it. needsRehash is set when the password matched but the
+// hash should be replaced with one from the current hasher.
+func CheckPassword(encoded, password string) (needsRehash bool, err error) {
+	// Accounts created through single sign-on have no password
+	if encoded == "" {
+		return false, ErrPasswordMismatch
+	}
+	hasher, err := hasherFor(encoded)
+	if err != nil {
+		return false, err
+	}
+	ok, err := hasher.Verify(encoded, password)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, ErrPasswordMismatch
+	}
+	return CurrentPasswordHasher().NeedsRehash(encoded), nil
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"forum/backend/logger"
+	"forum/backend/models"
+)
+
+// DefaultReactions is the reaction set used unless REACTIONS gives another
+const DefaultReactions = "like:👍,dislike:👎,love:❤️,laugh:😂,wow:😮,sad:😢,angry:😠"
+
+// maxReactionName is the longest reaction name, in characters
+const maxReactionName = 20
+
+var (
+	reactionsOnce sync.Once
+	reactions     []models.Reaction
+)
+
+// ParseReactions reads a reaction set: a comma separated list of name:emoji
+// pairs such as "like:👍,party:🎉". Names are lowercase letters, digits and
+// underscores, and each may appear once.
+func ParseReactions(value string) ([]models.Reaction, error) {
+	var set []models.Reaction
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, emoji, ok := strings.Cut(entry, ":")
+		name, emoji = strings.TrimSpace(name), strings.TrimSpace(emoji)
+		if !ok || emoji == "" || !validReactionName(name) {
+			return nil, fmt.Errorf("invalid reaction %q, want name:emoji", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("reaction %s is listed twice", name)
+		}
+		seen[name] = true
+		set = append(set, models.Reaction{Name: name, Emoji: emoji})
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("no reactions given")
+	}
+	return set, nil
+}
+
+func validReactionName(name string) bool {
+	if name == "" || len(name) > maxReactionName {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// ReactionsFromEnv reads the reaction set from REACTIONS, falling back to
+// DefaultReactions when it is unset or invalid
+func ReactionsFromEnv() []models.Reaction {
+	if value := os.Getenv("REACTIONS"); value != "" {
+		set, err := ParseReactions(value)
+		if err == nil {
+			return set
+		}
+		logger.Warning("Ignoring REACTIONS: %v", err)
+	}
+	set, _ := ParseReactions(DefaultReactions)
+	return set
+}
+
+// CurrentReactions returns the reactions users can leave, in display order
+func CurrentReactions() []models.Reaction {
+	reactionsOnce.Do(func() {
+		reactions = ReactionsFromEnv()
+	})
+	return reactions
+}
+
+// FindReaction looks up a reaction of the current set by name
+func FindReaction(name string) (models.Reaction, bool) {
+	for _, reaction := range CurrentReactions() {
+		if reaction.Name == name {
+			return reaction, true
+		}
+	}
+	return models.Reaction{}, false
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxTagLength is the longest tag, in characters
+const MaxTagLength = 50
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// NormalizeTag lower-cases a tag and drops a leading #. It reports false for
+// tags that aren't made of letters, digits and underscores, have no letter,
+// or are too long.
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", false
+	}
+	hasLetter := false
+	for _, r := range tag {
+		if !isTagRune(r) {
+			return "", false
+		}
+		hasLetter = hasLetter || unicode.IsLetter(r)
+	}
+	return tag, hasLetter
+}
+
+// ParseHashtags returns the #tags in text, normalized, each once and in the
+// order they first appear. A # inside a word, as in C# or page#anchor, does
+// not start a tag.
+func ParseHashtags(text string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '#' || (i > 0 && (isTagRune(runes[i-1]) || runes[i-1] == '#' || runes[i-1] == '&')) {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && isTagRune(runes[end]) {
+			end++
+		}
+		if tag, ok := NormalizeTag(string(runes[i+1 : end])); ok && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+		i = end - 1
+	}
+	return tags
+}
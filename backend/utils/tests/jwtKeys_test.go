@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"forum/backend/utils"
+)
+
+// newKeyRing writes a fresh one-key ring to a temporary file and installs it
+func newKeyRing(t *testing.T) (string, utils.SigningKey) {
+	path := filepath.Join(t.TempDir(), "jwt_keys.json")
+	key, err := utils.NewSigningKey()
+	if err != nil {
+		t.Fatalf("NewSigningKey() error = %v", err)
+	}
+	if err := utils.WriteKeyRing(path, &utils.KeyRing{Active: key.ID, Keys: []utils.SigningKey{key}}); err != nil {
+		t.Fatalf("WriteKeyRing() error = %v", err)
+	}
+	installKeyRing(t, path)
+	return path, key
+}
+
+func installKeyRing(t *testing.T, path string) *utils.KeyRing {
+	kr, err := utils.ReadKeyRing(path)
+	if err != nil {
+		t.Fatalf("ReadKeyRing() error = %v", err)
+	}
+	utils.SetKeyRing(kr)
+	return kr
+}
+
+func TestSigningKeys_RotateRetirePrune(t *testing.T) {
+	path, first := newKeyRing(t)
+
+	oldToken, err := utils.GenerateJWT("1", "session-1")
+	if err != nil {
+		t.Fatalf("GenerateJWT() error = %v", err)
+	}
+
+	// Rotating makes a new key active and keeps accepting the old one
+	second, err := utils.RotateSigningKey(path, time.Hour)
+	if err != nil {
+		t.Fatalf("RotateSigningKey() error = %v", err)
+	}
+	kr := installKeyRing(t, path)
+	if kr.Active != second.ID || len(kr.Keys) != 2 {
+		t.Fatalf("key ring after rotation = active %s with %d keys, want %s with 2", kr.Active, len(kr.Keys), second.ID)
+	}
+	if old, _ := kr.Find(first.ID); old.RetireAt == nil || old.IsRetired(time.Now()) {
+		t.Errorf("old key retire_at = %v, want scheduled in the future", old.RetireAt)
+	}
+	if payload, err := utils.ValidateJWT(oldToken); err != nil || payload.UserID != "1" {
+		t.Errorf("ValidateJWT(token of old key) = %v, %v, want it accepted", payload, err)
+	}
+	newToken, err := utils.GenerateJWT("2", "session-2")
+	if err != nil {
+		t.Fatalf("GenerateJWT() error = %v", err)
+	}
+	if _, err := utils.ValidateJWT(newToken); err != nil {
+		t.Errorf("ValidateJWT(token of new key) error = %v", err)
+	}
+
+	// The active key can't be retired, any other key can
+	if err := utils.RetireSigningKey(path, second.ID, time.Now()); err == nil {
+		t.Error("RetireSigningKey(active) succeeded, want an error")
+	}
+	if err := utils.RetireSigningKey(path, "missing", time.Now()); err == nil {
+		t.Error("RetireSigningKey(missing) succeeded, want an error")
+	}
+	if err := utils.RetireSigningKey(path, first.ID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("RetireSigningKey() error = %v", err)
+	}
+	installKeyRing(t, path)
+	if _, err := utils.ValidateJWT(oldToken); err == nil {
+		t.Error("ValidateJWT(token of retired key) succeeded, want an error")
+	}
+	if _, err := utils.ValidateJWT(newToken); err != nil {
+		t.Errorf("ValidateJWT(token of active key) after retiring the old one error = %v", err)
+	}
+
+	// Pruning drops keys retired long enough ago, never the active one
+	if removed, err := utils.PruneSigningKeys(path, time.Hour); err != nil || removed != 0 {
+		t.Errorf("PruneSigningKeys(1h) = %d, %v, want 0", removed, err)
+	}
+	if removed, err := utils.PruneSigningKeys(path, 0); err != nil || removed != 1 {
+		t.Errorf("PruneSigningKeys(0) = %d, %v, want 1", removed, err)
+	}
+	kr = installKeyRing(t, path)
+	if _, ok := kr.Find(first.ID); ok || len(kr.Keys) != 1 {
+		t.Errorf("key ring after pruning has %d keys, want only the active one", len(kr.Keys))
+	}
+	if _, err := utils.ValidateJWT(oldToken); err == nil {
+		t.Error("ValidateJWT(token of pruned key) succeeded, want an error")
+	}
+}
+
+func TestValidateJWT_UnknownKey(t *testing.T) {
+	newKeyRing(t)
+	token, err := utils.GenerateJWT("1", "session-1")
+	if err != nil {
+		t.Fatalf("GenerateJWT() error = %v", err)
+	}
+
+	// A token signed by a key this ring never had is rejected
+	newKeyRing(t)
+	if _, err := utils.ValidateJWT(token); err == nil {
+		t.Error("ValidateJWT(token of unknown key) succeeded, want an error")
+	}
+}
+
+func TestReadKeyRing_Invalid(t *testing.T) {
+	key, err := utils.NewSigningKey()
+	if err != nil {
+		t.Fatalf("NewSigningKey() error = %v", err)
+	}
+	short := key
+	short.Secret = "c2hvcnQ"
+	retired := key
+	past := time.Now().Add(-time.Minute)
+	retired.RetireAt = &past
+
+	tests := []struct {
+		name string
+		ring utils.KeyRing
+	}{
+		{"no keys", utils.KeyRing{Active: key.ID}},
+		{"missing active key", utils.KeyRing{Active: "other", Keys: []utils.SigningKey{key}}},
+		{"duplicate kid", utils.KeyRing{Active: key.ID, Keys: []utils.SigningKey{key, key}}},
+		{"short secret", utils.KeyRing{Active: key.ID, Keys: []utils.SigningKey{short}}},
+		{"retired active key", utils.KeyRing{Active: key.ID, Keys: []utils.SigningKey{retired}}},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "jwt_keys.json")
+		if err := utils.WriteKeyRing(path, &tt.ring); err == nil {
+			t.Errorf("WriteKeyRing(%s) succeeded, want an error", tt.name)
+		}
+
+		// A ring edited by hand is checked when read
+		data, _ := json.Marshal(tt.ring)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("Failed to write key ring: %v", err)
+		}
+		if _, err := utils.ReadKeyRing(path); err == nil {
+			t.Errorf("ReadKeyRing(%s) succeeded, want an error", tt.name)
+		}
+	}
+	if _, err := utils.ReadKeyRing(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("ReadKeyRing(missing file) succeeded, want an error")
+	}
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/backend/utils"
+
+	"github.com/gorilla/websocket"
+)
+
+// openClients opens one websocket per session, registers its server side for
+// userID and returns the client sides
+func openClients(t *testing.T, userID int, sessionIDs ...string) []*websocket.Conn {
+	registered := make(chan *websocket.Conn)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade() error = %v", err)
+			return
+		}
+		registered <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	var clients []*websocket.Conn
+	for _, sessionID := range sessionIDs {
+		client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		t.Cleanup(func() { client.Close() })
+		utils.RegisterClient(<-registered, userID, sessionID)
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// isClosed reports whether the server closed a client's connection. A timed
+// out read breaks the connection, so only ask about ones that should be closed.
+func isClosed(client *websocket.Conn) bool {
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := client.ReadMessage()
+	return err != nil && !strings.Contains(err.Error(), "timeout")
+}
+
+// openSessions lists the sessions that still have a registered connection
+func openSessions() map[string]bool {
+	utils.Mutex.Lock()
+	defer utils.Mutex.Unlock()
+	open := map[string]bool{}
+	for _, sessionID := range utils.ClientSessions {
+		open[sessionID] = true
+	}
+	return open
+}
+
+func TestCloseSessionConnections(t *testing.T) {
+	clients := openClients(t, 1, "laptop", "phone")
+	other := openClients(t, 2, "desktop")
+
+	if !utils.UserHasConnections(1) || !utils.UserHasConnections(2) {
+		t.Fatal("UserHasConnections() = false for registered users")
+	}
+
+	// Revoking one device closes only its connections
+	utils.CloseSessionConnections("laptop")
+	if !isClosed(clients[0]) {
+		t.Error("connection of the revoked session is still open")
+	}
+	if open := openSessions(); open["laptop"] || !open["phone"] || !open["desktop"] {
+		t.Errorf("sessions with connections after revoking the laptop = %v, want phone and desktop", open)
+	}
+	if !utils.UserHasConnections(1) {
+		t.Error("UserHasConnections() = false while the phone is still connected")
+	}
+
+	utils.CloseSessionConnections("phone", "unknown")
+	if !isClosed(clients[1]) {
+		t.Error("connection of the second revoked session is still open")
+	}
+	if utils.UserHasConnections(1) {
+		t.Error("UserHasConnections() = true after every session was revoked")
+	}
+	if !utils.UserHasConnections(2) {
+		t.Error("UserHasConnections() = false for a user whose sessions were kept")
+	}
+	if !openSessions()["desktop"] {
+		t.Error("CloseSessionConnections() closed a connection of another user")
+	}
+	utils.CloseSessionConnections("desktop")
+	if !isClosed(other[0]) {
+		t.Error("connection of the desktop session is still open")
+	}
+}
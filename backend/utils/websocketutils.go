@@ -13,9 +13,66 @@ import (
 
 var (
 	Clients = make(map[*websocket.Conn]int)
-	Mutex   = sync.Mutex{}
+	// ClientSessions maps each connection to the public ID of the session it was opened with
+	ClientSessions = make(map[*websocket.Conn]string)
+	Mutex          = sync.Mutex{}
 )
 
+// RegisterClient records a new connection for a user's session
+func RegisterClient(conn *websocket.Conn, userID int, sessionID string) {
+	Mutex.Lock()
+	defer Mutex.Unlock()
+	Clients[conn] = userID
+	ClientSessions[conn] = sessionID
+}
+
+// UnregisterClient forgets a connection. Callers must hold Mutex.
+func UnregisterClient(conn *websocket.Conn) {
+	delete(Clients, conn)
+	delete(ClientSessions, conn)
+}
+
+// UserHasConnections reports whether the user still has an open connection on any device
+func UserHasConnections(userID int) bool {
+	Mutex.Lock()
+	defer Mutex.Unlock()
+	for _, connUserID := range Clients {
+		if connUserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// CloseSessionConnections closes every connection opened by one of the given sessions
+func CloseSessionConnections(sessionIDs ...string) {
+	revoked := make(map[string]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		revoked[id] = true
+	}
+
+	Mutex.Lock()
+	defer Mutex.Unlock()
+	for conn, sessionID := range ClientSessions {
+		if revoked[sessionID] {
+			conn.Close()
+			UnregisterClient(conn)
+		}
+	}
+}
+
+// CloseUserConnections closes every connection the user has open on any device
+func CloseUserConnections(userID int) {
+	Mutex.Lock()
+	defer Mutex.Unlock()
+	for conn, connUserID := range Clients {
+		if connUserID == userID {
+			conn.Close()
+			UnregisterClient(conn)
+		}
+	}
+}
+
 // Broadcast sends a message to all connected clients
 func Broadcast(message []byte) {
 	Mutex.Lock()
@@ -26,7 +83,7 @@ func Broadcast(message []byte) {
 		if err != nil {
 			log.Println("Error broadcasting message:", err)
 			client.Close()
-			delete(Clients, client)
+			UnregisterClient(client)
 		}
 	}
 }
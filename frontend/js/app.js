@@ -1,10 +1,14 @@
 import { initializeMessages } from "./components/messages/messages.js";
 import { initializeWebSocket } from "./websocket/websocket.js";
 import { NotificationType, showNotification } from "./utils/notifications.js";
+import { refreshAccessToken } from "./security.js";
 
 import {
+  completeOIDCLogin,
+  completeMagicLogin,
   createAuthSection,
   setupAuthEventListeners,
+  showAuthForm,
 } from "./components/auth.js";
 import { createHeader } from "./components/header/header.js";
 import {
@@ -35,6 +39,9 @@ class App {
     this.router = new Router({
       "/": () => this.requireAuth(() => this.renderHome()),
       "/loginPage": () => this.renderAuth(),
+      "/resetPasswordPage": () => this.renderAuth("reset"),
+      "/oidcLoginPage": () => completeOIDCLogin(() => this.renderAuth()),
+      "/magicLoginPage": () => completeMagicLogin(() => this.renderAuth()),
       "/messagesPage": () => this.requireAuth(() => this.renderMessages()),
       "/profilePage": () => this.requireAuth(() => this.renderProfile()),
       "*": () => this.render404(),
@@ -44,6 +51,22 @@ class App {
   }
 
   init() {
+    // Outcome of following an email verification link
+    const emailVerified = new URLSearchParams(window.location.search).get("email_verified");
+    if (emailVerified === "1") {
+      showNotification("Your email address is verified", NotificationType.SUCCESS);
+    } else if (emailVerified === "0") {
+      showNotification("That verification link is invalid or has expired", NotificationType.ERROR);
+    }
+
+    // Outcome of a single sign-on redirect
+    const params = new URLSearchParams(window.location.search);
+    if (params.get("oidc_error")) {
+      showNotification(params.get("oidc_error"), NotificationType.ERROR);
+    } else if (params.get("oidc_linked") === "1") {
+      showNotification("Your external account is linked", NotificationType.SUCCESS);
+    }
+
     const token = localStorage.getItem("token");
     if (!token) {
       this.router.handleRoute(window.location.pathname);
@@ -72,7 +95,8 @@ class App {
         }),
       });
 
-      if (!response.ok) {
+      // An expired access token is renewed with the refresh token cookie
+      if (!response.ok && !(await refreshAccessToken())) {
         localStorage.removeItem("token");
         this.router.navigate("/loginPage");
         return;
@@ -97,13 +121,13 @@ class App {
     this.initializeForumFeatures();
 
     // Explicitly fetch posts when rendering home
-    fetchPosts(1, false); // Reset to page 1 and don't append
+    fetchPosts(); // Start again from the first page
   }
 
   renderAuth(type = "login") {
     
     const token = localStorage.getItem("token");
-    if (token) {
+    if (token && type !== "reset") {
       this.router.navigate("/");
       return;
     }
@@ -118,6 +142,9 @@ class App {
       authSection.style.display = "flex";
     }
     setupAuthEventListeners();
+    if (type === "reset") {
+      showAuthForm("reset-form");
+    }
   }
 
   render404() {
@@ -211,7 +238,7 @@ class App {
         window.location.pathname === "/" ||
         window.location.pathname === "/profilePage"
       ) {
-        await fetchPosts(1, false);
+        await fetchPosts();
       }
     } catch (error) {
       console.error("Error initializing forum:", error);
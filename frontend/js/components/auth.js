@@ -1,5 +1,7 @@
 import { NotificationType, showNotification } from "../utils/notifications.js";
 import Router from "../router/router.js";
+import { authenticatedFetch, refreshAccessToken, setCSRFToken } from "../security.js";
+import { escapeHTML } from "../utils.js";
 
 export function createAuthSection() {
   return `
@@ -11,6 +13,9 @@ export function createAuthSection() {
                 <div class="auth-forms">
                     ${createRegisterForm()}
                     ${createLoginForm()}
+                    ${createForgotPasswordForm()}
+                    ${createMagicLinkForm()}
+                    ${createResetPasswordForm()}
                 </div>
             </div>
         </div>
@@ -80,11 +85,73 @@ function createLoginForm() {
                             </div>
                             <button type="submit">Login</button>
                         </form>
+            <a href="/auth/oidc/login" id="oidc-login" class="oidc-login" style="display: none;"></a>
+            <p><a href="#" id="show-forgot">Forgot password?</a></p>
+            <p><a href="#" id="show-magic">Email me a sign-in link</a></p>
             <p>Don't have an account? <a href="#" id="show-register">Register</a></p>
         </div>
     `;
 }
 
+function createForgotPasswordForm() {
+  return `
+         <!-- Forgot Password Form -->
+                    <div id="forgot-form" class="auth-form">
+                        <h2>Reset Password</h2>
+                        <form>
+                            <div class="input-group">
+                                <i class="fas fa-envelope"></i>
+                                <input type="email" id="forgot-email" placeholder="Email" required>
+                            </div>
+                            <button type="submit">Send reset link</button>
+                        </form>
+            <p>Remembered it? <a href="#" class="back-to-login">Login</a></p>
+        </div>
+    `;
+}
+
+function createMagicLinkForm() {
+  return `
+         <!-- Sign-in Link Form -->
+                    <div id="magic-form" class="auth-form">
+                        <h2>Sign in by Email</h2>
+                        <form>
+                            <div class="input-group">
+                                <i class="fas fa-envelope"></i>
+                                <input type="email" id="magic-email" placeholder="Email" required>
+                            </div>
+                            <button type="submit">Send sign-in link</button>
+                        </form>
+            <p>Have your password? <a href="#" class="back-to-login">Login</a></p>
+        </div>
+    `;
+}
+
+function createResetPasswordForm() {
+  return `
+         <!-- Reset Password Form -->
+                    <div id="reset-form" class="auth-form">
+                        <h2>Choose a New Password</h2>
+                        <form>
+                            <div class="input-group">
+                                <i class="fas fa-lock"></i>
+                                <input type="password" id="reset-password" placeholder="New password" required>
+                                <i class="password-toggle">👁️</i>
+                            </div>
+                            <button type="submit">Update password</button>
+                        </form>
+            <p><a href="#" class="back-to-login">Back to login</a></p>
+        </div>
+    `;
+}
+
+// Show one of the auth forms and hide the others
+export function showAuthForm(id) {
+  document.querySelectorAll(".auth-form").forEach((form) => {
+    form.classList.toggle("active", form.id === id);
+  });
+}
+
 export function setupAuthEventListeners() {
   const showLoginLink = document.getElementById("show-login");
   const showRegisterLink = document.getElementById("show-register");
@@ -126,6 +193,42 @@ export function setupAuthEventListeners() {
     });
   }
 
+  const showForgotLink = document.getElementById("show-forgot");
+  if (showForgotLink) {
+    showForgotLink.addEventListener("click", (e) => {
+      e.preventDefault();
+      showAuthForm("forgot-form");
+    });
+  }
+  const showMagicLink = document.getElementById("show-magic");
+  if (showMagicLink) {
+    showMagicLink.addEventListener("click", (e) => {
+      e.preventDefault();
+      showAuthForm("magic-form");
+    });
+  }
+  document.querySelectorAll(".back-to-login").forEach((link) => {
+    link.addEventListener("click", (e) => {
+      e.preventDefault();
+      showAuthForm("login-form");
+    });
+  });
+
+  const forgotFormElement = document.querySelector("#forgot-form form");
+  if (forgotFormElement) {
+    forgotFormElement.addEventListener("submit", handleForgotPassword);
+  }
+  const magicFormElement = document.querySelector("#magic-form form");
+  if (magicFormElement) {
+    magicFormElement.addEventListener("submit", handleMagicLinkRequest);
+  }
+  const resetFormElement = document.querySelector("#reset-form form");
+  if (resetFormElement) {
+    resetFormElement.addEventListener("submit", handleResetPassword);
+  }
+
+  setupOIDCLogin();
+
   // Add form submission handlers
   if (loginFormElement) {
     loginFormElement.addEventListener("submit", handleLogin);
@@ -164,15 +267,15 @@ export async function handleLogin(e) {
 
     const data = await response.json();
 
-    if (response.ok) {
-      localStorage.setItem("token", data.token);
-      localStorage.setItem("userData", JSON.stringify(data.userData));
-
-      // Get router instance and navigate
-      const router = new Router();
-      router.navigate("/");
-
-      showNotification("Login successful!", NotificationType.SUCCESS);
+    if (response.ok && data.two_factor_required) {
+      showTwoFactorStep(data.challenge);
+    } else if (response.ok) {
+      finishLogin(data);
+    } else if (data.suspended) {
+      showNotification(
+        `${data.error}. Reason: ${escapeHTML(data.reason)}`,
+        NotificationType.ERROR
+      );
     } else {
       const errorMessage = data.error || "Invalid credentials";
       showNotification(errorMessage, NotificationType.ERROR);
@@ -186,6 +289,243 @@ export async function handleLogin(e) {
   }
 }
 
+async function handleForgotPassword(e) {
+  e.preventDefault();
+  const email = document.getElementById("forgot-email").value.trim();
+  if (!email) return;
+
+  try {
+    const response = await fetch("/password/forgot", {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify({ email }),
+    });
+    const data = await response.json();
+
+    if (response.ok) {
+      showNotification(data.message, NotificationType.SUCCESS);
+      showAuthForm("login-form");
+    } else {
+      showNotification(data.error || "Failed to send reset link", NotificationType.ERROR);
+    }
+  } catch (error) {
+    console.error("Error requesting password reset:", error);
+    showNotification("An error occurred. Please try again.", NotificationType.ERROR);
+  }
+}
+
+async function handleResetPassword(e) {
+  e.preventDefault();
+  const token = new URLSearchParams(window.location.search).get("token");
+  const password = document.getElementById("reset-password").value;
+  if (!token) {
+    showNotification("This reset link is incomplete", NotificationType.ERROR);
+    return;
+  }
+
+  try {
+    const response = await fetch("/password/reset", {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify({ token, password }),
+    });
+    const data = await response.json();
+
+    if (response.ok) {
+      showNotification(data.message, NotificationType.SUCCESS);
+      localStorage.removeItem("token");
+      new Router().navigate("/loginPage");
+      showAuthForm("login-form");
+    } else {
+      showNotification(data.error || "Failed to reset password", NotificationType.ERROR);
+    }
+  } catch (error) {
+    console.error("Error resetting password:", error);
+    showNotification("An error occurred. Please try again.", NotificationType.ERROR);
+  }
+}
+
+async function handleMagicLinkRequest(e) {
+  e.preventDefault();
+  const email = document.getElementById("magic-email").value.trim();
+  if (!email) return;
+
+  try {
+    const response = await fetch("/login/magic", {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify({ email }),
+    });
+    const data = await response.json();
+
+    if (response.ok) {
+      showNotification(data.message, NotificationType.SUCCESS);
+      showAuthForm("login-form");
+    } else {
+      showNotification(data.error || "Failed to send sign-in link", NotificationType.ERROR);
+    }
+  } catch (error) {
+    console.error("Error requesting sign-in link:", error);
+    showNotification("An error occurred. Please try again.", NotificationType.ERROR);
+  }
+}
+
+// The emailed sign-in link opens this page; posting its token signs in
+// exactly like the login form, including the 2FA step
+export async function completeMagicLogin(renderLogin) {
+  const router = new Router();
+  const token = new URLSearchParams(window.location.search).get("token");
+  if (!token) {
+    router.navigate("/loginPage");
+    return;
+  }
+
+  try {
+    const response = await fetch("/login/magic/verify", {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify({ token }),
+    });
+    const data = await response.json();
+
+    if (response.ok && data.two_factor_required) {
+      renderLogin();
+      showTwoFactorStep(data.challenge);
+    } else if (response.ok) {
+      finishLogin(data);
+    } else {
+      const message = data.suspended
+        ? `${data.error}. Reason: ${escapeHTML(data.reason)}`
+        : data.error || "Sign-in failed";
+      showNotification(message, NotificationType.ERROR);
+      router.navigate("/loginPage");
+    }
+  } catch (error) {
+    console.error("Error signing in with link:", error);
+    showNotification("An error occurred. Please try again.", NotificationType.ERROR);
+    router.navigate("/loginPage");
+  }
+}
+
+// Offer single sign-on when the server has a provider configured
+async function setupOIDCLogin() {
+  const link = document.getElementById("oidc-login");
+  if (!link) return;
+  try {
+    const response = await fetch("/auth/oidc");
+    const data = await response.json();
+    if (data.enabled) {
+      link.textContent = `Sign in with ${data.name}`;
+      link.style.display = "block";
+    }
+  } catch (error) {
+    console.error("Error checking single sign-on:", error);
+  }
+}
+
+// The OIDC callback leaves a session and refresh cookie behind; exchange it
+// for an access token and load the user before entering the app. Accounts
+// with 2FA are sent back with a challenge for the code prompt instead.
+export async function completeOIDCLogin(renderLogin) {
+  const router = new Router();
+  const challenge = new URLSearchParams(window.location.hash.slice(1)).get("challenge");
+  if (challenge) {
+    history.replaceState(null, "", window.location.pathname);
+    renderLogin();
+    showTwoFactorStep(challenge);
+    return;
+  }
+  if (!(await refreshAccessToken())) {
+    showNotification("Single sign-on failed", NotificationType.ERROR);
+    router.navigate("/loginPage");
+    return;
+  }
+  try {
+    const response = await authenticatedFetch("/api/me");
+    if (!response.ok) {
+      throw new Error("Failed to load user");
+    }
+    localStorage.setItem("userData", JSON.stringify(await response.json()));
+    router.navigate("/");
+    showNotification("Login successful!", NotificationType.SUCCESS);
+  } catch (error) {
+    console.error("Error completing single sign-on:", error);
+    localStorage.removeItem("token");
+    router.navigate("/loginPage");
+  }
+}
+
+function finishLogin(data) {
+  localStorage.setItem("token", data.token);
+  setCSRFToken(data.csrf_token);
+  localStorage.setItem("userData", JSON.stringify(data.userData));
+
+  // Get router instance and navigate
+  const router = new Router();
+  router.navigate("/");
+
+  if (data.deletion_cancelled) {
+    showNotification(
+      "Welcome back! Your account will no longer be deleted.",
+      NotificationType.SUCCESS
+    );
+  } else {
+    showNotification("Login successful!", NotificationType.SUCCESS);
+  }
+}
+
+// Replace the login form with a code prompt for accounts with 2FA
+function showTwoFactorStep(challenge) {
+  const loginForm = document.querySelector("#login-form form");
+  if (!loginForm) return;
+
+  loginForm.innerHTML = `
+        <div class="input-group">
+            <i class="fas fa-shield-alt"></i>
+            <input type="text" id="login-2fa-code" placeholder="Authentication or recovery code" autocomplete="one-time-code" required>
+        </div>
+        <button type="submit">Verify</button>
+    `;
+  loginForm.removeEventListener("submit", handleLogin);
+  loginForm.addEventListener("submit", async (e) => {
+    e.preventDefault();
+    const code = document.getElementById("login-2fa-code").value.trim();
+    if (!code) return;
+
+    try {
+      const response = await fetch("/login/2fa", {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+        },
+        body: JSON.stringify({ challenge, code }),
+      });
+      const data = await response.json();
+
+      if (response.ok) {
+        finishLogin(data);
+      } else {
+        showNotification(data.error || "Invalid code", NotificationType.ERROR);
+      }
+    } catch (error) {
+      console.error("Error during two-factor login:", error);
+      showNotification(
+        "An error occurred during login. Please try again.",
+        NotificationType.ERROR
+      );
+    }
+  });
+  document.getElementById("login-2fa-code").focus();
+}
+
 async function handleRegister(e) {
   e.preventDefault();
 
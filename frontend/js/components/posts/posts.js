@@ -2,19 +2,23 @@ import {
   createStorySection,
   createPostCard,
   createPostsFeed,
+  createFeedTabs,
   createPostHeader,
   createPostContent,
   createPostCategories,
+  createPostReactions,
   createPostActions,
   createPostComments,
 } from "./postsTemplates.js";
 import {
   handleCreatePost,
   handlePostReaction,
+  handleReactionClick,
   handleEnterKeyCommentSubmit,
   handleSavePost,
   handlePostSubmit,
   fetchPosts,
+  loadCategoryOptions,
 } from "./postsApi.js";
 import {
   handleCategorySelection,
@@ -29,6 +33,7 @@ import {
 } from "./postsEvents.js";
 import { registerTimeElement } from "../../utils/timeUpdater.js";
 import { setupInfiniteScroll, formatTimeAgo } from "../../utils.js";
+import { forumState } from "../../state.js";
 // main functions
 function createMainContent() {
   return `
@@ -36,6 +41,7 @@ function createMainContent() {
 
             ${createStorySection()}
             ${createPostCard()}
+            ${createFeedTabs()}
             ${createPostsFeed()}
         </main>
     `;
@@ -55,6 +61,14 @@ function setupPostEventListeners() {
       btn.addEventListener("click", handlePostReaction);
     });
 
+  // Emoji reactions. Their chips are redrawn as counts change, so clicks
+  // are picked up once on the feed rather than on each button.
+  const postsContainer = document.getElementById("posts-container");
+  if (postsContainer) {
+    postsContainer.removeEventListener("click", handleReactionClick);
+    postsContainer.addEventListener("click", handleReactionClick);
+  }
+
   // Comment submission
   document.querySelectorAll(".comment-input").forEach((input) => {
     input.addEventListener("keypress", handleEnterKeyCommentSubmit);
@@ -134,6 +148,20 @@ function setupPostEventListeners() {
   document.querySelectorAll(".category-dropdown").forEach((dropdown) => {
     dropdown.addEventListener("change", handleCategorySelection);
   });
+  loadCategoryOptions();
+
+  // Switch between the new, hot, top and following feeds. The tabs outlive
+  // the posts rendered under them, so they are bound once.
+  document.querySelectorAll(".feed-tab").forEach((tab) => {
+    tab.removeEventListener("click", handleFeedChange);
+    tab.addEventListener("click", handleFeedChange);
+  });
+  const feedWindow = document.querySelector(".feed-window");
+  if (feedWindow) {
+    feedWindow.value = forumState.feedWindow;
+    feedWindow.removeEventListener("change", handleFeedWindowChange);
+    feedWindow.addEventListener("change", handleFeedWindowChange);
+  }
 
   // Setup comment event listeners
   setupCommentEventListeners();
@@ -171,6 +199,31 @@ function closeModals() {
   });
 }
 
+function handleFeedChange(e) {
+  const feed = e.currentTarget.dataset.feed;
+  if (feed === forumState.feed) return;
+  forumState.feed = feed;
+
+  document.querySelectorAll(".feed-tab").forEach((tab) => {
+    tab.classList.toggle("active", tab.dataset.feed === feed);
+  });
+  const feedWindow = document.querySelector(".feed-window");
+  if (feedWindow) {
+    feedWindow.style.display = feed === "top" ? "inline-block" : "none";
+  }
+  reloadFeed();
+}
+
+function handleFeedWindowChange(e) {
+  forumState.feedWindow = e.target.value;
+  reloadFeed();
+}
+
+function reloadFeed() {
+  window.scrollTo(0, 0);
+  fetchPosts();
+}
+
 function renderPosts(posts, append = false, singlePost = false) {
   const postsContainer = document.getElementById("posts-container");
   if (!postsContainer) return;
@@ -188,6 +241,7 @@ function renderPosts(posts, append = false, singlePost = false) {
             ${createPostHeader(post)}
             ${createPostContent(post)}
             ${createPostCategories(post)}
+            ${createPostReactions(post)}
             ${createPostActions(post)}
             ${createPostComments(post)}
         </div>
@@ -7,9 +7,7 @@ import { authenticatedFetch } from "../../security.js";
 import { renderPosts, closePostModal, closeModals } from "./posts.js";
 import { SelectedCategories } from "./postsEvent.js";
 import { setupCommentEventListeners } from "./postsEvents.js";
-import { createComment } from "./postsTemplates.js";
-import { globalSocket } from "../../websocket/websocket.js";
-import { WebSocketMessageType } from "../../websocket/websocket.js";
+import { createComment, createReactionChips } from "./postsTemplates.js";
 
 async function handleCreatePost(e) {
   e.preventDefault();
@@ -32,23 +30,9 @@ async function handlePostReaction(e) {
   const postId = e.currentTarget.dataset.postId;
   const isLike = e.currentTarget.classList.contains("action-like-btn");
 
+  // The server broadcasts the new counts to everyone, us included
   try {
-    const response = await reactToPost(postId, isLike);
-
-    // Send WebSocket update for reaction counts
-    if (globalSocket && globalSocket.readyState === WebSocket.OPEN) {
-      globalSocket.send(
-        JSON.stringify({
-          type: WebSocketMessageType.POST_REACTION,
-          payload: {
-            post_id: parseInt(postId),
-            likes: response.likes,
-            dislikes: response.dislikes,
-            reaction_type: isLike ? "like" : "dislike",
-          },
-        })
-      );
-    }
+    await reactToPost(postId, isLike);
   } catch (error) {
     console.error("Error reacting to post:", error);
     showNotification("Failed to update reaction", NotificationType.ERROR);
@@ -145,20 +129,103 @@ async function handleSavePost(e) {
   }
 }
 
-async function fetchPosts(page = 1, append = false) {
+// Load the first page of the feed, or with append the page after the
+// ones already shown
+// loadReactionSet fetches the emoji reactions users can leave, once
+async function loadReactionSet() {
+  if (forumState.reactionSet.length > 0) return;
+
+  try {
+    const response = await fetch(`/api/reactions`);
+    if (!response.ok) {
+      throw new Error("Failed to fetch reactions");
+    }
+    const data = await response.json();
+    forumState.reactionSet = data.reactions || [];
+  } catch (error) {
+    console.error("Error loading reactions:", error);
+  }
+}
+
+async function togglePostReaction(postId, reaction) {
+  const response = await authenticatedFetch(`/api/posts/reactions`, {
+    method: "POST",
+    headers: {
+      "Content-Type": "application/json",
+    },
+    body: JSON.stringify({
+      id: parseInt(postId),
+      reaction,
+    }),
+  });
+
+  const data = await response.json();
+  if (!response.ok) {
+    throw new Error(data.error || "Failed to update reaction");
+  }
+  return data;
+}
+
+// handleReactionClick opens and closes the reaction picker of a post, and
+// leaves or takes back the reaction clicked in it or on its chips
+async function handleReactionClick(e) {
+  const toggle = e.target.closest(".reaction-picker-toggle");
+  if (toggle) {
+    const options = toggle.parentElement.querySelector(".reaction-options");
+    options.style.display = options.style.display === "none" ? "flex" : "none";
+    return;
+  }
+
+  const button = e.target.closest(".reaction-chip, .reaction-option");
+  if (!button) return;
+
+  const postId = button.dataset.postId;
+  const options = button.closest(".post-reactions")?.querySelector(".reaction-options");
+  if (options) {
+    options.style.display = "none";
+  }
+
+  try {
+    const data = await togglePostReaction(postId, button.dataset.reaction);
+    const chips = document.querySelector(
+      `.post-reactions[data-post-id="${postId}"] .reaction-chips`
+    );
+    if (chips) {
+      chips.innerHTML = createReactionChips(postId, data.reactions);
+    }
+  } catch (error) {
+    console.error("Error reacting to post:", error);
+    showNotification(
+      error.message || "Failed to update reaction",
+      NotificationType.ERROR
+    );
+  }
+}
+
+async function fetchPosts(append = false) {
   if (forumState.isLoading) return;
   
   // Reset state when loading first page
-  if (page === 1 && !append) {
+  if (!append) {
     forumState.allPostsLoaded = false;
+    forumState.nextCursor = "";
   }
   
   forumState.isLoading = true;
 
   try {
+    await loadReactionSet();
+
     const limit = 10;
+    const params = new URLSearchParams({ limit, feed: forumState.feed || "new" });
+    if (forumState.feed === "top") {
+      params.set("window", forumState.feedWindow || "week");
+    }
+    if (append && forumState.nextCursor) {
+      params.set("cursor", forumState.nextCursor);
+    }
     const response = await authenticatedFetch(
-      `/api/posts?page=${page}&limit=${limit}`,
+      `/api/posts?${params}`,
       {
         method: "GET",
         headers: {
@@ -174,7 +241,8 @@ async function fetchPosts(page = 1, append = false) {
     const data = await response.json();
     const posts = data.posts;
 
-    if (!posts || posts.length < limit) {
+    forumState.nextCursor = data.next_cursor;
+    if (!data.next_cursor) {
       forumState.allPostsLoaded = true;
     }
 
@@ -187,6 +255,29 @@ async function fetchPosts(page = 1, append = false) {
   }
 }
 
+// Fill the category pickers with the categories the server knows about
+async function loadCategoryOptions() {
+  try {
+    const response = await authenticatedFetch("/api/categories");
+    if (!response.ok) return;
+    const { categories } = await response.json();
+
+    document.querySelectorAll(".category-dropdown").forEach((dropdown) => {
+      dropdown
+        .querySelectorAll('option:not([value="default"])')
+        .forEach((option) => option.remove());
+      categories.forEach((category) => {
+        const option = document.createElement("option");
+        option.value = category.slug;
+        option.textContent = category.name;
+        dropdown.appendChild(option);
+      });
+    });
+  } catch (error) {
+    console.error("Error loading categories:", error);
+  }
+}
+
 async function createPost({ title, content, modal }) {
   if (!modal) return null;
 
@@ -387,7 +478,7 @@ async function refreshComments(postId) {
 
     const commentsContainer = document.querySelector(`#comments-${postId}`);
     if (commentsContainer) {
-      commentsContainer.innerHTML = Object.values(comments)
+      commentsContainer.innerHTML = Object.values(comments || {})
         .map((comment) =>
           createComment(
             comment,
@@ -439,12 +530,12 @@ async  function loadUserPosts() {
     postsContainer.innerHTML =
       '<div class="loading-spinner">Loading posts...</div>';
 
-    fetchPosts(1, false);
+    fetchPosts();
 
 }
 // Initialize state
-if (!forumState.hasOwnProperty("currentPage")) {
-  forumState.currentPage = 1;
+if (!forumState.hasOwnProperty("nextCursor")) {
+  forumState.nextCursor = "";
 }
 if (!forumState.hasOwnProperty("allPostsLoaded")) {
   forumState.allPostsLoaded = false;
@@ -453,10 +544,12 @@ if (!forumState.hasOwnProperty("allPostsLoaded")) {
 export {
   handleCreatePost,
   handlePostReaction,
+  handleReactionClick,
   handleEnterKeyCommentSubmit,
   handleSavePost,
   handlePostSubmit,
   fetchPosts,
+  loadCategoryOptions,
   refreshComments,
   submitComment,
   submitReply,
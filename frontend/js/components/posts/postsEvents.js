@@ -6,11 +6,26 @@ import {
 } from "../../utils/notifications.js";
 import { submitComment, refreshComments, submitReply } from "./postsApi.js";
 
+// Fetch the comments of a post unless they are already shown
+export async function loadComments(postId) {
+  const commentsContainer = document.querySelector(`#comments-${postId}`);
+  if (!commentsContainer || commentsContainer.dataset.loaded === "true") return;
+
+  try {
+    await refreshComments(postId);
+    commentsContainer.dataset.loaded = "true";
+    setupReplyListeners(commentsContainer);
+  } catch (error) {
+    // refreshComments already told the user
+  }
+}
+
 export function setupCommentEventListeners() {
   // Toggle comments
   document.querySelectorAll(".toggle-comments-btn").forEach((btn) => {
-    btn.addEventListener("click", (e) => {
-      const postId = e.currentTarget.dataset.postId;
+    btn.addEventListener("click", async (e) => {
+      const toggleBtn = e.currentTarget;
+      const postId = toggleBtn.dataset.postId;
       const commentsContent = document.querySelector(
         `#comments-section-${postId} .comments-content`
       );
@@ -18,28 +33,23 @@ export function setupCommentEventListeners() {
         const isHidden = commentsContent.style.display === "none";
         commentsContent.style.display = isHidden ? "block" : "none";
 
+        // The feed leaves comments out, so fetch them the first time
+        if (isHidden) {
+          await loadComments(postId);
+        }
+
         // Update the comment count text
         const commentCount = document.querySelectorAll(
           `#comments-${postId} .comment`
         ).length;
-        e.currentTarget.querySelector(
+        toggleBtn.querySelector(
           "span"
         ).textContent = `Comments (${commentCount}) ${isHidden ? "▼" : "▲"}`;
       }
     });
   });
 
-  // Reply button
-  document.querySelectorAll(".reply-btn").forEach((btn) => {
-    btn.addEventListener("click", (e) => {
-      const commentId = e.currentTarget.dataset.commentId;
-      const replyInput = document.querySelector(`#reply-input-${commentId}`);
-      if (replyInput) {
-        replyInput.style.display =
-          replyInput.style.display === "none" ? "block" : "none";
-      }
-    });
-  });
+  setupReplyListeners(document);
 
   // Load more comments
   document.querySelectorAll(".load-more-comments").forEach((btn) => {
@@ -63,7 +73,46 @@ export function setupCommentEventListeners() {
     });
   });
 
-  document.querySelectorAll(".reply-submit-btn").forEach((button) => {
+  // Submit buttons click handlers
+  document.querySelectorAll(".comment-submit-btn").forEach((btn) => {
+    btn.addEventListener(
+      "click",
+      debounce(async (e) => {
+        const button = e.target.closest(".comment-submit-btn");
+        const postId = button.dataset.postId;
+        const input = button
+          .closest(".comment-input-container")
+          .querySelector(".comment-input");
+        const content = input?.value?.trim();
+
+        if (content && postId) {
+          try {
+            await handleCommentSubmit(e, postId);
+          } catch (error) {
+            console.error("Error submitting comment:", error);
+          }
+        }
+      }),
+      1000
+    );
+  });
+}
+
+// Wire the reply buttons of the comments inside root
+function setupReplyListeners(root) {
+  // Reply button
+  root.querySelectorAll(".reply-btn").forEach((btn) => {
+    btn.addEventListener("click", (e) => {
+      const commentId = e.currentTarget.dataset.commentId;
+      const replyInput = document.querySelector(`#reply-input-${commentId}`);
+      if (replyInput) {
+        replyInput.style.display =
+          replyInput.style.display === "none" ? "block" : "none";
+      }
+    });
+  });
+
+  root.querySelectorAll(".reply-submit-btn").forEach((button) => {
     button.addEventListener(
       "click",
       debounce(async (e) => {
@@ -109,29 +158,6 @@ export function setupCommentEventListeners() {
       300
     );
   });
-  // Submit buttons click handlers
-  document.querySelectorAll(".comment-submit-btn").forEach((btn) => {
-    btn.addEventListener(
-      "click",
-      debounce(async (e) => {
-        const button = e.target.closest(".comment-submit-btn");
-        const postId = button.dataset.postId;
-        const input = button
-          .closest(".comment-input-container")
-          .querySelector(".comment-input");
-        const content = input?.value?.trim();
-
-        if (content && postId) {
-          try {
-            await handleCommentSubmit(e, postId);
-          } catch (error) {
-            console.error("Error submitting comment:", error);
-          }
-        }
-      }),
-      1000
-    );
-  });
 }
 
 export function setupPostMenuHandlers() {
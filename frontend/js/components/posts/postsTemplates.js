@@ -1,5 +1,5 @@
 import { escapeHTML, formatTimeAgo } from "../../utils.js";
-import { BASE_URL } from "../../state.js";
+import { BASE_URL, forumState } from "../../state.js";
 
 
 // Helper functions
@@ -232,7 +232,11 @@ function createPostHeader(post) {
                       post.user.profession
                         ? `${post.user.profession} • `
                         : "Feature in progress • "
-                    }<span id="${timeId}">${formatTimeAgo(post.timestamp)}</span></span>
+                    }<span id="${timeId}">${formatTimeAgo(post.timestamp)}</span>${
+                      post.edited_at
+                        ? ` • <span title="Edited ${new Date(post.edited_at).toLocaleString()}">edited</span>`
+                        : ""
+                    }</span>
                 </div>
             </div>
             <div class="post-menu">
@@ -344,14 +348,15 @@ function createPostContent(post) {
 }
 
 function createPostCategories(post) {
-  if (!post.category) return "";
-
   // Handle both array and comma-separated string formats
-  const categoryArray = Array.isArray(post.category)
+  const categoryArray = !post.category
+    ? []
+    : Array.isArray(post.category)
     ? post.category
     : post.category.split(",").map((cat) => cat.trim());
+  const tags = post.tags || [];
 
-  if (categoryArray.length === 0) return "";
+  if (categoryArray.length === 0 && tags.length === 0) return "";
 
   return `
         <div class="post-categories">
@@ -362,24 +367,84 @@ function createPostCategories(post) {
             `
               )
               .join("")}
+            ${tags
+              .map(
+                (tag) => `
+                <span class="category-tag hashtag">#${escapeHTML(tag)}</span>
+            `
+              )
+              .join("")}
         </div>
     `;
 }
 
+function createReactionChips(postId, reactions) {
+  return (reactions || [])
+    .map(
+      (reaction) => `
+                <button class="reaction-chip${
+                  reaction.reacted ? " reacted" : ""
+                }" data-post-id="${postId}" data-reaction="${escapeHTML(
+        reaction.name
+      )}" title="${escapeHTML(reaction.name)}">
+                    ${escapeHTML(reaction.emoji)} <span>${reaction.count}</span>
+                </button>
+            `
+    )
+    .join("");
+}
+
+function createPostReactions(post) {
+  return `
+        <div class="post-reactions" data-post-id="${post.id}">
+            <div class="reaction-chips">
+                ${createReactionChips(post.id, post.reactions)}
+            </div>
+            <div class="reaction-picker">
+                <button class="reaction-picker-toggle" title="React">
+                    <i class="far fa-face-smile"></i>
+                </button>
+                <div class="reaction-options" style="display: none;">
+                    ${forumState.reactionSet
+                      .map(
+                        (reaction) => `
+                        <button class="reaction-option" data-post-id="${
+                          post.id
+                        }" data-reaction="${escapeHTML(
+                          reaction.name
+                        )}" title="${escapeHTML(reaction.name)}">${escapeHTML(
+                          reaction.emoji
+                        )}</button>
+                    `
+                      )
+                      .join("")}
+                </div>
+            </div>
+        </div>
+    `;
+}
+
+// reactionCount is how many left the named reaction; likes and dislikes
+// are reactions too
+function reactionCount(reactions, name) {
+  const reaction = (reactions || []).find((r) => r.name === name);
+  return reaction ? reaction.count : 0;
+}
+
 function createPostActions(post) {
   return `
         <div class="post-actions">
             <li class="action-like-btn" data-post-id="${post.id}">
                 <i class="fa-solid fa-thumbs-up"></i>
-                <span>Like (${post.likes || 0})</span>
+                <span>Like (${reactionCount(post.reactions, "like")})</span>
             </li>
             <li class="action-dislike-btn" data-post-id="${post.id}">
                 <i class="fa-solid fa-thumbs-down"></i>
-                <span>Dislike (${post.dislikes || 0})</span>
+                <span>Dislike (${reactionCount(post.reactions, "dislike")})</span>
             </li>
             <li class="toggle-comments-btn active" data-post-id="${post.id}">
                 <i class="far fa-comment"></i>
-                <span>Comments (${post.comments?.length || 0})</span>
+                <span>Comments (${post.comment_count || 0})</span>
             </li>
         </div>
     `;
@@ -408,7 +473,7 @@ function createPostComments(post) {
                         </button>
                     </div>
                 </div>
-                <div class="comments-container" id="comments-${post.id}">
+                <div class="comments-container" id="comments-${post.id}" data-loaded="${post.comments ? "true" : "false"}">
                     ${
                       post.comments
                         ? post.comments
@@ -502,6 +567,33 @@ function createComment(comment, isReply = false, hasReplies = false) {
     `;
 }
 
+function createFeedTabs() {
+  const feeds = [
+    { mode: "new", label: "New" },
+    { mode: "hot", label: "Hot" },
+    { mode: "top", label: "Top" },
+    { mode: "following", label: "Following" },
+  ];
+  const current = forumState.feed || "new";
+  return `
+        <div class="feed-tabs">
+            ${feeds
+              .map(
+                (feed) => `
+                <button class="feed-tab${feed.mode === current ? " active" : ""}" data-feed="${feed.mode}">${feed.label}</button>
+            `
+              )
+              .join("")}
+            <select class="feed-window" style="display: ${current === "top" ? "inline-block" : "none"};">
+                <option value="day">Today</option>
+                <option value="week" selected>This week</option>
+                <option value="month">This month</option>
+                <option value="all">All time</option>
+            </select>
+        </div>
+    `;
+}
+
 function createPostsFeed() {
   return `
         <div class="posts-feed" id="posts-container">
@@ -515,10 +607,14 @@ export {
   createPostHeader,
   createPostContent,
   createPostCategories,
+  createReactionChips,
+  reactionCount,
+  createPostReactions,
   createPostActions,
   createPostComments,
   createComment,
   createPostsFeed,
+  createFeedTabs,
   createStorySection,
   createImagePostModal,
   createTextPostModal,
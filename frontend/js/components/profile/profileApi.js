@@ -49,11 +49,10 @@ async function handlePasswordSubmit(e) {
   }
 
   try {
-    const response = await fetch("/api/users/password", {
+    const response = await authenticatedFetch("/api/users/password", {
       method: "PUT",
       headers: {
         "Content-Type": "application/json",
-        Authorization: `Bearer ${localStorage.getItem("token")}`,
       },
       body: JSON.stringify({
         currentPassword,
@@ -470,22 +470,17 @@ const setupProfileEventListeners = function() {
     deleteAccountBtn.addEventListener("click", async () => {
       if (
         confirm(
-          "Are you absolutely sure you want to delete your account? This cannot be undone."
+          "Are you sure you want to delete your account? Sign in within 14 days if you change your mind."
         )
       ) {
         try {
-          const response = await fetch("/api/users/delete", {
+          const response = await authenticatedFetch("/api/users/delete", {
             method: "DELETE",
-            headers: {
-              Authorization: `Bearer ${localStorage.getItem("token")}`,
-            },
           });
 
           if (response.ok) {
-            showNotification(
-              "Account Deleted Succesfully",
-              NotificationType.SUCCESS
-            );
+            const data = await response.json();
+            showNotification(data.message, NotificationType.SUCCESS);
             localStorage.clear();
             window.location.href = "/loginpage";
           } else {
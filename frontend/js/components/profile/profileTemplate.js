@@ -170,19 +170,22 @@ export function createDeleteSection() {
                     </div>
                     <h4>Delete Your Account</h4>
                     <p class="warning-text">
-                        This action will permanently delete your account and all associated data. This includes:
+                        Your account will be deleted after 14 days. Signing in before then keeps it. After that we remove:
                     </p>
                     <ul class="deletion-items">
-                        <li><i class="fa-solid fa-circle-minus"></i> All your posts and comments</li>
                         <li><i class="fa-solid fa-circle-minus"></i> Your profile information</li>
                         <li><i class="fa-solid fa-circle-minus"></i> Your connections and followers</li>
+                        <li><i class="fa-solid fa-circle-minus"></i> Your uploaded photos and videos</li>
                         <li><i class="fa-solid fa-circle-minus"></i> All saved content</li>
                     </ul>
+                    <p class="warning-text">
+                        Your posts, comments and messages stay in other people's threads and conversations, shown as from a "deleted user".
+                    </p>
                     <div class="confirmation-box">
                         <label class="confirm-checkbox">
                             <input type="checkbox" id="delete-confirm">
                             <span class="checkmark"></span>
-                            I understand that this action cannot be undone
+                            I understand that my account will be deleted unless I sign in again within 14 days
                         </label>
                     </div>
                     <div class="delete-actions">
@@ -107,11 +107,10 @@ async function followUser(userName) {
 
     const userId = suggestionItem.dataset.userId;
 
-    const response = await fetch("/api/followers/follow", {
+    const response = await authenticatedFetch("/api/followers/follow", {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
-        Authorization: `Bearer ${localStorage.getItem("token")}`,
       },
       body: JSON.stringify({
         following_id: parseInt(userId),
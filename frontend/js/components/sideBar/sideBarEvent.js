@@ -1,6 +1,7 @@
 import { throttle } from "../../utils.js";
 import { createSuggestionItems, handleFollow } from "./sideBareTemplate.js";
 import { createLoader } from "../loader.js";
+import { loadComments } from "../posts/postsEvents.js";
 
 let currentPage = 1;
 let isLoadingUsers = false;
@@ -71,7 +72,7 @@ function setupFollowEventListeners() {
   });
 }
 
-function scrollToPost(postId) {
+async function scrollToPost(postId) {
   const postElement = document.getElementById(`post-${postId}`);
   if (!postElement) return;
 
@@ -90,6 +91,7 @@ function scrollToPost(postId) {
   const commentsContent = postElement.querySelector(".comments-content");
   if (commentsContent && commentsContent.style.display === "none") {
     commentsContent.style.display = "block";
+    await loadComments(postId);
 
     // Update the comments toggle button text
     const toggleBtn = postElement.querySelector(".toggle-comments-btn");
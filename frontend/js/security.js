@@ -8,19 +8,68 @@ window.escapeHTML = (str) => {
 // CSRF Token handling
 let csrfToken = '';
 
+// The token belongs to the session, so it is only fetched once logged in.
+// Login responses carry a fresh one, stored with setCSRFToken.
 async function fetchCSRFToken() {
-    const response = await fetch('/csrf-token');
+    if (!localStorage.getItem('token')) {
+        return;
+    }
+    const response = await fetch('/csrf-token', { credentials: 'include' });
     if (response.ok) {
         csrfToken = response.headers.get('X-CSRF-Token');
     }
 }
 
-// Update authenticatedFetch to include CSRF token
-function authenticatedFetch(url, options = {}) {
-    const token = localStorage.getItem('token');
-    if (!token) {
-        throw new Error('No authentication token found');
+function setCSRFToken(token) {
+    csrfToken = token || '';
+}
+
+function isSafeMethod(options) {
+    const method = (options.method || 'GET').toUpperCase();
+    return method === 'GET' || method === 'HEAD';
+}
+
+async function isCSRFRejection(response) {
+    if (response.status !== 403) {
+        return false;
     }
+    try {
+        const data = await response.clone().json();
+        return data.error === 'Invalid CSRF token';
+    } catch {
+        return false;
+    }
+}
+
+// Access tokens are short-lived; the refresh token lives in an HttpOnly
+// cookie and is exchanged for a new access token when the old one expires.
+// Concurrent callers share one refresh request.
+let refreshPromise = null;
+
+function refreshAccessToken() {
+    if (!refreshPromise) {
+        refreshPromise = fetch('/refresh-token', {
+            method: 'POST',
+            credentials: 'include',
+        })
+            .then(async (response) => {
+                if (!response.ok) {
+                    localStorage.removeItem('token');
+                    return false;
+                }
+                const data = await response.json();
+                localStorage.setItem('token', data.token);
+                return true;
+            })
+            .catch(() => false)
+            .finally(() => {
+                refreshPromise = null;
+            });
+    }
+    return refreshPromise;
+}
+
+function sendAuthenticated(url, options, token) {
     return fetch(url, {
         ...options,
         headers: {
@@ -33,6 +82,59 @@ function authenticatedFetch(url, options = {}) {
     });
 }
 
+// Update authenticatedFetch to include CSRF token and renew expired access and CSRF tokens
+async function authenticatedFetch(url, options = {}) {
+    const token = localStorage.getItem('token');
+    if (!token) {
+        throw new Error('No authentication token found');
+    }
+    if (!csrfToken && !isSafeMethod(options)) {
+        await fetchCSRFToken();
+    }
+    let response = await sendAuthenticated(url, options, token);
+    if (response.status === 401 && (await refreshAccessToken())) {
+        response = await sendAuthenticated(url, options, localStorage.getItem('token'));
+    }
+    // The CSRF token expires before the session; get a new one and retry once
+    if (await isCSRFRejection(response)) {
+        await fetchCSRFToken();
+        response = await sendAuthenticated(url, options, localStorage.getItem('token'));
+    }
+    showImpersonationBanner(response.headers.get('X-Impersonated-By'));
+    return response;
+}
+
+// Admins acting as a user see who they are signed in as on every page,
+// with a way back to their own account
+function showImpersonationBanner(adminNickname) {
+    let banner = document.getElementById('impersonation-banner');
+    if (!adminNickname) {
+        banner?.remove();
+        return;
+    }
+    if (banner) {
+        return;
+    }
+    banner = document.createElement('div');
+    banner.id = 'impersonation-banner';
+    banner.className = 'impersonation-banner';
+    banner.innerHTML = `
+        <span>${window.escapeHTML(adminNickname)}, you are acting as another user. Passwords and messages are off limits and everything you do is logged.</span>
+        <button type="button">Stop</button>`;
+    banner.querySelector('button').addEventListener('click', async () => {
+        const response = await authenticatedFetch('/api/impersonation', { method: 'DELETE' });
+        const data = await response.json().catch(() => ({}));
+        if (data.token) {
+            localStorage.setItem('token', data.token);
+            setCSRFToken(data.csrf_token);
+        } else {
+            localStorage.removeItem('token');
+        }
+        window.location.href = '/';
+    });
+    document.body.prepend(banner);
+}
+
 // Content Security Policy
 const cspHeader = {
     'default-src': ["'self'"],
@@ -61,4 +163,4 @@ document.addEventListener('DOMContentLoaded', async () => {
     setInterval(fetchCSRFToken, 30 * 60 * 1000); // Refresh every 30 minutes
 }); 
 
-export { authenticatedFetch, cspHeader, sanitizeInput };
\ No newline at end of file
+export { authenticatedFetch, refreshAccessToken, setCSRFToken, cspHeader, sanitizeInput };
\ No newline at end of file
@@ -1,8 +1,11 @@
 // Shared state across modules
 export const forumState = {
     isLoading: false,
-    currentPage: 1,
-    hasMorePosts: true
+    nextCursor: "",
+    hasMorePosts: true,
+    feed: "new",
+    feedWindow: "week",
+    reactionSet: []
 }; 
 
 export const BASE_URL = "http://localhost:8080"
\ No newline at end of file
@@ -145,8 +145,7 @@ function setupInfiniteScroll() {
     // Load more when user reaches 80% of the page
     if (scrollPosition > bodyHeight * 0.8) {
       if (!forumState.isLoading && !forumState.allPostsLoaded) {
-        forumState.currentPage = (forumState.currentPage || 1) + 1;
-        fetchPosts(forumState.currentPage, true);
+        fetchPosts(true);
       }
     }
   }, 500);
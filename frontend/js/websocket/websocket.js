@@ -2,7 +2,7 @@ import { NotificationType, showNotification } from "../utils/notifications.js";
 import { escapeHTML } from "../utils.js";
 import {
   handleWebsocketUpdatePost,
-  handlePostReactionUpdate,
+  handleReactionUpdate,
   handleUnreadCountUpdate,
   handleNewNotification,
   handleMessageListUpdate,
@@ -15,6 +15,7 @@ import {
 import { formatNumber, formatTimeAgo } from "../utils.js";
 import { fetchUserPhotos } from "../components/profile/profileApi.js";
 import { startTimeUpdates } from "../utils/timeUpdater.js";
+import { refreshAccessToken } from "../security.js";
 
 export let globalSocket = null;
 export let isIntentionalLogout = false;
@@ -84,8 +85,10 @@ export function initializeWebSocket() {
         );
 
         clearTimeout(reconnectTimeout);
-        reconnectTimeout = setTimeout(() => {
+        reconnectTimeout = setTimeout(async () => {
           reconnectAttempts++;
+          // The handshake may have failed because the access token expired
+          await refreshAccessToken();
           connect();
         }, delay);
       } else {
@@ -147,7 +150,7 @@ export const WebSocketMessageType = {
   NEW_MESSAGE: "new_message",
   PHOTO_UPDATE: "photo_update",
   PROFILE_UPDATE: "profile_update",
-  POST_REACTION: "post_reaction",
+  REACTION: "reaction",
   UNREAD_COUNT_UPDATE: "unread_count_update",
   NEW_NOTIFICATION: "new_notification",
   MESSAGE_LIST_UPDATE: "message_list_update",
@@ -204,8 +207,8 @@ export function handleWebSocketMessage(data) {
     case WebSocketMessageType.PROFILE_UPDATE:
       handleProfileUpdate(payload);
       break;
-    case WebSocketMessageType.POST_REACTION:
-      handlePostReactionUpdate(payload);
+    case WebSocketMessageType.REACTION:
+      handleReactionUpdate(payload);
       break;
     case WebSocketMessageType.UNREAD_COUNT_UPDATE:
       handleUnreadCountUpdate(payload);
@@ -4,7 +4,11 @@ import { showNotification, NotificationType } from "../utils/notifications.js";
 import { createNotificationItem } from "../components/header/headerTemplate.js";
 import { updateNotificationBadge } from "../components/header/headerEvent.js";
 import { updateTypingStatus } from "../components/messages/messagesTemplates.js";
-import { createComment } from "../components/posts/postsTemplates.js";
+import {
+  createComment,
+  createReactionChips,
+  reactionCount,
+} from "../components/posts/postsTemplates.js";
 
 function handleWebsocketUpdatePost(post) {
   if (post) {
@@ -15,30 +19,41 @@ function handleWebsocketUpdatePost(post) {
 
 export { handleWebsocketUpdatePost };
 
-export function handlePostReactionUpdate(data) {
-  const { post_id, likes, dislikes } = data;
-
-  // Update like count
-  const likeBtn = document.querySelector(
-    `.action-like-btn[data-post-id="${post_id}"]`
-  );
-  if (likeBtn) {
-    const likeSpan = likeBtn.querySelector("span");
-    if (likeSpan) {
-      likeSpan.textContent = `Like (${likes || 0})`;
+export function handleReactionUpdate(data) {
+  const { entity_type, entity_id, reactions } = data;
+  if (entity_type !== "post") return;
+
+  // Likes and dislikes are reactions, shown on their own buttons too
+  for (const [name, label] of [
+    ["like", "Like"],
+    ["dislike", "Dislike"],
+  ]) {
+    const span = document.querySelector(
+      `.action-${name}-btn[data-post-id="${entity_id}"] span`
+    );
+    if (span) {
+      span.textContent = `${label} (${reactionCount(reactions, name)})`;
     }
   }
 
-  // Update dislike count
-  const dislikeBtn = document.querySelector(
-    `.action-dislike-btn[data-post-id="${post_id}"]`
+  const chips = document.querySelector(
+    `.post-reactions[data-post-id="${entity_id}"] .reaction-chips`
+  );
+  if (!chips) return;
+
+  // The counts are shared by everyone, so keep our own reactions marked
+  const reacted = new Set(
+    [...chips.querySelectorAll(".reaction-chip.reacted")].map(
+      (chip) => chip.dataset.reaction
+    )
+  );
+  chips.innerHTML = createReactionChips(
+    entity_id,
+    (reactions || []).map((reaction) => ({
+      ...reaction,
+      reacted: reacted.has(reaction.name),
+    }))
   );
-  if (dislikeBtn) {
-    const dislikeSpan = dislikeBtn.querySelector("span");
-    if (dislikeSpan) {
-      dislikeSpan.textContent = `Dislike (${dislikes || 0})`;
-    }
-  }
 }
 
 export function handleUnreadCountUpdate(data) {
@@ -12,3 +12,5 @@ require (
 	github.com/gorilla/websocket v1.5.3
 
 )
+
+require golang.org/x/sys v0.29.0 // indirect
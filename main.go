@@ -10,10 +10,13 @@ import (
 	"syscall"
 	"time"
 
+	"forum/backend/commands"
 	"forum/backend/controllers"
 	"forum/backend/database"
+	"forum/backend/handlers"
 	"forum/backend/logger"
 	"forum/backend/routes"
+	"forum/backend/utils"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -23,6 +26,17 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Run an admin command instead of the server, e.g. `go run main.go jwt-keys rotate`
+	if commands.IsCommand(os.Args[1:]) {
+		os.Exit(commands.Run(os.Args[1:]))
+	}
+
+	// Load the JWT signing keys
+	keysPath := utils.SigningKeysPath()
+	if err := utils.LoadSigningKeys(keysPath); err != nil {
+		log.Fatal("Failed to load JWT signing keys:", err)
+	}
+
 	// Initialize database tables
 	db, err := database.InitializeDatabase()
 	if db == nil {
@@ -33,6 +47,26 @@ func main() {
 	}
 	defer db.Close()
 
+	// Tag the posts written before tags existed
+	if tagged, err := controllers.BackfillPostTags(db); err != nil {
+		logger.Error("Failed to tag existing posts: %v", err)
+	} else if tagged > 0 {
+		logger.Info("Tagged %d existing posts from their hashtags", tagged)
+	}
+
+	// Pick how outgoing mail is delivered
+	mailer, err := utils.NewMailerFromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure mailer:", err)
+	}
+
+	// Single sign-on is optional
+	var oidcProvider *utils.OIDCProvider
+	if cfg, ok := utils.OIDCConfigFromEnv(); ok {
+		oidcProvider = utils.NewOIDCProvider(cfg)
+		logger.Info("OIDC login enabled for issuer %s", cfg.Issuer)
+	}
+
 	// Initialize handlers
 	// Create a context that cancels on interrupt signals (e.g., Ctrl+C)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -54,6 +88,30 @@ func main() {
 		controllers.CleanupExpiredSessions(ctx, db)
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		utils.ReloadSigningKeys(ctx, keysPath)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		controllers.ProcessMailOutbox(ctx, db, mailer)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		controllers.ProcessDataExports(ctx, db)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		controllers.ProcessScheduledPosts(ctx, db, handlers.AnnounceNewPost(controllers.NewPostController(db)))
+	}()
+
 	// Determine the port to listen on
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -74,12 +132,19 @@ func main() {
 	routes.UserRegAndLogin(db)
 	routes.MainRoute(db)
 	routes.PostRoute(db)
+	routes.SearchRoute(db)
+	routes.ReactionRoutes(db)
 	routes.WebScokcetRoute()
 	routes.SetupFollowersRoutes(db)
 	routes.SetupUserRoutes(db)
 	routes.MessagesRoutes(db)
 	routes.SetupCommentRoutes(db)
 	routes.NotificationRoutes(db)
+	routes.SessionRoutes(db)
+	routes.TwoFactorRoutes(db)
+	routes.OIDCRoutes(db, oidcProvider)
+	routes.APITokenRoutes(db)
+	routes.AdminRoutes(db)
 
 	logger.Info("Starting Application...")
 